@@ -0,0 +1,418 @@
+package cockroach
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/adapter/postgres"
+	"github.com/lib/pq"
+)
+
+const indent = "    "
+
+// CockroachDB speaks the PostgreSQL wire protocol, but its pg_catalog emulation
+// is incomplete. So this relies on information_schema as much as possible.
+type CockroachDatabase struct {
+	config adapter.Config
+	db     *sql.DB
+}
+
+func NewDatabase(config adapter.Config) (adapter.Database, error) {
+	db, err := adapter.OpenDB(config, "postgres", cockroachBuildDSN(config))
+	if err != nil {
+		return nil, err
+	}
+
+	return &CockroachDatabase{
+		db:     db,
+		config: config,
+	}, nil
+}
+
+func (d *CockroachDatabase) TableNames() ([]string, error) {
+	rows, err := d.db.Query(
+		`select table_schema, table_name from information_schema.tables
+		 where table_schema not in ('information_schema', 'pg_catalog', 'crdb_internal', 'pg_extension')
+		 and table_type = 'BASE TABLE';`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []string{}
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, schema+"."+name)
+	}
+	return tables, nil
+}
+
+var (
+	suffixSemicolon = regexp.MustCompile(`;$`)
+	spaces          = regexp.MustCompile(`[ ]+`)
+)
+
+func (d *CockroachDatabase) Views() ([]string, error) {
+	rows, err := d.db.Query(
+		`select table_schema, table_name, view_definition from information_schema.views
+		 where table_schema not in ('information_schema', 'pg_catalog', 'crdb_internal', 'pg_extension');`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ddls []string
+	for rows.Next() {
+		var schema, name, definition string
+		if err := rows.Scan(&schema, &name, &definition); err != nil {
+			return nil, err
+		}
+		definition = strings.TrimSpace(definition)
+		definition = strings.ReplaceAll(definition, "\n", "")
+		definition = suffixSemicolon.ReplaceAllString(definition, "")
+		definition = spaces.ReplaceAllString(definition, " ")
+		ddls = append(
+			ddls, fmt.Sprintf(
+				"CREATE VIEW %s AS %s;", schema+"."+name, definition,
+			),
+		)
+	}
+	return ddls, nil
+}
+
+func (d *CockroachDatabase) Triggers() ([]string, error) {
+	return nil, nil
+}
+
+// Enums in user schemas, which are qualified unless they're in public. Labels are fetched as an array since they may contain spaces.
+func (d *CockroachDatabase) Types() ([]string, error) {
+	rows, err := d.db.Query(
+		`select n.nspname, t.typname, array_agg(e.enumlabel order by e.enumsortorder)
+		 from pg_enum e
+		 join pg_type t on e.enumtypid = t.oid
+		 join pg_namespace n on t.typnamespace = n.oid
+		 where n.nspname not in ('information_schema', 'pg_catalog', 'crdb_internal', 'pg_extension')
+		 group by n.nspname, t.typname
+		 order by n.nspname, t.typname;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ddls []string
+	for rows.Next() {
+		var schema, typeName string
+		var labels []string
+		if err := rows.Scan(&schema, &typeName, pq.Array(&labels)); err != nil {
+			return nil, err
+		}
+		if schema != "public" {
+			typeName = schema + "." + typeName
+		}
+		enumLabels := []string{}
+		for _, label := range labels {
+			enumLabels = append(enumLabels, fmt.Sprintf("'%s'", strings.ReplaceAll(label, "'", "''")))
+		}
+		ddls = append(
+			ddls, fmt.Sprintf(
+				"CREATE TYPE %s AS ENUM (%s);", typeName, strings.Join(enumLabels, ", "),
+			),
+		)
+	}
+	return ddls, nil
+}
+
+func (d *CockroachDatabase) DumpTableDDL(table string) (string, error) {
+	cols, err := d.getColumns(table)
+	if err != nil {
+		return "", err
+	}
+	pkeyName, pkeyCols, err := d.getPrimaryKey(table, cols)
+	if err != nil {
+		return "", err
+	}
+	indexDefs, err := d.getIndexDefs(table, pkeyName)
+	if err != nil {
+		return "", err
+	}
+	foreignDefs, err := d.getForeignDefs(table)
+	if err != nil {
+		return "", err
+	}
+	return buildDumpTableDDL(table, cols, pkeyCols, indexDefs, foreignDefs), nil
+}
+
+func buildDumpTableDDL(table string, columns []column, pkeyCols, indexDefs, foreignDefs []string) string {
+	var queryBuilder strings.Builder
+	fmt.Fprintf(&queryBuilder, "CREATE TABLE %s (", table)
+	for i, col := range columns {
+		if i > 0 {
+			fmt.Fprint(&queryBuilder, ",")
+		}
+		fmt.Fprint(&queryBuilder, "\n"+indent)
+		fmt.Fprintf(&queryBuilder, "\"%s\" %s", col.Name, col.GetDataType())
+		if col.Length > 0 {
+			fmt.Fprintf(&queryBuilder, "(%d)", col.Length)
+		}
+		if !col.Nullable {
+			fmt.Fprint(&queryBuilder, " NOT NULL")
+		}
+		if col.Default != "" && !col.IsAutoIncrement {
+			fmt.Fprintf(&queryBuilder, " DEFAULT %s", col.Default)
+		}
+	}
+	if len(pkeyCols) > 0 {
+		fmt.Fprint(&queryBuilder, ",\n"+indent)
+		fmt.Fprintf(&queryBuilder, "PRIMARY KEY (\"%s\")", strings.Join(pkeyCols, "\", \""))
+	}
+	fmt.Fprintf(&queryBuilder, "\n);\n")
+	for _, v := range indexDefs {
+		fmt.Fprintf(&queryBuilder, "%s;\n", v)
+	}
+	for _, v := range foreignDefs {
+		fmt.Fprintf(&queryBuilder, "%s;\n", v)
+	}
+	return strings.TrimSuffix(queryBuilder.String(), "\n")
+}
+
+type column struct {
+	Name            string
+	dataType        string
+	Length          int
+	Nullable        bool
+	Default         string
+	IsAutoIncrement bool
+	Hidden          bool
+}
+
+func (c *column) GetDataType() string {
+	switch c.dataType {
+	case "bigint":
+		if c.IsAutoIncrement {
+			return "bigserial"
+		}
+		return c.dataType
+	case "timestamp without time zone":
+		return "timestamp"
+	case "time without time zone":
+		return "time"
+	default:
+		return c.dataType
+	}
+}
+
+func (d *CockroachDatabase) getColumns(table string) ([]column, error) {
+	const query = `SELECT column_name, column_default, is_nullable, character_maximum_length, data_type, is_hidden
+	FROM information_schema.columns
+	WHERE table_schema = $1 AND table_name = $2
+	ORDER BY ordinal_position;`
+
+	schema, table := postgres.SplitTableName(table)
+	rows, err := d.db.Query(query, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := make([]column, 0)
+	for rows.Next() {
+		col := column{}
+		var colName, isNullable, dataType, isHidden string
+		var colDefault *string
+		var maxLen *int
+		err = rows.Scan(&colName, &colDefault, &isNullable, &maxLen, &dataType, &isHidden)
+		if err != nil {
+			return nil, err
+		}
+		col.Name = colName
+		if colDefault != nil {
+			col.Default = *colDefault
+			// SERIAL is normalized to `DEFAULT unique_rowid()` by serial_normalization=rowid
+			col.IsAutoIncrement = *colDefault == "unique_rowid()" || strings.HasPrefix(*colDefault, "nextval(")
+		}
+		col.Nullable = isNullable == "YES"
+		col.dataType = dataType
+		if maxLen != nil {
+			col.Length = *maxLen
+		}
+		col.Hidden = isHidden == "YES"
+		cols = append(cols, col)
+	}
+
+	// Hide `rowid` implicitly added to a table without a primary key
+	visibleCols := make([]column, 0)
+	for _, col := range cols {
+		if !col.Hidden {
+			visibleCols = append(visibleCols, col)
+		}
+	}
+	return visibleCols, nil
+}
+
+// Returns an empty name when the primary key is the implicit one on a hidden `rowid`.
+func (d *CockroachDatabase) getPrimaryKey(table string, cols []column) (string, []string, error) {
+	const query = `SELECT tc.constraint_name, kcu.column_name
+FROM
+	information_schema.table_constraints AS tc
+	JOIN information_schema.key_column_usage AS kcu
+		USING (table_schema, table_name, constraint_name)
+WHERE constraint_type = 'PRIMARY KEY' AND tc.table_schema=$1 AND tc.table_name=$2 ORDER BY kcu.ordinal_position`
+	schema, table := postgres.SplitTableName(table)
+	rows, err := d.db.Query(query, schema, table)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	visibleColumns := map[string]bool{}
+	for _, col := range cols {
+		visibleColumns[col.Name] = true
+	}
+
+	var constraintName string
+	columnNames := make([]string, 0)
+	for rows.Next() {
+		var columnName string
+		err = rows.Scan(&constraintName, &columnName)
+		if err != nil {
+			return "", nil, err
+		}
+		if !visibleColumns[columnName] {
+			return constraintName, nil, nil
+		}
+		columnNames = append(columnNames, columnName)
+	}
+	return constraintName, columnNames, nil
+}
+
+// pg_indexes of CockroachDB has a database-qualified name in indexdef. So this builds it from information_schema.statistics.
+func (d *CockroachDatabase) getIndexDefs(table string, pkeyName string) ([]string, error) {
+	const query = `SELECT index_name, non_unique, column_name, direction
+	FROM information_schema.statistics
+	WHERE table_schema = $1 AND table_name = $2 AND index_name != $3
+	AND storing = 'NO' AND implicit = 'NO'
+	ORDER BY index_name, seq_in_index;`
+
+	schema, tableName := postgres.SplitTableName(table)
+	rows, err := d.db.Query(query, schema, tableName, pkeyName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexNames := []string{}
+	indexUnique := map[string]bool{}
+	indexColumns := map[string][]string{}
+	for rows.Next() {
+		var indexName, nonUnique, columnName, direction string
+		err = rows.Scan(&indexName, &nonUnique, &columnName, &direction)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := indexColumns[indexName]; !ok {
+			indexNames = append(indexNames, indexName)
+		}
+		indexUnique[indexName] = nonUnique == "NO"
+		columnDefinition := fmt.Sprintf("\"%s\"", columnName)
+		if direction == "DESC" {
+			columnDefinition += " DESC"
+		}
+		indexColumns[indexName] = append(indexColumns[indexName], columnDefinition)
+	}
+
+	indexes := make([]string, 0)
+	for _, indexName := range indexNames {
+		unique := ""
+		if indexUnique[indexName] {
+			unique = " UNIQUE"
+		}
+		indexes = append(indexes, fmt.Sprintf("CREATE%s INDEX \"%s\" ON %s (%s)", unique, indexName, table, strings.Join(indexColumns[indexName], ", ")))
+	}
+	return indexes, nil
+}
+
+func (d *CockroachDatabase) getForeignDefs(table string) ([]string, error) {
+	const query = `SELECT
+	tc.table_schema, tc.constraint_name, tc.table_name, kcu.column_name,
+	ccu.table_schema AS foreign_table_schema,
+	ccu.table_name AS foreign_table_name,
+	ccu.column_name AS foreign_column_name,
+	rc.update_rule AS foreign_update_rule,
+	rc.delete_rule AS foreign_delete_rule
+FROM
+	information_schema.table_constraints AS tc
+	JOIN information_schema.key_column_usage AS kcu
+		ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+	JOIN information_schema.constraint_column_usage AS ccu
+		ON tc.constraint_name = ccu.constraint_name AND tc.table_schema = ccu.constraint_schema
+	JOIN information_schema.referential_constraints AS rc
+		ON tc.constraint_name = rc.constraint_name AND tc.table_schema = rc.constraint_schema
+WHERE constraint_type = 'FOREIGN KEY' AND tc.table_schema=$1 AND tc.table_name=$2`
+	schema, table := postgres.SplitTableName(table)
+	rows, err := d.db.Query(query, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := make([]string, 0)
+	for rows.Next() {
+		var tableSchema, constraintName, tableName, columnName, foreignTableSchema, foreignTableName, foreignColumnName, foreignUpdateRule, foreignDeleteRule string
+		err = rows.Scan(&tableSchema, &constraintName, &tableName, &columnName, &foreignTableSchema, &foreignTableName, &foreignColumnName, &foreignUpdateRule, &foreignDeleteRule)
+		if err != nil {
+			return nil, err
+		}
+		def := fmt.Sprintf(
+			"ALTER TABLE ONLY %s.%s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s.%s(%s) ON UPDATE %s ON DELETE %s",
+			tableSchema, tableName, constraintName, columnName, foreignTableSchema, foreignTableName, foreignColumnName, foreignUpdateRule, foreignDeleteRule,
+		)
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+func (d *CockroachDatabase) DB() *sql.DB {
+	return d.db
+}
+
+func (d *CockroachDatabase) DumpConcurrency() int {
+	return d.config.DumpConcurrency
+}
+
+func (d *CockroachDatabase) Close() error {
+	return d.db.Close()
+}
+
+func cockroachBuildDSN(config adapter.Config) string {
+	host := ""
+	if config.Socket == "" {
+		host = fmt.Sprintf("%s:%d", config.Host, config.Port)
+	} else {
+		host = config.Socket
+	}
+
+	var options []string
+	if sslmode, ok := os.LookupEnv("PGSSLMODE"); ok {
+		options = append(options, fmt.Sprintf("sslmode=%s", sslmode))
+	}
+	if sslrootcert, ok := os.LookupEnv("PGSSLROOTCERT"); ok {
+		options = append(options, fmt.Sprintf("sslrootcert=%s", sslrootcert))
+	}
+
+	userInfo := url.QueryEscape(config.User)
+	if config.Password != "" {
+		userInfo += ":" + url.QueryEscape(config.Password)
+	}
+	return fmt.Sprintf("postgres://%s@%s/%s?%s", userInfo, host, config.DbName, strings.Join(options, "&"))
+}
@@ -0,0 +1,241 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	createTableRegexp      = regexp.MustCompile(`(?is)^\s*CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(;]+)`)
+	createIndexOnRegexp    = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?\S+\s+ON\s+(?:ONLY\s+)?([^\s(;]+)`)
+	alterTableRegexp       = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(?:ONLY\s+)?(?:IF\s+EXISTS\s+)?([^\s(;]+)`)
+	alterTableUnsafeRegexp = regexp.MustCompile(`(?is)\s(DROP|RENAME)\s`)
+	referencesRegexp       = regexp.MustCompile(`(?is)\sREFERENCES\s+([^\s(;]+)`)
+	derivedTableRegexp     = regexp.MustCompile(`(?is)\s(PARTITION\s+OF|INHERITS|LIKE)\b`) // a table made from another one
+)
+
+// Return tables read or written by a DDL which can be run concurrently with DDLs of other tables,
+// or nil if it needs to be run alone, e.g. DROP, CREATE VIEW, CREATE TYPE, CREATE TABLE ... PARTITION OF, and UPDATE for a backfill.
+func concurrentDDLTables(ddl string) []string {
+	var table string
+	if m := createTableRegexp.FindStringSubmatch(ddl); m != nil && !derivedTableRegexp.MatchString(ddl) {
+		table = m[1]
+	} else if m := createIndexOnRegexp.FindStringSubmatch(ddl); m != nil {
+		table = m[1]
+	} else if m := alterTableRegexp.FindStringSubmatch(ddl); m != nil && !alterTableUnsafeRegexp.MatchString(ddl) {
+		table = m[1]
+	} else {
+		return nil
+	}
+
+	tables := []string{concurrentTableKey(table)}
+	for _, m := range referencesRegexp.FindAllStringSubmatch(ddl, -1) {
+		tables = append(tables, concurrentTableKey(m[1]))
+	}
+	return tables
+}
+
+// Compare tables without a schema, which may conflict more than needed but never less
+func concurrentTableKey(table string) string {
+	table = strings.NewReplacer("`", "", "\"", "", "[", "", "]", "").Replace(table)
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+	return strings.ToLower(table)
+}
+
+// Return indexes of earlier DDLs which each DDL needs to wait for: the ones touching the same tables, and
+// every earlier one for a DDL to be run alone. A DDL to be run alone is waited for by every later one as well.
+func ddlDependencies(ddls []string) [][]int {
+	tables := make([][]string, len(ddls))
+	for i, ddl := range ddls {
+		tables[i] = concurrentDDLTables(ddl)
+	}
+
+	dependencies := make([][]int, len(ddls))
+	lastAlone := -1
+	for i := range ddls {
+		if tables[i] == nil {
+			for j := lastAlone + 1; j < i; j++ {
+				dependencies[i] = append(dependencies[i], j)
+			}
+			if lastAlone >= 0 {
+				dependencies[i] = append(dependencies[i], lastAlone)
+			}
+			lastAlone = i
+			continue
+		}
+		if lastAlone >= 0 {
+			dependencies[i] = append(dependencies[i], lastAlone)
+		}
+		for j := lastAlone + 1; j < i; j++ {
+			if sharesTable(tables[i], tables[j]) {
+				dependencies[i] = append(dependencies[i], j)
+			}
+		}
+	}
+	return dependencies
+}
+
+// Return true if the DDL needs to run after the earlier one, e.g. when both touch the same table, so that they can't be reordered
+func DependsOn(ddl string, earlier string) bool {
+	return len(ddlDependencies([]string{earlier, ddl})[1]) > 0
+}
+
+func sharesTable(tables []string, others []string) bool {
+	for _, table := range tables {
+		for _, other := range others {
+			if table == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Run DDLs on up to the number of connections without a transaction, starting each DDL once the DDLs it depends on
+// are applied. After a failure, no more DDL is started, and the ones already running are waited for.
+// --before-apply is run on each connection, and --after-apply is run after all DDLs.
+func runDDLsConcurrently(ctx context.Context, d Database, ddls []string, skipDrop bool, beforeApply string, afterApply string,
+	concurrency int, retry Retry, out io.Writer, progressOut io.Writer, onApplied func(ddl string, duration time.Duration)) ([]string, error) {
+	if !jsonLog {
+		fmt.Fprintln(out, "-- Apply --")
+	}
+	var targets []string
+	skipped := 0
+	for _, ddl := range ddls {
+		if skipDrop && IsDropDDLIn(ddls, ddl) {
+			if jsonLog {
+				LogEvent("ddl", map[string]interface{}{"statement": ddl, "skipped": true})
+			} else {
+				fmt.Fprintf(out, "-- Skipped: %s;\n", ddl)
+			}
+			skipped++
+			continue
+		}
+		targets = append(targets, ddl)
+	}
+
+	dependencies := ddlDependencies(targets)
+	waiting := make([]int, len(targets)) // the number of DDLs each DDL waits for
+	dependents := make([][]int, len(targets))
+	for i, indexes := range dependencies {
+		waiting[i] = len(indexes)
+		for _, j := range indexes {
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	type result struct {
+		index    int
+		applied  bool // false for a DDL not started after a failure
+		duration time.Duration
+		err      error
+	}
+	jobs := make(chan int, len(targets))
+	results := make(chan result, len(targets))
+	failed := make(chan struct{})
+	var failOnce sync.Once
+	var outMutex sync.Mutex // not to mix lines of DDLs
+	lockedOut := writerFunc(func(p []byte) (int, error) {
+		outMutex.Lock()
+		defer outMutex.Unlock()
+		return out.Write(p)
+	})
+	monitor, _ := d.(StatementMonitor)
+	progress := newProgress(ctx, progressOut, targets, false, monitor)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(targets); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := d.DB().Conn(ctx)
+			if err == nil {
+				defer conn.Close()
+				if len(beforeApply) > 0 {
+					err = execDDL(ctx, session{conn: conn, retry: retry}, beforeApply, beforeApply, lockedOut)
+				}
+			}
+			for i := range jobs {
+				select {
+				case <-failed:
+					results <- result{index: i}
+					continue
+				default:
+				}
+				start := time.Now()
+				if err == nil {
+					err = progress.run(targets[i], func() error {
+						return execDDL(ctx, session{conn: conn, retry: retry}, targets[i], targets[i]+";", lockedOut)
+					})
+				}
+				if err != nil {
+					failOnce.Do(func() { close(failed) })
+				}
+				results <- result{index: i, applied: err == nil, duration: time.Since(start), err: err}
+			}
+		}()
+	}
+
+	queued := 0
+	for i := range targets {
+		if waiting[i] == 0 {
+			jobs <- i
+			queued++
+		}
+	}
+	applied := []string{}
+	var firstErr error
+	for finished := 0; finished < queued; finished++ {
+		r := <-results
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+		if !r.applied {
+			continue
+		}
+		applied = append(applied, targets[r.index])
+		if onApplied != nil {
+			onApplied(targets[r.index], r.duration)
+		}
+		for _, dependent := range dependents[r.index] {
+			if waiting[dependent]--; waiting[dependent] == 0 && firstErr == nil {
+				jobs <- dependent
+				queued++
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return applied, firstErr
+	}
+
+	if len(afterApply) > 0 {
+		conn, err := d.DB().Conn(ctx)
+		if err != nil {
+			return applied, err
+		}
+		defer conn.Close()
+		if err := execDDL(ctx, session{conn: conn, retry: retry}, afterApply, afterApply, out); err != nil {
+			return applied, err
+		}
+	}
+	if !jsonLog {
+		PrintSkippedDrops(out, skipped)
+	}
+	progress.summarize()
+	return applied, nil
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -2,9 +2,17 @@
 package adapter
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/k0kubun/sqldef/schema/batch"
 )
 
 type Config struct {
@@ -15,9 +23,62 @@ type Config struct {
 	Port     int
 	Socket   string
 
+	// Session variables to fail fast instead of blocking others, e.g. `5s` for PostgreSQL and `5` for MySQL
+	LockTimeout      string
+	StatementTimeout string // Only PostgreSQL
+
+	// Only PostgreSQL: interval of TCP keepalive probes, so that long DDLs are not cut as idle by load balancers. 0 is Go's default of 15s, and negative disables them.
+	Keepalive time.Duration
+
+	// Only PostgreSQL: connect through PgBouncer's transaction pooling, which doesn't keep sessions or prepared statements
+	PgBouncer bool
+
+	// Only PostgreSQL: dump only the schema, and set it first in search_path so that unqualified names in DDLs refer to it
+	Schema string
+
+	// "debug" to log every query and its duration
+	LogLevel string
+
+	// Tables dumped at once by DumpDDLs, each using a connection. 0 or 1 dumps them one by one.
+	DumpConcurrency int
+
+	// Skip parts of the schema which the user can't read, e.g. policies of others' tables, instead of failing. Only PostgreSQL and MySQL.
+	LimitedPrivileges bool
+
 	// Only MySQL
 	MySQLEnableCleartextPlugin bool
 	SkipView                   bool
+
+	// Only MSSQL
+	MssqlAuth string
+}
+
+// Return the database to connect without the password, e.g. user@host:port/dbname, or the file name of SQLite3
+func (c Config) Target() string {
+	target := c.DbName
+	if len(c.Socket) > 0 {
+		target = fmt.Sprintf("unix(%s)/%s", c.Socket, c.DbName)
+	} else if len(c.Host) > 0 && c.Port > 0 {
+		target = fmt.Sprintf("%s:%d/%s", c.Host, c.Port, c.DbName)
+	} else if len(c.Host) > 0 {
+		target = fmt.Sprintf("%s/%s", c.Host, c.DbName)
+	}
+	if len(c.User) > 0 {
+		target = c.User + "@" + target
+	}
+	if len(c.Schema) > 0 {
+		target += "#" + c.Schema
+	}
+	return target
+}
+
+// Report a part of the schema skipped by LimitedPrivileges to stderr, or return the error without it
+func (c Config) SkipUnreadable(what string, err error) error {
+	if err == nil || !c.LimitedPrivileges {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "-- Skipped %s, which can't be read: %s --\n", what, err)
+	return nil
 }
 
 // Abstraction layer for multiple kinds of databases
@@ -31,8 +92,40 @@ type Database interface {
 	Close() error
 }
 
+// Implemented by PostgreSQL to run each DDL in its own transaction for --pgbouncer, since each transaction may use another server session
+type TransactionPooler interface {
+	// Return whether sessions are pooled by transactions, and statements to set session variables in each transaction, e.g. SET LOCAL
+	TransactionPooling() (bool, []string)
+}
+
+// Implemented by PostgreSQL to report what a long-running DDL waits for with --progress
+type StatementMonitor interface {
+	// Describe what the running statement waits for, e.g. "waiting for Lock: relation, blocked by PID 42", or "" if nothing
+	StatementActivity(ctx context.Context, statement string) (string, error)
+}
+
+// Implemented by PostgreSQL and MySQL to estimate the impact of DDLs for --impact
+type ImpactEstimator interface {
+	ServerVersion() (string, error)
+	EstimatedRows(table string) (int64, error) // -1 if unknown
+	TableSize(table string) (int64, error)     // bytes of the table and its indexes, -1 if unknown
+}
+
+// Implemented by PostgreSQL and MySQL to prevent concurrent applies to the same database by an advisory lock
+type ApplyLocker interface {
+	// Take the lock held until release is called or the process exits, calling waiting once if another process holds it.
+	// Waiting for it gives up by --lock-timeout.
+	LockApply(ctx context.Context, waiting func()) (release func() error, err error)
+}
+
+// Implemented by MySQL to compare table names as the server does for --fold-identifiers
+type TableNameFolder interface {
+	LowerCaseTableNames() (int, error)
+}
+
 // TODO: This should probably be part of the Database interface
-func DumpDDLs(d Database) (string, error) {
+// Tables are dumped unless skipTable returns true, and skipTable may be nil.
+func DumpDDLs(d Database, skipTable func(table string) bool) (string, error) {
 	ddls := []string{}
 
 	typeDDLs, err := d.Types()
@@ -45,14 +138,17 @@ func DumpDDLs(d Database) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	var tables []string
 	for _, tableName := range tableNames {
-		ddl, err := d.DumpTableDDL(tableName)
-		if err != nil {
-			return "", err
+		if skipTable == nil || !skipTable(tableName) {
+			tables = append(tables, tableName)
 		}
-
-		ddls = append(ddls, ddl)
 	}
+	tableDDLs, err := dumpTableDDLs(d, tables)
+	if err != nil {
+		return "", err
+	}
+	ddls = append(ddls, tableDDLs...)
 
 	viewDDLs, err := d.Views()
 	if err != nil {
@@ -69,30 +165,388 @@ func DumpDDLs(d Database) (string, error) {
 	return strings.Join(ddls, "\n\n"), nil
 }
 
-func RunDDLs(d Database, ddls []string, skipDrop bool, beforeApply string) error {
-	transaction, err := d.DB().Begin()
+// Implemented by databases whose DumpTableDDL can be called concurrently
+type ConcurrentDumper interface {
+	DumpConcurrency() int // Config.DumpConcurrency
+}
+
+// Implemented by databases which can dump multiple tables by a few catalog queries, instead of queries per table
+type BatchDumper interface {
+	DumpTableDDLs(tables []string) ([]string, error)
+}
+
+// Dump tables by a bounded number of workers, keeping the order of tables. A BatchDumper dumps a batch of tables
+// per worker. The first error in the order is returned, and workers stop taking tables after an error.
+func dumpTableDDLs(d Database, tables []string) ([]string, error) {
+	concurrency := 1
+	if dumper, ok := d.(ConcurrentDumper); ok && dumper.DumpConcurrency() > 1 {
+		concurrency = dumper.DumpConcurrency()
+	}
+
+	batchSize := 1
+	batchDumper, batch := d.(BatchDumper)
+	if batch && len(tables) > 0 {
+		batchSize = (len(tables) + concurrency - 1) / concurrency
+	}
+	var batches [][2]int // ranges of tables
+	for start := 0; start < len(tables); start += batchSize {
+		end := start + batchSize
+		if end > len(tables) {
+			end = len(tables)
+		}
+		batches = append(batches, [2]int{start, end})
+	}
+
+	ddls := make([]string, len(tables))
+	errs := make([]error, len(batches))
+	indexes := make(chan int)
+	failed := make(chan struct{})
+	var failOnce sync.Once
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(batches); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				start, end := batches[i][0], batches[i][1]
+				if batch {
+					var batchDDLs []string
+					if batchDDLs, errs[i] = batchDumper.DumpTableDDLs(tables[start:end]); errs[i] == nil {
+						copy(ddls[start:end], batchDDLs)
+					}
+				} else {
+					ddls[start], errs[i] = d.DumpTableDDL(tables[start])
+				}
+				if errs[i] != nil {
+					failOnce.Do(func() { close(failed) })
+				}
+			}
+		}()
+	}
+feed:
+	for i := range batches {
+		select {
+		case indexes <- i:
+		case <-failed:
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ddls, nil
+}
+
+// DROP TABLE, DROP COLUMN, DROP CONSTRAINT, and so on. Not `ALTER COLUMN ... DROP DEFAULT` or `DROP NOT NULL`.
+// DELETE of rows not in `-- @seed` is destructive as well.
+var dropDDLRegexp = regexp.MustCompile(`(?i)^\s*(DROP|DELETE\s+FROM)\s|\sDROP\s+(COLUMN|CONSTRAINT|PRIMARY\s+KEY|FOREIGN\s+KEY|INDEX|KEY|CHECK)\b`)
+
+// Return true if the DDL is destructive, which is skipped unless --enable-drop is given
+func IsDropDDL(ddl string) bool {
+	return dropDDLRegexp.MatchString(ddl)
+}
+
+var (
+	dropViewRegexp   = regexp.MustCompile(`(?i)^\s*DROP\s+VIEW\s+([^\s;]+)`)
+	createViewRegexp = regexp.MustCompile(`(?i)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?VIEW\s+([^\s(;]+)`)
+)
+
+// Return true if the DDL in ddls is destructive. DROP VIEW is not when a later DDL creates the view again,
+// e.g. to change the type of a column the view selects from.
+func IsDropDDLIn(ddls []string, ddl string) bool {
+	if !IsDropDDL(ddl) {
+		return false
+	}
+	m := dropViewRegexp.FindStringSubmatch(ddl)
+	if m == nil {
+		return true
+	}
+	found := false
+	for _, later := range ddls {
+		if later == ddl {
+			found = true
+		} else if c := createViewRegexp.FindStringSubmatch(later); found && c != nil && unqualifiedViewName(c[1]) == unqualifiedViewName(m[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func unqualifiedViewName(name string) string {
+	name = strings.NewReplacer("`", "", "\"", "", "[", "", "]", "").Replace(name)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(name)
+}
+
+// Run DDLs in a single transaction, so that a failure doesn't leave the schema half-migrated.
+// With noTransaction, they're run in a single session instead, e.g. for CREATE INDEX CONCURRENTLY.
+// With concurrency more than 1, DDLs of independent tables are run on up to the number of sessions without a transaction.
+// With transaction pooling of TransactionPooler, each DDL is run in its own transaction instead of them all.
+// When ctx is cancelled, the running DDL is cancelled, the transaction is rolled back, and *InterruptedError is returned.
+// Applied DDLs are printed to out, and their progress is printed to progressOut unless it's nil.
+// onApplied, unless it's nil, is called with each DDL and its duration after it succeeds, or after the commit in a transaction,
+// so that DDLs of a transaction rolled back and retried are not reported twice.
+func RunDDLs(ctx context.Context, d Database, ddls []string, skipDrop bool, beforeApply string, afterApply string, noTransaction bool, concurrency int, retry Retry, out io.Writer, progressOut io.Writer, onApplied func(ddl string, duration time.Duration)) error {
+	monitor, _ := d.(StatementMonitor)
+	if pooler, ok := d.(TransactionPooler); ok {
+		if pooling, settings := pooler.TransactionPooling(); pooling {
+			// Applied DDLs are committed one by one, so only a failed DDL is retried
+			applied, err := runDDLs(ctx, transactionPerStatement{db: d.DB(), settings: settings, noTransaction: noTransaction, retry: retry}, ddls, skipDrop, beforeApply, afterApply, out, progressOut, monitor, onApplied)
+			return interrupted(ctx, err, applied, false)
+		}
+	}
+	if concurrency > 1 {
+		applied, err := runDDLsConcurrently(ctx, d, ddls, skipDrop, beforeApply, afterApply, concurrency, retry, out, progressOut, onApplied)
+		return interrupted(ctx, err, applied, false)
+	}
+	if noTransaction {
+		conn, err := d.DB().Conn(ctx)
+		if err != nil {
+			return interrupted(ctx, err, nil, false)
+		}
+		defer conn.Close()
+		// Applied DDLs are not rolled back, so only a failed DDL is retried
+		applied, err := runDDLs(ctx, session{conn: conn, retry: retry}, ddls, skipDrop, beforeApply, afterApply, out, progressOut, monitor, onApplied)
+		return interrupted(ctx, err, applied, false)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := runDDLsInTransaction(ctx, d, ddls, skipDrop, beforeApply, afterApply, out, progressOut, monitor, onApplied)
+		if err == nil || !retry.wait(ctx, err, attempt) {
+			return interrupted(ctx, err, nil, true)
+		}
+	}
+}
+
+func runDDLsInTransaction(ctx context.Context, d Database, ddls []string, skipDrop bool, beforeApply string, afterApply string, out io.Writer, progressOut io.Writer, monitor StatementMonitor, onApplied func(ddl string, duration time.Duration)) error {
+	transaction, err := d.DB().BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	fmt.Println("-- Apply --")
-	if len(beforeApply) > 0 {
-		fmt.Println(beforeApply)
-		if _, err := transaction.Exec(beforeApply); err != nil {
+	type appliedDDL struct {
+		ddl      string
+		duration time.Duration
+	}
+	var applied []appliedDDL
+	record := func(ddl string, duration time.Duration) {
+		applied = append(applied, appliedDDL{ddl: ddl, duration: duration})
+	}
+	if _, err := runDDLs(ctx, transaction, ddls, skipDrop, beforeApply, afterApply, out, progressOut, monitor, record); err != nil {
+		transaction.Rollback()
+		return err
+	}
+	if err := transaction.Commit(); err != nil {
+		return err
+	}
+	if onApplied != nil {
+		for _, a := range applied {
+			onApplied(a.ddl, a.duration)
+		}
+	}
+	return nil
+}
+
+// Returned by RunDDLs when it's interrupted by --timeout or a signal
+type InterruptedError struct {
+	Cause      error    // context.DeadlineExceeded or context.Canceled
+	Applied    []string // DDLs applied without a transaction
+	RolledBack bool
+}
+
+func (e *InterruptedError) Error() string {
+	message := "Interrupted by a signal"
+	if e.Cause == context.DeadlineExceeded {
+		message = "Timed out by --timeout"
+	}
+	if e.RolledBack {
+		return message + ". The transaction was rolled back, and no DDL was applied."
+	}
+	if len(e.Applied) == 0 {
+		return message + ". No DDL was applied."
+	}
+	return fmt.Sprintf("%s. Only %d DDLs were applied:\n%s;", message, len(e.Applied), strings.Join(e.Applied, ";\n"))
+}
+
+func interrupted(ctx context.Context, err error, applied []string, rolledBack bool) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return &InterruptedError{Cause: ctx.Err(), Applied: applied, RolledBack: rolledBack}
+}
+
+// Retries of DDLs failing with transient errors, given by --retry and --retry-wait
+type Retry struct {
+	Count int
+	Wait  time.Duration
+}
+
+// Lock timeouts, deadlocks, and serialization failures of PostgreSQL, MySQL, SQL Server, SQLite3, and CockroachDB
+var retryableErrorRegexp = regexp.MustCompile(`(?i)lock timeout|lock wait timeout|lock request time out|could not obtain lock|deadlock|could not serialize access|restart transaction|database is locked`)
+
+// Wait for the next attempt and return true, if the error is transient and retries are left
+func (r Retry) wait(ctx context.Context, err error, attempt int) bool {
+	if attempt > r.Count || ctx.Err() != nil || !retryableErrorRegexp.MatchString(err.Error()) {
+		return false
+	}
+	if jsonLog {
+		LogEvent("retry", map[string]interface{}{"attempt": attempt, "error": err.Error(), "wait_ms": DurationMs(r.Wait)})
+	} else {
+		fmt.Fprintf(os.Stderr, "-- Retrying in %s (%d/%d): %s --\n", r.Wait, attempt, r.Count, err)
+	}
+	select {
+	case <-time.After(r.Wait):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// executor for a connection, to keep session variables set by --before-apply
+type session struct {
+	conn  *sql.Conn
+	retry Retry
+}
+
+func (s session) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := s.conn.ExecContext(ctx, query, args...)
+		if err == nil || !s.retry.wait(ctx, err, attempt) {
+			return result, err
+		}
+	}
+}
+
+// executor running each statement in its own transaction after settings, for transaction pooling which doesn't keep sessions.
+// With noTransaction, statements are run alone without settings instead, e.g. CREATE INDEX CONCURRENTLY.
+type transactionPerStatement struct {
+	db            *sql.DB
+	settings      []string
+	noTransaction bool
+	retry         Retry
+}
+
+func (t transactionPerStatement) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := t.exec(ctx, query, args...)
+		if err == nil || !t.retry.wait(ctx, err, attempt) {
+			return result, err
+		}
+	}
+}
+
+func (t transactionPerStatement) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if t.noTransaction {
+		return t.db.ExecContext(ctx, query, args...)
+	}
+	transaction, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, setting := range t.settings {
+		if _, err := transaction.ExecContext(ctx, setting); err != nil {
 			transaction.Rollback()
-			return err
+			return nil, err
+		}
+	}
+	result, err := transaction.ExecContext(ctx, query, args...)
+	if err != nil {
+		transaction.Rollback()
+		return nil, err
+	}
+	return result, transaction.Commit()
+}
+
+// Return applied DDLs as well, which are not rolled back without a transaction.
+// onApplied is called after each DDL succeeds, which is not committed yet in a transaction.
+func runDDLs(ctx context.Context, e executor, ddls []string, skipDrop bool, beforeApply string, afterApply string, out io.Writer, progressOut io.Writer, monitor StatementMonitor, onApplied func(ddl string, duration time.Duration)) ([]string, error) {
+	if !jsonLog {
+		fmt.Fprintln(out, "-- Apply --")
+	}
+	if len(beforeApply) > 0 {
+		if err := execDDL(ctx, e, beforeApply, beforeApply, out); err != nil {
+			return nil, err
 		}
 	}
+	applied := []string{}
+	skipped := 0
+	progress := newProgress(ctx, progressOut, ddls, skipDrop, monitor)
 	for _, ddl := range ddls {
-		if skipDrop && strings.Contains(ddl, "DROP") {
-			fmt.Printf("-- Skipped: %s;\n", ddl)
+		if skipDrop && IsDropDDLIn(ddls, ddl) {
+			if jsonLog {
+				LogEvent("ddl", map[string]interface{}{"statement": ddl, "skipped": true})
+			} else {
+				fmt.Fprintf(out, "-- Skipped: %s;\n", ddl)
+			}
+			skipped++
 			continue
 		}
-		fmt.Printf("%s;\n", ddl)
-		if _, err := transaction.Exec(ddl); err != nil {
-			transaction.Rollback()
+		start := time.Now()
+		err := progress.run(ddl, func() error {
+			return execDDL(ctx, e, ddl, ddl+";", out)
+		})
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, ddl)
+		if onApplied != nil {
+			onApplied(ddl, time.Since(start))
+		}
+	}
+	if len(afterApply) > 0 {
+		if err := execDDL(ctx, e, afterApply, afterApply, out); err != nil {
+			return applied, err
+		}
+	}
+	if !jsonLog {
+		PrintSkippedDrops(out, skipped)
+	}
+	progress.summarize()
+	return applied, nil
+}
+
+func execStatement(ctx context.Context, e executor, ddl string) error {
+	for {
+		result, err := e.ExecContext(ctx, ddl)
+		if err != nil || !strings.HasSuffix(ddl, batch.Suffix) {
+			return err
+		}
+		if rows, err := result.RowsAffected(); err != nil || rows == 0 {
 			return err
 		}
 	}
-	transaction.Commit()
-	return nil
+}
+
+// Print a line of the DDL and execute it. With --log-format=json, a "ddl" event is printed after execution instead.
+func execDDL(ctx context.Context, e executor, ddl string, line string, out io.Writer) error {
+	if !jsonLog {
+		fmt.Fprintln(out, line)
+		return execStatement(ctx, e, ddl)
+	}
+
+	start := time.Now()
+	err := execStatement(ctx, e, ddl)
+	fields := map[string]interface{}{"statement": ddl, "duration_ms": DurationMs(time.Since(start))}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	LogEvent("ddl", fields)
+	return err
+}
+
+// Summarize destructive DDLs skipped by --skip-drop or the lack of --enable-drop
+func PrintSkippedDrops(out io.Writer, skipped int) {
+	if skipped > 0 {
+		fmt.Fprintf(out, "-- Skipped destructive DDLs: %d --\n", skipped)
+	}
 }
@@ -0,0 +1,174 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// A database dumping "DDL of <table>", which records the number of tables dumped at once
+type fakeDumper struct {
+	concurrency int
+	failed      string // a table failing to be dumped
+
+	mutex   sync.Mutex
+	running int
+	maxRun  int
+}
+
+func (d *fakeDumper) DumpTableDDL(table string) (string, error) {
+	d.mutex.Lock()
+	d.running++
+	if d.running > d.maxRun {
+		d.maxRun = d.running
+	}
+	d.mutex.Unlock()
+
+	time.Sleep(time.Millisecond) // let other workers start
+	d.mutex.Lock()
+	d.running--
+	d.mutex.Unlock()
+	if table == d.failed {
+		return "", errors.New("failed to dump " + table)
+	}
+	return "DDL of " + table, nil
+}
+
+func (d *fakeDumper) DumpConcurrency() int          { return d.concurrency }
+func (d *fakeDumper) TableNames() ([]string, error) { return nil, nil }
+func (d *fakeDumper) Views() ([]string, error)      { return nil, nil }
+func (d *fakeDumper) Triggers() ([]string, error)   { return nil, nil }
+func (d *fakeDumper) Types() ([]string, error)      { return nil, nil }
+func (d *fakeDumper) DB() *sql.DB                   { return nil }
+func (d *fakeDumper) Close() error                  { return nil }
+
+// A fakeDumper which dumps a batch of tables by DumpTableDDLs
+type fakeBatchDumper struct {
+	fakeDumper
+	batches [][]string
+}
+
+func (d *fakeBatchDumper) DumpTableDDLs(tables []string) ([]string, error) {
+	d.mutex.Lock()
+	d.batches = append(d.batches, tables)
+	d.mutex.Unlock()
+	ddls := []string{}
+	for _, table := range tables {
+		ddl, err := d.DumpTableDDL(table)
+		if err != nil {
+			return nil, err
+		}
+		ddls = append(ddls, ddl)
+	}
+	return ddls, nil
+}
+
+func TestDumpTableDDLs(t *testing.T) {
+	var tables, expected []string
+	for i := 1; i <= 10; i++ {
+		tables = append(tables, fmt.Sprintf("t%02d", i))
+		expected = append(expected, fmt.Sprintf("DDL of t%02d", i))
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		maxRun      int
+	}{
+		{name: "one by one by default", concurrency: 0, maxRun: 1},
+		{name: "one by one", concurrency: 1, maxRun: 1},
+		{name: "concurrently", concurrency: 3, maxRun: 3},
+		{name: "more workers than tables", concurrency: 16, maxRun: 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := &fakeDumper{concurrency: test.concurrency}
+			ddls, err := dumpTableDDLs(d, tables)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ddls, expected) {
+				t.Errorf("expected %v but got %v", expected, ddls)
+			}
+			if d.maxRun > test.maxRun {
+				t.Errorf("expected up to %d tables dumped at once, but got %d", test.maxRun, d.maxRun)
+			}
+		})
+	}
+
+	t.Run("batches", func(t *testing.T) {
+		d := &fakeBatchDumper{fakeDumper: fakeDumper{concurrency: 3}}
+		ddls, err := dumpTableDDLs(d, tables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ddls, expected) {
+			t.Errorf("expected %v but got %v", expected, ddls)
+		}
+		if len(d.batches) != 3 {
+			t.Errorf("expected 3 batches but got %v", d.batches)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		d := &fakeDumper{concurrency: 3, failed: "t04"}
+		if _, err := dumpTableDDLs(d, tables); err == nil || err.Error() != "failed to dump t04" {
+			t.Errorf("expected the error of t04 but got %v", err)
+		}
+	})
+}
+
+// A fakeDumper of an in-memory SQLite3 database
+type fakeDatabase struct {
+	fakeDumper
+	db *sql.DB
+}
+
+func (d *fakeDatabase) DB() *sql.DB { return d.db }
+
+func TestRunDDLsRetry(t *testing.T) {
+	// fail_once() fails as a transient error only at the first call
+	failed := false
+	sql.Register("sqlite3_fail_once", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("fail_once", func() (int, error) {
+				if !failed {
+					failed = true
+					return 0, errors.New("database is locked")
+				}
+				return 1, nil
+			}, false)
+		},
+	})
+	db, err := sql.Open("sqlite3_fail_once", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // each connection opens another in-memory database
+	defer db.Close()
+
+	// DDLs of the rolled back attempt are not reported
+	ddls := []string{"CREATE TABLE users (id integer)", "SELECT fail_once()"}
+	var applied []string
+	onApplied := func(ddl string, duration time.Duration) {
+		applied = append(applied, ddl)
+	}
+	err = RunDDLs(context.Background(), &fakeDatabase{db: db}, ddls, false, "", "", false, 1, Retry{Count: 1}, io.Discard, nil, onApplied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !failed {
+		t.Error("expected the first attempt to fail")
+	}
+	if !reflect.DeepEqual(applied, ddls) {
+		t.Errorf("expected %v but got %v", ddls, applied)
+	}
+}
@@ -0,0 +1,216 @@
+// Adapter for out-of-tree databases. It talks to an adapter command with line-delimited JSON over stdin/stdout.
+//
+// Each request is a JSON object like {"method": "dump_table_ddl", "table": "users"}, and the adapter command
+// must reply one line of {"result": ..., "error": "..."} for it. Methods:
+//
+//	dialect        -> "mysql", "postgres", "sqlite3", "mssql", "cockroach", or "redshift"
+//	table_names    -> ["table", ...]
+//	dump_table_ddl -> "CREATE TABLE ...", with "table"
+//	views          -> ["CREATE VIEW ...", ...]
+//	triggers       -> ["CREATE TRIGGER ...", ...]
+//	types          -> ["CREATE TYPE ...", ...]
+//	begin, commit, rollback
+//	exec           -> null, with "query"
+package external
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/k0kubun/sqldef/adapter"
+)
+
+type request struct {
+	Method string `json:"method"`
+	Table  string `json:"table,omitempty"`
+	Query  string `json:"query,omitempty"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+type ExternalDatabase struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	mutex  sync.Mutex
+	db     *sql.DB
+}
+
+func NewDatabase(command []string) (*ExternalDatabase, error) {
+	if len(command) == 0 {
+		return nil, errors.New("adapter command is empty")
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	d := &ExternalDatabase{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}
+	d.db = sql.OpenDB(connector{database: d})
+	return d, nil
+}
+
+// Return the dialect of the adapter command, which is used to choose a parser.
+func (d *ExternalDatabase) Dialect() (string, error) {
+	var dialect string
+	err := d.call(request{Method: "dialect"}, &dialect)
+	return dialect, err
+}
+
+func (d *ExternalDatabase) TableNames() ([]string, error) {
+	var tables []string
+	err := d.call(request{Method: "table_names"}, &tables)
+	return tables, err
+}
+
+func (d *ExternalDatabase) DumpTableDDL(table string) (string, error) {
+	var ddl string
+	err := d.call(request{Method: "dump_table_ddl", Table: table}, &ddl)
+	return ddl, err
+}
+
+func (d *ExternalDatabase) Views() ([]string, error) {
+	var ddls []string
+	err := d.call(request{Method: "views"}, &ddls)
+	return ddls, err
+}
+
+func (d *ExternalDatabase) Triggers() ([]string, error) {
+	var ddls []string
+	err := d.call(request{Method: "triggers"}, &ddls)
+	return ddls, err
+}
+
+func (d *ExternalDatabase) Types() ([]string, error) {
+	var ddls []string
+	err := d.call(request{Method: "types"}, &ddls)
+	return ddls, err
+}
+
+func (d *ExternalDatabase) DB() *sql.DB {
+	return d.db
+}
+
+func (d *ExternalDatabase) Close() error {
+	d.db.Close()
+	d.stdin.Close()
+	return d.cmd.Wait()
+}
+
+// Send a request and decode its result to `result`. A null result leaves `result` unchanged.
+func (d *ExternalDatabase) call(req request, result interface{}) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if _, err := d.stdin.Write(append(payload, '\n')); err != nil {
+		return fmt.Errorf("failed to send '%s' to the adapter command: %s", req.Method, err)
+	}
+
+	line, err := d.stdout.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("failed to receive a response of '%s' from the adapter command: %s", req.Method, err)
+	}
+	var res response
+	if err := json.Unmarshal(line, &res); err != nil {
+		return fmt.Errorf("invalid response of '%s' from the adapter command: %s", req.Method, err)
+	}
+	if res.Error != "" {
+		return errors.New(res.Error)
+	}
+	if result == nil || len(res.Result) == 0 || string(res.Result) == "null" {
+		return nil
+	}
+	return json.Unmarshal(res.Result, result)
+}
+
+// database/sql driver proxying transactions to the adapter command, so that adapter.RunDDLs can use it.
+type connector struct {
+	database *ExternalDatabase
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return conn{database: c.database}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return externalDriver{}
+}
+
+type externalDriver struct{}
+
+func (externalDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("the external adapter can't be opened by a DSN")
+}
+
+type conn struct {
+	database *ExternalDatabase
+}
+
+func (c conn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported by the external adapter")
+}
+
+func (c conn) Close() error {
+	return nil
+}
+
+func (c conn) Begin() (driver.Tx, error) {
+	if err := c.database.call(request{Method: "begin"}, nil); err != nil {
+		return nil, err
+	}
+	return tx{database: c.database}, nil
+}
+
+func (c conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, errors.New("query arguments are not supported by the external adapter")
+	}
+	if err := c.database.call(request{Method: "exec", Query: query}, nil); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+type tx struct {
+	database *ExternalDatabase
+}
+
+func (t tx) Commit() error {
+	return t.database.call(request{Method: "commit"}, nil)
+}
+
+func (t tx) Rollback() error {
+	return t.database.call(request{Method: "rollback"}, nil)
+}
+
+var _ adapter.Database = (*ExternalDatabase)(nil)
@@ -2,7 +2,9 @@ package file
 
 import (
 	"database/sql"
+
 	"github.com/k0kubun/sqldef"
+	"github.com/k0kubun/sqldef/schema"
 )
 
 // Pseudo adapter for comparison between files
@@ -43,3 +45,20 @@ func (f FileDatabase) DB() *sql.DB {
 func (f FileDatabase) Close() error {
 	return nil
 }
+
+// Pseudo adapter for the current schema saved by --snapshot
+type SnapshotDatabase struct {
+	FileDatabase
+	mode schema.GeneratorMode
+}
+
+func NewSnapshotDatabase(file string, mode schema.GeneratorMode) SnapshotDatabase {
+	return SnapshotDatabase{
+		FileDatabase: NewDatabase(file),
+		mode:         mode,
+	}
+}
+
+func (s SnapshotDatabase) DumpTableDDL(file string) (string, error) {
+	return sqldef.ReadSnapshot(file, s.mode)
+}
@@ -0,0 +1,341 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Open a database like sql.Open. With --log-level=debug, every query is logged to stderr with its duration.
+func OpenDB(config Config, driverName string, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if config.LogLevel != "debug" {
+		return keepDumpConnections(config, db), nil
+	}
+
+	var connector driver.Connector = dsnConnector{dsn: dsn, driver: db.Driver()}
+	if driverContext, ok := db.Driver().(driver.DriverContext); ok {
+		if connector, err = driverContext.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	db.Close()
+	return OpenConnector(config, connector), nil
+}
+
+// Open a database like sql.OpenDB, with logging for --log-level=debug
+func OpenConnector(config Config, connector driver.Connector) *sql.DB {
+	if config.LogLevel == "debug" {
+		connector = loggingConnector{connector: connector}
+	}
+	return keepDumpConnections(config, sql.OpenDB(connector))
+}
+
+// Keep connections of concurrent DumpTableDDL calls open, which are closed after each query beyond the default of 2 idle ones
+func keepDumpConnections(config Config, db *sql.DB) *sql.DB {
+	if config.DumpConcurrency > 2 {
+		db.SetMaxIdleConns(config.DumpConcurrency)
+	}
+	return db
+}
+
+var jsonLog bool
+
+// With --log-format=json, print one JSON object per event to stdout instead of the text output.
+// Errors given to log.Fatal are printed as "error" events as well.
+func SetLogFormat(format string) {
+	jsonLog = format == "json"
+	if jsonLog {
+		log.SetFlags(0)
+		log.SetOutput(errorEventWriter{})
+	}
+}
+
+func JSONLog() bool {
+	return jsonLog
+}
+
+// Print an event like {"event":"ddl","statement":"...","duration_ms":1.5,"time":"..."}.
+// An event is one of "query", "ddl", "retry", "error", and "summary".
+func LogEvent(event string, fields map[string]interface{}) {
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	fields["event"] = event
+	fields["time"] = time.Now().Format(time.RFC3339Nano)
+	out, err := json.Marshal(fields)
+	if err != nil {
+		panic(err) // fields are strings and numbers
+	}
+	fmt.Println(string(out))
+}
+
+func DurationMs(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+type errorEventWriter struct{}
+
+func (errorEventWriter) Write(p []byte) (int, error) {
+	LogEvent("error", map[string]interface{}{"message": strings.TrimSuffix(string(p), "\n")})
+	return len(p), nil
+}
+
+func logQuery(query string, args interface{}, start time.Time, err error) {
+	query = strings.TrimSpace(query)
+	var params []string
+	if values, ok := args.([]driver.NamedValue); ok {
+		for _, value := range values {
+			params = append(params, fmt.Sprintf("%v", value.Value))
+		}
+	}
+	if err == driver.ErrSkip {
+		err = nil
+	}
+
+	if jsonLog {
+		fields := map[string]interface{}{"statement": query, "duration_ms": DurationMs(time.Since(start))}
+		if len(params) > 0 {
+			fields["args"] = params
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		LogEvent("query", fields)
+		return
+	}
+
+	if len(params) > 0 {
+		query += fmt.Sprintf(" -- [%s]", strings.Join(params, ", "))
+	}
+	result := time.Since(start).String()
+	if err != nil {
+		result += ", error: " + err.Error()
+	}
+	fmt.Fprintf(os.Stderr, "-- debug (%s): %s\n", result, query)
+}
+
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type loggingConnector struct {
+	connector driver.Connector
+}
+
+func (c loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return loggingConn{conn: conn}, nil
+}
+
+func (c loggingConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// Pass everything through to the driver's connection, logging queries on the way
+type loggingConn struct {
+	conn driver.Conn
+}
+
+func (c loggingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return loggingStmt{stmt: stmt, query: query}, nil
+}
+
+func (c loggingConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c loggingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var tx driver.Tx
+	var err error
+	if beginner, ok := c.conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.conn.Begin()
+	}
+	logQuery("BEGIN", nil, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return loggingTx{tx: tx}, nil
+}
+
+func (c loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip // fall back to Prepare
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		logQuery(query, args, start, err)
+	}
+	return result, err
+}
+
+func (c loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip // fall back to Prepare
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		logQuery(query, args, start, err)
+	}
+	return rows, err
+}
+
+func (c loggingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c loggingConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c loggingConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type loggingTx struct {
+	tx driver.Tx
+}
+
+func (t loggingTx) Commit() error {
+	start := time.Now()
+	err := t.tx.Commit()
+	logQuery("COMMIT", nil, start, err)
+	return err
+}
+
+func (t loggingTx) Rollback() error {
+	start := time.Now()
+	err := t.tx.Rollback()
+	logQuery("ROLLBACK", nil, start, err)
+	return err
+}
+
+type loggingStmt struct {
+	stmt  driver.Stmt
+	query string
+}
+
+func (s loggingStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s loggingStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s loggingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.stmt.Exec(args)
+	logQuery(s.query, nil, start, err)
+	return result, err
+}
+
+func (s loggingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.stmt.Query(args)
+	logQuery(s.query, nil, start, err)
+	return rows, err
+}
+
+func (s loggingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.stmt.(driver.StmtExecContext)
+	if !ok {
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Exec(values)
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, args)
+	logQuery(s.query, args, start, err)
+	return result, err
+}
+
+func (s loggingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.stmt.(driver.StmtQueryContext)
+	if !ok {
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Query(values)
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, args)
+	logQuery(s.query, args, start, err)
+	return rows, err
+}
+
+func (s loggingStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if len(arg.Name) > 0 {
+			return nil, fmt.Errorf("named parameters are not supported by the driver: %s", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
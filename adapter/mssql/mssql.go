@@ -4,25 +4,52 @@ import (
 	"database/sql"
 	"fmt"
 	"net/url"
+	"os"
+	"os/exec"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 
-	_ "github.com/denisenkom/go-mssqldb"
+	driver "github.com/denisenkom/go-mssqldb"
 	"github.com/k0kubun/sqldef/adapter"
 )
 
 const indent = "    "
 
+// Values of adapter.Config.MssqlAuth
+const (
+	AuthSQL        = "sql"
+	AuthIntegrated = "integrated"
+	AuthAzureAD    = "azure-ad"
+)
+
 type MssqlDatabase struct {
 	config adapter.Config
 	db     *sql.DB
 }
 
 func NewDatabase(config adapter.Config) (adapter.Database, error) {
-	db, err := sql.Open("sqlserver", mssqlBuildDSN(config))
-	if err != nil {
-		return nil, err
+	var db *sql.DB
+	switch config.MssqlAuth {
+	case AuthAzureAD:
+		connector, err := driver.NewAccessTokenConnector(mssqlBuildDSN(config), azureADAccessToken)
+		if err != nil {
+			return nil, err
+		}
+		db = adapter.OpenConnector(config, connector)
+	case AuthIntegrated:
+		// SSPI with the current Windows user is available only on Windows. Otherwise NTLM requires DOMAIN\user.
+		if runtime.GOOS != "windows" && !strings.Contains(config.User, `\`) {
+			return nil, fmt.Errorf("integrated authentication requires a user name like DOMAIN\\user on %s", runtime.GOOS)
+		}
+		fallthrough
+	default:
+		var err error
+		db, err = adapter.OpenDB(config, "sqlserver", mssqlBuildDSN(config))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &MssqlDatabase{
@@ -64,10 +91,14 @@ func (d *MssqlDatabase) DumpTableDDL(table string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return buildDumpTableDDL(table, cols, indexDefs, foreignDefs), nil
+	extendedProperties, err := d.getExtendedProperties(table)
+	if err != nil {
+		return "", err
+	}
+	return buildDumpTableDDL(table, cols, indexDefs, foreignDefs, extendedProperties), nil
 }
 
-func buildDumpTableDDL(table string, columns []column, indexDefs []*indexDef, foreignDefs []string) string {
+func buildDumpTableDDL(table string, columns []column, indexDefs []*indexDef, foreignDefs []string, extendedProperties []string) string {
 	var queryBuilder strings.Builder
 	fmt.Fprintf(&queryBuilder, "CREATE TABLE %s (", table)
 	for i, col := range columns {
@@ -155,6 +186,11 @@ func buildDumpTableDDL(table string, columns []column, indexDefs []*indexDef, fo
 			}
 			fmt.Fprint(&queryBuilder, " )")
 		}
+		fmt.Fprint(&queryBuilder, ";\n")
+	}
+
+	for _, v := range extendedProperties {
+		fmt.Fprintf(&queryBuilder, "%s;\n", v)
 	}
 	return strings.TrimSuffix(queryBuilder.String(), "\n")
 }
@@ -421,6 +457,42 @@ WHERE f.parent_object_id = OBJECT_ID('[%s].[%s]')`, schema, table)
 	return defs, nil
 }
 
+func (d *MssqlDatabase) getExtendedProperties(table string) ([]string, error) {
+	schema, table := splitTableName(table)
+	query := fmt.Sprintf(`SELECT
+	COL_NAME(ep.major_id, ep.minor_id),
+	CAST(ep.value AS nvarchar(max))
+FROM sys.extended_properties ep
+WHERE ep.class = 1 AND ep.name = 'MS_Description' AND ep.major_id = OBJECT_ID('[%s].[%s]')
+ORDER BY ep.minor_id`, schema, table)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := make([]string, 0)
+	for rows.Next() {
+		var columnName *string // NULL for the table itself
+		var value string
+		err = rows.Scan(&columnName, &value)
+		if err != nil {
+			return nil, err
+		}
+		property := fmt.Sprintf(
+			"EXEC sp_addextendedproperty @name = N'MS_Description', @value = N'%s', @level0type = N'SCHEMA', @level0name = N'%s', @level1type = N'TABLE', @level1name = N'%s'",
+			strings.ReplaceAll(value, "'", "''"), schema, table,
+		)
+		if columnName != nil {
+			property += fmt.Sprintf(", @level2type = N'COLUMN', @level2name = N'%s'", *columnName)
+		}
+		properties = append(properties, property)
+	}
+
+	return properties, nil
+}
+
 func boolToOnOff(in bool) string {
 	if in {
 		return "ON"
@@ -501,6 +573,10 @@ func (d *MssqlDatabase) DB() *sql.DB {
 	return d.db
 }
 
+func (d *MssqlDatabase) DumpConcurrency() int {
+	return d.config.DumpConcurrency
+}
+
 func (d *MssqlDatabase) Close() error {
 	return d.db.Close()
 }
@@ -511,13 +587,37 @@ func mssqlBuildDSN(config adapter.Config) string {
 
 	u := &url.URL{
 		Scheme:   "sqlserver",
-		User:     url.UserPassword(config.User, config.Password),
 		Host:     fmt.Sprintf("%s:%d", config.Host, config.Port),
 		RawQuery: query.Encode(),
 	}
+	switch config.MssqlAuth {
+	case AuthIntegrated:
+		// An empty user makes the driver use SSPI with the current Windows user
+		if strings.Contains(config.User, `\`) {
+			u.User = url.UserPassword(config.User, config.Password)
+		}
+	case AuthAzureAD:
+		// Authenticated by an access token instead
+	default:
+		u.User = url.UserPassword(config.User, config.Password)
+	}
 	return u.String()
 }
 
+// Access token for Azure SQL Database. This is called for every new connection since a token expires.
+// $MSSQL_ACCESS_TOKEN is used if set. Otherwise, it's acquired by Azure CLI with the signed-in account.
+func azureADAccessToken() (string, error) {
+	if token, ok := os.LookupEnv("MSSQL_ACCESS_TOKEN"); ok {
+		return token, nil
+	}
+
+	out, err := exec.Command("az", "account", "get-access-token", "--resource", "https://database.windows.net/", "--query", "accessToken", "--output", "tsv").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token by Azure CLI (set $MSSQL_ACCESS_TOKEN or run `az login`): %s", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 func splitTableName(table string) (string, string) {
 	schema := "dbo"
 	schemaTable := strings.SplitN(table, ".", 2)
@@ -1,20 +1,28 @@
 package mysql
 
 import (
+	"context"
+	"crypto/sha1"
 	"database/sql"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
 
 	driver "github.com/go-sql-driver/mysql"
 	"github.com/k0kubun/sqldef/adapter"
 )
 
 type MysqlDatabase struct {
-	config adapter.Config
-	db     *sql.DB
+	config  adapter.Config
+	db      *sql.DB
+	version string     // lazily fetched by ServerVersion()
+	mutex   sync.Mutex // of version, since tables may be dumped concurrently
 }
 
 func NewDatabase(config adapter.Config) (adapter.Database, error) {
-	db, err := sql.Open("mysql", mysqlBuildDSN(config))
+	db, err := adapter.OpenDB(config, "mysql", mysqlBuildDSN(config))
 	if err != nil {
 		return nil, err
 	}
@@ -26,7 +34,7 @@ func NewDatabase(config adapter.Config) (adapter.Database, error) {
 }
 
 func (d *MysqlDatabase) TableNames() ([]string, error) {
-	rows, err := d.db.Query("show full tables where Table_Type != 'VIEW'")
+	rows, err := d.db.Query("show full tables where Table_Type not in ('VIEW', 'SEQUENCE')")
 	if err != nil {
 		return nil, err
 	}
@@ -53,6 +61,12 @@ func (d *MysqlDatabase) DumpTableDDL(table string) (string, error) {
 		return "", err
 	}
 
+	if mariadb, err := d.isMariadb(); err != nil {
+		return "", err
+	} else if mariadb {
+		ddl = normalizeMariadbJSONColumns(ddl)
+	}
+
 	return ddl + ";", nil
 }
 
@@ -61,7 +75,8 @@ func (d *MysqlDatabase) Views() ([]string, error) {
 		return []string{}, nil
 	}
 
-	rows, err := d.db.Query("show full tables where TABLE_TYPE = 'VIEW'")
+	// Definitions of all views by one query, instead of a query per view
+	rows, err := d.db.Query("select TABLE_NAME, VIEW_DEFINITION from INFORMATION_SCHEMA.VIEWS where TABLE_SCHEMA = ? order by TABLE_NAME;", d.config.DbName)
 	if err != nil {
 		return nil, err
 	}
@@ -69,23 +84,25 @@ func (d *MysqlDatabase) Views() ([]string, error) {
 
 	var ddls []string
 	for rows.Next() {
-		var viewName, viewType, definition string
-		if err = rows.Scan(&viewName, &viewType); err != nil {
+		var viewName, definition string
+		if err = rows.Scan(&viewName, &definition); err != nil {
 			return nil, err
 		}
-		query := fmt.Sprintf("select VIEW_DEFINITION from INFORMATION_SCHEMA.VIEWS where TABLE_SCHEMA = '%s' AND TABLE_NAME = '%s';", d.config.DbName, viewName)
-		if err = d.db.QueryRow(query).Scan(&definition); err != nil {
-			return nil, err
+		if definition == "" { // hidden without the SHOW VIEW privilege
+			if err := d.config.SkipUnreadable("view "+viewName, errors.New("SHOW VIEW privilege is required")); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		ddls = append(ddls, fmt.Sprintf("CREATE VIEW %s AS %s;", viewName, definition))
 	}
-	return ddls, nil
+	return ddls, rows.Err()
 }
 
 func (d *MysqlDatabase) Triggers() ([]string, error) {
 	rows, err := d.db.Query("show triggers")
 	if err != nil {
-		return nil, err
+		return nil, d.config.SkipUnreadable("triggers", err)
 	}
 	defer rows.Close()
 
@@ -101,14 +118,146 @@ func (d *MysqlDatabase) Triggers() ([]string, error) {
 	return ddls, nil
 }
 
+// MariaDB's sequences are dumped before tables, like Postgres types, since tables may use them as defaults.
 func (d *MysqlDatabase) Types() ([]string, error) {
-	return nil, nil
+	if mariadb, err := d.isMariadb(); err != nil || !mariadb {
+		return nil, err
+	}
+
+	rows, err := d.db.Query("show full tables where Table_Type = 'SEQUENCE'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sequences []string
+	for rows.Next() {
+		var sequence, tableType string
+		if err := rows.Scan(&sequence, &tableType); err != nil {
+			return nil, err
+		}
+		sequences = append(sequences, sequence)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var ddls []string
+	for _, sequence := range sequences {
+		var ddl string
+		if err := d.db.QueryRow(fmt.Sprintf("show create sequence `%s`", sequence)).Scan(&sequence, &ddl); err != nil {
+			return nil, err
+		}
+		ddls = append(ddls, ddl+";")
+	}
+	return ddls, nil
+}
+
+func (d *MysqlDatabase) ServerVersion() (string, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.version == "" {
+		if err := d.db.QueryRow("select version()").Scan(&d.version); err != nil {
+			return "", err
+		}
+	}
+	return d.version, nil
+}
+
+// Estimated by InnoDB's statistics, or -1 if the table is not found
+func (d *MysqlDatabase) EstimatedRows(table string) (int64, error) {
+	var rows sql.NullInt64
+	err := d.db.QueryRow("select table_rows from information_schema.tables where table_schema = database() and table_name = ?", table).Scan(&rows)
+	if err == sql.ErrNoRows || !rows.Valid {
+		return -1, nil
+	}
+	return rows.Int64, err
+}
+
+// Estimated by InnoDB's statistics, or -1 if the table is not found
+func (d *MysqlDatabase) TableSize(table string) (int64, error) {
+	var size sql.NullInt64
+	err := d.db.QueryRow("select data_length + index_length from information_schema.tables where table_schema = database() and table_name = ?", table).Scan(&size)
+	if err == sql.ErrNoRows || !size.Valid {
+		return -1, nil
+	}
+	return size.Int64, err
+}
+
+// MariaDB reports a version like "10.6.11-MariaDB-1:10.6.11+maria~ubu2004"
+func (d *MysqlDatabase) isMariadb() (bool, error) {
+	version, err := d.ServerVersion()
+	return strings.Contains(version, "MariaDB"), err
+}
+
+var mariadbJSONColumnRegexp = regexp.MustCompile("(?m)^(\\s*`([^`]+)`) longtext(?: CHARACTER SET \\w+)?(?: COLLATE \\w+)?(.*?) CHECK \\(json_valid\\(`([^`]+)`\\)\\)(,?)$")
+
+// MariaDB's JSON is an alias of LONGTEXT with a json_valid() CHECK constraint. Dump it as JSON to be compared with schema files.
+func normalizeMariadbJSONColumns(ddl string) string {
+	return mariadbJSONColumnRegexp.ReplaceAllStringFunc(ddl, func(line string) string {
+		match := mariadbJSONColumnRegexp.FindStringSubmatch(line)
+		if match[2] != match[4] {
+			return line // json_valid() of another column
+		}
+		return match[1] + " json" + match[3] + match[5]
+	})
+}
+
+// Return lower_case_table_names, which is 1 or 2 when table names are compared case-insensitively
+func (d *MysqlDatabase) LowerCaseTableNames() (int, error) {
+	var value int
+	err := d.db.QueryRow("SELECT @@lower_case_table_names").Scan(&value)
+	return value, err
+}
+
+// Take a lock named after the database by GET_LOCK on a dedicated connection, waiting for --lock-timeout seconds if it's given.
+// The connection is taken from the pool of OpenDB, which has no limit of open connections, and held until release is called.
+func (d *MysqlDatabase) LockApply(ctx context.Context, waiting func()) (func() error, error) {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := applyLockName(d.config.DbName)
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if locked.Int64 != 1 {
+		waiting()
+		timeout := "-1" // forever
+		if d.config.LockTimeout != "" {
+			timeout = d.config.LockTimeout
+		}
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeout).Scan(&locked); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if locked.Int64 != 1 {
+			conn.Close()
+			return nil, fmt.Errorf("timed out waiting for the lock '%s' by --lock-timeout", name)
+		}
+	}
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), "DO RELEASE_LOCK(?)", name)
+		return err
+	}, nil
+}
+
+// A name of GET_LOCK is up to 64 characters, while a database name alone can be 64 characters
+func applyLockName(dbName string) string {
+	return fmt.Sprintf("sqldef.%x", sha1.Sum([]byte(dbName)))
 }
 
 func (d *MysqlDatabase) DB() *sql.DB {
 	return d.db
 }
 
+func (d *MysqlDatabase) DumpConcurrency() int {
+	return d.config.DumpConcurrency
+}
+
 func (d *MysqlDatabase) Close() error {
 	return d.db.Close()
 }
@@ -127,5 +276,8 @@ func mysqlBuildDSN(config adapter.Config) string {
 		c.Net = "unix"
 		c.Addr = config.Socket
 	}
+	if config.LockTimeout != "" {
+		c.Params = map[string]string{"lock_wait_timeout": config.LockTimeout}
+	}
 	return c.FormatDSN()
 }
@@ -0,0 +1,21 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyLockName(t *testing.T) {
+	for _, dbName := range []string{"", "sqldef_test", strings.Repeat("a", 64)} {
+		name := applyLockName(dbName)
+		if !strings.HasPrefix(name, "sqldef.") || len(name) > 64 {
+			t.Errorf("expected a lock name with the prefix up to 64 characters for %q, but got %q", dbName, name)
+		}
+	}
+	if applyLockName("db1") == applyLockName("db2") {
+		t.Error("expected different lock names of different databases")
+	}
+	if applyLockName("db1") != applyLockName("db1") {
+		t.Error("expected the same lock name of the same database")
+	}
+}
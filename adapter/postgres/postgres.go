@@ -1,29 +1,44 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/k0kubun/sqldef/adapter"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const indent = "    "
 
 type PostgresDatabase struct {
-	config adapter.Config
-	db     *sql.DB
+	config  adapter.Config
+	db      *sql.DB
+	version string     // lazily fetched by ServerVersion()
+	citus   *bool      // lazily fetched by isCitus()
+	mutex   sync.Mutex // of lazily fetched fields, since tables may be dumped concurrently
 }
 
 func NewDatabase(config adapter.Config) (adapter.Database, error) {
-	db, err := sql.Open("postgres", postgresBuildDSN(config))
-	if err != nil {
-		return nil, err
+	var db *sql.DB
+	if config.Keepalive != 0 {
+		db = adapter.OpenConnector(config, keepaliveConnector{dsn: postgresBuildDSN(config), interval: config.Keepalive})
+	} else {
+		var err error
+		db, err = adapter.OpenDB(config, "postgres", postgresBuildDSN(config))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &PostgresDatabase{
@@ -32,13 +47,54 @@ func NewDatabase(config adapter.Config) (adapter.Database, error) {
 	}, nil
 }
 
+// Connect by lib/pq with the interval of TCP keepalive probes given by --keepalive
+type keepaliveConnector struct {
+	dsn      string
+	interval time.Duration
+}
+
+func (c keepaliveConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return pq.DialOpen(c, c.dsn)
+}
+
+func (c keepaliveConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+func (c keepaliveConnector) Dial(network, address string) (net.Conn, error) {
+	return c.DialContext(context.Background(), network, address)
+}
+
+func (c keepaliveConnector) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.DialContext(ctx, network, address)
+}
+
+func (c keepaliveConnector) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := net.Dialer{KeepAlive: c.interval}
+	return dialer.DialContext(ctx, network, address)
+}
+
 func (d *PostgresDatabase) TableNames() ([]string, error) {
-	rows, err := d.db.Query(
+	citus, err := d.isCitus()
+	if err != nil {
+		return nil, err
+	}
+	shardCondition := ""
+	if citus {
+		// Citus shards are also visible as tables on worker nodes
+		shardCondition = "and not pg_catalog.relation_is_a_known_shard(format('%I.%I', table_schema, table_name)::regclass)"
+	}
+
+	rows, err := d.db.Query(fmt.Sprintf(
 		`select table_schema, table_name from information_schema.tables
-		 where table_schema not in ('information_schema', 'pg_catalog')
+		 where table_schema not in ('information_schema', 'pg_catalog', 'gp_toolkit')
 		 and (table_schema != 'public' or table_name != 'pg_buffercache')
-		 and table_type = 'BASE TABLE';`,
-	)
+		 and ($1 = '' or table_schema = $1)
+		 and table_type = 'BASE TABLE' %s
+		 order by format('%%I.%%I', table_schema, table_name)::regclass::oid;`, shardCondition,
+	), d.config.Schema)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +122,9 @@ func (d *PostgresDatabase) Views() ([]string, error) {
 		 inner join pg_views on table_name = viewname
 		 where table_schema not in ('information_schema', 'pg_catalog', 'repack')
 		 and (table_schema != 'public' or table_name != 'pg_buffercache')
-		 and table_type = 'VIEW';`,
+		 and ($1 = '' or table_schema = $1)
+		 and table_type = 'VIEW'
+		 order by format('%I.%I', table_schema, table_name)::regclass::oid;`, d.config.Schema,
 	)
 	if err != nil {
 		return nil, err
@@ -97,11 +155,15 @@ func (d *PostgresDatabase) Triggers() ([]string, error) {
 }
 
 func (d *PostgresDatabase) Types() ([]string, error) {
+	// Types are qualified only with --schema, whose desired types are moved to the schema
 	rows, err := d.db.Query(
-		`select t.typname, string_agg(e.enumlabel, ' ')
+		`select case when $1 = '' then t.typname else format('%I.%I', n.nspname, t.typname) end, string_agg(e.enumlabel, ' ' order by e.enumsortorder)
 		 from pg_enum e
 		 join pg_type t on e.enumtypid = t.oid
-		 group by t.typname;`,
+		 join pg_namespace n on t.typnamespace = n.oid
+		 where $1 = '' or n.nspname = $1
+		 group by n.nspname, t.typname
+		 order by n.nspname, t.typname;`, d.config.Schema,
 	)
 	if err != nil {
 		return nil, err
@@ -128,38 +190,105 @@ func (d *PostgresDatabase) Types() ([]string, error) {
 }
 
 func (d *PostgresDatabase) DumpTableDDL(table string) (string, error) {
-	cols, err := d.getColumns(table)
+	ddls, err := d.DumpTableDDLs([]string{table})
 	if err != nil {
 		return "", err
 	}
-	pkeyCols, err := d.getPrimaryKeyColumns(table)
+	return ddls[0], nil
+}
+
+// Dump tables by a query of each kind of their catalog, e.g. columns and indexes of all tables, instead of queries per table
+func (d *PostgresDatabase) DumpTableDDLs(tables []string) ([]string, error) {
+	cols, err := d.getColumns(tables)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	getPrimaryKeyColumns, getForeignDefs := d.getPrimaryKeyColumns, d.getForeignDefs
+	if d.config.LimitedPrivileges {
+		// information_schema hides constraints of tables which the user doesn't own, while pg_catalog doesn't
+		getPrimaryKeyColumns, getForeignDefs = d.getCatalogPrimaryKeyColumns, d.getCatalogForeignDefs
 	}
-	indexDefs, err := d.getIndexDefs(table)
+	pkeyCols, err := getPrimaryKeyColumns(tables)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	foreignDefs, err := d.getForeignDefs(table)
+	indexDefs, err := d.getIndexDefs(tables)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	policyDefs, err := d.getPolicyDefs(table)
+	foreignDefs, err := getForeignDefs(tables)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	policyDefs, err := d.getPolicyDefs(tables)
+	if err = d.config.SkipUnreadable("policies", err); err != nil {
+		return nil, err
 	}
-	checkConstraints, err := d.getTableCheckConstraints(table)
+	checkConstraints, err := d.getTableCheckConstraints(tables)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	uniqueConstraints, err := d.getUniqueConstraints(table)
+	uniqueConstraints, err := d.getUniqueConstraints(tables)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	commentDefs, err := d.getCommentDefs(tables)
+	if err = d.config.SkipUnreadable("comments", err); err != nil {
+		return nil, err
+	}
+
+	var storageClauses map[string]string
+	if yugabyte, err := d.isYugabyte(); err != nil {
+		return nil, err
+	} else if yugabyte {
+		storageClauses, err = d.getYugabyteStorageClauses(tables)
+		if err = d.config.SkipUnreadable("the storage of tables", err); err != nil {
+			return nil, err
+		}
+	}
+	if greenplum, err := d.isGreenplum(); err != nil {
+		return nil, err
+	} else if greenplum {
+		storageClauses, err = d.getGreenplumDistributionClauses(tables)
+		if err = d.config.SkipUnreadable("the distribution of tables", err); err != nil {
+			return nil, err
+		}
+	}
+	var distributeDefs map[string]string
+	if citus, err := d.isCitus(); err != nil {
+		return nil, err
+	} else if citus {
+		distributeDefs, err = d.getCitusDistributeTableDefs(tables)
+		if err = d.config.SkipUnreadable("the distribution of tables", err); err != nil {
+			return nil, err
+		}
+	}
+
+	ddls := make([]string, len(tables))
+	for i, table := range tables {
+		ddl := buildDumpTableDDL(table, cols[table], pkeyCols[table], indexDefs[table], foreignDefs[table], policyDefs[table],
+			checkConstraints[table], uniqueConstraints[table], commentDefs[table], storageClauses[table])
+		if distributeDef, ok := distributeDefs[table]; ok {
+			ddl += "\n" + distributeDef + ";"
+		}
+		ddls[i] = ddl
 	}
-	return buildDumpTableDDL(table, cols, pkeyCols, indexDefs, foreignDefs, policyDefs, checkConstraints, uniqueConstraints), nil
+	return ddls, nil
+}
+
+// Return the qualified names of tables to query their catalog by `= ANY($1)`, and a map from them to the given names
+func catalogKeys(tables []string) (interface{}, map[string]string) {
+	keys := make([]string, 0, len(tables))
+	names := map[string]string{}
+	for _, table := range tables {
+		schema, name := SplitTableName(table)
+		keys = append(keys, schema+"."+name)
+		names[schema+"."+name] = table
+	}
+	return pq.Array(keys), names
 }
 
-func buildDumpTableDDL(table string, columns []column, pkeyCols, indexDefs, foreignDefs, policyDefs []string, checkConstraints, uniqueConstraints map[string]string) string {
+func buildDumpTableDDL(table string, columns []column, pkeyCols, indexDefs, foreignDefs, policyDefs []string, checkConstraints, uniqueConstraints map[string]string, commentDefs []string, storageClause string) string {
 	var queryBuilder strings.Builder
 	fmt.Fprintf(&queryBuilder, "CREATE TABLE %s (", table)
 	for i, col := range columns {
@@ -188,11 +317,11 @@ func buildDumpTableDDL(table string, columns []column, pkeyCols, indexDefs, fore
 		fmt.Fprint(&queryBuilder, ",\n"+indent)
 		fmt.Fprintf(&queryBuilder, "PRIMARY KEY (\"%s\")", strings.Join(pkeyCols, "\", \""))
 	}
-	for constraintName, constraintDef := range checkConstraints {
+	for _, constraintName := range sortedKeys(checkConstraints) {
 		fmt.Fprint(&queryBuilder, ",\n"+indent)
-		fmt.Fprintf(&queryBuilder, "CONSTRAINT %s %s", constraintName, constraintDef)
+		fmt.Fprintf(&queryBuilder, "CONSTRAINT %s %s", constraintName, checkConstraints[constraintName])
 	}
-	fmt.Fprintf(&queryBuilder, "\n);\n")
+	fmt.Fprintf(&queryBuilder, "\n)%s;\n", storageClause)
 	for _, v := range indexDefs {
 		fmt.Fprintf(&queryBuilder, "%s;\n", v)
 	}
@@ -202,12 +331,25 @@ func buildDumpTableDDL(table string, columns []column, pkeyCols, indexDefs, fore
 	for _, v := range policyDefs {
 		fmt.Fprintf(&queryBuilder, "%s;\n", v)
 	}
-	for _, constraintDef := range uniqueConstraints {
-		fmt.Fprintf(&queryBuilder, "%s;\n", constraintDef)
+	for _, constraintName := range sortedKeys(uniqueConstraints) {
+		fmt.Fprintf(&queryBuilder, "%s;\n", uniqueConstraints[constraintName])
+	}
+	for _, v := range commentDefs {
+		fmt.Fprintf(&queryBuilder, "%s;\n", v)
 	}
 	return strings.TrimSuffix(queryBuilder.String(), "\n")
 }
 
+// Constraints are dumped in the order of their names, so that the same schema is always dumped the same, e.g. for --plan
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 type columnConstraint struct {
 	definition string
 	name       string
@@ -254,10 +396,12 @@ func (c *column) GetDataType() string {
 	}
 }
 
-func (d *PostgresDatabase) getColumns(table string) ([]column, error) {
+func (d *PostgresDatabase) getColumns(tables []string) (map[string][]column, error) {
 	const query = `WITH
 	  columns AS (
 	    SELECT
+	      n.nspname || '.' || c.relname AS table_key,
+	      f.attnum,
 	      s.column_name,
 	      s.column_default,
 	      s.is_nullable,
@@ -265,7 +409,7 @@ func (d *PostgresDatabase) getColumns(table string) ([]column, error) {
 	      CASE
 	      WHEN s.data_type IN ('ARRAY', 'USER-DEFINED') THEN format_type(f.atttypid, f.atttypmod)
 	      ELSE s.data_type
-	      END,
+	      END AS data_type,
 	      s.identity_generation
 	    FROM pg_attribute f
 	    JOIN pg_class c ON c.oid = f.attrelid JOIN pg_type t ON t.oid = f.atttypid
@@ -273,50 +417,50 @@ func (d *PostgresDatabase) getColumns(table string) ([]column, error) {
 	    LEFT JOIN pg_namespace n ON n.oid = c.relnamespace
 	    LEFT JOIN information_schema.columns s ON s.column_name = f.attname AND s.table_name = c.relname AND s.table_schema = n.nspname
 	    WHERE c.relkind = 'r'::char
-	    AND n.nspname = $1
-	    AND c.relname = $2
+	    AND n.nspname || '.' || c.relname = ANY($1)
 	    AND f.attnum > 0
-	    ORDER BY f.attnum
 	  ),
 	  column_constraints AS (
-	    SELECT att.attname column_name, tmp.name, tmp.type , tmp.definition
+	    SELECT tmp.table_key, att.attname column_name, tmp.name, tmp.type , tmp.definition
 	    FROM (
 	      SELECT unnest(con.conkey) AS conkey,
 	             pg_get_constraintdef(con.oid, true) AS definition,
 	             cls.oid AS relid,
+	             nsp.nspname || '.' || cls.relname AS table_key,
 	             con.conname AS name,
 	             con.contype AS type
 	      FROM   pg_constraint con
 	      JOIN   pg_namespace nsp ON nsp.oid = con.connamespace
 	      JOIN   pg_class cls ON cls.oid = con.conrelid
-	      WHERE  nsp.nspname = $1
-	      AND    cls.relname = $2
+	      WHERE  nsp.nspname || '.' || cls.relname = ANY($1)
 	      AND    array_length(con.conkey, 1) = 1
 	    ) tmp
 	    JOIN pg_attribute att ON tmp.conkey = att.attnum AND tmp.relid = att.attrelid
 	  ),
 	  check_constraints AS (
-	    SELECT column_name, name, definition
+	    SELECT table_key, column_name, name, definition
 	    FROM   column_constraints
 	    WHERE  type = 'c'
 	  )
-	SELECT    columns.*, checks.name, checks.definition
+	SELECT    columns.table_key, columns.column_name, columns.column_default, columns.is_nullable,
+	          columns.character_maximum_length, columns.data_type, columns.identity_generation, checks.name, checks.definition
 	FROM      columns
-	LEFT JOIN check_constraints checks USING (column_name);`
+	LEFT JOIN check_constraints checks USING (table_key, column_name)
+	ORDER BY  columns.table_key, columns.attnum;`
 
-	schema, table := SplitTableName(table)
-	rows, err := d.db.Query(query, schema, table)
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	cols := make([]column, 0)
+	result := map[string][]column{}
 	for rows.Next() {
 		col := column{}
-		var colName, isNullable, dataType string
+		var key, colName, isNullable, dataType string
 		var maxLenStr, colDefault, idGen, checkName, checkDefinition *string
-		err = rows.Scan(&colName, &colDefault, &isNullable, &maxLenStr, &dataType, &idGen, &checkName, &checkDefinition)
+		err = rows.Scan(&key, &colName, &colDefault, &isNullable, &maxLenStr, &dataType, &idGen, &checkName, &checkDefinition)
 		if err != nil {
 			return nil, err
 		}
@@ -346,140 +490,164 @@ func (d *PostgresDatabase) getColumns(table string) ([]column, error) {
 				name:       *checkName,
 			}
 		}
-		cols = append(cols, col)
+		result[names[key]] = append(result[names[key]], col)
 	}
-	return cols, nil
+	return result, rows.Err()
 }
 
-func (d *PostgresDatabase) getIndexDefs(table string) ([]string, error) {
+func (d *PostgresDatabase) getIndexDefs(tables []string) (map[string][]string, error) {
 	// Exclude indexes that are implicitly created for primary keys or unique constraints.
+	// Indexes are ordered by their creation, as a query per table usually returned them.
 	const query = `WITH
 	  unique_and_pk_constraints AS (
-	    SELECT con.conname AS name
+	    SELECT nsp.nspname || '.' || cls.relname AS table_key, con.conname AS name
 	    FROM   pg_constraint con
 	    JOIN   pg_namespace nsp ON nsp.oid = con.connamespace
 	    JOIN   pg_class cls ON cls.oid = con.conrelid
 	    WHERE  con.contype IN ('p', 'u')
-	    AND    nsp.nspname = $1
-	    AND    cls.relname = $2
+	    AND    nsp.nspname || '.' || cls.relname = ANY($1)
 	  )
-	SELECT indexName, indexdef
+	SELECT schemaname || '.' || tablename, schemaname, indexName, indexdef
 	FROM   pg_indexes
-	WHERE  schemaname = $1
-	AND    tablename = $2
-	AND    indexName NOT IN (SELECT name FROM unique_and_pk_constraints)
+	WHERE  schemaname || '.' || tablename = ANY($1)
+	AND    (schemaname || '.' || tablename, indexName) NOT IN (SELECT table_key, name FROM unique_and_pk_constraints)
+	ORDER BY schemaname, tablename, format('%I.%I', schemaname, indexName)::regclass::oid
 	`
-	schema, table := SplitTableName(table)
-	rows, err := d.db.Query(query, schema, table)
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	indexes := make([]string, 0)
+	indexes := map[string][]string{}
+	indexNames := map[string][]string{} // schema.index of each index in indexes
+	var allIndexNames []string
 	for rows.Next() {
-		var indexName, indexdef string
-		err = rows.Scan(&indexName, &indexdef)
+		var key, schema, indexName, indexdef string
+		err = rows.Scan(&key, &schema, &indexName, &indexdef)
 		if err != nil {
 			return nil, err
 		}
-		indexName = strings.Trim(indexName, `" `)
+		indexName = schema + "." + strings.Trim(indexName, `" `)
+		indexNames[names[key]] = append(indexNames[names[key]], indexName)
+		allIndexNames = append(allIndexNames, indexName)
+		indexes[names[key]] = append(indexes[names[key]], indexdef)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		indexes = append(indexes, indexdef)
+	if yugabyte, err := d.isYugabyte(); err != nil {
+		return nil, err
+	} else if yugabyte && len(allIndexNames) > 0 {
+		properties, err := d.getYugabyteProperties(allIndexNames)
+		if err != nil {
+			return nil, err
+		}
+		for table, defs := range indexes {
+			for i := range defs {
+				defs[i] += properties[indexNames[table][i]].splitClause()
+			}
+		}
 	}
 	return indexes, nil
 }
 
-func (d *PostgresDatabase) getTableCheckConstraints(tableName string) (map[string]string, error) {
-	const query = `SELECT con.conname, pg_get_constraintdef(con.oid, true)
+func (d *PostgresDatabase) getTableCheckConstraints(tables []string) (map[string]map[string]string, error) {
+	const query = `SELECT nsp.nspname || '.' || cls.relname, con.conname, pg_get_constraintdef(con.oid, true)
 	FROM   pg_constraint con
 	JOIN   pg_namespace nsp ON nsp.oid = con.connamespace
 	JOIN   pg_class cls ON cls.oid = con.conrelid
 	WHERE  con.contype = 'c'
-	AND    nsp.nspname = $1
-	AND    cls.relname = $2
+	AND    nsp.nspname || '.' || cls.relname = ANY($1)
 	AND    array_length(con.conkey, 1) > 1;`
 
-	result := map[string]string{}
-	schema, table := SplitTableName(tableName)
-	rows, err := d.db.Query(query, schema, table)
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	result := map[string]map[string]string{}
 	for rows.Next() {
-		var constraintName, constraintDef string
-		err = rows.Scan(&constraintName, &constraintDef)
+		var key, constraintName, constraintDef string
+		err = rows.Scan(&key, &constraintName, &constraintDef)
 		if err != nil {
 			return nil, err
 		}
-		result[constraintName] = constraintDef
+		if result[names[key]] == nil {
+			result[names[key]] = map[string]string{}
+		}
+		result[names[key]][constraintName] = constraintDef
 	}
-
-	return result, nil
+	return result, rows.Err()
 }
 
-func (d *PostgresDatabase) getUniqueConstraints(tableName string) (map[string]string, error) {
-	const query = `SELECT con.conname, pg_get_constraintdef(con.oid)
+func (d *PostgresDatabase) getUniqueConstraints(tables []string) (map[string]map[string]string, error) {
+	const query = `SELECT nsp.nspname || '.' || cls.relname, con.conname, pg_get_constraintdef(con.oid)
 	FROM   pg_constraint con
 	JOIN   pg_namespace nsp ON nsp.oid = con.connamespace
 	JOIN   pg_class cls ON cls.oid = con.conrelid
 	WHERE  con.contype = 'u'
-	AND    nsp.nspname = $1
-	AND    cls.relname = $2;`
+	AND    nsp.nspname || '.' || cls.relname = ANY($1);`
 
-	result := map[string]string{}
-	schema, table := SplitTableName(tableName)
-	rows, err := d.db.Query(query, schema, table)
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	result := map[string]map[string]string{}
 	for rows.Next() {
-		var constraintName, constraintDef string
-		err = rows.Scan(&constraintName, &constraintDef)
+		var key, constraintName, constraintDef string
+		err = rows.Scan(&key, &constraintName, &constraintDef)
 		if err != nil {
 			return nil, err
 		}
-		result[constraintName] = fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", tableName, constraintName, constraintDef)
+		tableName := names[key]
+		if result[tableName] == nil {
+			result[tableName] = map[string]string{}
+		}
+		result[tableName][constraintName] = fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", tableName, constraintName, constraintDef)
 	}
-
-	return result, nil
+	return result, rows.Err()
 }
 
-func (d *PostgresDatabase) getPrimaryKeyColumns(table string) ([]string, error) {
+func (d *PostgresDatabase) getPrimaryKeyColumns(tables []string) (map[string][]string, error) {
 	const query = `SELECT
-	tc.table_schema, tc.constraint_name, tc.table_name, kcu.column_name
+	tc.table_schema || '.' || tc.table_name, kcu.column_name
 FROM
 	information_schema.table_constraints AS tc
 	JOIN information_schema.key_column_usage AS kcu
 		USING (table_schema, table_name, constraint_name)
-WHERE constraint_type = 'PRIMARY KEY' AND tc.table_schema=$1 AND tc.table_name=$2 ORDER BY kcu.ordinal_position`
-	schema, table := SplitTableName(table)
-	rows, err := d.db.Query(query, schema, table)
+WHERE constraint_type = 'PRIMARY KEY' AND tc.table_schema || '.' || tc.table_name = ANY($1)
+ORDER BY tc.table_schema, tc.table_name, kcu.ordinal_position`
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	columnNames := make([]string, 0)
-	var tableSchema, constraintName, tableName string
+	columnNames := map[string][]string{}
 	for rows.Next() {
-		var columnName string
-		err = rows.Scan(&tableSchema, &constraintName, &tableName, &columnName)
+		var key, columnName string
+		err = rows.Scan(&key, &columnName)
 		if err != nil {
 			return nil, err
 		}
-		columnNames = append(columnNames, columnName)
+		columnNames[names[key]] = append(columnNames[names[key]], columnName)
 	}
-	return columnNames, nil
+	return columnNames, rows.Err()
 }
 
 // refs: https://gist.github.com/PickledDragon/dd41f4e72b428175354d
-func (d *PostgresDatabase) getForeignDefs(table string) ([]string, error) {
+func (d *PostgresDatabase) getForeignDefs(tables []string) (map[string][]string, error) {
 	const query = `SELECT
+	tc.table_schema || '.' || tc.table_name,
 	tc.table_schema, tc.constraint_name, tc.table_name, kcu.column_name,
 	ccu.table_schema AS foreign_table_schema,
 	ccu.table_name AS foreign_table_name,
@@ -494,18 +662,19 @@ FROM
 		ON tc.constraint_name = ccu.constraint_name
 	JOIN information_schema.referential_constraints AS rc
 		ON tc.constraint_name = rc.constraint_name
-WHERE constraint_type = 'FOREIGN KEY' AND tc.table_schema=$1 AND tc.table_name=$2`
-	schema, table := SplitTableName(table)
-	rows, err := d.db.Query(query, schema, table)
+WHERE constraint_type = 'FOREIGN KEY' AND tc.table_schema || '.' || tc.table_name = ANY($1)
+ORDER BY tc.table_schema, tc.table_name, tc.constraint_name, kcu.ordinal_position`
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	defs := make([]string, 0)
+	defs := map[string][]string{}
 	for rows.Next() {
-		var tableSchema, constraintName, tableName, columnName, foreignTableSchema, foreignTableName, foreignColumnName, foreignUpdateRule, foreignDeleteRule string
-		err = rows.Scan(&tableSchema, &constraintName, &tableName, &columnName, &foreignTableSchema, &foreignTableName, &foreignColumnName, &foreignUpdateRule, &foreignDeleteRule)
+		var key, tableSchema, constraintName, tableName, columnName, foreignTableSchema, foreignTableName, foreignColumnName, foreignUpdateRule, foreignDeleteRule string
+		err = rows.Scan(&key, &tableSchema, &constraintName, &tableName, &columnName, &foreignTableSchema, &foreignTableName, &foreignColumnName, &foreignUpdateRule, &foreignDeleteRule)
 		if err != nil {
 			return nil, err
 		}
@@ -513,9 +682,86 @@ WHERE constraint_type = 'FOREIGN KEY' AND tc.table_schema=$1 AND tc.table_name=$
 			"ALTER TABLE ONLY %s.%s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s.%s(%s) ON UPDATE %s ON DELETE %s",
 			tableSchema, tableName, constraintName, columnName, foreignTableSchema, foreignTableName, foreignColumnName, foreignUpdateRule, foreignDeleteRule,
 		)
-		defs = append(defs, def)
+		defs[names[key]] = append(defs[names[key]], def)
 	}
-	return defs, nil
+	return defs, rows.Err()
+}
+
+// Same as getPrimaryKeyColumns, but by pg_catalog which shows constraints of tables the user doesn't own
+func (d *PostgresDatabase) getCatalogPrimaryKeyColumns(tables []string) (map[string][]string, error) {
+	const query = `SELECT n.nspname || '.' || c.relname, a.attname
+FROM pg_constraint con
+	JOIN pg_class c ON c.oid = con.conrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	CROSS JOIN LATERAL unnest(con.conkey) WITH ORDINALITY AS k(attnum, ordinal_position)
+	JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+WHERE con.contype = 'p' AND n.nspname || '.' || c.relname = ANY($1)
+ORDER BY n.nspname, c.relname, k.ordinal_position`
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columnNames := map[string][]string{}
+	for rows.Next() {
+		var key, columnName string
+		if err := rows.Scan(&key, &columnName); err != nil {
+			return nil, err
+		}
+		columnNames[names[key]] = append(columnNames[names[key]], columnName)
+	}
+	return columnNames, rows.Err()
+}
+
+// Actions of pg_constraint.confupdtype and confdeltype, named like information_schema.referential_constraints
+var foreignKeyActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+// Same as getForeignDefs, but by pg_catalog which shows constraints of tables the user doesn't own
+func (d *PostgresDatabase) getCatalogForeignDefs(tables []string) (map[string][]string, error) {
+	const query = `SELECT
+	n.nspname || '.' || c.relname, n.nspname, con.conname, c.relname,
+	(SELECT string_agg(a.attname, ', ' ORDER BY k.ordinal_position) FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, ordinal_position)
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum),
+	fn.nspname, fc.relname,
+	(SELECT string_agg(a.attname, ', ' ORDER BY k.ordinal_position) FROM unnest(con.confkey) WITH ORDINALITY AS k(attnum, ordinal_position)
+		JOIN pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.attnum),
+	con.confupdtype, con.confdeltype
+FROM pg_constraint con
+	JOIN pg_class c ON c.oid = con.conrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_class fc ON fc.oid = con.confrelid
+	JOIN pg_namespace fn ON fn.oid = fc.relnamespace
+WHERE con.contype = 'f' AND n.nspname || '.' || c.relname = ANY($1)
+ORDER BY n.nspname, c.relname, con.conname`
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := map[string][]string{}
+	for rows.Next() {
+		var key, tableSchema, constraintName, tableName, columnNames, foreignTableSchema, foreignTableName, foreignColumnNames, updateType, deleteType string
+		err = rows.Scan(&key, &tableSchema, &constraintName, &tableName, &columnNames, &foreignTableSchema, &foreignTableName, &foreignColumnNames, &updateType, &deleteType)
+		if err != nil {
+			return nil, err
+		}
+		def := fmt.Sprintf(
+			"ALTER TABLE ONLY %s.%s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s.%s(%s) ON UPDATE %s ON DELETE %s",
+			tableSchema, tableName, constraintName, columnNames, foreignTableSchema, foreignTableName, foreignColumnNames, foreignKeyActions[updateType], foreignKeyActions[deleteType],
+		)
+		defs[names[key]] = append(defs[names[key]], def)
+	}
+	return defs, rows.Err()
 }
 
 var (
@@ -523,22 +769,22 @@ var (
 	policyRolesSuffixRegex = regexp.MustCompile(`}$`)
 )
 
-func (d *PostgresDatabase) getPolicyDefs(table string) ([]string, error) {
-	const query = "SELECT policyname, permissive, roles, cmd, qual, with_check FROM pg_policies WHERE schemaname = $1 AND tablename = $2;"
-	schema, table := SplitTableName(table)
-	rows, err := d.db.Query(query, schema, table)
+func (d *PostgresDatabase) getPolicyDefs(tables []string) (map[string][]string, error) {
+	const query = "SELECT schemaname || '.' || tablename, tablename, policyname, permissive, roles, cmd, qual, with_check FROM pg_policies WHERE schemaname || '.' || tablename = ANY($1) ORDER BY schemaname, tablename, policyname;"
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	defs := make([]string, 0)
+	defs := map[string][]string{}
 	for rows.Next() {
 		var (
-			policyName, permissive, roles, cmd string
-			using, withCheck                   sql.NullString
+			key, table, policyName, permissive, roles, cmd string
+			using, withCheck                               sql.NullString
 		)
-		err = rows.Scan(&policyName, &permissive, &roles, &cmd, &using, &withCheck)
+		err = rows.Scan(&key, &table, &policyName, &permissive, &roles, &cmd, &using, &withCheck)
 		if err != nil {
 			return nil, err
 		}
@@ -554,15 +800,310 @@ func (d *PostgresDatabase) getPolicyDefs(table string) ([]string, error) {
 		if withCheck.Valid {
 			def += fmt.Sprintf(" WITH CHECK %s", withCheck.String)
 		}
-		defs = append(defs, def+";")
+		defs[names[key]] = append(defs[names[key]], def+";")
+	}
+	return defs, rows.Err()
+}
+
+func (d *PostgresDatabase) getCommentDefs(tables []string) (map[string][]string, error) {
+	const query = `SELECT n.nspname || '.' || c.relname, n.nspname, c.relname, a.attname, d.description FROM pg_description d
+		JOIN pg_class c ON c.oid = d.objoid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = d.objsubid AND d.objsubid > 0
+		WHERE d.classoid = 'pg_class'::regclass AND n.nspname || '.' || c.relname = ANY($1)
+		ORDER BY n.nspname, c.relname, d.objsubid;`
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := map[string][]string{}
+	for rows.Next() {
+		var key, schema, table string
+		var columnName sql.NullString // NULL for the table itself
+		var description string
+		if err := rows.Scan(&key, &schema, &table, &columnName, &description); err != nil {
+			return nil, err
+		}
+		value := "'" + strings.ReplaceAll(description, "'", "''") + "'"
+		if columnName.Valid {
+			defs[names[key]] = append(defs[names[key]], fmt.Sprintf("COMMENT ON COLUMN %s.%s.\"%s\" IS %s", schema, table, columnName.String, value))
+		} else {
+			defs[names[key]] = append(defs[names[key]], fmt.Sprintf("COMMENT ON TABLE %s.%s IS %s", schema, table, value))
+		}
+	}
+	return defs, rows.Err()
+}
+
+func (d *PostgresDatabase) ServerVersion() (string, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.version == "" {
+		if err := d.db.QueryRow("SELECT version()").Scan(&d.version); err != nil {
+			return "", err
+		}
+	}
+	return d.version, nil
+}
+
+// Report the wait event and blocking sessions of a running statement, found by its text which pg_stat_activity may truncate
+func (d *PostgresDatabase) StatementActivity(ctx context.Context, statement string) (string, error) {
+	const query = `SELECT wait_event_type, wait_event, pg_blocking_pids(pid) FROM pg_stat_activity
+		WHERE datname = current_database() AND state = 'active' AND pid <> pg_backend_pid() AND query <> '' AND position(query IN $1) = 1
+		LIMIT 1`
+	var waitEventType, waitEvent sql.NullString
+	var blockingPids pq.Int64Array
+	err := d.db.QueryRowContext(ctx, query, statement).Scan(&waitEventType, &waitEvent, &blockingPids)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	var activities []string
+	if waitEvent.Valid {
+		activities = append(activities, fmt.Sprintf("waiting for %s: %s", waitEventType.String, waitEvent.String))
+	}
+	if len(blockingPids) > 0 {
+		pids := make([]string, len(blockingPids))
+		for i, pid := range blockingPids {
+			pids[i] = strconv.FormatInt(pid, 10)
+		}
+		activities = append(activities, "blocked by PID "+strings.Join(pids, ", "))
+	}
+	return strings.Join(activities, ", "), nil
+}
+
+// With --pgbouncer, session variables are set in each transaction, which runs a DDL
+func (d *PostgresDatabase) TransactionPooling() (bool, []string) {
+	if !d.config.PgBouncer {
+		return false, nil
+	}
+	var settings []string
+	if d.config.LockTimeout != "" {
+		settings = append(settings, "SET LOCAL lock_timeout = "+pq.QuoteLiteral(d.config.LockTimeout))
+	}
+	if d.config.StatementTimeout != "" {
+		settings = append(settings, "SET LOCAL statement_timeout = "+pq.QuoteLiteral(d.config.StatementTimeout))
+	}
+	if d.config.Schema != "" {
+		settings = append(settings, "SET LOCAL search_path = "+schemaSearchPath(d.config.Schema))
+	}
+	return true, settings
+}
+
+// Key of pg_advisory_lock, which is scoped to the database
+const applyLockKey = "sqldef"
+
+// Take a session-level advisory lock on a dedicated connection. Its wait is cancelled by lock_timeout of --lock-timeout.
+func (d *PostgresDatabase) LockApply(ctx context.Context, waiting func()) (func() error, error) {
+	if d.config.PgBouncer {
+		// A session-level lock would be left on a pooled server session, blocking other clients
+		return func() error { return nil }, nil
+	}
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", applyLockKey).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		waiting()
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", applyLockKey); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", applyLockKey)
+		return err
+	}, nil
+}
+
+// Estimated by the statistics of VACUUM and ANALYZE, or -1 if the table has never been analyzed
+func (d *PostgresDatabase) EstimatedRows(table string) (int64, error) {
+	schema, name := SplitTableName(table)
+	var rows float64
+	err := d.db.QueryRow(
+		"SELECT c.reltuples FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND c.relname = $2",
+		schema, name,
+	).Scan(&rows)
+	if err == sql.ErrNoRows || rows < 0 {
+		return -1, nil
+	}
+	return int64(rows), err
+}
+
+// Including indexes and TOAST, or -1 if the table is not found
+func (d *PostgresDatabase) TableSize(table string) (int64, error) {
+	schema, name := SplitTableName(table)
+	var size int64
+	err := d.db.QueryRow(
+		"SELECT pg_total_relation_size(c.oid) FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND c.relname = $2",
+		schema, name,
+	).Scan(&size)
+	if err == sql.ErrNoRows {
+		return -1, nil
+	}
+	return size, err
+}
+
+// YugabyteDB reports a version like "PostgreSQL 11.2-YB-2.15.0.0-b0 on x86_64-pc-linux-gnu, ..."
+func (d *PostgresDatabase) isYugabyte() (bool, error) {
+	version, err := d.ServerVersion()
+	return strings.Contains(version, "-YB-"), err
+}
+
+// Greenplum reports a version like "PostgreSQL 9.4.26 (Greenplum Database 6.20.0 build commit:...) on ..."
+func (d *PostgresDatabase) isGreenplum() (bool, error) {
+	version, err := d.ServerVersion()
+	return strings.Contains(version, "Greenplum Database"), err
+}
+
+func (d *PostgresDatabase) isCitus() (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.citus == nil {
+		var citus bool
+		if err := d.db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'citus')").Scan(&citus); err != nil {
+			return false, err
+		}
+		d.citus = &citus
 	}
-	return defs, nil
+	return *d.citus, nil
+}
+
+// Return Citus' `SELECT create_distributed_table(...)` or `SELECT create_reference_table(...)` of tables.
+// Citus local tables are not exported since they're added to metadata implicitly.
+func (d *PostgresDatabase) getCitusDistributeTableDefs(tables []string) (map[string]string, error) {
+	const query = `SELECT n.nspname || '.' || c.relname, p.partmethod, p.repmodel,
+		CASE WHEN p.partmethod = 'h' THEN column_to_column_name(p.logicalrelid, p.partkey) ELSE '' END
+		FROM pg_dist_partition p
+		JOIN pg_class c ON c.oid = p.logicalrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname || '.' || c.relname = ANY($1)`
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := map[string]string{}
+	for rows.Next() {
+		var key, partitionMethod, replicationModel, distributionColumn string
+		if err := rows.Scan(&key, &partitionMethod, &replicationModel, &distributionColumn); err != nil {
+			return nil, err
+		}
+		table := names[key]
+		switch {
+		case partitionMethod == "h":
+			defs[table] = fmt.Sprintf("SELECT create_distributed_table('%s', '%s')", table, distributionColumn)
+		case partitionMethod == "n" && replicationModel == "t":
+			defs[table] = fmt.Sprintf("SELECT create_reference_table('%s')", table)
+		}
+	}
+	return defs, rows.Err()
+}
+
+// Return Greenplum's `DISTRIBUTED BY (...)`, `DISTRIBUTED RANDOMLY`, or `DISTRIBUTED REPLICATED` clauses of tables.
+func (d *PostgresDatabase) getGreenplumDistributionClauses(tables []string) (map[string]string, error) {
+	const query = `SELECT n.nspname || '.' || c.relname, pg_get_table_distributedby(c.oid)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname || '.' || c.relname = ANY($1)`
+	keys, names := catalogKeys(tables)
+	rows, err := d.db.Query(query, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clauses := map[string]string{}
+	for rows.Next() {
+		var key, distribution string
+		if err := rows.Scan(&key, &distribution); err != nil {
+			return nil, err
+		}
+		if distribution != "" {
+			clauses[names[key]] = " " + distribution
+		}
+	}
+	return clauses, rows.Err()
+}
+
+// Return YugabyteDB's `WITH (colocation = false)` and `SPLIT INTO` clauses of tables.
+func (d *PostgresDatabase) getYugabyteStorageClauses(tables []string) (map[string]string, error) {
+	properties, err := d.getYugabyteProperties(tables)
+	if err != nil {
+		return nil, err
+	}
+	clauses := map[string]string{}
+	for table, property := range properties {
+		clause := ""
+		if property.databaseColocated && !property.colocated {
+			clause = " WITH (colocation = false)"
+		}
+		clauses[table] = clause + property.splitClause()
+	}
+	return clauses, nil
+}
+
+type yugabyteProperties struct {
+	numTablets        int
+	numHashKeyColumns int
+	colocated         bool
+	databaseColocated bool
+}
+
+// Return YugabyteDB's `SPLIT INTO` clause of a hash-sharded table or index. Range-sharded ones are not supported yet.
+func (p yugabyteProperties) splitClause() string {
+	if p.colocated || p.numHashKeyColumns == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" SPLIT INTO %d TABLETS", p.numTablets)
+}
+
+// Return yb_table_properties of tables or indexes
+func (d *PostgresDatabase) getYugabyteProperties(relations []string) (map[string]yugabyteProperties, error) {
+	const query = `SELECT n.nspname || '.' || c.relname, p.num_tablets, p.num_hash_key_columns, p.is_colocated, yb_is_database_colocated()
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		CROSS JOIN LATERAL yb_table_properties(c.oid) p
+		WHERE n.nspname || '.' || c.relname = ANY($1)`
+	keys, names := catalogKeys(relations)
+	rows, err := d.db.Query(query, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := map[string]yugabyteProperties{}
+	for rows.Next() {
+		var key string
+		var property yugabyteProperties
+		if err := rows.Scan(&key, &property.numTablets, &property.numHashKeyColumns, &property.colocated, &property.databaseColocated); err != nil {
+			return nil, err
+		}
+		properties[names[key]] = property
+	}
+	return properties, rows.Err()
 }
 
 func (d *PostgresDatabase) DB() *sql.DB {
 	return d.db
 }
 
+func (d *PostgresDatabase) DumpConcurrency() int {
+	return d.config.DumpConcurrency
+}
+
 func (d *PostgresDatabase) Close() error {
 	return d.db.Close()
 }
@@ -587,10 +1128,56 @@ func postgresBuildDSN(config adapter.Config) string {
 		options = append(options, fmt.Sprintf("sslrootcert=%s", sslrootcert))
 	}
 
+	if config.PgBouncer {
+		// Send parameters with queries instead of preparing unnamed statements, which may go to another server session.
+		// Session variables are set by SET LOCAL of TransactionPooling instead, since PgBouncer rejects unknown startup parameters.
+		options = append(options, "binary_parameters=yes")
+	}
+
+	// Unknown parameters are sent to the server as session variables by lib/pq
+	if config.LockTimeout != "" && !config.PgBouncer {
+		options = append(options, fmt.Sprintf("lock_timeout=%s", url.QueryEscape(config.LockTimeout)))
+	}
+	if config.StatementTimeout != "" && !config.PgBouncer {
+		options = append(options, fmt.Sprintf("statement_timeout=%s", url.QueryEscape(config.StatementTimeout)))
+	}
+
+	if config.Schema != "" && !config.PgBouncer {
+		options = append(options, fmt.Sprintf("search_path=%s", url.QueryEscape(schemaSearchPath(config.Schema))))
+	}
+
 	// `QueryEscape` instead of `PathEscape` so that colon can be escaped.
 	return fmt.Sprintf("postgres://%s:%s@%s/%s?%s", url.QueryEscape(user), url.QueryEscape(password), host, database, strings.Join(options, "&"))
 }
 
+// Put the schema of --schema before public, which keeps extensions and shared objects visible
+func schemaSearchPath(schema string) string {
+	return pq.QuoteIdentifier(schema) + ", public"
+}
+
+// Return schemas matching the LIKE pattern of --each-schema, e.g. tenant_%
+func SchemaNames(database adapter.Database, pattern string) ([]string, error) {
+	rows, err := database.DB().Query(
+		`select nspname from pg_namespace
+		 where nspname like $1 and nspname not in ('information_schema', 'pg_catalog', 'pg_toast')
+		 order by nspname;`, pattern,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemas := []string{}
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
+
 func SplitTableName(table string) (string, string) {
 	schema := "public"
 	schemaTable := strings.SplitN(table, ".", 2)
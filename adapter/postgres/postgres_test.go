@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildDumpTableDDL(t *testing.T) {
+	columns := []column{
+		{Name: "id", dataType: "bigint"},
+		{Name: "a", dataType: "integer", Nullable: true},
+		{Name: "b", dataType: "integer", Nullable: true},
+	}
+	checkConstraints := map[string]string{}
+	uniqueConstraints := map[string]string{}
+	for i := 1; i <= 10; i++ {
+		checkConstraints[fmt.Sprintf("check_%02d", i)] = fmt.Sprintf("CHECK ((a + b) > %d)", i)
+		uniqueConstraints[fmt.Sprintf("unique_%02d", i)] = fmt.Sprintf("ALTER TABLE public.t ADD CONSTRAINT unique_%02d UNIQUE (a, b)", i)
+	}
+
+	// Maps are ranged in random orders, so a DDL dumped many times has to be the same
+	expected := buildDumpTableDDL("public.t", columns, []string{"id"}, nil, nil, nil, checkConstraints, uniqueConstraints, nil, "")
+	for i := 0; i < 20; i++ {
+		actual := buildDumpTableDDL("public.t", columns, []string{"id"}, nil, nil, nil, checkConstraints, uniqueConstraints, nil, "")
+		if actual != expected {
+			t.Fatalf("expected the same DDL %q but got %q", expected, actual)
+		}
+	}
+
+	expectedPrefix := "CREATE TABLE public.t (\n" +
+		"    \"id\" bigint NOT NULL,\n" +
+		"    \"a\" integer,\n" +
+		"    \"b\" integer,\n" +
+		"    PRIMARY KEY (\"id\"),\n" +
+		"    CONSTRAINT check_01 CHECK ((a + b) > 1),\n" +
+		"    CONSTRAINT check_02 CHECK ((a + b) > 2),\n"
+	if !strings.HasPrefix(expected, expectedPrefix) {
+		t.Errorf("expected check constraints in the order of their names, but got %q", expected)
+	}
+	expectedSuffix := "ALTER TABLE public.t ADD CONSTRAINT unique_09 UNIQUE (a, b);\n" +
+		"ALTER TABLE public.t ADD CONSTRAINT unique_10 UNIQUE (a, b);"
+	if !strings.HasSuffix(expected, expectedSuffix) {
+		t.Errorf("expected unique constraints in the order of their names, but got %q", expected)
+	}
+}
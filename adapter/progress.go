@@ -0,0 +1,132 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Interval to report a DDL which is still running with --progress
+var progressInterval = 10 * time.Second
+
+// Progress of applying DDLs, printed by --progress so that a long apply is not silent.
+// A nil *progress prints nothing. DDLs may be run concurrently by --apply-concurrency.
+type progress struct {
+	ctx     context.Context
+	out     io.Writer
+	total   int
+	start   time.Time
+	monitor StatementMonitor // nil if the database doesn't report activities of statements
+
+	mutex      sync.Mutex
+	index      int
+	slowest    time.Duration
+	slowestDDL string
+}
+
+func newProgress(ctx context.Context, out io.Writer, ddls []string, skipDrop bool, monitor StatementMonitor) *progress {
+	if out == nil || jsonLog { // "ddl" and "summary" events have durations already
+		return nil
+	}
+	total := 0
+	for _, ddl := range ddls {
+		if !skipDrop || !IsDropDDLIn(ddls, ddl) {
+			total++
+		}
+	}
+	return &progress{ctx: ctx, out: out, total: total, start: time.Now(), monitor: monitor}
+}
+
+// Run a DDL, reporting it before and after the execution, and every progressInterval while it's running
+func (p *progress) run(ddl string, exec func() error) error {
+	if p == nil {
+		return exec()
+	}
+	p.mutex.Lock()
+	p.index++
+	index := p.index
+	p.printf("-- [%d/%d] %s elapsed: %s --\n", index, p.total, roundDuration(time.Since(p.start)), summarizeDDL(ddl))
+	p.mutex.Unlock()
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				activity := p.activity(ddl)
+				p.mutex.Lock()
+				p.printf("-- [%d/%d] Still running for %s%s --\n", index, p.total, roundDuration(time.Since(start)), activity)
+				p.mutex.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+	err := exec()
+	close(done)
+
+	duration := time.Since(start)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err != nil {
+		p.printf("-- [%d/%d] Failed in %s --\n", index, p.total, roundDuration(duration))
+		return err
+	}
+	p.printf("-- [%d/%d] Done in %s --\n", index, p.total, roundDuration(duration))
+	if duration >= p.slowest {
+		p.slowest, p.slowestDDL = duration, ddl
+	}
+	return nil
+}
+
+// What a running DDL waits for, e.g. ", waiting for Lock: relation, blocked by PID 42", or "" if unknown
+func (p *progress) activity(ddl string) string {
+	if p.monitor == nil {
+		return ""
+	}
+	activity, err := p.monitor.StatementActivity(p.ctx, ddl)
+	if err != nil || activity == "" {
+		return ""
+	}
+	return ", " + activity
+}
+
+func (p *progress) printf(format string, args ...interface{}) {
+	fmt.Fprintf(p.out, format, args...)
+}
+
+func (p *progress) summarize() {
+	if p == nil || p.index == 0 {
+		return
+	}
+	fmt.Fprintf(p.out, "-- Applied %d DDLs in %s. The slowest one took %s: %s --\n",
+		p.index, roundDuration(time.Since(p.start)), roundDuration(p.slowest), summarizeDDL(p.slowestDDL))
+}
+
+func roundDuration(duration time.Duration) time.Duration {
+	if duration < time.Millisecond {
+		return duration.Round(time.Microsecond)
+	} else if duration < time.Second {
+		return duration.Round(time.Millisecond)
+	}
+	return duration.Round(100 * time.Millisecond)
+}
+
+// The first line of a DDL, shortened to fit in a line of a terminal
+func summarizeDDL(ddl string) string {
+	lines := strings.SplitN(strings.TrimSpace(ddl), "\n", 2)
+	summary := []rune(strings.TrimSpace(lines[0]))
+	if len(summary) > 80 {
+		return string(summary[:77]) + "..."
+	}
+	if len(lines) > 1 {
+		return string(summary) + " ..."
+	}
+	return string(summary)
+}
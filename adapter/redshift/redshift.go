@@ -0,0 +1,338 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/adapter/postgres"
+	_ "github.com/lib/pq"
+)
+
+const indent = "    "
+
+// Redshift is forked from PostgreSQL 8.0, so it lacks many catalogs which the postgres adapter relies on,
+// and it has distribution styles, sort keys, and compression encodings instead of indexes.
+type RedshiftDatabase struct {
+	config adapter.Config
+	db     *sql.DB
+}
+
+func NewDatabase(config adapter.Config) (adapter.Database, error) {
+	db, err := adapter.OpenDB(config, "postgres", redshiftBuildDSN(config))
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedshiftDatabase{
+		db:     db,
+		config: config,
+	}, nil
+}
+
+func (d *RedshiftDatabase) TableNames() ([]string, error) {
+	rows, err := d.db.Query(
+		`select schemaname, tablename from pg_tables
+		 where schemaname not in ('information_schema', 'pg_catalog', 'pg_internal', 'pg_automv')
+		 and schemaname not like 'pg_temp_%';`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []string{}
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, schema+"."+name)
+	}
+	return tables, nil
+}
+
+var (
+	suffixSemicolon = regexp.MustCompile(`;$`)
+	spaces          = regexp.MustCompile(`[ ]+`)
+)
+
+func (d *RedshiftDatabase) Views() ([]string, error) {
+	rows, err := d.db.Query(
+		`select schemaname, viewname, definition from pg_views
+		 where schemaname not in ('information_schema', 'pg_catalog', 'pg_internal', 'pg_automv');`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ddls []string
+	for rows.Next() {
+		var schema, name, definition string
+		if err := rows.Scan(&schema, &name, &definition); err != nil {
+			return nil, err
+		}
+		definition = strings.TrimSpace(definition)
+		definition = strings.ReplaceAll(definition, "\n", "")
+		definition = suffixSemicolon.ReplaceAllString(definition, "")
+		definition = spaces.ReplaceAllString(definition, " ")
+		ddls = append(
+			ddls, fmt.Sprintf(
+				"CREATE VIEW %s AS %s;", schema+"."+name, definition,
+			),
+		)
+	}
+	return ddls, nil
+}
+
+// Redshift supports neither triggers nor user-defined types
+func (d *RedshiftDatabase) Triggers() ([]string, error) {
+	return nil, nil
+}
+
+func (d *RedshiftDatabase) Types() ([]string, error) {
+	return nil, nil
+}
+
+func (d *RedshiftDatabase) DumpTableDDL(table string) (string, error) {
+	cols, err := d.getColumns(table)
+	if err != nil {
+		return "", err
+	}
+	distStyle, err := d.getDistStyle(table)
+	if err != nil {
+		return "", err
+	}
+	pkeyCols, err := d.getPrimaryKeyColumns(table)
+	if err != nil {
+		return "", err
+	}
+	foreignDefs, err := d.getForeignDefs(table)
+	if err != nil {
+		return "", err
+	}
+	return buildDumpTableDDL(table, cols, distStyle, pkeyCols, foreignDefs), nil
+}
+
+func buildDumpTableDDL(table string, columns []column, distStyle string, pkeyCols, foreignDefs []string) string {
+	var queryBuilder strings.Builder
+	fmt.Fprintf(&queryBuilder, "CREATE TABLE %s (", table)
+	distKey := ""
+	sortStyle := "COMPOUND"
+	sortKeys := make([]string, len(columns))
+	for i, col := range columns {
+		if i > 0 {
+			fmt.Fprint(&queryBuilder, ",")
+		}
+		fmt.Fprint(&queryBuilder, "\n"+indent)
+		fmt.Fprintf(&queryBuilder, "\"%s\" %s", col.Name, col.DataType)
+		if col.NotNull {
+			fmt.Fprint(&queryBuilder, " NOT NULL")
+		}
+		if col.Default != "" {
+			fmt.Fprintf(&queryBuilder, " DEFAULT %s", col.Default)
+		}
+		if col.Encoding != "" && col.Encoding != "none" {
+			fmt.Fprintf(&queryBuilder, " ENCODE %s", col.Encoding)
+		}
+		if col.DistKey {
+			distKey = col.Name
+		}
+		// A negative order means an interleaved sort key
+		sortKeyOrder := col.SortKeyOrder
+		if sortKeyOrder < 0 {
+			sortStyle = "INTERLEAVED"
+			sortKeyOrder = -sortKeyOrder
+		}
+		if sortKeyOrder > 0 && sortKeyOrder <= len(columns) {
+			sortKeys[sortKeyOrder-1] = fmt.Sprintf("\"%s\"", col.Name)
+		}
+	}
+	if len(pkeyCols) > 0 {
+		fmt.Fprint(&queryBuilder, ",\n"+indent)
+		fmt.Fprintf(&queryBuilder, "PRIMARY KEY (\"%s\")", strings.Join(pkeyCols, "\", \""))
+	}
+	fmt.Fprint(&queryBuilder, "\n)")
+
+	if distStyle != "" {
+		fmt.Fprintf(&queryBuilder, "\nDISTSTYLE %s", distStyle)
+	}
+	if distKey != "" {
+		fmt.Fprintf(&queryBuilder, "\nDISTKEY (\"%s\")", distKey)
+	}
+	var orderedSortKeys []string
+	for _, sortKey := range sortKeys {
+		if sortKey != "" {
+			orderedSortKeys = append(orderedSortKeys, sortKey)
+		}
+	}
+	if len(orderedSortKeys) > 0 {
+		fmt.Fprintf(&queryBuilder, "\n%s SORTKEY (%s)", sortStyle, strings.Join(orderedSortKeys, ", "))
+	}
+
+	fmt.Fprint(&queryBuilder, ";\n")
+	for _, v := range foreignDefs {
+		fmt.Fprintf(&queryBuilder, "%s;\n", v)
+	}
+	return strings.TrimSuffix(queryBuilder.String(), "\n")
+}
+
+type column struct {
+	Name         string
+	DataType     string
+	NotNull      bool
+	Default      string
+	Encoding     string
+	DistKey      bool
+	SortKeyOrder int
+}
+
+// This reads pg_attribute directly because pg_table_def shows only tables in search_path.
+func (d *RedshiftDatabase) getColumns(table string) ([]column, error) {
+	const query = `SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull, format_encoding(a.attencodingtype::integer),
+	a.attisdistkey, a.attsortkeyord, pg_get_expr(ad.adbin, ad.adrelid)
+	FROM pg_attribute a
+	JOIN pg_class c ON c.oid = a.attrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+	WHERE n.nspname = $1 AND c.relname = $2 AND a.attnum > 0 AND NOT a.attisdropped
+	ORDER BY a.attnum;`
+
+	schema, table := postgres.SplitTableName(table)
+	rows, err := d.db.Query(query, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := make([]column, 0)
+	for rows.Next() {
+		col := column{}
+		var colDefault *string
+		err = rows.Scan(&col.Name, &col.DataType, &col.NotNull, &col.Encoding, &col.DistKey, &col.SortKeyOrder, &colDefault)
+		if err != nil {
+			return nil, err
+		}
+		// IDENTITY columns have an internal default expression, which cannot be given to CREATE TABLE.
+		if colDefault != nil && !strings.HasPrefix(*colDefault, `"identity"(`) {
+			col.Default = *colDefault
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// AUTO distribution styles are omitted since they are the default.
+func (d *RedshiftDatabase) getDistStyle(table string) (string, error) {
+	const query = `SELECT c.reldiststyle FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relname = $2;`
+
+	schema, table := postgres.SplitTableName(table)
+	var distStyle int
+	if err := d.db.QueryRow(query, schema, table).Scan(&distStyle); err != nil {
+		return "", err
+	}
+	switch distStyle {
+	case 0:
+		return "EVEN", nil
+	case 1:
+		return "KEY", nil
+	case 8:
+		return "ALL", nil
+	default:
+		return "", nil
+	}
+}
+
+func (d *RedshiftDatabase) getPrimaryKeyColumns(table string) ([]string, error) {
+	const query = `SELECT kcu.column_name
+FROM
+	information_schema.table_constraints AS tc
+	JOIN information_schema.key_column_usage AS kcu
+		ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema AND tc.table_name = kcu.table_name
+WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema=$1 AND tc.table_name=$2 ORDER BY kcu.ordinal_position`
+	schema, table := postgres.SplitTableName(table)
+	rows, err := d.db.Query(query, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columnNames := make([]string, 0)
+	for rows.Next() {
+		var columnName string
+		if err := rows.Scan(&columnName); err != nil {
+			return nil, err
+		}
+		columnNames = append(columnNames, columnName)
+	}
+	return columnNames, nil
+}
+
+func (d *RedshiftDatabase) getForeignDefs(table string) ([]string, error) {
+	const query = `SELECT
+	tc.table_schema, tc.constraint_name, tc.table_name, kcu.column_name,
+	ccu.table_schema AS foreign_table_schema,
+	ccu.table_name AS foreign_table_name,
+	ccu.column_name AS foreign_column_name
+FROM
+	information_schema.table_constraints AS tc
+	JOIN information_schema.key_column_usage AS kcu
+		ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+	JOIN information_schema.constraint_column_usage AS ccu
+		ON tc.constraint_name = ccu.constraint_name AND tc.table_schema = ccu.constraint_schema
+WHERE constraint_type = 'FOREIGN KEY' AND tc.table_schema=$1 AND tc.table_name=$2`
+	schema, table := postgres.SplitTableName(table)
+	rows, err := d.db.Query(query, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := make([]string, 0)
+	for rows.Next() {
+		var tableSchema, constraintName, tableName, columnName, foreignTableSchema, foreignTableName, foreignColumnName string
+		err = rows.Scan(&tableSchema, &constraintName, &tableName, &columnName, &foreignTableSchema, &foreignTableName, &foreignColumnName)
+		if err != nil {
+			return nil, err
+		}
+		// Foreign keys are informational in Redshift, so referential actions are not available.
+		def := fmt.Sprintf(
+			"ALTER TABLE %s.%s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s.%s(%s)",
+			tableSchema, tableName, constraintName, columnName, foreignTableSchema, foreignTableName, foreignColumnName,
+		)
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+func (d *RedshiftDatabase) DB() *sql.DB {
+	return d.db
+}
+
+func (d *RedshiftDatabase) DumpConcurrency() int {
+	return d.config.DumpConcurrency
+}
+
+func (d *RedshiftDatabase) Close() error {
+	return d.db.Close()
+}
+
+func redshiftBuildDSN(config adapter.Config) string {
+	var options []string
+	if sslmode, ok := os.LookupEnv("PGSSLMODE"); ok {
+		options = append(options, fmt.Sprintf("sslmode=%s", sslmode))
+	}
+	if sslrootcert, ok := os.LookupEnv("PGSSLROOTCERT"); ok {
+		options = append(options, fmt.Sprintf("sslrootcert=%s", sslrootcert))
+	}
+
+	// `QueryEscape` instead of `PathEscape` so that colon can be escaped.
+	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?%s", url.QueryEscape(config.User), url.QueryEscape(config.Password), config.Host, config.Port, config.DbName, strings.Join(options, "&"))
+}
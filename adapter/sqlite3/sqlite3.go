@@ -13,7 +13,7 @@ type Sqlite3Database struct {
 }
 
 func NewDatabase(config adapter.Config) (adapter.Database, error) {
-	db, err := sql.Open("sqlite3", config.DbName)
+	db, err := adapter.OpenDB(config, "sqlite3", config.DbName)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +65,7 @@ func (d *Sqlite3Database) Views() ([]string, error) {
 		if err = rows.Scan(&sql); err != nil {
 			return nil, err
 		}
-		ddls = append(ddls, sql + ";")
+		ddls = append(ddls, sql+";")
 	}
 
 	return ddls, nil
@@ -0,0 +1,73 @@
+package sqldef
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// The library API to embed sqldef in services and operators instead of running the commands.
+// Unlike Run, these functions return errors instead of exiting the process, and print nothing unless it's asked.
+
+// A DDL generated by Diff
+type Statement struct {
+	SQL         string
+	Operation   string // e.g. CREATE TABLE, ALTER TABLE, DROP INDEX
+	Object      string // e.g. a table name for ALTER TABLE, and an index name for DROP INDEX
+	Destructive bool   // e.g. DROP TABLE and DROP COLUMN, which are applied only with ApplyOptions.EnableDrop
+}
+
+// Options of Apply, which correspond to the command-line options of the same names
+type ApplyOptions struct {
+	EnableDrop    bool
+	NoTransaction bool
+	BeforeApply   string
+	AfterApply    string
+	Concurrency   int // apply DDLs of independent tables on up to the number of connections, without a transaction
+	Retry         adapter.Retry
+	Output        io.Writer // applied DDLs are printed like the commands do if it's not nil
+	Progress      io.Writer // progress of each DDL is printed like --progress if it's not nil
+}
+
+// Return the current schema of the database in the form of --export, to give it to Diff.
+// Tables are dumped unless skipTable returns true, and skipTable may be nil.
+func Export(db adapter.Database, skipTable func(table string) bool) (string, error) {
+	return adapter.DumpDDLs(db, skipTable)
+}
+
+// Return DDLs to make the current schema SQL the desired one. The config can be a zero value,
+// or built by schema.ParseGeneratorConfig for --target-table and --skip-table.
+func Diff(desired string, current string, generatorMode schema.GeneratorMode, config schema.GeneratorConfig) ([]Statement, error) {
+	ddls, err := schema.GenerateIdempotentDDLs(generatorMode, desired, current, config)
+	if err != nil {
+		return nil, err
+	}
+	statements := []Statement{}
+	for _, ddl := range ddls {
+		planned := describeDDL(ddl, false)
+		statements = append(statements, Statement{
+			SQL:         ddl,
+			Operation:   planned.Operation,
+			Object:      planned.Object,
+			Destructive: adapter.IsDropDDLIn(ddls, ddl),
+		})
+	}
+	return statements, nil
+}
+
+// Apply DDLs returned by Diff to the database in a transaction, skipping destructive ones unless EnableDrop is set.
+// When ctx is cancelled, the running DDL is cancelled, and *adapter.InterruptedError is returned.
+func Apply(ctx context.Context, db adapter.Database, statements []Statement, options ApplyOptions) error {
+	ddls := []string{}
+	for _, statement := range statements {
+		ddls = append(ddls, statement.SQL)
+	}
+	out := options.Output
+	if out == nil {
+		out = ioutil.Discard
+	}
+	return adapter.RunDDLs(ctx, db, ddls, !options.EnableDrop, options.BeforeApply, options.AfterApply, options.NoTransaction, options.Concurrency, options.Retry, out, options.Progress, nil)
+}
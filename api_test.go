@@ -0,0 +1,211 @@
+package sqldef
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/k0kubun/sqldef/schema"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// A database which returns fixed DDLs, and applies DDLs to an in-memory SQLite3 database
+type fakeDatabase struct {
+	tables map[string]string
+	order  []string
+	views  []string
+	db     *sql.DB
+	err    error
+}
+
+func (d *fakeDatabase) TableNames() ([]string, error) {
+	return d.order, d.err
+}
+
+func (d *fakeDatabase) DumpTableDDL(table string) (string, error) {
+	ddl, ok := d.tables[table]
+	if !ok {
+		return "", errors.New("unknown table: " + table)
+	}
+	return ddl, nil
+}
+
+func (d *fakeDatabase) Views() ([]string, error) {
+	return d.views, nil
+}
+
+func (d *fakeDatabase) Triggers() ([]string, error) {
+	return nil, nil
+}
+
+func (d *fakeDatabase) Types() ([]string, error) {
+	return nil, nil
+}
+
+func (d *fakeDatabase) DB() *sql.DB {
+	return d.db
+}
+
+func (d *fakeDatabase) Close() error {
+	if d.db == nil {
+		return nil
+	}
+	return d.db.Close()
+}
+
+func TestExport(t *testing.T) {
+	db := &fakeDatabase{
+		tables: map[string]string{
+			"users": "CREATE TABLE users (id integer);",
+			"posts": "CREATE TABLE posts (id integer);",
+		},
+		order: []string{"users", "posts"},
+		views: []string{"CREATE VIEW v AS SELECT id FROM users;"},
+	}
+
+	tests := []struct {
+		name      string
+		skipTable func(table string) bool
+		expected  string
+	}{
+		{
+			name:     "all tables",
+			expected: "CREATE TABLE users (id integer);\n\nCREATE TABLE posts (id integer);\n\nCREATE VIEW v AS SELECT id FROM users;",
+		},
+		{
+			name:      "skipped table",
+			skipTable: func(table string) bool { return table == "users" },
+			expected:  "CREATE TABLE posts (id integer);\n\nCREATE VIEW v AS SELECT id FROM users;",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := Export(db, test.skipTable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %q but got %q", test.expected, actual)
+			}
+		})
+	}
+
+	_, err := Export(&fakeDatabase{err: errors.New("permission denied")}, nil)
+	if err == nil || err.Error() != "permission denied" {
+		t.Errorf("expected the error of TableNames but got %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		desired  string
+		current  string
+		expected []Statement
+	}{
+		{
+			name:     "nothing modified",
+			desired:  "CREATE TABLE users (id integer);",
+			current:  "CREATE TABLE users (id integer);",
+			expected: []Statement{},
+		},
+		{
+			name:    "statements split per DDL",
+			desired: "CREATE TABLE users (id integer, name text);\nCREATE TABLE posts (id integer);\nCREATE INDEX index_name ON users (name);",
+			current: "CREATE TABLE users (id integer);",
+			expected: []Statement{
+				{SQL: "ALTER TABLE `users` ADD COLUMN `name` text AFTER `id`", Operation: "ALTER TABLE", Object: "users"},
+				{SQL: "CREATE TABLE posts (id integer)", Operation: "CREATE TABLE", Object: "posts"},
+				{SQL: "CREATE INDEX index_name ON users (name)", Operation: "CREATE INDEX", Object: "index_name"},
+			},
+		},
+		{
+			name:    "destructive statements",
+			desired: "CREATE TABLE users (id integer);",
+			current: "CREATE TABLE users (id integer, name text);\nCREATE TABLE posts (id integer);",
+			expected: []Statement{
+				{SQL: "ALTER TABLE `users` DROP COLUMN `name`", Operation: "ALTER TABLE", Object: "users", Destructive: true},
+				{SQL: "DROP TABLE `posts`", Operation: "DROP TABLE", Object: "posts", Destructive: true},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := Diff(test.desired, test.current, schema.GeneratorModeMysql, schema.GeneratorConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %#v but got %#v", test.expected, actual)
+			}
+		})
+	}
+
+	if _, err := Diff("CREATE TABLUE users (id integer);", "", schema.GeneratorModeMysql, schema.GeneratorConfig{}); err == nil {
+		t.Error("expected an error of the unparsable desired schema")
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		enableDrop bool
+		expected   []string
+	}{
+		{
+			name:     "destructive statements skipped",
+			expected: []string{"posts", "users"},
+		},
+		{
+			name:       "destructive statements applied with EnableDrop",
+			enableDrop: true,
+			expected:   []string{"users"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sqlDB, err := sql.Open("sqlite3", ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sqlDB.SetMaxOpenConns(1) // each connection opens another in-memory database
+			db := &fakeDatabase{db: sqlDB}
+			defer db.Close()
+			if _, err := sqlDB.Exec("CREATE TABLE posts (id integer);"); err != nil {
+				t.Fatal(err)
+			}
+
+			statements, err := Diff("CREATE TABLE users (id integer);", "CREATE TABLE posts (id integer);", schema.GeneratorModeSQLite3, schema.GeneratorConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			if err := Apply(context.Background(), db, statements, ApplyOptions{EnableDrop: test.enableDrop, Output: &out}); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), "CREATE TABLE users (id integer);") {
+				t.Errorf("expected applied DDLs in the output but got %q", out.String())
+			}
+
+			rows, err := sqlDB.Query("SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			tables := []string{}
+			for rows.Next() {
+				var table string
+				if err := rows.Scan(&table); err != nil {
+					t.Fatal(err)
+				}
+				tables = append(tables, table)
+			}
+			if !reflect.DeepEqual(tables, test.expected) {
+				t.Errorf("expected tables %v but got %v", test.expected, tables)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package sqldef
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Date and time printed by log.Fatal
+var logTimestampRegexp = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+
+// A JSON line appended to --audit-log for each run, as evidence of schema changes kept outside of the database
+type auditRecord struct {
+	Time       string   `json:"time"`
+	Target     string   `json:"target"`
+	Operator   string   `json:"operator"`
+	Plan       []string `json:"plan"`    // DDLs to apply, excluding skipped ones
+	Outcome    string   `json:"outcome"` // "unchanged", "planned" for a dry run, "applied", or "failed"
+	Error      string   `json:"error,omitempty"`
+	DurationMs float64  `json:"duration_ms"`
+}
+
+// A nil *auditLog records nothing
+type auditLog struct {
+	path    string
+	start   time.Time
+	record  auditRecord
+	dryRun  bool
+	applied bool
+	written bool
+}
+
+// Start recording a run. A failure given to log.Fatal is recorded as well, before the process exits.
+func startAuditLog(path string, target string) *auditLog {
+	a := &auditLog{
+		path:   path,
+		start:  time.Now(),
+		record: auditRecord{Target: target, Operator: currentUser(), Plan: []string{}},
+	}
+	log.SetOutput(io.MultiWriter(auditErrorWriter{audit: a}, log.Writer()))
+	return a
+}
+
+func (a *auditLog) plan(ddls []string, skipDrop bool, dryRun bool) {
+	if a != nil {
+		a.record.Plan = appliedDDLs(ddls, skipDrop)
+		a.dryRun = dryRun
+	}
+}
+
+func (a *auditLog) succeeded() {
+	if a != nil {
+		a.applied = true
+	}
+}
+
+// Append the record, which is done only once for a run
+func (a *auditLog) finish() {
+	if a == nil || a.written {
+		return
+	}
+	a.written = true
+	if a.record.Outcome == "" {
+		switch {
+		case len(a.record.Plan) == 0:
+			a.record.Outcome = "unchanged"
+		case a.applied:
+			a.record.Outcome = "applied"
+		case a.dryRun:
+			a.record.Outcome = "planned"
+		default:
+			a.record.Outcome = "failed"
+		}
+	}
+	a.record.Time = a.start.UTC().Format(time.RFC3339)
+	a.record.DurationMs = float64(time.Since(a.start).Microseconds()) / 1000
+	if err := a.append(); err != nil {
+		fmt.Fprintf(os.Stderr, "-- WARNING: Failed to append to --audit-log '%s': %s --\n", a.path, err)
+	}
+}
+
+func (a *auditLog) append() error {
+	line, err := json.Marshal(a.record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Record a message of log.Fatal as the error of a failed run
+type auditErrorWriter struct {
+	audit *auditLog
+}
+
+func (w auditErrorWriter) Write(p []byte) (int, error) {
+	w.audit.record.Outcome = "failed"
+	w.audit.record.Error = logTimestampRegexp.ReplaceAllString(strings.TrimSuffix(string(p), "\n"), "")
+	w.audit.finish()
+	return len(p), nil
+}
@@ -0,0 +1,190 @@
+// Integration test of cockroachdef command.
+//
+// Test requirement:
+//   - go command
+//   - `psql -Uroot -p26257` must succeed against an insecure CockroachDB node
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/adapter/cockroach"
+	"github.com/k0kubun/sqldef/cmd/testutils"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+const (
+	applyPrefix     = "-- Apply --\n"
+	nothingModified = "-- Nothing is modified --\n"
+	database        = "cockroachdef_test"
+)
+
+func TestApply(t *testing.T) {
+	tests, err := testutils.ReadTests("tests.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resetTestDatabase()
+			db, err := connectDatabase()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			testutils.RunTest(t, db, test, schema.GeneratorModeCockroach, "")
+		})
+	}
+}
+
+func TestCockroachdefApply(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := stripHeredoc(`
+		CREATE TABLE users (
+		  id bigint NOT NULL,
+		  name text,
+		  PRIMARY KEY ("id")
+		);
+		`,
+	)
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+	assertApplyOutput(t, createTable, nothingModified)
+}
+
+func TestCockroachdefDryRun(t *testing.T) {
+	resetTestDatabase()
+	writeFile("schema.sql", stripHeredoc(`
+	    CREATE TABLE users (
+	        id bigint NOT NULL PRIMARY KEY,
+	        age integer
+	    );
+	    `,
+	))
+
+	dryRun := assertedExecute(t, "./cockroachdef", database, "--dry-run", "--file", "schema.sql")
+	apply := assertedExecute(t, "./cockroachdef", database, "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, dryRun, strings.Replace(apply, "Apply", "dry run", 1))
+}
+
+func TestCockroachdefExportEnums(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("psql", "-Uroot", "-h", "127.0.0.1", "-p", "26257", database, "-c", stripHeredoc(`
+		CREATE TYPE status AS ENUM ('in progress', 'won''t do');
+		CREATE SCHEMA other;
+		CREATE TYPE other.status AS ENUM ('done');
+		`,
+	))
+
+	// Labels with spaces or quotes are kept, and types of the same name in other schemas are not merged
+	out := assertedExecute(t, "./cockroachdef", database, "--export")
+	for _, expected := range []string{
+		"CREATE TYPE status AS ENUM ('in progress', 'won''t do');",
+		"CREATE TYPE other.status AS ENUM ('done');",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected '%s' in the export, but got '%s'", expected, out)
+		}
+	}
+}
+
+func TestCockroachdefHelp(t *testing.T) {
+	_, err := execute("./cockroachdef", "--help")
+	if err != nil {
+		t.Errorf("failed to run --help: %s", err)
+	}
+
+	out, err := execute("./cockroachdef")
+	if err == nil {
+		t.Errorf("no database must be error, but successfully got: %s", out)
+	}
+}
+
+func TestMain(m *testing.M) {
+	if _, ok := os.LookupEnv("PGSSLMODE"); !ok {
+		os.Setenv("PGSSLMODE", "disable")
+	}
+
+	resetTestDatabase()
+	mustExecute("go", "build")
+	status := m.Run()
+	_ = os.Remove("cockroachdef")
+	_ = os.Remove("schema.sql")
+	os.Exit(status)
+}
+
+func assertApplyOutput(t *testing.T, schema string, expected string) {
+	t.Helper()
+	writeFile("schema.sql", schema)
+	actual := assertedExecute(t, "./cockroachdef", database, "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, actual, expected)
+}
+
+func mustExecute(command string, args ...string) {
+	out, err := execute(command, args...)
+	if err != nil {
+		log.Printf("failed to execute '%s %s': `%s`", command, strings.Join(args, " "), out)
+		log.Fatal(err)
+	}
+}
+
+func assertedExecute(t *testing.T, command string, args ...string) string {
+	t.Helper()
+	out, err := execute(command, args...)
+	if err != nil {
+		t.Errorf("failed to execute '%s %s' (error: '%s'): `%s`", command, strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+func assertEquals(t *testing.T, actual string, expected string) {
+	t.Helper()
+	if expected != actual {
+		t.Errorf("expected '%s' but got '%s'", expected, actual)
+	}
+}
+
+func execute(command string, args ...string) (string, error) {
+	cmd := exec.Command(command, args...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func resetTestDatabase() {
+	mustExecute("psql", "-Uroot", "-h", "127.0.0.1", "-p", "26257", "-c", fmt.Sprintf("DROP DATABASE IF EXISTS %s CASCADE;", database))
+	mustExecute("psql", "-Uroot", "-h", "127.0.0.1", "-p", "26257", "-c", fmt.Sprintf("CREATE DATABASE %s;", database))
+}
+
+func writeFile(path string, content string) {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	file.Write(([]byte)(content))
+}
+
+func stripHeredoc(heredoc string) string {
+	heredoc = strings.TrimPrefix(heredoc, "\n")
+	re := regexp.MustCompilePOSIX("^\t*")
+	return re.ReplaceAllLiteralString(heredoc, "")
+}
+
+func connectDatabase() (adapter.Database, error) {
+	return cockroach.NewDatabase(adapter.Config{
+		User:   "root",
+		Host:   "127.0.0.1",
+		Port:   26257,
+		DbName: database,
+	})
+}
@@ -191,7 +191,7 @@ func TestMssqldefCreateView(t *testing.T) {
 	assertApplyOutput(t, createTable+createView, applyPrefix+dropView+createView)
 	assertApplyOutput(t, createTable+createView, nothingModified)
 
-	assertApplyOutput(t, "", applyPrefix+"DROP TABLE [dbo].[users];\n"+dropView)
+	assertApplyOutput(t, "", applyPrefix+dropView+"DROP TABLE [dbo].[users];\n")
 }
 
 func TestMssqldefTrigger(t *testing.T) {
@@ -1020,8 +1020,25 @@ func TestMssqldefSkipDrop(t *testing.T) {
 	writeFile("schema.sql", "")
 
 	skipDrop := assertedExecute(t, "./mssqldef", "-Usa", "-PPassw0rd", "mssqldef_test", "--skip-drop", "--file", "schema.sql")
-	apply := assertedExecute(t, "./mssqldef", "-Usa", "-PPassw0rd", "mssqldef_test", "--file", "schema.sql")
-	assertEquals(t, skipDrop, strings.Replace(apply, "DROP", "-- Skipped: DROP", 1))
+	apply := assertedExecute(t, "./mssqldef", "-Usa", "-PPassw0rd", "mssqldef_test", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, skipDrop, strings.Replace(apply, "DROP", "-- Skipped: DROP", 1)+"-- Skipped destructive DDLs: 1 --\n")
+}
+
+func TestMssqldefEnableDrop(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlcmd", "-Usa", "-PPassw0rd", "-dmssqldef_test", "-Q", stripHeredoc(`
+		CREATE TABLE users (
+		    id integer NOT NULL PRIMARY KEY,
+		    age integer
+		);`,
+	))
+
+	writeFile("schema.sql", "")
+
+	skipped := assertedExecute(t, "./mssqldef", "-Usa", "-PPassw0rd", "mssqldef_test", "--file", "schema.sql")
+	assertEquals(t, skipped, applyPrefix+"-- Skipped: DROP TABLE [dbo].[users];\n-- Skipped destructive DDLs: 1 --\n")
+	dropped := assertedExecute(t, "./mssqldef", "-Usa", "-PPassw0rd", "mssqldef_test", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, dropped, applyPrefix+"DROP TABLE [dbo].[users];\n")
 }
 
 func TestMssqldefExport(t *testing.T) {
@@ -1084,13 +1101,13 @@ func TestMain(m *testing.M) {
 func assertApply(t *testing.T, schema string) {
 	t.Helper()
 	writeFile("schema.sql", schema)
-	assertedExecute(t, "./mssqldef", "-Usa", "-PPassw0rd", "mssqldef_test", "--file", "schema.sql")
+	assertedExecute(t, "./mssqldef", "-Usa", "-PPassw0rd", "mssqldef_test", "--file", "schema.sql", "--enable-drop")
 }
 
 func assertApplyOutput(t *testing.T, schema string, expected string) {
 	t.Helper()
 	writeFile("schema.sql", schema)
-	actual := assertedExecute(t, "./mssqldef", "-Usa", "-PPassw0rd", "mssqldef_test", "--file", "schema.sql")
+	actual := assertedExecute(t, "./mssqldef", "-Usa", "-PPassw0rd", "mssqldef_test", "--file", "schema.sql", "--enable-drop")
 	assertEquals(t, actual, expected)
 }
 
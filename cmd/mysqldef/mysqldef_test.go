@@ -6,6 +6,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -783,6 +784,26 @@ func TestMysqldefKeywordIndexColumns(t *testing.T) {
 	assertApplyOutput(t, createTable, nothingModified)
 }
 
+func TestMysqldefDescriptions(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (\n  id bigint PRIMARY KEY,\n  name text\n);\n"
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		  id bigint PRIMARY KEY,
+		  -- description: Full name, e.g. 'Jane Doe'
+		  name text
+		);
+		`,
+	))
+	apply := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql", "--descriptions")
+	assertEquals(t, apply, applyPrefix+"ALTER TABLE `users` CHANGE COLUMN `name` `name` text COMMENT 'Full name, e.g. ''Jane Doe''';\n")
+	apply = assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql", "--descriptions")
+	assertEquals(t, apply, nothingModified)
+}
+
 func TestMysqldefMysqlDoubleDashComment(t *testing.T) {
 	resetTestDatabase()
 
@@ -1028,7 +1049,7 @@ func TestMysqldefView(t *testing.T) {
 	assertApplyOutput(t, createTable+createView, applyPrefix+expected)
 	assertApplyOutput(t, createTable+createView, nothingModified)
 
-	assertApplyOutput(t, "", applyPrefix+"DROP TABLE `posts`;\nDROP TABLE `users`;\nDROP VIEW `foo`;\n")
+	assertApplyOutput(t, "", applyPrefix+"DROP VIEW `foo`;\nDROP TABLE `posts`;\nDROP TABLE `users`;\n")
 }
 
 func TestMysqldefTriggerInsert(t *testing.T) {
@@ -1285,6 +1306,104 @@ func TestMysqldefFileComparison(t *testing.T) {
 	assertEquals(t, output, nothingModified)
 }
 
+// SingleStore's clauses are not supported by MySQL, so this is tested by comparing files.
+func TestMysqldefSingleStoreTableLayout(t *testing.T) {
+	writeFile("current.sql", stripHeredoc(`
+		CREATE TABLE `+"`users`"+` (
+		  `+"`id`"+` bigint(20) NOT NULL,
+		  `+"`created_at`"+` datetime NOT NULL,
+		  PRIMARY KEY (`+"`id`"+`),
+		  SHARD KEY `+"`__SHARDKEY`"+` (`+"`id`"+`),
+		  SORT KEY `+"`__UNORDERED`"+` ()
+		) AUTOSTATS_CARDINALITY_MODE=INCREMENTAL AUTOSTATS_HISTOGRAM_MODE=CREATE AUTOSTATS_SAMPLING=ON SQL_MODE='STRICT_ALL_TABLES';
+		CREATE ROWSTORE REFERENCE TABLE countries (
+		  code char(2) NOT NULL,
+		  PRIMARY KEY (code)
+		);
+		`,
+	))
+	defer os.Remove("current.sql")
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		  id bigint(20) NOT NULL,
+		  created_at datetime NOT NULL,
+		  PRIMARY KEY (id)
+		);
+		CREATE ROWSTORE REFERENCE TABLE countries (
+		  code char(2) NOT NULL,
+		  name varchar(40),
+		  PRIMARY KEY (code)
+		);
+		`,
+	))
+	output := assertedExecute(t, "./mysqldef", "--file", "current.sql", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, output, "-- dry run --\nALTER TABLE `countries` ADD COLUMN `name` varchar(40) AFTER `code`;\n")
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		  id bigint(20) NOT NULL,
+		  created_at datetime NOT NULL,
+		  PRIMARY KEY (id),
+		  SORT KEY (created_at)
+		);
+		CREATE REFERENCE TABLE countries (
+		  code char(2) NOT NULL,
+		  PRIMARY KEY (code)
+		);
+		`,
+	))
+	output = assertedExecute(t, "./mysqldef", "--file", "current.sql", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, output, stripHeredoc(`
+		-- dry run --
+		DROP TABLE `+"`users`"+`;
+		CREATE TABLE users (
+		  id bigint(20) NOT NULL,
+		  created_at datetime NOT NULL,
+		  PRIMARY KEY (id),
+		  SORT KEY (created_at)
+		);
+		DROP TABLE `+"`countries`"+`;
+		CREATE REFERENCE TABLE countries (
+		  code char(2) NOT NULL,
+		  PRIMARY KEY (code)
+		);
+		`,
+	))
+}
+
+func TestMysqldefMariadbSequences(t *testing.T) {
+	writeFile("current.sql", stripHeredoc(`
+		CREATE SEQUENCE `+"`user_ids`"+` start with 1 minvalue 1 maxvalue 9223372036854775806 increment by 1 cache 1000 nocycle ENGINE=InnoDB;
+		CREATE SEQUENCE `+"`order_ids`"+` start with 1 minvalue 1 maxvalue 9223372036854775806 increment by 1 cache 1000 nocycle ENGINE=InnoDB;
+		CREATE SEQUENCE `+"`old_ids`"+` start with 1 minvalue 1 maxvalue 9223372036854775806 increment by 1 cache 1000 nocycle ENGINE=InnoDB;
+		CREATE TABLE `+"`users`"+` (
+		  `+"`id`"+` bigint(20) NOT NULL DEFAULT nextval(`+"`user_ids`"+`),
+		  PRIMARY KEY (`+"`id`"+`)
+		) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+		`,
+	))
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE SEQUENCE user_ids;
+		CREATE SEQUENCE order_ids INCREMENT BY 10 CACHE 100 CYCLE;
+		CREATE SEQUENCE invoice_ids START WITH 1000;
+		CREATE TABLE users (
+		  id bigint NOT NULL DEFAULT nextval(user_ids) PRIMARY KEY
+		);
+		`,
+	))
+	defer os.Remove("current.sql")
+
+	output := assertedExecute(t, "./mysqldef", "--file", "current.sql", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, output, stripHeredoc(`
+		-- dry run --
+		ALTER SEQUENCE `+"`order_ids`"+` INCREMENT BY 10 CACHE 100 CYCLE;
+		CREATE SEQUENCE invoice_ids START WITH 1000;
+		DROP SEQUENCE `+"`old_ids`"+`;
+		`,
+	))
+}
+
 func TestMysqldefApply(t *testing.T) {
 	resetTestDatabase()
 
@@ -1312,6 +1431,22 @@ func TestMysqldefDryRun(t *testing.T) {
 	assertEquals(t, dryRun, strings.Replace(apply, "Apply", "dry run", 1))
 }
 
+func TestMysqldefDesiredDatabase(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("mysql", "-uroot", "-e", "DROP DATABASE IF EXISTS mysqldef_test_desired;")
+	mustExecute("mysql", "-uroot", "-e", "CREATE DATABASE mysqldef_test_desired;")
+	defer mustExecute("mysql", "-uroot", "-e", "DROP DATABASE mysqldef_test_desired;")
+	mustExecute("mysql", "-uroot", "mysqldef_test_desired", "-e", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, name text);")
+	mustExecute("mysql", "-uroot", "mysqldef_test", "-e", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY);")
+
+	dryRun := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--desired-db", "mysqldef_test_desired", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\nALTER TABLE `users` ADD COLUMN `name` text AFTER `id`;\n")
+
+	assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--desired-db", "mysqldef_test_desired")
+	dryRun = assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--desired-db", "mysqldef_test_desired", "--dry-run")
+	assertEquals(t, dryRun, nothingModified)
+}
+
 func TestMysqldefExport(t *testing.T) {
 	resetTestDatabase()
 	out := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--export")
@@ -1328,6 +1463,27 @@ func TestMysqldefExport(t *testing.T) {
 	assertEquals(t, out, ddls)
 }
 
+func TestMysqldefExportDumpConcurrency(t *testing.T) {
+	resetTestDatabase()
+
+	var ddls []string
+	for i := 1; i <= 10; i++ {
+		ddls = append(ddls, fmt.Sprintf("CREATE TABLE t%02d (id bigint PRIMARY KEY, name varchar(40), KEY t%02d_name (name));", i, i))
+	}
+	mustExecute("mysql", "-uroot", "mysqldef_test", "-e", strings.Join(ddls, "\n"))
+
+	// Tables dumped at once are printed in the same order as the ones dumped one by one
+	expected := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--export", "--dump-concurrency=1")
+	for i := 1; i <= 10; i++ {
+		if !strings.Contains(expected, fmt.Sprintf("CREATE TABLE `t%02d`", i)) {
+			t.Fatalf("expected table t%02d in the export, but got: %s", i, expected)
+		}
+	}
+	for _, concurrency := range []string{"--dump-concurrency=3", "--dump-concurrency=16"} {
+		assertEquals(t, assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--export", concurrency), expected)
+	}
+}
+
 func TestMysqldefSkipDrop(t *testing.T) {
 	resetTestDatabase()
 	mustExecute("mysql", "-uroot", "mysqldef_test", "-e", stripHeredoc(`
@@ -1340,8 +1496,25 @@ func TestMysqldefSkipDrop(t *testing.T) {
 	writeFile("schema.sql", "")
 
 	skipDrop := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--skip-drop", "--file", "schema.sql")
-	apply := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql")
-	assertEquals(t, skipDrop, strings.Replace(apply, "DROP", "-- Skipped: DROP", 1))
+	apply := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, skipDrop, strings.Replace(apply, "DROP", "-- Skipped: DROP", 1)+"-- Skipped destructive DDLs: 1 --\n")
+}
+
+func TestMysqldefEnableDrop(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("mysql", "-uroot", "mysqldef_test", "-e", stripHeredoc(`
+		CREATE TABLE users (
+		  name varchar(40),
+		  created_at datetime NOT NULL
+		) DEFAULT CHARSET=latin1;`,
+	))
+
+	writeFile("schema.sql", "")
+
+	skipped := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql")
+	assertEquals(t, skipped, applyPrefix+"-- Skipped: DROP TABLE `users`;\n-- Skipped destructive DDLs: 1 --\n")
+	dropped := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, dropped, applyPrefix+"DROP TABLE `users`;\n")
 }
 
 func TestMysqldefSkipView(t *testing.T) {
@@ -1382,6 +1555,15 @@ func TestMysqldefBeforeApply(t *testing.T) {
 	assertEquals(t, apply, nothingModified)
 }
 
+func TestMysqldefDefaultNormalization(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("mysql", "-uroot", "mysqldef_test", "-e", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, active boolean DEFAULT true, score decimal(5,2) DEFAULT 1.5, created_at datetime DEFAULT now());")
+
+	writeFile("schema.sql", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, active boolean DEFAULT true, score decimal(5,2) DEFAULT 1.5, created_at datetime DEFAULT CURRENT_TIMESTAMP());")
+	dryRun := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql", "--dry-run")
+	assertEquals(t, dryRun, nothingModified)
+}
+
 func TestMysqldefHelp(t *testing.T) {
 	_, err := execute("./mysqldef", "--help")
 	if err != nil {
@@ -1410,22 +1592,22 @@ func TestMain(m *testing.M) {
 func assertApply(t *testing.T, schema string) {
 	t.Helper()
 	writeFile("schema.sql", schema)
-	assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql")
+	assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql", "--enable-drop")
 }
 
 func assertApplyOutput(t *testing.T, schema string, expected string) {
 	t.Helper()
 	writeFile("schema.sql", schema)
-	actual := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql")
+	actual := assertedExecute(t, "./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql", "--enable-drop")
 	assertEquals(t, actual, expected)
 }
 
 func assertApplyFailure(t *testing.T, schema string, expected string) {
 	t.Helper()
 	writeFile("schema.sql", schema)
-	actual, err := execute("./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql")
+	actual, err := execute("./mysqldef", "-uroot", "mysqldef_test", "--file", "schema.sql", "--enable-drop")
 	if err == nil {
-		t.Errorf("expected 'mysqldef -uroot mysqldef_test --file schema.sql' to fail but succeeded with: %s", actual)
+		t.Errorf("expected 'mysqldef -uroot mysqldef_test --file schema.sql --enable-drop' to fail but succeeded with: %s", actual)
 	}
 	assertEquals(t, actual, expected)
 }
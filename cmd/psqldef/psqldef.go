@@ -5,7 +5,7 @@ import (
 	"log"
 	"os"
 	"strings"
-	"syscall"
+	"time"
 
 	"github.com/k0kubun/sqldef/adapter/file"
 
@@ -14,32 +14,107 @@ import (
 	"github.com/k0kubun/sqldef/adapter"
 	"github.com/k0kubun/sqldef/adapter/postgres"
 	"github.com/k0kubun/sqldef/schema"
-	"golang.org/x/term"
 )
 
 var version string
 
-// Return parsed options and schema filename
+// Return parsed options, the connection to the desired database if given, and schema filename
 // TODO: Support `sqldef schema.sql -opt val...`
-func parseOptions(args []string) (adapter.Config, *sqldef.Options) {
+func parseOptions(args []string) (adapter.Config, *adapter.Config, *sqldef.Options) {
 	var opts struct {
-		User        string   `short:"U" long:"user" description:"PostgreSQL user name" value-name:"username" default:"postgres"`
-		Password    string   `short:"W" long:"password" description:"PostgreSQL user password, overridden by $PGPASSWORD" value-name:"password"`
-		Host        string   `short:"h" long:"host" description:"Host or socket directory to connect to the PostgreSQL server" value-name:"hostname" default:"127.0.0.1"`
-		Port        uint     `short:"p" long:"port" description:"Port used for the connection" value-name:"port" default:"5432"`
-		Prompt      bool     `long:"password-prompt" description:"Force PostgreSQL user password prompt"`
-		File        []string `short:"f" long:"file" description:"Read schema SQL from the file, rather than stdin" value-name:"filename" default:"-"`
-		DryRun      bool     `long:"dry-run" description:"Don't run DDLs but just show them"`
-		Export      bool     `long:"export" description:"Just dump the current schema to stdout"`
-		SkipDrop    bool     `long:"skip-drop" description:"Skip destructive changes such as DROP"`
-		BeforeApply string   `long:"before-apply" description:"Execute the given string before applying the regular DDLs"`
-		Help        bool     `long:"help" description:"Show this help"`
-		Version     bool     `long:"version" description:"Show this version"`
+		User               string        `short:"U" long:"user" description:"PostgreSQL user name" value-name:"username" default:"postgres"`
+		Password           string        `short:"W" long:"password" description:"PostgreSQL user password, overridden by $PGPASSWORD, or prompted without a value" value-name:"password" optional:"yes" optional-value:"\x00"`
+		Host               string        `short:"h" long:"host" description:"Host or socket directory to connect to the PostgreSQL server" value-name:"hostname" default:"127.0.0.1"`
+		Port               uint          `short:"p" long:"port" description:"Port used for the connection" value-name:"port" default:"5432"`
+		Prompt             bool          `long:"password-prompt" description:"Force PostgreSQL user password prompt"`
+		PasswordEnv        string        `long:"password-env" description:"Read the password from the environment variable" value-name:"name"`
+		PasswordFile       string        `long:"password-file" description:"Read the password from the file, e.g. a Docker secret" value-name:"path"`
+		DesiredHost        string        `long:"desired-host" description:"Host of another database whose schema is used as the desired one, instead of files (default: --host)" value-name:"hostname"`
+		DesiredPort        uint          `long:"desired-port" description:"Port of the desired database (default: --port)" value-name:"port"`
+		DesiredDb          string        `long:"desired-db" description:"Name of another database whose schema is used as the desired one, instead of files (default: db_name)" value-name:"db_name"`
+		Config             string        `long:"config" description:"Read options from the YAML file (default: sqldef.yml if it exists)" value-name:"config_file"`
+		Targets            string        `long:"targets" description:"Apply the schema to each of the databases listed in the YAML file, e.g. shards, instead of db_name" value-name:"targets_file"`
+		TargetsConcurrency int           `long:"targets-concurrency" description:"Apply the schema to targets of --targets or schemas of --each-schema at once up to the number" value-name:"count" default:"4"`
+		Schema             string        `long:"schema" description:"Only manage the schema, putting tables, views, and types of the desired schema without a schema in it" value-name:"schema_name"`
+		EachSchema         string        `long:"each-schema" description:"Apply the schema to each of schemas matching the LIKE pattern as --schema, e.g. tenant_% for a schema per tenant" value-name:"pattern"`
+		File               []string      `short:"f" long:"file" description:"Read schema SQL from the file, rather than stdin" value-name:"filename" default:"-"`
+		ExpandEnv          bool          `long:"expand-env" description:"Expand ${VAR} in the schema SQL with environment variables"`
+		Template           string        `long:"template" description:"Preprocess the schema SQL with Go's text/template, using values in the YAML or JSON file" value-name:"values_file"`
+		Env                string        `long:"env" description:"Keep sections of the schema SQL in -- sqldef:if env=name blocks for the environment" value-name:"name"`
+		Variables          []string      `long:"variable" description:"Set the psql variable to substitute :name, :'name', and :\"name\" in the schema SQL, like \\set" value-name:"name=value"`
+		DryRun             bool          `long:"dry-run" description:"Don't run DDLs but just show them"`
+		Check              bool          `long:"check" description:"Same as --dry-run, but exit with 2 when there are differences"`
+		Quiet              bool          `short:"q" long:"quiet" description:"Same as --check, but print nothing"`
+		Interactive        bool          `long:"interactive" description:"Review each DDL to apply, skip, or postpone it before applying them, like git add -p"`
+		Watch              bool          `long:"watch" description:"Compare the current schema with the desired one every --interval, reporting changes of the drift"`
+		Interval           time.Duration `long:"interval" description:"Interval of --watch" value-name:"duration" default:"10m"`
+		Webhook            string        `long:"webhook" description:"Post changes of the drift found by --watch to the URL as JSON, e.g. a Slack incoming webhook" value-name:"url"`
+		Serve              string        `long:"serve" description:"Serve the HTTP API to plan, approve, and apply schema changes on the address, like sqldef serve" value-name:"address"`
+		TokenEnv           string        `long:"token-env" description:"Read the token required by --serve to plan and apply from the environment variable" value-name:"name" default:"SQLDEF_TOKEN"`
+		ApproverTokenEnv   string        `long:"approver-token-env" description:"Read another token required by --serve to approve plans from the environment variable" value-name:"name" default:"SQLDEF_APPROVER_TOKEN"`
+		Lint               bool          `long:"lint" description:"Check the desired schema for common problems instead of applying it, exiting with 2 by --check if any"`
+		Output             string        `long:"output" description:"Format of --dry-run output, migration to write a pair of up and down migration files, or liquibase to print a Liquibase changelog" choice:"text" choice:"json" choice:"markdown" choice:"migration" choice:"liquibase" default:"text"`
+		MigrationDir       string        `long:"migration-dir" description:"Directory to write files of --output=migration" value-name:"directory"`
+		MigrationFormat    string        `long:"migration-format" description:"Naming of files of --output=migration" choice:"golang-migrate" choice:"flyway" default:"golang-migrate"`
+		NoColor            bool          `long:"no-color" description:"Don't colorize --dry-run output, which is also disabled by $NO_COLOR"`
+		Compact            bool          `long:"compact" description:"Print generated DDLs as they are, without putting each column of CREATE TABLE on its own line"`
+		QuoteIdentifiers   string        `long:"quote-identifiers" description:"Quote identifiers of generated DDLs always, only when required by auto, or never" choice:"always" choice:"auto" choice:"never" default:"always"`
+		FoldIdentifiers    bool          `long:"fold-identifiers" description:"Lower unquoted identifiers of the desired schema as PostgreSQL does, instead of comparing them as written"`
+		Impact             bool          `long:"impact" description:"Annotate --dry-run output with lock levels, table rewrites, estimated rows, sizes, and durations of DDLs"`
+		LogLevel           string        `long:"log-level" description:"Log every query with its duration to stderr with debug" choice:"info" choice:"debug" default:"info"`
+		LogFormat          string        `long:"log-format" description:"Print one JSON object per event of applying DDLs with json" choice:"text" choice:"json" default:"text"`
+		Verbose            bool          `short:"v" long:"verbose" description:"Same as --log-level=debug"`
+		Plan               string        `long:"plan" description:"Write DDLs to run and a fingerprint of the current schema to the file, instead of applying them" value-name:"plan_file"`
+		ApplyPlan          string        `long:"apply" description:"Apply DDLs in the file written by --plan, unless the current schema has changed since then" value-name:"plan_file"`
+		Rollback           string        `long:"rollback" description:"Write DDLs to revert the generated ones to the file, e.g. for an emergency revert" value-name:"rollback_file"`
+		Export             bool          `long:"export" description:"Just dump the current schema to stdout"`
+		OutputFile         string        `short:"o" long:"output-file" description:"Write the output, e.g. of --export and --dry-run, to the file instead of stdout" value-name:"file"`
+		ExportDir          string        `long:"export-dir" description:"Just dump the current schema to the directory, one file per table, view, type, and trigger" value-name:"directory"`
+		Snapshot           string        `long:"snapshot" description:"Just save the current schema to the JSON file, to be compared by --against-snapshot" value-name:"snapshot_file"`
+		AgainstSnapshot    string        `long:"against-snapshot" description:"Show DDLs against the schema saved by --snapshot instead of the database, like --dry-run" value-name:"snapshot_file"`
+		DumpConcurrency    int           `long:"dump-concurrency" description:"Dump tables at once up to the number, each using a connection" value-name:"count" default:"4"`
+		ApplyConcurrency   int           `long:"apply-concurrency" description:"Apply DDLs of independent tables at once up to the number, each using a connection, without a transaction" value-name:"count" default:"1"`
+		NoApplyLock        bool          `long:"no-apply-lock" description:"Don't take an advisory lock which makes concurrent applies to the database wait"`
+		GenerateGo         string        `long:"generate-go" description:"Print Go structs of tables in the desired schema, or the current one with --export, in the package" value-name:"package" optional:"yes" optional-value:"models"`
+		ExportFormat       string        `long:"export-format" description:"Print an ER diagram, or tables, columns, indexes, and constraints as JSON, of the desired schema or the current one with --export" choice:"mermaid" choice:"dot" choice:"json"`
+		SkipDrop           bool          `long:"skip-drop" description:"Skip destructive changes such as DROP"`
+		EnableDrop         bool          `long:"enable-drop" description:"Enable destructive changes such as DROP"`
+		MaxRisk            string        `long:"max-risk" description:"Abort when a DDL to run is riskier than the level" choice:"safe" choice:"blocking" choice:"destructive"`
+		Policy             string        `long:"policy" description:"Abort when a DDL to run is denied by the YAML file" value-name:"policy_file"`
+		Only               []string      `long:"only" description:"Only apply or show DDLs of the objects, e.g. table:users and index:index_users_on_name, or the numbers of DDLs in the plan" value-name:"kind:name"`
+		TargetTables       []string      `long:"target-table" description:"Only touch or export tables matching the regular expression" value-name:"table_name"`
+		SkipTables         []string      `long:"skip-table" description:"Never touch or export tables matching the regular expression, e.g. awsdms_.*" value-name:"table_name"`
+		Manifest           string        `long:"manifest" description:"Only manage tables and views listed in the file, which records the ones created by sqldef" value-name:"manifest_file"`
+		LockFile           string        `long:"lock-file" description:"Record fingerprints of the applied schema to the file, and warn when the database has changed since then" value-name:"lock_file"`
+		Resume             string        `long:"resume" description:"Record DDLs not applied yet to the file, and apply the ones in it instead of generating DDLs after a failure" value-name:"resume_file"`
+		History            bool          `long:"history" description:"Record applied DDLs with when, by whom, and how long they took to the sqldef_history table"`
+		AuditLog           string        `long:"audit-log" description:"Append a JSON line with the database, the operator, DDLs, and the outcome of each run to the file" value-name:"audit_log"`
+		BeforeApply        string        `long:"before-apply" description:"Execute the given string before applying the regular DDLs"`
+		AfterApply         string        `long:"after-apply" description:"Execute the given string after applying the regular DDLs"`
+		PreApplyHook       string        `long:"pre-apply-hook" description:"Run the shell command with DDLs to apply on stdin before applying them, aborting if it fails" value-name:"command"`
+		PostApplyHook      string        `long:"post-apply-hook" description:"Run the shell command with applied DDLs on stdin after applying them" value-name:"command"`
+		Progress           bool          `long:"progress" description:"Print progress of each DDL and a timing summary to stderr while applying DDLs"`
+		Summary            bool          `long:"summary" description:"Print numbers of created, altered, and dropped tables, columns, and indexes, and the estimated or actual duration after DDLs"`
+		Retry              int           `long:"retry" description:"Retry DDLs failing with lock timeouts, deadlocks, or serialization failures up to the number of times" value-name:"count"`
+		RetryWait          time.Duration `long:"retry-wait" description:"Interval of --retry" value-name:"duration" default:"5s"`
+		Timeout            time.Duration `long:"timeout" description:"Give up after the duration, cancelling the running DDL and rolling back the transaction" value-name:"duration"`
+		SafeTypeChange     bool          `long:"safe-type-change" description:"Change column types by adding a new column, backfilling it in batches, and swapping them, instead of a blocking ALTER, with --no-transaction while the table is not written"`
+		SafeNotNull        bool          `long:"safe-not-null" description:"Add NOT NULL columns without a default as nullable, backfill them by -- @backfill expression, and then set NOT NULL"`
+		Descriptions       bool          `long:"descriptions" description:"Translate -- description: comments above tables and columns into database comments"`
+		NoTransaction      bool          `long:"no-transaction" description:"Don't wrap DDLs in a transaction, e.g. for CREATE INDEX CONCURRENTLY"`
+		LockTimeout        string        `long:"lock-timeout" description:"Set lock_timeout of the session, e.g. 5s, which also limits the wait for another apply" value-name:"timeout"`
+		StatementTimeout   string        `long:"statement-timeout" description:"Set statement_timeout of the session, e.g. 1min" value-name:"timeout"`
+		Keepalive          time.Duration `long:"keepalive" description:"Interval of TCP keepalive probes, so that long DDLs are not cut as idle by load balancers, or negative to disable them (default: 15s)" value-name:"duration"`
+		PgBouncer          bool          `long:"pgbouncer" description:"Connect through PgBouncer's transaction pooling, running each DDL in its own transaction without session variables, prepared statements, or the advisory lock"`
+		LimitedPrivileges  bool          `long:"limited-privileges" description:"Skip parts of the schema which the user can't read, e.g. policies and triggers, instead of failing, such as for --export and --dry-run by a read-only user"`
+		Completion         string        `long:"completion" description:"Print a completion script of the shell" choice:"bash" choice:"zsh" choice:"fish" hidden:"true"`
+		Help               bool          `long:"help" description:"Show this help"`
+		Version            bool          `long:"version" description:"Show this version"`
 	}
 
 	parser := flags.NewParser(&opts, flags.None)
 	parser.Usage = "[option...] db_name"
-	args, err := parser.ParseArgs(args)
+	args, err := sqldef.ParseArgs(parser, args)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -54,18 +129,91 @@ func parseOptions(args []string) (adapter.Config, *sqldef.Options) {
 		os.Exit(0)
 	}
 
-	desiredFile, currentFile := sqldef.ParseFiles(opts.File)
+	if len(opts.Completion) > 0 {
+		if err := sqldef.WriteCompletion(os.Stdout, parser, opts.Completion); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if len(opts.Targets) > 0 {
+		os.Exit(sqldef.ApplyTargets(parser, opts.Targets, opts.TargetsConcurrency, opts.File, args))
+	}
+
+	adapter.SetLogFormat(opts.LogFormat)
+	noDatabase := opts.Lint || len(opts.AgainstSnapshot) > 0 || ((len(opts.GenerateGo) > 0 || len(opts.ExportFormat) > 0) && !opts.Export) // the desired schema is used without a database
+	desiredFiles, currentFile := sqldef.ParseFiles(opts.File, len(args) > 0 || noDatabase)
 	options := sqldef.Options{
-		DesiredFile: desiredFile,
-		CurrentFile: currentFile,
-		DryRun:      opts.DryRun,
-		Export:      opts.Export,
-		SkipDrop:    opts.SkipDrop,
-		BeforeApply: opts.BeforeApply,
+		DesiredFiles:     desiredFiles,
+		CurrentFile:      currentFile,
+		ExpandEnv:        opts.ExpandEnv,
+		Template:         opts.Template,
+		Env:              opts.Env,
+		Variables:        opts.Variables,
+		DryRun:           opts.DryRun,
+		Check:            opts.Check,
+		Quiet:            opts.Quiet,
+		Interactive:      opts.Interactive,
+		Watch:            opts.Watch,
+		Interval:         opts.Interval,
+		Webhook:          opts.Webhook,
+		Serve:            opts.Serve,
+		TokenEnv:         opts.TokenEnv,
+		ApproverTokenEnv: opts.ApproverTokenEnv,
+		Lint:             opts.Lint,
+		Config:           opts.Config,
+		Output:           opts.Output,
+		MigrationDir:     opts.MigrationDir,
+		MigrationFormat:  opts.MigrationFormat,
+		NoColor:          opts.NoColor,
+		Compact:          opts.Compact,
+		QuoteIdentifiers: opts.QuoteIdentifiers,
+		FoldIdentifiers:  opts.FoldIdentifiers,
+		Schema:           opts.Schema,
+		Impact:           opts.Impact,
+		Plan:             opts.Plan,
+		ApplyPlan:        opts.ApplyPlan,
+		Rollback:         opts.Rollback,
+		Export:           opts.Export,
+		OutputFile:       opts.OutputFile,
+		ExportDir:        opts.ExportDir,
+		Snapshot:         opts.Snapshot,
+		AgainstSnapshot:  opts.AgainstSnapshot,
+		GenerateGo:       opts.GenerateGo,
+		ExportFormat:     opts.ExportFormat,
+		SkipDrop:         opts.SkipDrop,
+		EnableDrop:       opts.EnableDrop,
+		MaxRisk:          opts.MaxRisk,
+		Policy:           opts.Policy,
+		Only:             opts.Only,
+		TargetTables:     opts.TargetTables,
+		SkipTables:       opts.SkipTables,
+		Manifest:         opts.Manifest,
+		LockFile:         opts.LockFile,
+		Resume:           opts.Resume,
+		History:          opts.History,
+		AuditLog:         opts.AuditLog,
+		Version:          version,
+		BeforeApply:      opts.BeforeApply,
+		AfterApply:       opts.AfterApply,
+		PreApplyHook:     opts.PreApplyHook,
+		PostApplyHook:    opts.PostApplyHook,
+		ApplyConcurrency: opts.ApplyConcurrency,
+		NoApplyLock:      opts.NoApplyLock,
+		Progress:         opts.Progress,
+		Summary:          opts.Summary,
+		Retry:            opts.Retry,
+		RetryWait:        opts.RetryWait,
+		Timeout:          opts.Timeout,
+		SafeTypeChange:   opts.SafeTypeChange,
+		SafeNotNull:      opts.SafeNotNull,
+		Descriptions:     opts.Descriptions,
+		NoTransaction:    opts.NoTransaction,
+		PgBouncer:        opts.PgBouncer,
 	}
 
 	database := ""
-	if len(currentFile) == 0 {
+	if len(currentFile) == 0 && !noDatabase {
 		if len(args) == 0 {
 			fmt.Print("No database is specified!\n\n")
 			parser.WriteHelp(os.Stdout)
@@ -83,53 +231,120 @@ func parseOptions(args []string) (adapter.Config, *sqldef.Options) {
 		password = opts.Password
 	}
 
-	if opts.Prompt {
-		fmt.Printf("Enter Password: ")
-		pass, err := term.ReadPassword(int(syscall.Stdin))
+	if len(opts.PasswordEnv) > 0 || len(opts.PasswordFile) > 0 {
+		password, err = sqldef.LookupPassword(opts.PasswordEnv, opts.PasswordFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if opts.Prompt || opts.Password == sqldef.PasswordPrompt {
+		password, err = sqldef.PromptPassword()
 		if err != nil {
 			log.Fatal(err)
 		}
-		password = string(pass)
 	}
 
+	if opts.Verbose {
+		opts.LogLevel = "debug"
+	}
 	config := adapter.Config{
-		DbName:   database,
-		User:     opts.User,
-		Password: password,
-		Host:     opts.Host,
-		Port:     int(opts.Port),
+		LogLevel:        opts.LogLevel,
+		DumpConcurrency: opts.DumpConcurrency,
+		DbName:          database,
+		User:            opts.User,
+		Password:        password,
+		Host:            opts.Host,
+		Port:            int(opts.Port),
+
+		LockTimeout:       opts.LockTimeout,
+		StatementTimeout:  opts.StatementTimeout,
+		LimitedPrivileges: opts.LimitedPrivileges,
+		PgBouncer:         opts.PgBouncer,
+		Keepalive:         opts.Keepalive,
+		Schema:            opts.Schema,
 	}
 	if _, err := os.Stat(config.Host); !os.IsNotExist(err) {
 		config.Socket = config.Host
 	}
-	return config, &options
+
+	var desiredConfig *adapter.Config
+	if len(opts.DesiredHost) > 0 || opts.DesiredPort > 0 || len(opts.DesiredDb) > 0 {
+		desired := config // the same user and password
+		if len(opts.DesiredHost) > 0 {
+			desired.Host, desired.Socket = opts.DesiredHost, ""
+			if _, err := os.Stat(desired.Host); !os.IsNotExist(err) {
+				desired.Socket = desired.Host
+			}
+		}
+		if opts.DesiredPort > 0 {
+			desired.Port = int(opts.DesiredPort)
+		}
+		if len(opts.DesiredDb) > 0 {
+			desired.DbName = opts.DesiredDb
+		}
+		desiredConfig = &desired
+	}
+	options.Target = config.Target()
+
+	if len(opts.EachSchema) > 0 && len(currentFile) == 0 && !noDatabase {
+		os.Exit(applyEachSchema(config, opts.EachSchema, opts.TargetsConcurrency, opts.File, opts.OutputFile))
+	}
+	return config, desiredConfig, &options
+}
+
+// Apply the schema to each schema matching the pattern of --each-schema, which is listed by connecting to the database
+func applyEachSchema(config adapter.Config, pattern string, concurrency int, files []string, outputFile string) int {
+	database, err := connect(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	schemas, err := postgres.SchemaNames(database, pattern)
+	database.Close()
+	if err != nil {
+		log.Fatalf("Failed to list schemas of --each-schema: %s", err)
+	}
+	return sqldef.ApplyEachSchema(schemas, concurrency, files, outputFile)
+}
+
+// Connect to PostgreSQL, emulating the default behavior (sslmode=prefer) of psql when PGSSLMODE is not set,
+// which is not supported by Go's lib/pq.
+func connect(config adapter.Config) (adapter.Database, error) {
+	database, err := postgres.NewDatabase(config)
+	if _, ok := os.LookupEnv("PGSSLMODE"); !ok && err == nil {
+		e := database.DB().Ping()
+		if e != nil && strings.Contains(fmt.Sprintf("%s", e), "SSL is not enabled") {
+			database.Close()
+			os.Setenv("PGSSLMODE", "disable")
+			database, err = postgres.NewDatabase(config)
+		}
+	}
+	return database, err
 }
 
 func main() {
-	config, options := parseOptions(os.Args[1:])
+	config, desiredConfig, options := parseOptions(os.Args[1:])
 
 	var database adapter.Database
 	if len(options.CurrentFile) > 0 {
 		database = file.NewDatabase(options.CurrentFile)
+	} else if len(options.AgainstSnapshot) > 0 {
+		database = file.NewSnapshotDatabase(options.AgainstSnapshot, schema.GeneratorModePostgres)
 	} else {
 		var err error
-		database, err = postgres.NewDatabase(config)
-
-		// Emulate the default behavior (sslmode=prefer) of psql when PGSSLMODE is not set,
-		// which is not supported by Go's lib/pq.
-		if _, ok := os.LookupEnv("PGSSLMODE"); !ok && err == nil {
-			e := database.DB().Ping()
-			if e != nil && strings.Contains(fmt.Sprintf("%s", e), "SSL is not enabled") {
-				database.Close()
-				os.Setenv("PGSSLMODE", "disable")
-				database, err = postgres.NewDatabase(config)
-			}
+		database, err = connect(config)
+		if err != nil {
+			log.Fatal(err)
 		}
+		defer database.Close()
+	}
 
+	if desiredConfig != nil {
+		desiredDatabase, err := connect(*desiredConfig)
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer database.Close()
+		defer desiredDatabase.Close()
+		options.DesiredDB = desiredDatabase
 	}
 
 	sqldef.Run(schema.GeneratorModePostgres, database, options)
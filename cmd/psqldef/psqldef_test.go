@@ -6,17 +6,23 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/k0kubun/sqldef/adapter"
 	"github.com/k0kubun/sqldef/adapter/postgres"
 	"github.com/k0kubun/sqldef/cmd/testutils"
 	"github.com/k0kubun/sqldef/schema"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -1263,6 +1269,53 @@ func TestPsqldefDryRun(t *testing.T) {
 	assertEquals(t, dryRun, strings.Replace(apply, "Apply", "dry run", 1))
 }
 
+func TestPsqldefDesiredDatabase(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("psql", "-Upostgres", "-c", "DROP DATABASE IF EXISTS psqldef_test_desired;")
+	mustExecute("psql", "-Upostgres", "-c", "CREATE DATABASE psqldef_test_desired;")
+	defer mustExecute("psql", "-Upostgres", "-c", "DROP DATABASE psqldef_test_desired;")
+	mustExecute("psql", "-Upostgres", "psqldef_test_desired", "-c", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, name text);")
+	mustExecuteSQL("CREATE TABLE users (id bigint NOT NULL PRIMARY KEY);")
+
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--desired-db", "psqldef_test_desired", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+`ALTER TABLE "public"."users" ADD COLUMN "name" text;`+"\n")
+
+	assertedExecute(t, "./psqldef", "-Upostgres", database, "--desired-db", "psqldef_test_desired")
+	dryRun = assertedExecute(t, "./psqldef", "-Upostgres", database, "--desired-db", "psqldef_test_desired", "--dry-run")
+	assertEquals(t, dryRun, nothingModified)
+}
+
+func TestPsqldefDesiredDatabaseFormat(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("psql", "-Upostgres", "-c", "DROP DATABASE IF EXISTS psqldef_test_desired;")
+	mustExecute("psql", "-Upostgres", "-c", "CREATE DATABASE psqldef_test_desired;")
+	defer mustExecute("psql", "-Upostgres", "-c", "DROP DATABASE psqldef_test_desired;")
+	mustExecute("psql", "-Upostgres", "psqldef_test_desired", "-c", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, name text);")
+
+	// CREATE TABLE dumped from the desired database is re-indented
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--desired-db", "psqldef_test_desired", "--dry-run")
+	assertEquals(t, dryRun, stripHeredoc(`
+		-- dry run --
+		CREATE TABLE public.users (
+		  "id" bigint NOT NULL,
+		  "name" text,
+		  PRIMARY KEY ("id")
+		);
+		`,
+	))
+
+	dryRun = assertedExecute(t, "./psqldef", "-Upostgres", database, "--desired-db", "psqldef_test_desired", "--dry-run", "--compact")
+	assertEquals(t, dryRun, stripHeredoc(`
+		-- dry run --
+		CREATE TABLE public.users (
+		    "id" bigint NOT NULL,
+		    "name" text,
+		    PRIMARY KEY ("id")
+		);
+		`,
+	))
+}
+
 func TestPsqldefSkipDrop(t *testing.T) {
 	resetTestDatabase()
 	mustExecuteSQL(stripHeredoc(`
@@ -1279,8 +1332,29 @@ func TestPsqldefSkipDrop(t *testing.T) {
 	writeFile("schema.sql", "")
 
 	skipDrop := assertedExecute(t, "./psqldef", "-Upostgres", database, "--skip-drop", "--file", "schema.sql")
-	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql")
-	assertEquals(t, skipDrop, strings.Replace(apply, "DROP", "-- Skipped: DROP", 1))
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, skipDrop, strings.Replace(apply, "DROP", "-- Skipped: DROP", 1)+"-- Skipped destructive DDLs: 1 --\n")
+}
+
+func TestPsqldefEnableDrop(t *testing.T) {
+	resetTestDatabase()
+	mustExecuteSQL(stripHeredoc(`
+		CREATE TABLE users (
+		    id bigint NOT NULL PRIMARY KEY,
+		    age int,
+		    c_char_1 char,
+		    c_char_10 char(10),
+		    c_varchar_10 varchar(10),
+		    c_varchar_unlimited varchar
+		);`,
+	))
+
+	writeFile("schema.sql", "")
+
+	skipped := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql")
+	assertEquals(t, skipped, applyPrefix+`-- Skipped: DROP TABLE "public"."users";`+"\n-- Skipped destructive DDLs: 1 --\n")
+	dropped := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, dropped, applyPrefix+`DROP TABLE "public"."users";`+"\n")
 }
 
 func TestPsqldefExport(t *testing.T) {
@@ -1314,6 +1388,145 @@ func TestPsqldefExport(t *testing.T) {
 	))
 }
 
+func TestPsqldefExportDumpConcurrency(t *testing.T) {
+	resetTestDatabase()
+
+	var ddls []string
+	for i := 1; i <= 10; i++ {
+		ddls = append(ddls, fmt.Sprintf("CREATE TABLE t%02d (id bigint PRIMARY KEY, name text); CREATE INDEX t%02d_name ON t%02d (name);", i, i, i))
+	}
+	mustExecuteSQL(strings.Join(ddls, "\n"))
+
+	// Tables dumped at once are printed in the same order as the ones dumped one by one
+	expected := assertedExecute(t, "./psqldef", "-Upostgres", database, "--export", "--dump-concurrency=1")
+	for i := 1; i <= 10; i++ {
+		if !strings.Contains(expected, fmt.Sprintf("CREATE INDEX t%02d_name ON public.t%02d", i, i)) {
+			t.Fatalf("expected table t%02d in the export, but got: %s", i, expected)
+		}
+	}
+	for _, concurrency := range []string{"--dump-concurrency=3", "--dump-concurrency=16"} {
+		assertEquals(t, assertedExecute(t, "./psqldef", "-Upostgres", database, "--export", concurrency), expected)
+	}
+	assertEquals(t, assertedExecute(t, "./psqldef", "-Upostgres", database, "--export"), expected)
+}
+
+func TestPsqldefExportDeterministic(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("plan.sql")
+
+	var ddls []string
+	for i := 1; i <= 5; i++ {
+		ddls = append(ddls, fmt.Sprintf("CREATE TABLE t%d (id bigint PRIMARY KEY, a integer, b integer);", i))
+	}
+	for i := 1; i <= 5; i++ {
+		ddls = append(ddls,
+			fmt.Sprintf("ALTER TABLE t1 ADD CONSTRAINT t1_check_%d CHECK (a + b > %d);", i, i),
+			fmt.Sprintf("ALTER TABLE t1 ADD CONSTRAINT t1_unique_%d UNIQUE (a, b);", i),
+			fmt.Sprintf("ALTER TABLE t1 ADD CONSTRAINT t1_fkey_%d FOREIGN KEY (a) REFERENCES t%d (id);", i, i),
+		)
+	}
+	mustExecuteSQL(strings.Join(ddls, "\n"))
+
+	// The same schema is dumped the same, which --plan and --lock-file compare by its fingerprint
+	expected := assertedExecute(t, "./psqldef", "-Upostgres", database, "--export")
+	for i := 0; i < 5; i++ {
+		assertEquals(t, assertedExecute(t, "./psqldef", "-Upostgres", database, "--export"), expected)
+	}
+
+	writeFile("schema.sql", expected+"\nCREATE TABLE t6 (id bigint PRIMARY KEY);\n")
+	assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--plan", "plan.sql")
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--apply", "plan.sql")
+	if !strings.Contains(apply, "CREATE TABLE t6") {
+		t.Errorf("expected the plan to be applied, but got: %s", apply)
+	}
+}
+
+func TestPsqldefLockFile(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("sqldef.lock")
+
+	// Constraints of a table are dumped in a fixed order, which is not warned as a change
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		  id bigint NOT NULL PRIMARY KEY,
+		  a integer,
+		  b integer,
+		  CONSTRAINT users_check_1 CHECK (a + b > 1),
+		  CONSTRAINT users_check_2 CHECK (a + b > 2),
+		  CONSTRAINT users_check_3 CHECK (a + b > 3)
+		);
+		ALTER TABLE users ADD CONSTRAINT users_unique_1 UNIQUE (a, b);
+		ALTER TABLE users ADD CONSTRAINT users_unique_2 UNIQUE (b, a);
+		ALTER TABLE users ADD CONSTRAINT users_unique_3 UNIQUE (a);
+		`,
+	))
+	assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--lock-file", "sqldef.lock")
+	for i := 0; i < 5; i++ {
+		dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--lock-file", "sqldef.lock", "--dry-run")
+		assertEquals(t, dryRun, nothingModified)
+	}
+
+	mustExecuteSQL("CREATE TABLE manual (id bigint);")
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--lock-file", "sqldef.lock", "--dry-run")
+	if !strings.HasPrefix(dryRun, "-- WARNING: The database has changed since the last apply recorded in 'sqldef.lock'") {
+		t.Errorf("expected a warning of the out-of-band change, but got: %s", dryRun)
+	}
+}
+
+func TestPsqldefServe(t *testing.T) {
+	resetTestDatabase()
+	mustExecuteSQL("CREATE TABLE users (id bigint PRIMARY KEY);")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	cmd := exec.Command("./psqldef", "-Upostgres", database, "--serve", address)
+	cmd.Env = append(os.Environ(), "SQLDEF_TOKEN=secret", "SQLDEF_APPROVER_TOKEN=approver")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	request := func(path string, token string, body string) map[string]interface{} {
+		t.Helper()
+		req, err := http.NewRequest("POST", "http://"+address+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		var res *http.Response
+		for i := 0; i < 50; i++ { // wait for the server to start
+			if res, err = http.DefaultClient.Do(req); err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var result map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	plan := request("/plans", "secret", "CREATE TABLE users (id bigint PRIMARY KEY, name text);")
+	id, ok := plan["id"].(string)
+	if !ok {
+		t.Fatalf("expected a plan, but got %v", plan)
+	}
+	request("/plans/"+id+"/approve", "approver", "")
+	plan = request("/plans/"+id+"/apply", "secret", "")
+	assertEquals(t, fmt.Sprint(plan["status"]), "applied")
+	assertEquals(t, fmt.Sprint(plan["output"]), applyPrefix+`ALTER TABLE "public"."users" ADD COLUMN "name" text;`+"\n")
+}
+
 func TestPsqldefExportCompositePrimaryKey(t *testing.T) {
 	resetTestDatabase()
 
@@ -1362,6 +1575,728 @@ func TestPsqldefBeforeApply(t *testing.T) {
 	assertEquals(t, owner, "dummy_owner_role\n")
 }
 
+func TestPsqldefAfterApply(t *testing.T) {
+	resetTestDatabase()
+
+	beforeApply := "SET LOCAL lock_timeout = '5s';"
+	afterApply := "COMMENT ON TABLE dummy IS 'applied';"
+	createTable := "CREATE TABLE dummy (id int);"
+	writeFile("schema.sql", createTable)
+
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "-f", "schema.sql", "--before-apply", beforeApply, "--after-apply", afterApply, "--dry-run")
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "-f", "schema.sql", "--before-apply", beforeApply, "--after-apply", afterApply)
+	assertEquals(t, dryRun, strings.Replace(apply, "Apply", "dry run", 1))
+	assertEquals(t, apply, applyPrefix+beforeApply+"\n"+createTable+"\n"+afterApply+"\n")
+
+	apply = assertedExecute(t, "./psqldef", "-Upostgres", database, "-f", "schema.sql", "--before-apply", beforeApply, "--after-apply", afterApply)
+	assertEquals(t, apply, nothingModified)
+
+	comment := assertedExecute(t, "psql", "-Upostgres", database, "-tAc", "SELECT obj_description('dummy'::regclass)")
+	assertEquals(t, comment, "applied\n")
+}
+
+func TestPsqldefLockTimeout(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id bigint, name text);\n"
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+
+	db, err := connectDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	transaction, err := db.DB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transaction.Rollback()
+	if _, err := transaction.Exec("LOCK TABLE users IN ACCESS EXCLUSIVE MODE"); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile("schema.sql", "CREATE TABLE users (id bigint, name text, age integer);\n")
+	out, err := execute("./psqldef", "-Upostgres", database, "--file", "schema.sql", "--lock-timeout", "100ms", "--statement-timeout", "10s")
+	if err == nil {
+		t.Errorf("expected ALTER TABLE to time out, but succeeded with: %s", out)
+	} else if !strings.Contains(out, "lock timeout") {
+		t.Errorf("expected a lock timeout error, but got: %s", out)
+	}
+}
+
+func TestPsqldefRetry(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id bigint, name text);\n"
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+
+	db, err := connectDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	transaction, err := db.DB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transaction.Exec("LOCK TABLE users IN ACCESS EXCLUSIVE MODE"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(1 * time.Second)
+		transaction.Rollback()
+	}()
+
+	addColumn := `ALTER TABLE "public"."users" ADD COLUMN "age" integer;` + "\n"
+	writeFile("schema.sql", "CREATE TABLE users (id bigint, name text, age integer);\n")
+	out := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--lock-timeout", "100ms", "--retry", "10", "--retry-wait", "500ms")
+	if !strings.Contains(out, "-- Retrying in 500ms (1/10): pq: canceling statement due to lock timeout --") {
+		t.Errorf("expected a retry after a lock timeout, but got: %s", out)
+	}
+	if !strings.HasSuffix(out, applyPrefix+addColumn) {
+		t.Errorf("expected ALTER TABLE to be applied, but got: %s", out)
+	}
+}
+
+func TestPsqldefTimeout(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id bigint, name text);\n"
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+
+	db, err := connectDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	transaction, err := db.DB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transaction.Rollback()
+	if _, err := transaction.Exec("LOCK TABLE users IN ACCESS EXCLUSIVE MODE"); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile("schema.sql", "CREATE TABLE users (id bigint, name text, age integer);\nCREATE TABLE posts (id bigint);\n")
+	out, err := execute("./psqldef", "-Upostgres", database, "--file", "schema.sql", "--timeout", "1s")
+	if err == nil {
+		t.Errorf("expected ALTER TABLE to time out, but succeeded with: %s", out)
+	} else if !strings.Contains(out, "Timed out by --timeout. The transaction was rolled back, and no DDL was applied.") {
+		t.Errorf("expected a timeout error, but got: %s", out)
+	}
+	transaction.Rollback()
+
+	// CREATE TABLE posts was rolled back as well
+	assertApplyOutput(t, createTable, nothingModified)
+}
+
+func TestPsqldefNoTransaction(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id bigint, name text);\n"
+	createIndex := "CREATE INDEX CONCURRENTLY index_name ON users (name);\n"
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+
+	writeFile("schema.sql", createTable+createIndex)
+	if out, err := execute("./psqldef", "-Upostgres", database, "--file", "schema.sql"); err == nil {
+		t.Errorf("expected CREATE INDEX CONCURRENTLY to fail in a transaction, but succeeded with: %s", out)
+	}
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--no-transaction")
+	assertEquals(t, apply, applyPrefix+createIndex)
+	assertApplyOutput(t, createTable+createIndex, nothingModified)
+}
+
+func TestPsqldefApplyConcurrency(t *testing.T) {
+	resetTestDatabase()
+
+	createUsers := "CREATE TABLE users (id bigint PRIMARY KEY, name text);\n"
+	createPosts := "CREATE TABLE posts (id bigint PRIMARY KEY, user_id bigint REFERENCES users (id));\n"
+	createTags := "CREATE TABLE tags (id bigint PRIMARY KEY);\n"
+	createIndex := "CREATE INDEX index_users_on_name ON users (name);\n"
+	writeFile("schema.sql", createUsers+createPosts+createTags+createIndex)
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--apply-concurrency", "4")
+
+	// DDLs of independent tables may be applied in any order, but posts and the index wait for users
+	if !strings.HasPrefix(apply, applyPrefix) {
+		t.Fatalf("expected %q as a prefix, but got: %s", applyPrefix, apply)
+	}
+	lines := strings.SplitAfter(strings.TrimPrefix(apply, applyPrefix), "\n")
+	sorted := append([]string{}, lines...)
+	sort.Strings(sorted)
+	assertEquals(t, strings.Join(sorted, ""), createIndex+createPosts+createTags+createUsers)
+	for i, line := range lines {
+		if (line == createPosts || line == createIndex) && !strings.Contains(strings.Join(lines[:i], ""), createUsers) {
+			t.Errorf("expected %q to be applied after %q, but got: %s", line, createUsers, apply)
+		}
+	}
+	assertApplyOutput(t, createUsers+createPosts+createTags+createIndex, nothingModified)
+}
+
+func TestPsqldefSafeTypeChange(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id bigint PRIMARY KEY, age integer NOT NULL DEFAULT 0);\n"
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+	mustExecuteSQL("INSERT INTO users SELECT i, i FROM generate_series(1, 2500) AS i;")
+
+	createTable = "CREATE TABLE users (id bigint PRIMARY KEY, age bigint NOT NULL DEFAULT 0);\n"
+	writeFile("schema.sql", createTable)
+	if out, err := execute("./psqldef", "-Upostgres", database, "--file", "schema.sql", "--safe-type-change", "--enable-drop"); err == nil {
+		t.Errorf("--safe-type-change without --no-transaction must be error, but successfully got: %s", out)
+	}
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--safe-type-change", "--no-transaction", "--enable-drop")
+	assertEquals(t, apply, applyPrefix+stripHeredoc(`
+		ALTER TABLE "public"."users" ADD COLUMN "age__new" bigint;
+		UPDATE "public"."users" SET "age__new" = CAST("age" AS bigint) WHERE ctid IN (SELECT ctid FROM "public"."users" WHERE "age__new" IS NULL AND "age" IS NOT NULL LIMIT 1000) /* sqldef:batch */;
+		ALTER TABLE "public"."users" RENAME COLUMN "age" TO "age__old";
+		ALTER TABLE "public"."users" RENAME COLUMN "age__new" TO "age";
+		ALTER TABLE "public"."users" DROP COLUMN "age__old";
+		ALTER TABLE "public"."users" ALTER COLUMN "age" SET NOT NULL;
+		ALTER TABLE "public"."users" ALTER COLUMN "age" SET DEFAULT 0;
+		`,
+	))
+	assertApplyOutput(t, createTable, nothingModified)
+
+	out := assertedExecute(t, "psql", "-Upostgres", database, "-tAc", "SELECT sum(age) FROM users")
+	assertEquals(t, out, "3126250\n")
+}
+
+func TestPsqldefSafeNotNull(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id bigint PRIMARY KEY);\n"
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+	mustExecuteSQL("INSERT INTO users VALUES (1), (2);")
+
+	createTable = "CREATE TABLE users (\n  id bigint PRIMARY KEY,\n  role text NOT NULL -- @backfill 'member'\n);\n"
+	writeFile("schema.sql", createTable)
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--safe-not-null")
+	assertEquals(t, apply, applyPrefix+stripHeredoc(`
+		ALTER TABLE "public"."users" ADD COLUMN "role" text;
+		UPDATE "public"."users" SET "role" = 'member' WHERE "role" IS NULL;
+		ALTER TABLE "public"."users" ALTER COLUMN "role" SET NOT NULL;
+		`,
+	))
+	assertApplyOutput(t, createTable, nothingModified)
+
+	out := assertedExecute(t, "psql", "-Upostgres", database, "-tAc", "SELECT count(*) FROM users WHERE role = 'member'")
+	assertEquals(t, out, "2\n")
+}
+
+func TestPsqldefBackfillAnnotation(t *testing.T) {
+	resetTestDatabase()
+	mustExecuteSQL("CREATE TABLE users (id bigint PRIMARY KEY, email text); INSERT INTO users VALUES (1, 'A@example.com'), (2, 'b@example.com');")
+
+	// NOT NULL is set after the backfill in batches without --safe-not-null
+	createTable := stripHeredoc(`
+		CREATE TABLE users (
+		  id bigint PRIMARY KEY,
+		  email text,
+		  normalized_email text NOT NULL -- sqldef:backfill UPDATE users SET normalized_email = lower(email) WHERE id IN (SELECT id FROM users WHERE normalized_email IS NULL LIMIT 1)
+		);
+		`,
+	)
+	writeFile("schema.sql", createTable)
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql")
+	assertEquals(t, apply, applyPrefix+stripHeredoc(`
+		ALTER TABLE "public"."users" ADD COLUMN "normalized_email" text;
+		UPDATE users SET normalized_email = lower(email) WHERE id IN (SELECT id FROM users WHERE normalized_email IS NULL LIMIT 1) /* sqldef:batch */;
+		ALTER TABLE "public"."users" ALTER COLUMN "normalized_email" SET NOT NULL;
+		`,
+	))
+	assertApplyOutput(t, createTable, nothingModified)
+
+	out := assertedExecute(t, "psql", "-Upostgres", database, "-tAc", "SELECT string_agg(normalized_email, ',' ORDER BY id) FROM users")
+	assertEquals(t, out, "a@example.com,b@example.com\n")
+}
+
+func TestPsqldefFoldIdentifiers(t *testing.T) {
+	resetTestDatabase()
+	mustExecuteSQL(`CREATE TABLE users (id bigint PRIMARY KEY, "Name" text);`)
+
+	writeFile("schema.sql", `CREATE TABLE Users (ID bigint PRIMARY KEY, "Name" text, Email text);`)
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--dry-run", "--fold-identifiers")
+	assertEquals(t, dryRun, "-- dry run --\n"+`ALTER TABLE "public"."users" ADD COLUMN "email" text;`+"\n")
+
+	dryRun = assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--dry-run", "--fold-identifiers", "--quote-identifiers", "auto")
+	assertEquals(t, dryRun, "-- dry run --\nALTER TABLE public.users ADD COLUMN email text;\n")
+}
+
+func TestPsqldefDefaultNormalization(t *testing.T) {
+	resetTestDatabase()
+	mustExecuteSQL(`CREATE TABLE users (id bigint PRIMARY KEY, age integer DEFAULT '0'::integer, active boolean DEFAULT 't', created_at timestamp DEFAULT now());`)
+
+	writeFile("schema.sql", `CREATE TABLE users (id bigint PRIMARY KEY, age integer DEFAULT 0, active boolean DEFAULT true, created_at timestamp DEFAULT CURRENT_TIMESTAMP);`)
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--dry-run")
+	assertEquals(t, dryRun, nothingModified)
+}
+
+func TestPsqldefApplyLock(t *testing.T) {
+	resetTestDatabase()
+	db, err := connectDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	release, err := db.(adapter.ApplyLocker).LockApply(context.Background(), func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createTable := "CREATE TABLE users (\n  id bigint PRIMARY KEY\n);\n"
+	writeFile("schema.sql", createTable)
+	out, err := execute("./psqldef", "-Upostgres", database, "--file", "schema.sql", "--lock-timeout", "1s")
+	if err == nil {
+		t.Errorf("expected psqldef to time out waiting for the lock, but got: %s", out)
+	}
+	if !strings.HasPrefix(out, "-- Waiting for another sqldef applying DDLs to the database --\n") {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	// Dry runs don't wait for it
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createTable)
+
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--lock-timeout", "1s")
+	assertEquals(t, apply, applyPrefix+createTable)
+}
+
+func TestPsqldefStatementActivity(t *testing.T) {
+	resetTestDatabase()
+	mustExecuteSQL("CREATE TABLE users (id bigint PRIMARY KEY);")
+	db, err := connectDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	transaction, err := db.DB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transaction.Rollback()
+	if _, err := transaction.Exec("LOCK TABLE users"); err != nil {
+		t.Fatal(err)
+	}
+	alterTable := "ALTER TABLE users ADD COLUMN name text"
+	done := make(chan struct{})
+	go func() {
+		db.DB().Exec(alterTable)
+		close(done)
+	}()
+
+	var activity string
+	for i := 0; i < 50 && activity == ""; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if activity, err = db.(adapter.StatementMonitor).StatementActivity(context.Background(), alterTable); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.HasPrefix(activity, "waiting for Lock: relation, blocked by PID ") {
+		t.Errorf("unexpected activity: %q", activity)
+	}
+	transaction.Rollback()
+	<-done
+}
+
+func TestPsqldefKeepalive(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (\n  id bigint PRIMARY KEY\n);\n"
+	writeFile("schema.sql", createTable)
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--keepalive", "30s")
+	assertEquals(t, apply, applyPrefix+createTable)
+	apply = assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--keepalive", "-1s")
+	assertEquals(t, apply, nothingModified)
+}
+
+func TestPsqldefPgBouncer(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (\n  id bigint PRIMARY KEY\n);\n"
+	writeFile("schema.sql", createTable)
+	out, err := execute("./psqldef", "-Upostgres", database, "--file", "schema.sql", "--pgbouncer", "--lock-timeout", "1s", "--after-apply", "SELECT 1/0;")
+	if err == nil {
+		t.Errorf("expected --after-apply to fail, but got: %s", out)
+	}
+
+	// Each DDL is committed by itself, so the failure doesn't roll it back
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--pgbouncer", "--dry-run")
+	assertEquals(t, dryRun, nothingModified)
+}
+
+func TestPsqldefVariables(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (\n  id bigint PRIMARY KEY,\n  name text DEFAULT ':owner' -- :fillfactor\n);\n"
+	writeFile("schema.sql", "\\set fillfactor 70\n"+createTable+
+		"COMMENT ON TABLE users IS :'owner';\n"+
+		"CREATE INDEX index_users_on_name ON users (name) WITH (fillfactor = :fillfactor);\n")
+
+	// Variables are not substituted in quotes and comments
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--variable", "owner=app's owner", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createTable+
+		"COMMENT ON TABLE users IS 'app''s owner';\n"+
+		"CREATE INDEX index_users_on_name ON users (name) WITH (fillfactor = 70);\n")
+}
+
+func TestPsqldefPgDump(t *testing.T) {
+	resetTestDatabase()
+
+	writeFile("dump.sql", stripHeredoc(`
+		--
+		-- PostgreSQL database dump
+		--
+
+		SET statement_timeout = 0;
+		SET client_encoding = 'UTF8';
+		SELECT pg_catalog.set_config('search_path', '', false);
+
+		CREATE TABLE public.users (
+		    id bigint NOT NULL,
+		    name text DEFAULT 'a;b'::text
+		);
+
+		ALTER TABLE public.users OWNER TO postgres;
+
+		COMMENT ON TABLE public.users IS 'Users';
+
+		CREATE SEQUENCE public.users_id_seq
+		    START WITH 1
+		    INCREMENT BY 1
+		    NO MINVALUE
+		    NO MAXVALUE
+		    CACHE 1;
+
+		ALTER TABLE public.users_id_seq OWNER TO postgres;
+
+		ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
+
+		ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);
+
+		ALTER TABLE ONLY public.users
+		    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+		ALTER TABLE ONLY public.users
+		    ADD CONSTRAINT users_name_key UNIQUE (name);
+
+		CREATE INDEX index_users_on_name ON public.users USING btree (name);
+
+		GRANT SELECT ON TABLE public.users TO PUBLIC;
+
+		--
+		-- PostgreSQL database dump complete
+		--
+		`,
+	))
+	assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "dump.sql")
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "dump.sql", "--dry-run")
+	assertEquals(t, dryRun, nothingModified)
+}
+
+func TestPsqldefEachSchema(t *testing.T) {
+	resetTestDatabase()
+	mustExecuteSQL(stripHeredoc(`
+		CREATE SCHEMA tenant_1;
+		CREATE SCHEMA tenant_2;
+		CREATE TABLE tenant_1.users (
+		    id bigint PRIMARY KEY
+		);
+		CREATE TABLE tenant_2.users (
+		    id bigint PRIMARY KEY,
+		    name text
+		);`,
+	))
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		    id bigint PRIMARY KEY,
+		    name text
+		);
+		CREATE INDEX index_users_on_name ON users (name);
+		`,
+	))
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--each-schema", "tenant_%", "--dry-run")
+	assertEquals(t, dryRun, "-- Target tenant_1 --\n-- dry run --\n"+
+		"ALTER TABLE \"tenant_1\".\"users\" ADD COLUMN \"name\" text;\n"+
+		"CREATE INDEX index_users_on_name ON users (name);\n"+
+		"-- Target tenant_2 --\n-- dry run --\n"+
+		"CREATE INDEX index_users_on_name ON users (name);\n"+
+		"-- Targets: 2 succeeded --\n")
+
+	assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--each-schema", "tenant_%")
+	dryRun = assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--schema", "tenant_2", "--dry-run")
+	assertEquals(t, dryRun, nothingModified)
+}
+
+func TestPsqldefLimitedPrivileges(t *testing.T) {
+	resetTestDatabase()
+	mustExecuteSQL(stripHeredoc(`
+		CREATE TABLE users (
+		    id bigint PRIMARY KEY
+		);
+		CREATE TABLE posts (
+		    id bigint PRIMARY KEY,
+		    user_id bigint REFERENCES users (id) ON DELETE CASCADE
+		);`,
+	))
+	mustExecuteSQL("DROP ROLE IF EXISTS readonly_user;")
+	mustExecuteSQL("CREATE ROLE readonly_user LOGIN;")
+	mustExecuteSQL("GRANT SELECT ON ALL TABLES IN SCHEMA public TO readonly_user;")
+
+	// information_schema hides constraints of the tables from a user who doesn't own them
+	export := assertedExecute(t, "./psqldef", "-Upostgres", database, "--export")
+	limitedExport := assertedExecute(t, "./psqldef", "-Ureadonly_user", database, "--export", "--limited-privileges")
+	assertEquals(t, limitedExport, export)
+}
+
+func TestPsqldefDescriptions(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (\n  id bigint PRIMARY KEY,\n  name text\n);\n"
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+
+	writeFile("schema.sql", stripHeredoc(`
+		-- description: People who signed up
+		CREATE TABLE users (
+		  id bigint PRIMARY KEY,
+		  -- description: Full name, e.g. 'Jane Doe'
+		  name text
+		);
+		`,
+	))
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--descriptions")
+	assertEquals(t, apply, applyPrefix+stripHeredoc(`
+		COMMENT ON TABLE users IS 'People who signed up';
+		COMMENT ON COLUMN users.name IS 'Full name, e.g. ''Jane Doe''';
+		`,
+	))
+	apply = assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--descriptions")
+	assertEquals(t, apply, nothingModified)
+
+	out := assertedExecute(t, "psql", "-Upostgres", database, "-tAc", "SELECT col_description('users'::regclass, 2)")
+	assertEquals(t, out, "Full name, e.g. 'Jane Doe'\n")
+}
+
+func TestPsqldefImpact(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id bigint PRIMARY KEY, age integer);\n"
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+	mustExecuteSQL("INSERT INTO users SELECT i, i FROM generate_series(1, 100) AS i;")
+	mustExecuteSQL("ANALYZE users;")
+
+	writeFile("schema.sql", "CREATE TABLE users (id bigint PRIMARY KEY, age bigint, name text DEFAULT '');\nCREATE INDEX index_age ON users (age);\n")
+	dryRun := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--dry-run", "--impact")
+	// The size of pages depends on the server
+	dryRun = regexp.MustCompile(`size: [\d.]+ kB`).ReplaceAllString(dryRun, "size: N kB")
+	assertEquals(t, dryRun, stripHeredoc(`
+		-- dry run --
+		ALTER TABLE "public"."users" ALTER COLUMN "age" TYPE bigint;
+		-- risk: blocking, lock: ACCESS EXCLUSIVE, rewrite: yes, rows: ~100, size: N kB, duration: ~0s
+		ALTER TABLE "public"."users" ADD COLUMN "name" text DEFAULT '';
+		-- risk: safe, lock: ACCESS EXCLUSIVE, rewrite: no, rows: ~100, size: N kB
+		CREATE INDEX index_age ON users (age);
+		-- risk: blocking, lock: SHARE, rewrite: no, rows: ~100, size: N kB, duration: ~0s
+		`,
+	))
+}
+
+func TestPsqldefPasswordEnvAndFile(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id bigint, name text);\n"
+	writeFile("schema.sql", createTable)
+	writeFile("password", "secret\n")
+	defer os.Remove("password")
+	os.Setenv("PGPASSWORD_APP", "secret")
+	defer os.Unsetenv("PGPASSWORD_APP")
+
+	apply := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--password-env", "PGPASSWORD_APP")
+	assertEquals(t, apply, applyPrefix+createTable)
+	apply = assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--password-file", "password")
+	assertEquals(t, apply, nothingModified)
+
+	if out, err := execute("./psqldef", "-Upostgres", database, "--file", "schema.sql", "--password-env", "PGPASSWORD_UNSET"); err == nil {
+		t.Errorf("expected an error for an unset variable, but got: %s", out)
+	}
+	if out, err := execute("./psqldef", "-Upostgres", database, "--file", "schema.sql", "--password-file", "missing"); err == nil {
+		t.Errorf("expected an error for a missing file, but got: %s", out)
+	}
+}
+
+func TestPsqldefPasswordPrompt(t *testing.T) {
+	for _, command := range []string{"script", "setsid"} {
+		if _, err := exec.LookPath(command); err != nil {
+			t.Skipf("%s command is needed to run psqldef with and without a terminal", command)
+		}
+	}
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (\n  id bigint,\n  name text\n);\n"
+	writeFile("schema.sql", createTable)
+
+	// -W without a value prompts the password on the terminal
+	cmd := exec.Command("script", "-qec", "./psqldef -Upostgres "+database+" --file schema.sql -W", "/dev/null")
+	cmd.Stdin = strings.NewReader("secret\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to run psqldef -W: %s\n%s", err, out)
+	}
+	apply := strings.ReplaceAll(string(out), "\r\n", "\n")
+	if !strings.Contains(apply, "Enter Password: ") || !strings.HasSuffix(apply, applyPrefix+createTable) {
+		t.Errorf("expected a password prompt and the applied DDL, but got: %q", apply)
+	}
+
+	// It fails instead of waiting for a password without a terminal
+	output, err := execute("setsid", "-w", "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--password")
+	if err == nil || !strings.Contains(output, "failed to prompt a password: no terminal is available") {
+		t.Errorf("expected an error without a terminal, but got: %s", output)
+	}
+}
+
+// YugabyteDB's storage clauses are not supported by PostgreSQL, so this is tested by comparing files.
+func TestPsqldefYugabyteStorageClauses(t *testing.T) {
+	writeFile("current.sql", stripHeredoc(`
+		CREATE TABLE public.users (
+		    "id" bigint NOT NULL,
+		    "name" text,
+		    PRIMARY KEY ("id")
+		) WITH (colocation = false) SPLIT INTO 4 TABLETS;
+		CREATE INDEX index_name ON public.users USING lsm (name HASH) SPLIT INTO 2 TABLETS;
+		`,
+	))
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		  id bigint NOT NULL PRIMARY KEY,
+		  name text
+		) WITH (colocation = false) SPLIT INTO 4 TABLETS;
+		CREATE INDEX index_name ON users (name);
+		CREATE INDEX index_id_name ON users (id HASH, name) SPLIT INTO 8 TABLETS;
+		CREATE TABLE posts (
+		  id bigint NOT NULL PRIMARY KEY
+		) SPLIT AT VALUES ((100), (200));
+		`,
+	))
+	defer os.Remove("current.sql")
+
+	output := assertedExecute(t, "./psqldef", "--file", "current.sql", "--file", "schema.sql")
+	assertEquals(t, output, stripHeredoc(`
+		-- dry run --
+		CREATE INDEX index_id_name ON users (id HASH, name) SPLIT INTO 8 TABLETS;
+		CREATE TABLE posts (
+		  id bigint NOT NULL PRIMARY KEY
+		) SPLIT AT VALUES ((100), (200));
+		`,
+	))
+}
+
+func TestPsqldefGreenplumDistribution(t *testing.T) {
+	writeFile("current.sql", stripHeredoc(`
+		CREATE TABLE public.users (
+		    "id" bigint NOT NULL,
+		    "code" integer,
+		    "name" text
+		) DISTRIBUTED BY (id, code);
+		CREATE TABLE public.logs (
+		    "id" bigint NOT NULL
+		) DISTRIBUTED RANDOMLY;
+		`,
+	))
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		  id bigint NOT NULL,
+		  code bigint,
+		  name text
+		) DISTRIBUTED BY (id, code);
+		CREATE TABLE logs (
+		  id bigint NOT NULL
+		) DISTRIBUTED REPLICATED;
+		CREATE TABLE events (
+		  id bigint NOT NULL
+		) DISTRIBUTED BY (id);
+		`,
+	))
+	defer os.Remove("current.sql")
+
+	output := assertedExecute(t, "./psqldef", "--file", "current.sql", "--file", "schema.sql")
+	assertEquals(t, output, stripHeredoc(`
+		-- dry run --
+		ALTER TABLE "public"."users" SET DISTRIBUTED RANDOMLY;
+		ALTER TABLE "public"."users" ALTER COLUMN "code" TYPE bigint;
+		ALTER TABLE "public"."users" SET DISTRIBUTED BY ("id", "code");
+		ALTER TABLE "public"."logs" SET DISTRIBUTED REPLICATED;
+		CREATE TABLE events (
+		  id bigint NOT NULL
+		) DISTRIBUTED BY (id);
+		`,
+	))
+}
+
+func TestPsqldefCitusDistributedTables(t *testing.T) {
+	writeFile("current.sql", stripHeredoc(`
+		CREATE TABLE public.users (
+		    "id" bigint NOT NULL,
+		    "tenant_id" integer NOT NULL,
+		    "name" text
+		);
+		SELECT create_distributed_table('public.users', 'tenant_id');
+		CREATE TABLE public.events (
+		    "id" bigint NOT NULL,
+		    "user_id" bigint NOT NULL
+		);
+		SELECT create_distributed_table('public.events', 'id');
+		CREATE TABLE public.logs (
+		    "id" bigint NOT NULL
+		);
+		SELECT create_reference_table('public.logs');
+		`,
+	))
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		  id bigint NOT NULL,
+		  tenant_id bigint NOT NULL,
+		  name text
+		);
+		SELECT create_distributed_table('users', 'tenant_id');
+		CREATE UNIQUE INDEX index_name ON users (name);
+		CREATE TABLE events (
+		  id bigint NOT NULL,
+		  user_id bigint NOT NULL
+		);
+		SELECT create_distributed_table('events', distribution_column => 'user_id');
+		CREATE TABLE logs (
+		  id bigint NOT NULL
+		);
+		CREATE TABLE posts (
+		  id bigint NOT NULL
+		);
+		SELECT create_distributed_table('posts', 'id', colocate_with => 'none');
+		`,
+	))
+	defer os.Remove("current.sql")
+
+	output := assertedExecute(t, "./psqldef", "--file", "current.sql", "--file", "schema.sql")
+	assertEquals(t, output, stripHeredoc(`
+		-- WARNING: Citus can't propagate 'ALTER TABLE "public"."users" ALTER COLUMN "tenant_id" TYPE bigint' to workers: the distribution column can't be altered
+		-- WARNING: Citus can't propagate 'CREATE UNIQUE INDEX index_name ON users (name)' to workers: a unique index of a distributed table must include the distribution column
+		-- dry run --
+		ALTER TABLE "public"."users" ALTER COLUMN "tenant_id" TYPE bigint;
+		CREATE UNIQUE INDEX index_name ON users (name);
+		SELECT alter_distributed_table('"public"."events"', distribution_column := 'user_id');
+		CREATE TABLE posts (
+		  id bigint NOT NULL
+		);
+		SELECT create_distributed_table('posts', 'id', colocate_with => 'none');
+		SELECT undistribute_table('"public"."logs"');
+		`,
+	))
+}
+
 func TestPsqldefHelp(t *testing.T) {
 	_, err := execute("./psqldef", "--help")
 	if err != nil {
@@ -1390,13 +2325,13 @@ func TestMain(m *testing.M) {
 func assertApply(t *testing.T, schema string) {
 	t.Helper()
 	writeFile("schema.sql", schema)
-	assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql")
+	assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--enable-drop")
 }
 
 func assertApplyOutput(t *testing.T, schema string, expected string) {
 	t.Helper()
 	writeFile("schema.sql", schema)
-	actual := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql")
+	actual := assertedExecute(t, "./psqldef", "-Upostgres", database, "--file", "schema.sql", "--enable-drop")
 	assertEquals(t, actual, expected)
 }
 
@@ -0,0 +1,106 @@
+// Integration test of redshiftdef command.
+//
+// Test requirement:
+//   - go command
+//   - $REDSHIFT_HOST, $PGUSER, and $PGPASSWORD for a Redshift cluster. Tests are skipped without $REDSHIFT_HOST.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/adapter/redshift"
+	"github.com/k0kubun/sqldef/cmd/testutils"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+const database = "redshiftdef_test"
+
+func TestApply(t *testing.T) {
+	tests, err := testutils.ReadTests("tests.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resetTestDatabase()
+			db, err := connectDatabase()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			testutils.RunTest(t, db, test, schema.GeneratorModeRedshift, "")
+		})
+	}
+}
+
+func TestRedshiftdefHelp(t *testing.T) {
+	_, err := execute("./redshiftdef", "--help")
+	if err != nil {
+		t.Errorf("failed to run --help: %s", err)
+	}
+
+	out, err := execute("./redshiftdef")
+	if err == nil {
+		t.Errorf("no database must be error, but successfully got: %s", out)
+	}
+}
+
+func TestMain(m *testing.M) {
+	if _, ok := os.LookupEnv("REDSHIFT_HOST"); !ok {
+		fmt.Println("skipping redshiftdef tests because $REDSHIFT_HOST is not set")
+		os.Exit(0)
+	}
+
+	resetTestDatabase()
+	mustExecute("go", "build")
+	status := m.Run()
+	_ = os.Remove("redshiftdef")
+	os.Exit(status)
+}
+
+func mustExecute(command string, args ...string) {
+	out, err := execute(command, args...)
+	if err != nil {
+		log.Printf("failed to execute '%s %s': `%s`", command, strings.Join(args, " "), out)
+		log.Fatal(err)
+	}
+}
+
+func execute(command string, args ...string) (string, error) {
+	cmd := exec.Command(command, args...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// A Redshift cluster is usually shared, so this recreates the public schema instead of the database.
+func resetTestDatabase() {
+	db, err := connectDatabase()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, sql := range []string{"DROP SCHEMA IF EXISTS public CASCADE", "CREATE SCHEMA public"} {
+		if _, err := db.DB().Exec(sql); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func connectDatabase() (adapter.Database, error) {
+	return redshift.NewDatabase(adapter.Config{
+		User:     os.Getenv("PGUSER"),
+		Password: os.Getenv("PGPASSWORD"),
+		Host:     os.Getenv("REDSHIFT_HOST"),
+		Port:     5439,
+		DbName:   database,
+	})
+}
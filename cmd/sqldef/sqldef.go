@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/k0kubun/sqldef"
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/adapter/external"
+	"github.com/k0kubun/sqldef/adapter/file"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+var version string
+
+var generatorModes = map[string]schema.GeneratorMode{
+	"mysql":     schema.GeneratorModeMysql,
+	"postgres":  schema.GeneratorModePostgres,
+	"sqlite3":   schema.GeneratorModeSQLite3,
+	"mssql":     schema.GeneratorModeMssql,
+	"cockroach": schema.GeneratorModeCockroach,
+	"redshift":  schema.GeneratorModeRedshift,
+}
+
+// Return parsed options and the adapter command
+func parseOptions(args []string) ([]string, *sqldef.Options) {
+	var opts struct {
+		AdapterCmd       string        `long:"adapter-cmd" description:"Command of an adapter speaking sqldef's JSON protocol over stdin/stdout" value-name:"command"`
+		Config           string        `long:"config" description:"Read options from the YAML file (default: sqldef.yml if it exists)" value-name:"config_file"`
+		File             []string      `short:"f" long:"file" description:"Read schema SQL from the file, rather than stdin" value-name:"filename" default:"-"`
+		ExpandEnv        bool          `long:"expand-env" description:"Expand ${VAR} in the schema SQL with environment variables"`
+		Template         string        `long:"template" description:"Preprocess the schema SQL with Go's text/template, using values in the YAML or JSON file" value-name:"values_file"`
+		Env              string        `long:"env" description:"Keep sections of the schema SQL in -- sqldef:if env=name blocks for the environment" value-name:"name"`
+		DryRun           bool          `long:"dry-run" description:"Don't run DDLs but just show them"`
+		Check            bool          `long:"check" description:"Same as --dry-run, but exit with 2 when there are differences"`
+		Quiet            bool          `short:"q" long:"quiet" description:"Same as --check, but print nothing"`
+		Interactive      bool          `long:"interactive" description:"Review each DDL to apply, skip, or postpone it before applying them, like git add -p"`
+		Watch            bool          `long:"watch" description:"Compare the current schema with the desired one every --interval, reporting changes of the drift"`
+		Interval         time.Duration `long:"interval" description:"Interval of --watch" value-name:"duration" default:"10m"`
+		Webhook          string        `long:"webhook" description:"Post changes of the drift found by --watch to the URL as JSON, e.g. a Slack incoming webhook" value-name:"url"`
+		Lint             bool          `long:"lint" description:"Check the desired schema for common problems instead of applying it, exiting with 2 by --check if any"`
+		Output           string        `long:"output" description:"Format of --dry-run output, migration to write a pair of up and down migration files, or liquibase to print a Liquibase changelog" choice:"text" choice:"json" choice:"markdown" choice:"migration" choice:"liquibase" default:"text"`
+		MigrationDir     string        `long:"migration-dir" description:"Directory to write files of --output=migration" value-name:"directory"`
+		MigrationFormat  string        `long:"migration-format" description:"Naming of files of --output=migration" choice:"golang-migrate" choice:"flyway" default:"golang-migrate"`
+		NoColor          bool          `long:"no-color" description:"Don't colorize --dry-run output, which is also disabled by $NO_COLOR"`
+		Compact          bool          `long:"compact" description:"Print generated DDLs as they are, without putting each column of CREATE TABLE on its own line"`
+		QuoteIdentifiers string        `long:"quote-identifiers" description:"Quote identifiers of generated DDLs always, only when required by auto, or never" choice:"always" choice:"auto" choice:"never" default:"always"`
+		LogFormat        string        `long:"log-format" description:"Print one JSON object per event of applying DDLs with json" choice:"text" choice:"json" default:"text"`
+		Plan             string        `long:"plan" description:"Write DDLs to run and a fingerprint of the current schema to the file, instead of applying them" value-name:"plan_file"`
+		ApplyPlan        string        `long:"apply" description:"Apply DDLs in the file written by --plan, unless the current schema has changed since then" value-name:"plan_file"`
+		Rollback         string        `long:"rollback" description:"Write DDLs to revert the generated ones to the file, e.g. for an emergency revert" value-name:"rollback_file"`
+		Export           bool          `long:"export" description:"Just dump the current schema to stdout"`
+		OutputFile       string        `short:"o" long:"output-file" description:"Write the output, e.g. of --export and --dry-run, to the file instead of stdout" value-name:"file"`
+		ExportDir        string        `long:"export-dir" description:"Just dump the current schema to the directory, one file per table, view, type, and trigger" value-name:"directory"`
+		SkipDrop         bool          `long:"skip-drop" description:"Skip destructive changes such as DROP"`
+		EnableDrop       bool          `long:"enable-drop" description:"Enable destructive changes such as DROP"`
+		MaxRisk          string        `long:"max-risk" description:"Abort when a DDL to run is riskier than the level" choice:"safe" choice:"blocking" choice:"destructive"`
+		Policy           string        `long:"policy" description:"Abort when a DDL to run is denied by the YAML file" value-name:"policy_file"`
+		Only             []string      `long:"only" description:"Only apply or show DDLs of the objects, e.g. table:users and index:index_users_on_name, or the numbers of DDLs in the plan" value-name:"kind:name"`
+		TargetTables     []string      `long:"target-table" description:"Only touch or export tables matching the regular expression" value-name:"table_name"`
+		SkipTables       []string      `long:"skip-table" description:"Never touch or export tables matching the regular expression, e.g. awsdms_.*" value-name:"table_name"`
+		Manifest         string        `long:"manifest" description:"Only manage tables and views listed in the file, which records the ones created by sqldef" value-name:"manifest_file"`
+		LockFile         string        `long:"lock-file" description:"Record fingerprints of the applied schema to the file, and warn when the database has changed since then" value-name:"lock_file"`
+		Resume           string        `long:"resume" description:"Record DDLs not applied yet to the file, and apply the ones in it instead of generating DDLs after a failure" value-name:"resume_file"`
+		History          bool          `long:"history" description:"Record applied DDLs with when, by whom, and how long they took to the sqldef_history table"`
+		AuditLog         string        `long:"audit-log" description:"Append a JSON line with the database, the operator, DDLs, and the outcome of each run to the file" value-name:"audit_log"`
+		BeforeApply      string        `long:"before-apply" description:"Execute the given string before applying the regular DDLs"`
+		AfterApply       string        `long:"after-apply" description:"Execute the given string after applying the regular DDLs"`
+		PreApplyHook     string        `long:"pre-apply-hook" description:"Run the shell command with DDLs to apply on stdin before applying them, aborting if it fails" value-name:"command"`
+		PostApplyHook    string        `long:"post-apply-hook" description:"Run the shell command with applied DDLs on stdin after applying them" value-name:"command"`
+		Progress         bool          `long:"progress" description:"Print progress of each DDL and a timing summary to stderr while applying DDLs"`
+		Summary          bool          `long:"summary" description:"Print numbers of created, altered, and dropped tables, columns, and indexes, and the estimated or actual duration after DDLs"`
+		Retry            int           `long:"retry" description:"Retry DDLs failing with lock timeouts, deadlocks, or serialization failures up to the number of times" value-name:"count"`
+		RetryWait        time.Duration `long:"retry-wait" description:"Interval of --retry" value-name:"duration" default:"5s"`
+		Timeout          time.Duration `long:"timeout" description:"Give up after the duration, cancelling the running DDL and rolling back the transaction" value-name:"duration"`
+		Completion       string        `long:"completion" description:"Print a completion script of the shell" choice:"bash" choice:"zsh" choice:"fish" hidden:"true"`
+		Help             bool          `long:"help" description:"Show this help"`
+		Version          bool          `long:"version" description:"Show this version"`
+	}
+
+	parser := flags.NewParser(&opts, flags.PassDoubleDash)
+	parser.Usage = "--adapter-cmd=command [option...] [adapter_arg...]"
+	args, err := sqldef.ParseArgs(parser, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.Help {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+
+	if opts.Version {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+
+	if len(opts.Completion) > 0 {
+		if err := sqldef.WriteCompletion(os.Stdout, parser, opts.Completion); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if len(strings.TrimSpace(opts.AdapterCmd)) == 0 {
+		fmt.Print("No --adapter-cmd is specified!\n\n")
+		parser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	adapter.SetLogFormat(opts.LogFormat)
+	desiredFiles, currentFile := sqldef.ParseFiles(opts.File, len(args) > 0)
+	options := sqldef.Options{
+		DesiredFiles:     desiredFiles,
+		CurrentFile:      currentFile,
+		ExpandEnv:        opts.ExpandEnv,
+		Template:         opts.Template,
+		Env:              opts.Env,
+		DryRun:           opts.DryRun,
+		Check:            opts.Check,
+		Quiet:            opts.Quiet,
+		Interactive:      opts.Interactive,
+		Watch:            opts.Watch,
+		Interval:         opts.Interval,
+		Webhook:          opts.Webhook,
+		Lint:             opts.Lint,
+		Config:           opts.Config,
+		Output:           opts.Output,
+		MigrationDir:     opts.MigrationDir,
+		MigrationFormat:  opts.MigrationFormat,
+		NoColor:          opts.NoColor,
+		Compact:          opts.Compact,
+		QuoteIdentifiers: opts.QuoteIdentifiers,
+		Plan:             opts.Plan,
+		ApplyPlan:        opts.ApplyPlan,
+		Rollback:         opts.Rollback,
+		Export:           opts.Export,
+		OutputFile:       opts.OutputFile,
+		ExportDir:        opts.ExportDir,
+		SkipDrop:         opts.SkipDrop,
+		EnableDrop:       opts.EnableDrop,
+		MaxRisk:          opts.MaxRisk,
+		Policy:           opts.Policy,
+		Only:             opts.Only,
+		TargetTables:     opts.TargetTables,
+		SkipTables:       opts.SkipTables,
+		Manifest:         opts.Manifest,
+		LockFile:         opts.LockFile,
+		Resume:           opts.Resume,
+		History:          opts.History,
+		AuditLog:         opts.AuditLog,
+		Version:          version,
+		BeforeApply:      opts.BeforeApply,
+		AfterApply:       opts.AfterApply,
+		PreApplyHook:     opts.PreApplyHook,
+		PostApplyHook:    opts.PostApplyHook,
+		Progress:         opts.Progress,
+		Summary:          opts.Summary,
+		Retry:            opts.Retry,
+		RetryWait:        opts.RetryWait,
+		Timeout:          opts.Timeout,
+	}
+
+	// Remaining arguments are passed to the adapter command
+	command := append(strings.Fields(opts.AdapterCmd), args...)
+	options.Target = strings.Join(command, " ")
+	return command, &options
+}
+
+// Return the dialect and options of `sqldef diff current.sql desired.sql`, which compares two files without any database
+func parseDiffOptions(args []string) (schema.GeneratorMode, *sqldef.Options) {
+	var opts struct {
+		Dialect    string `long:"dialect" description:"SQL dialect of the files" choice:"mysql" choice:"postgres" choice:"sqlite3" choice:"mssql" choice:"cockroach" choice:"redshift"`
+		Check      bool   `long:"check" description:"Exit with 2 when there are differences"`
+		Output     string `long:"output" description:"Format of the output" choice:"text" choice:"json" default:"text"`
+		Compact    bool   `long:"compact" description:"Print generated DDLs as they are, without putting each column of CREATE TABLE on its own line"`
+		NoColor    bool   `long:"no-color" description:"Don't colorize the output, which is also disabled by $NO_COLOR"`
+		SkipDrop   bool   `long:"skip-drop" description:"Skip destructive changes such as DROP"`
+		EnableDrop bool   `long:"enable-drop" description:"Enable destructive changes such as DROP"`
+		Help       bool   `long:"help" description:"Show this help"`
+	}
+
+	parser := flags.NewParser(&opts, flags.None)
+	parser.Usage = "diff --dialect=dialect [option...] current.sql desired.sql"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.Help {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+
+	if len(opts.Dialect) == 0 || len(args) != 2 {
+		fmt.Print("Both --dialect and two files are required!\n\n")
+		parser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	options := sqldef.Options{
+		DesiredFiles: []string{args[1]},
+		CurrentFile:  args[0],
+		Check:        opts.Check,
+		Output:       opts.Output,
+		Compact:      opts.Compact,
+		NoColor:      opts.NoColor,
+		SkipDrop:     opts.SkipDrop,
+		EnableDrop:   opts.EnableDrop,
+	}
+	return generatorModes[opts.Dialect], &options
+}
+
+// Return the dialect and options of `sqldef normalize schema.sql`, which prints the schema in the form of --export
+func parseNormalizeOptions(args []string) (schema.GeneratorMode, *sqldef.Options) {
+	var opts struct {
+		Dialect    string   `long:"dialect" description:"SQL dialect of the files" choice:"mysql" choice:"postgres" choice:"sqlite3" choice:"mssql" choice:"cockroach" choice:"redshift"`
+		ExpandEnv  bool     `long:"expand-env" description:"Expand ${VAR} in the schema SQL with environment variables"`
+		SkipTables []string `long:"skip-table" description:"Never print tables matching the regular expression" value-name:"table_name"`
+		Help       bool     `long:"help" description:"Show this help"`
+	}
+
+	parser := flags.NewParser(&opts, flags.None)
+	parser.Usage = "normalize --dialect=dialect [option...] schema.sql..."
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.Help {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+
+	if len(opts.Dialect) == 0 || len(args) == 0 {
+		fmt.Print("Both --dialect and files are required!\n\n")
+		parser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	options := sqldef.Options{
+		DesiredFiles: args,
+		Normalize:    true,
+		ExpandEnv:    opts.ExpandEnv,
+		SkipTables:   opts.SkipTables,
+	}
+	return generatorModes[opts.Dialect], &options
+}
+
+// Return the dialect and the file of `sqldef convert db/schema.rb`, which prints a schema of another tool as SQL
+func parseConvertOptions(args []string) (schema.GeneratorMode, string) {
+	var opts struct {
+		Dialect string `long:"dialect" description:"SQL dialect to convert the file to" choice:"mysql" choice:"postgres" choice:"sqlite3" choice:"mssql" choice:"cockroach" choice:"redshift"`
+		Help    bool   `long:"help" description:"Show this help"`
+	}
+
+	parser := flags.NewParser(&opts, flags.None)
+	parser.Usage = "convert --dialect=dialect schema.rb|structure.sql|schema.prisma|schema.yml"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.Help {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+
+	if len(opts.Dialect) == 0 || len(args) != 1 {
+		fmt.Print("Both --dialect and a file are required!\n\n")
+		parser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+	return generatorModes[opts.Dialect], args[0]
+}
+
+// Return the adapter command, the address to listen, and options of `sqldef serve`, which plans and applies schema changes over HTTP
+func parseServeOptions(args []string) ([]string, string, sqldef.ServerOptions) {
+	var opts struct {
+		AdapterCmd       string        `long:"adapter-cmd" description:"Command of an adapter speaking sqldef's JSON protocol over stdin/stdout" value-name:"command"`
+		Listen           string        `long:"listen" description:"Address to listen on" value-name:"address" default:"127.0.0.1:8080"`
+		TokenEnv         string        `long:"token-env" description:"Read the token required as 'Authorization: Bearer <token>' to plan and apply from the environment variable" value-name:"name" default:"SQLDEF_TOKEN"`
+		ApproverTokenEnv string        `long:"approver-token-env" description:"Read another token required to approve plans from the environment variable" value-name:"name" default:"SQLDEF_APPROVER_TOKEN"`
+		EnableDrop       bool          `long:"enable-drop" description:"Enable destructive changes such as DROP"`
+		Timeout          time.Duration `long:"timeout" description:"Give up applying a plan after the duration, cancelling the running DDL and rolling back the transaction" value-name:"duration"`
+		TargetTables     []string      `long:"target-table" description:"Only touch tables matching the regular expression" value-name:"table_name"`
+		SkipTables       []string      `long:"skip-table" description:"Never touch tables matching the regular expression, e.g. awsdms_.*" value-name:"table_name"`
+		Help             bool          `long:"help" description:"Show this help"`
+	}
+
+	parser := flags.NewParser(&opts, flags.PassDoubleDash)
+	parser.Usage = "serve --adapter-cmd=command [option...] [adapter_arg...]"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.Help {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+
+	if len(strings.TrimSpace(opts.AdapterCmd)) == 0 {
+		// Built-in databases are served by --serve of their commands, e.g. `psqldef --serve`
+		fmt.Print("No --adapter-cmd is specified! Only a database of --adapter-cmd is served, and psqldef and mysqldef serve theirs by --serve.\n\n")
+		parser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	token, approverToken, err := sqldef.ServerTokens(opts.TokenEnv, opts.ApproverTokenEnv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config, err := schema.ParseGeneratorConfig(opts.TargetTables, opts.SkipTables)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	command := append(strings.Fields(opts.AdapterCmd), args...)
+	return command, opts.Listen, sqldef.ServerOptions{Token: token, ApproverToken: approverToken, EnableDrop: opts.EnableDrop, Timeout: opts.Timeout, Config: config}
+}
+
+// Return the database of the adapter command, and the generator mode of its dialect
+func connectExternalDatabase(command []string) (*external.ExternalDatabase, schema.GeneratorMode) {
+	externalDatabase, err := external.NewDatabase(command)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dialect, err := externalDatabase.Dialect()
+	if err != nil {
+		log.Fatal(err)
+	}
+	generatorMode, ok := generatorModes[dialect]
+	if !ok {
+		log.Fatalf("Unsupported dialect '%s' is returned by the adapter command", dialect)
+	}
+	return externalDatabase, generatorMode
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		generatorMode, options := parseDiffOptions(os.Args[2:])
+		sqldef.Run(generatorMode, file.NewDatabase(options.CurrentFile), options)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "normalize" {
+		generatorMode, options := parseNormalizeOptions(os.Args[2:])
+		sqldef.Run(generatorMode, nil, options) // no database is needed
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		generatorMode, file := parseConvertOptions(os.Args[2:])
+		sql, err := sqldef.ConvertSchema(generatorMode, file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(sql)
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		command, listen, serverOptions := parseServeOptions(os.Args[2:])
+		externalDatabase, generatorMode := connectExternalDatabase(command)
+		defer externalDatabase.Close()
+
+		log.Printf("Listening on %s", listen)
+		log.Fatal(http.ListenAndServe(listen, sqldef.NewServer(generatorMode, externalDatabase, serverOptions)))
+	}
+
+	command, options := parseOptions(os.Args[1:])
+	externalDatabase, generatorMode := connectExternalDatabase(command)
+	defer externalDatabase.Close()
+
+	var database adapter.Database = externalDatabase
+	if len(options.CurrentFile) > 0 {
+		database = file.NewDatabase(options.CurrentFile)
+	}
+
+	sqldef.Run(generatorMode, database, options)
+}
@@ -0,0 +1,457 @@
+// Integration test of sqldef command with an external adapter.
+//
+// Test requirement:
+//   - go command
+//   - sh command
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// An adapter command which has a `users` table and logs executed DDLs to stderr
+const adapterScript = `#!/bin/sh
+while read -r line; do
+  case "$line" in
+    *'"dialect"'*) printf '%s\n' '{"result":"sqlite3"}' ;;
+    *'"table_names"'*) printf '%s\n' '{"result":["users"]}' ;;
+    *'"dump_table_ddl"'*) printf '%s\n' '{"result":"CREATE TABLE users (\n  id integer PRIMARY KEY\n);"}' ;;
+    *'"exec"'*) printf 'executed: %s\n' "$line" >&2; printf '%s\n' '{"result":null}' ;;
+    *) printf '%s\n' '{"result":null}' ;;
+  esac
+done
+`
+
+func TestSqldefExport(t *testing.T) {
+	output := assertedExecute(t, "./sqldef", "--adapter-cmd", "./adapter.sh", "--export")
+	assertEquals(t, output, "CREATE TABLE users (\n  id integer PRIMARY KEY\n);\n")
+}
+
+func TestSqldefApply(t *testing.T) {
+	writeFile("schema.sql", "CREATE TABLE users (\n  id integer PRIMARY KEY,\n  name text\n);\n")
+
+	output := assertedExecute(t, "./sqldef", "--adapter-cmd", "./adapter.sh", "--file", "schema.sql", "--dry-run")
+	assertEquals(t, output, "-- dry run --\nALTER TABLE `users` ADD COLUMN `name` text;\n")
+
+	output = assertedExecute(t, "./sqldef", "--adapter-cmd", "./adapter.sh", "--file", "schema.sql")
+	assertEquals(t, output, "-- Apply --\n"+
+		"ALTER TABLE `users` ADD COLUMN `name` text;\n"+
+		"executed: {\"method\":\"exec\",\"query\":\"ALTER TABLE `users` ADD COLUMN `name` text\"}\n")
+}
+
+func TestSqldefDiff(t *testing.T) {
+	writeFile("current.sql", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY);\nCREATE TABLE logs (id bigint);\n")
+	writeFile("schema.sql", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, name text);\n")
+	defer os.Remove("current.sql")
+
+	output := assertedExecute(t, "./sqldef", "diff", "--dialect=postgres", "current.sql", "schema.sql")
+	assertEquals(t, output, "-- dry run --\n"+
+		`ALTER TABLE "public"."users" ADD COLUMN "name" text;`+"\n"+
+		`-- Skipped: DROP TABLE "public"."logs";`+"\n"+
+		"-- Skipped destructive DDLs: 1 --\n")
+
+	output, err := execute("./sqldef", "diff", "--dialect=mysql", "--enable-drop", "--check", "current.sql", "schema.sql")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Errorf("expected exit status 2 for differences, but got: %v", err)
+	}
+	assertEquals(t, output, "-- dry run --\nALTER TABLE `users` ADD COLUMN `name` text AFTER `id`;\nDROP TABLE `logs`;\n")
+
+	if out, err := execute("./sqldef", "diff", "current.sql", "schema.sql"); err == nil {
+		t.Errorf("no --dialect must be error, but successfully got: %s", out)
+	}
+}
+
+func TestSqldefDiffFormat(t *testing.T) {
+	writeFile("current.sql", "")
+	defer os.Remove("current.sql")
+
+	// CREATE TABLE written in the desired schema is kept as it's written
+	createTable := "CREATE TABLE users (id integer,   name text,\n\tage integer  );\n"
+	writeFile("schema.sql", createTable)
+	output := assertedExecute(t, "./sqldef", "diff", "--dialect=postgres", "current.sql", "schema.sql")
+	assertEquals(t, output, "-- dry run --\n"+createTable)
+
+	// CREATE TABLE whose foreign key to a table created later is deferred is put one column per line, keeping the text of each column
+	writeFile("schema.sql", "CREATE TABLE posts (id integer PRIMARY KEY, user_id integer REFERENCES users (id));\n"+
+		"CREATE TABLE users (id integer PRIMARY KEY, name text CHECK (name IN (E'a\\'', 'b,  c')),\n"+
+		"    post_id integer REFERENCES posts (id),   bio text\n"+
+		"      DEFAULT ''\n"+
+		");\n")
+	output = assertedExecute(t, "./sqldef", "diff", "--dialect=postgres", "current.sql", "schema.sql")
+	assertEquals(t, output, "-- dry run --\n"+
+		"CREATE TABLE users (\n"+
+		"  id integer PRIMARY KEY,\n"+
+		`  name text CHECK (name IN (E'a\'', 'b,  c')),`+"\n"+
+		"  post_id integer,\n"+
+		"  bio text\n"+
+		"      DEFAULT ''\n"+
+		");\n"+
+		"CREATE TABLE posts (id integer PRIMARY KEY, user_id integer REFERENCES users (id));\n"+
+		"ALTER TABLE \"public\".\"users\" ADD FOREIGN KEY (post_id) REFERENCES posts (id);\n")
+
+	// Spaces, commas, and parentheses in identifiers quoted by brackets are kept
+	writeFile("schema.sql", "CREATE TABLE [dbo].[users] ([id] int PRIMARY KEY, [table_id] int REFERENCES [dbo].[my  table] ([x,  (y]]]));\n"+
+		"CREATE TABLE [dbo].[my  table] ([first  name] nvarchar(10) DEFAULT 'a  b', [x,  (y]]] int PRIMARY KEY,"+
+		" [user_id] int, CONSTRAINT [fk] FOREIGN KEY ([user_id]) REFERENCES [dbo].[users] ([id]));\n")
+	output = assertedExecute(t, "./sqldef", "diff", "--dialect=mssql", "current.sql", "schema.sql")
+	assertEquals(t, output, "-- dry run --\n"+
+		"CREATE TABLE [dbo].[my  table] (\n"+
+		"  [first  name] nvarchar(10) DEFAULT 'a  b',\n"+
+		"  [x,  (y]]] int PRIMARY KEY,\n"+
+		"  [user_id] int\n"+
+		");\n"+
+		"CREATE TABLE [dbo].[users] ([id] int PRIMARY KEY, [table_id] int REFERENCES [dbo].[my  table] ([x,  (y]]]));\n"+
+		"ALTER TABLE [dbo].[my  table] ADD CONSTRAINT [fk] FOREIGN KEY ([user_id]) REFERENCES [dbo].[users] ([id]);\n")
+
+	// --compact prints it as it's built
+	output = assertedExecute(t, "./sqldef", "diff", "--dialect=mssql", "--compact", "current.sql", "schema.sql")
+	assertEquals(t, output, "-- dry run --\n"+
+		"CREATE TABLE [dbo].[my  table] ([first  name] nvarchar(10) DEFAULT 'a  b', [x,  (y]]] int PRIMARY KEY, [user_id] int);\n"+
+		"CREATE TABLE [dbo].[users] ([id] int PRIMARY KEY, [table_id] int REFERENCES [dbo].[my  table] ([x,  (y]]]));\n"+
+		"ALTER TABLE [dbo].[my  table] ADD CONSTRAINT [fk] FOREIGN KEY ([user_id]) REFERENCES [dbo].[users] ([id]);\n")
+}
+
+func TestSqldefDiffDefaults(t *testing.T) {
+	defer os.Remove("current.sql")
+	for _, tc := range []struct {
+		dialect  string
+		current  string
+		desired  string
+		modified bool
+	}{
+		{"mysql", "datetime DEFAULT now()", "datetime DEFAULT CURRENT_TIMESTAMP", false},
+		{"mysql", "datetime DEFAULT CURRENT_TIMESTAMP(0)", "datetime DEFAULT CURRENT_TIMESTAMP()", false},
+		{"mysql", "tinyint(1) DEFAULT '1'", "tinyint(1) DEFAULT true", false},
+		{"mysql", "decimal(5,2) DEFAULT '1.50'", "decimal(5,2) DEFAULT 1.5", false},
+		{"postgres", "timestamp DEFAULT now()", "timestamp DEFAULT CURRENT_TIMESTAMP", false},
+		{"postgres", "integer DEFAULT '0'::integer", "integer DEFAULT 0", false},
+		{"postgres", "boolean DEFAULT 't'", "boolean DEFAULT true", false},
+
+		// String literals are not functions or keywords
+		{"mysql", "varchar(10) DEFAULT 'true'", "varchar(10) DEFAULT '1'", true},
+		{"mysql", "varchar(20) DEFAULT 'now'", "varchar(20) DEFAULT CURRENT_TIMESTAMP", true},
+		{"postgres", "text DEFAULT 't'", "text DEFAULT 'true'", true},
+		{"postgres", "timestamp DEFAULT 'now'", "timestamp DEFAULT CURRENT_TIMESTAMP", true},
+		// CURRENT_TIMESTAMP(0) truncates fractional seconds in PostgreSQL
+		{"postgres", "timestamp DEFAULT now()", "timestamp DEFAULT CURRENT_TIMESTAMP(0)", true},
+		// Numbers are compared exactly
+		{"mysql", "decimal(30,20) DEFAULT 0.1000000000000000000001", "decimal(30,20) DEFAULT 0.1", true},
+		{"postgres", "numeric(30,20) DEFAULT '0.1000000000000000000001'::numeric", "numeric(30,20) DEFAULT 0.1", true},
+	} {
+		writeFile("current.sql", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, col "+tc.current+");\n")
+		writeFile("schema.sql", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, col "+tc.desired+");\n")
+		output := assertedExecute(t, "./sqldef", "diff", "--dialect="+tc.dialect, "current.sql", "schema.sql")
+		if modified := output != "-- Nothing is modified --\n"; modified != tc.modified {
+			t.Errorf("expected modified=%t from %s DEFAULT `%s` to `%s`, but got:\n%s", tc.modified, tc.dialect, tc.current, tc.desired, output)
+		}
+	}
+}
+
+func TestSqldefDiffFloatPrecision(t *testing.T) {
+	defer os.Remove("current.sql")
+	for _, tc := range []struct {
+		current  string
+		desired  string
+		modified bool
+	}{
+		{"real", "float(1)", false},
+		{"real", "float(24)", false},
+		{"double precision", "float(25)", false},
+		{"double precision", "FLOAT(53)", false},
+		{"double precision", "float", false},
+		{"real", "float(25)", true},
+		{"double precision", "float(24)", true},
+	} {
+		writeFile("current.sql", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, col "+tc.current+");\n")
+		writeFile("schema.sql", "CREATE TABLE users (id bigint NOT NULL PRIMARY KEY, col "+tc.desired+");\n")
+		output := assertedExecute(t, "./sqldef", "diff", "--dialect=postgres", "current.sql", "schema.sql")
+		if modified := output != "-- Nothing is modified --\n"; modified != tc.modified {
+			t.Errorf("expected modified=%t from `%s` to `%s`, but got:\n%s", tc.modified, tc.current, tc.desired, output)
+		}
+	}
+}
+
+func TestSqldefDiffExtendedProperty(t *testing.T) {
+	defer os.Remove("current.sql")
+	property := "@name = N'MS_Description', %s@level0type = N'SCHEMA', @level0name = N'sales', @level1type = N'TABLE', @level1name = N'orders', @level2type = N'COLUMN', @level2name = N'id'"
+	writeFile("current.sql", "CREATE TABLE sales.orders (id int);\n"+
+		"EXEC sp_addextendedproperty "+fmt.Sprintf(property, "@value = N'Old', ")+";\n")
+
+	// The schema of the property is kept when it's updated
+	writeFile("schema.sql", "CREATE TABLE sales.orders (id int);\n"+
+		"EXEC sp_addextendedproperty "+fmt.Sprintf(property, "@value = N'New', ")+";\n")
+	output := assertedExecute(t, "./sqldef", "diff", "--dialect=mssql", "current.sql", "schema.sql")
+	assertEquals(t, output, "-- dry run --\n"+
+		"EXEC sp_updateextendedproperty "+fmt.Sprintf(property, "@value = N'New', ")+";\n")
+
+	// and when it's dropped
+	writeFile("schema.sql", "CREATE TABLE sales.orders (id int);\n")
+	output = assertedExecute(t, "./sqldef", "diff", "--dialect=mssql", "current.sql", "schema.sql")
+	assertEquals(t, output, "-- dry run --\n"+
+		"EXEC sp_dropextendedproperty "+fmt.Sprintf(property, "")+";\n")
+}
+
+func TestSqldefNormalize(t *testing.T) {
+	writeFile("schema.sql", "CREATE INDEX posts_user_id ON posts (user_id);\n"+
+		"create table posts (id bigserial primary key, user_id int not null, created_at timestamp with time zone default now());\n")
+
+	output := assertedExecute(t, "./sqldef", "normalize", "--dialect=postgres", "schema.sql")
+	assertEquals(t, output, `CREATE TABLE "public"."posts" (`+"\n"+
+		`    "id" bigserial NOT NULL,`+"\n"+
+		`    "user_id" integer NOT NULL,`+"\n"+
+		`    "created_at" timestamp with time zone DEFAULT now(),`+"\n"+
+		`    PRIMARY KEY ("id")`+"\n"+
+		");\n\n"+
+		`CREATE INDEX "posts_user_id" ON "public"."posts" ("user_id");`+"\n")
+
+	writeFile("schema.sql", output)
+	assertEquals(t, assertedExecute(t, "./sqldef", "normalize", "--dialect=postgres", "schema.sql"), output)
+
+	if out, err := execute("./sqldef", "normalize", "schema.sql"); err == nil {
+		t.Errorf("no --dialect must be error, but successfully got: %s", out)
+	}
+}
+
+func TestSqldefConvert(t *testing.T) {
+	writeFile("schema.rb", `ActiveRecord::Schema[7.0].define(version: 2023_01_01_000000) do
+  create_table "users", force: :cascade do |t|
+    t.string "name", null: false
+    t.index ["name"], name: "index_users_on_name", unique: true
+  end
+
+  create_table "posts", force: :cascade do |t|
+    t.bigint "user_id"
+  end
+
+  add_foreign_key "posts", "users"
+end
+`)
+
+	output := assertedExecute(t, "./sqldef", "convert", "--dialect=postgres", "schema.rb")
+	assertEquals(t, output, `CREATE TABLE "users" (`+"\n"+
+		`  "id" bigserial NOT NULL PRIMARY KEY,`+"\n"+
+		`  "name" character varying NOT NULL`+"\n"+
+		");\n"+
+		`CREATE UNIQUE INDEX "index_users_on_name" ON "users" ("name");`+"\n"+
+		`CREATE TABLE "posts" (`+"\n"+
+		`  "id" bigserial NOT NULL PRIMARY KEY,`+"\n"+
+		`  "user_id" bigint,`+"\n"+
+		`  CONSTRAINT "fk_rails_5b5ddfd518" FOREIGN KEY ("user_id") REFERENCES "users" ("id")`+"\n"+
+		");\n")
+
+	if out, err := execute("./sqldef", "convert", "schema.rb"); err == nil {
+		t.Errorf("no --dialect must be error, but successfully got: %s", out)
+	}
+}
+
+func TestSqldefConvertPrisma(t *testing.T) {
+	writeFile("schema.prisma", `model User {
+  id    Int    @id @default(autoincrement())
+  email String @unique
+  posts Post[]
+
+  @@map("users")
+}
+
+model Post {
+  id       Int  @id @default(autoincrement())
+  author   User @relation(fields: [authorId], references: [id])
+  authorId Int  @map("author_id")
+}
+`)
+
+	output := assertedExecute(t, "./sqldef", "convert", "--dialect=postgres", "schema.prisma")
+	assertEquals(t, output, `CREATE TABLE "users" (`+"\n"+
+		`  "id" serial NOT NULL,`+"\n"+
+		`  "email" text NOT NULL,`+"\n"+
+		`  CONSTRAINT "users_pkey" PRIMARY KEY ("id")`+"\n"+
+		");\n"+
+		`CREATE UNIQUE INDEX "users_email_key" ON "users" ("email");`+"\n"+
+		`CREATE TABLE "Post" (`+"\n"+
+		`  "id" serial NOT NULL,`+"\n"+
+		`  "author_id" integer NOT NULL,`+"\n"+
+		`  CONSTRAINT "Post_pkey" PRIMARY KEY ("id"),`+"\n"+
+		`  CONSTRAINT "Post_author_id_fkey" FOREIGN KEY ("author_id") REFERENCES "users" ("id") ON DELETE RESTRICT ON UPDATE CASCADE`+"\n"+
+		");\n")
+}
+
+func TestSqldefConvertYAML(t *testing.T) {
+	writeFile("schema.yml", `tables:
+  - name: users
+    columns:
+      - {name: id, type: bigint, primary_key: true, auto_increment: true}
+      - {name: email, type: string, unique: true}
+      - {name: active, type: boolean, default: true}
+    indexes:
+      - {columns: [active, email]}
+`)
+
+	output := assertedExecute(t, "./sqldef", "convert", "--dialect=mysql", "schema.yml")
+	assertEquals(t, output, "CREATE TABLE `users` (\n"+
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n"+
+		"  `email` varchar(255) NOT NULL,\n"+
+		"  `active` tinyint(1) NOT NULL DEFAULT 1,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		");\n"+
+		"CREATE UNIQUE INDEX `users_email_key` ON `users` (`email`);\n"+
+		"CREATE INDEX `users_active_email_idx` ON `users` (`active`, `email`);\n")
+
+	output = assertedExecute(t, "./sqldef", "convert", "--dialect=postgres", "schema.yml")
+	assertEquals(t, output, `CREATE TABLE "users" (`+"\n"+
+		`  "id" bigserial NOT NULL,`+"\n"+
+		`  "email" varchar(255) NOT NULL,`+"\n"+
+		`  "active" boolean NOT NULL DEFAULT true,`+"\n"+
+		`  CONSTRAINT "users_pkey" PRIMARY KEY ("id")`+"\n"+
+		");\n"+
+		`CREATE UNIQUE INDEX "users_email_key" ON "users" ("email");`+"\n"+
+		`CREATE INDEX "users_active_email_idx" ON "users" ("active", "email");`+"\n")
+}
+
+func TestSqldefServe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	cmd := exec.Command("./sqldef", "serve", "--adapter-cmd", "./adapter.sh", "--listen", address)
+	cmd.Env = append(os.Environ(), "SQLDEF_TOKEN=secret", "SQLDEF_APPROVER_TOKEN=approver")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	request := func(method string, path string, token string, body string) (int, map[string]interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(method, "http://"+address+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		var res *http.Response
+		for i := 0; i < 50; i++ { // wait for the server to start
+			if res, err = http.DefaultClient.Do(req); err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var result map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, result
+	}
+
+	if status, _ := request("POST", "/plans", "wrong", ""); status != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong token, but got %d", status)
+	}
+	if status, _ := request("POST", "/plans", "approver", ""); status != http.StatusForbidden {
+		t.Errorf("expected 403 for a plan by the approver, but got %d", status)
+	}
+	if status, _ := request("POST", "/plans", "secret", strings.Repeat(" ", 10<<20+1)); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a too large schema, but got %d", status)
+	}
+
+	status, plan := request("POST", "/plans", "secret", "CREATE TABLE users (\n  id integer PRIMARY KEY,\n  name text\n);\n")
+	if status != http.StatusCreated {
+		t.Fatalf("expected 201 for a plan, but got %d: %v", status, plan)
+	}
+	statements := plan["statements"].([]interface{})
+	assertEquals(t, statements[0].(map[string]interface{})["statement"].(string), "ALTER TABLE `users` ADD COLUMN `name` text")
+	id := plan["id"].(string)
+
+	if status, _ := request("POST", "/plans/"+id+"/apply", "secret", ""); status != http.StatusConflict {
+		t.Errorf("expected 409 to apply a plan which is not approved, but got %d", status)
+	}
+	if status, _ := request("POST", "/plans/"+id+"/approve", "secret", ""); status != http.StatusForbidden {
+		t.Errorf("expected 403 to approve a plan without the approver's token, but got %d", status)
+	}
+	_, plan = request("POST", "/plans/"+id+"/approve", "approver", "")
+	assertEquals(t, plan["status"].(string), "approved")
+	if status, _ := request("POST", "/plans/"+id+"/apply", "approver", ""); status != http.StatusForbidden {
+		t.Errorf("expected 403 to apply a plan with the approver's token, but got %d", status)
+	}
+	_, plan = request("POST", "/plans/"+id+"/apply", "secret", "")
+	assertEquals(t, plan["status"].(string), "applied")
+	assertEquals(t, plan["output"].(string), "-- Apply --\nALTER TABLE `users` ADD COLUMN `name` text;\n")
+}
+
+func TestSqldefHelp(t *testing.T) {
+	_, err := execute("./sqldef", "--help")
+	if err != nil {
+		t.Errorf("failed to run --help: %s", err)
+	}
+
+	out, err := execute("./sqldef")
+	if err == nil {
+		t.Errorf("no adapter command must be error, but successfully got: %s", out)
+	}
+}
+
+func TestMain(m *testing.M) {
+	writeFile("adapter.sh", adapterScript)
+	mustExecute("chmod", "+x", "adapter.sh")
+	mustExecute("go", "build")
+	status := m.Run()
+	_ = os.Remove("sqldef")
+	_ = os.Remove("adapter.sh")
+	_ = os.Remove("schema.sql")
+	_ = os.Remove("schema.rb")
+	_ = os.Remove("schema.prisma")
+	_ = os.Remove("schema.yml")
+	os.Exit(status)
+}
+
+func mustExecute(command string, args ...string) {
+	out, err := execute(command, args...)
+	if err != nil {
+		log.Printf("failed to execute '%s %s': `%s`", command, strings.Join(args, " "), out)
+		log.Fatal(err)
+	}
+}
+
+func assertedExecute(t *testing.T, command string, args ...string) string {
+	t.Helper()
+	out, err := execute(command, args...)
+	if err != nil {
+		t.Errorf("failed to execute '%s %s' (error: '%s'): `%s`", command, strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+func assertEquals(t *testing.T, actual string, expected string) {
+	t.Helper()
+	if expected != actual {
+		t.Errorf("expected '%s' but got '%s'", expected, actual)
+	}
+}
+
+func execute(command string, args ...string) (string, error) {
+	cmd := exec.Command(command, args...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func writeFile(path string, content string) {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	file.Write(([]byte)(content))
+}
@@ -6,16 +6,22 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/k0kubun/sqldef/adapter"
 	"github.com/k0kubun/sqldef/adapter/sqlite3"
 	"github.com/k0kubun/sqldef/cmd/testutils"
 	"github.com/k0kubun/sqldef/schema"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -60,18 +66,896 @@ func TestSQLite3defApply(t *testing.T) {
 	assertApplyOutput(t, createTable, nothingModified)
 }
 
+func TestSQLite3defMultipleFiles(t *testing.T) {
+	resetTestDatabase()
+
+	createUsers := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n"
+	createView := "CREATE VIEW user_ids AS SELECT id FROM users;\n"
+	writeFile("tables.sql", createUsers)
+	writeFile("views.sql", "CREATE VIEW user_ids AS SELECT id FROM users") // no semicolon
+	defer os.Remove("tables.sql")
+	defer os.Remove("views.sql")
+
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "tables.sql", "--file", "views.sql")
+	assertEquals(t, apply, applyPrefix+createUsers+createView)
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "-f", "tables.sql", "-f", "views.sql")
+	assertEquals(t, apply, nothingModified)
+
+	// Without a database, the first file is compared with the rest of them
+	writeFile("schema.sql", "")
+	output := assertedExecute(t, "./sqlite3def", "--file", "schema.sql", "--file", "tables.sql", "--file", "views.sql")
+	assertEquals(t, output, "-- dry run --\n"+createUsers+createView)
+}
+
+func TestSQLite3defDirectoryAndGlob(t *testing.T) {
+	resetTestDatabase()
+
+	createPosts := "CREATE TABLE posts (id integer NOT NULL PRIMARY KEY);\n"
+	createUsers := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n"
+	if err := os.MkdirAll("schema/tables", 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("schema")
+	writeFile("schema/tables/users.sql", createUsers)
+	writeFile("schema/tables/posts.sql", createPosts)
+	writeFile("schema/README.md", "not a schema")
+
+	// Files are loaded in lexical order
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema/", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createPosts+createUsers)
+	dryRun = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema/**/*.sql", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createPosts+createUsers)
+	dryRun = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema/*/u*.sql", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createUsers)
+}
+
+func TestSQLite3defInclude(t *testing.T) {
+	resetTestDatabase()
+
+	createPosts := "CREATE TABLE posts (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	createUsers := "CREATE TABLE users (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	createView := "CREATE VIEW user_ids AS select id from users;\n"
+	if err := os.MkdirAll("schema/tables", 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("schema")
+	writeFile("schema/tables/users.sql", createUsers)
+	writeFile("schema/tables/posts.sql", "\\ir users.sql\n"+createPosts)
+	writeFile("schema/main.sql", "-- sqldef:include tables/posts.sql\n\\i 'views.sql'\n")
+	writeFile("schema/views.sql", createView)
+
+	// Included files are resolved relative to the including file
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema/main.sql", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createUsers+createPosts+createView)
+
+	writeFile("schema/tables/users.sql", "-- sqldef:include ../main.sql\n")
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema/main.sql", "--dry-run")
+	if err == nil || !strings.Contains(out, "is included recursively") {
+		t.Errorf("expected an error for a recursive include, but got: %s", out)
+	}
+}
+
+func TestSQLite3defExpandEnv(t *testing.T) {
+	resetTestDatabase()
+
+	writeFile("schema.sql", "CREATE TABLE ${TABLE_PREFIX}users (id integer NOT NULL PRIMARY KEY, name text DEFAULT '$1');\n")
+	os.Setenv("TABLE_PREFIX", "staging_")
+	defer os.Unsetenv("TABLE_PREFIX")
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--expand-env", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\nCREATE TABLE staging_users (id integer NOT NULL PRIMARY KEY, name text DEFAULT '$1');\n")
+
+	os.Unsetenv("TABLE_PREFIX")
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--expand-env", "--dry-run")
+	if err == nil {
+		t.Errorf("expected an error for an unset variable, but got: %s", out)
+	}
+}
+
+func TestSQLite3defTemplate(t *testing.T) {
+	resetTestDatabase()
+
+	writeFile("schema.sql", stripHeredoc(`
+		{{range $i := seq .shards}}
+		CREATE TABLE events_{{$i}} (id integer NOT NULL PRIMARY KEY);
+		{{end}}
+		{{if eq .env "staging"}}
+		CREATE TABLE debug_logs (id integer NOT NULL PRIMARY KEY);
+		{{end}}
+		`,
+	))
+	writeFile("values.yml", "shards: 2\nenv: production\n")
+	defer os.Remove("values.yml")
+
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--template", "values.yml", "--dry-run")
+	assertEquals(t, dryRun, stripHeredoc(`
+		-- dry run --
+		CREATE TABLE events_0 (id integer NOT NULL PRIMARY KEY);
+		CREATE TABLE events_1 (id integer NOT NULL PRIMARY KEY);
+		`,
+	))
+
+	writeFile("values.yml", `{"shards": 1}`)
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--template", "values.yml", "--dry-run")
+	if err == nil {
+		t.Errorf("expected an error for a missing value, but got: %s", out)
+	}
+}
+
+func TestSQLite3defYAMLSchema(t *testing.T) {
+	resetTestDatabase()
+
+	writeFile("schema.yml", stripHeredoc(`
+		tables:
+		  - name: users
+		    columns:
+		      - {name: id, type: bigint, primary_key: true, auto_increment: true}
+		      - {name: email, type: string, size: 100}
+		      - {name: active, type: boolean, default: true}
+		      - {name: bio, type: text, nullable: true}
+		  - name: posts
+		    columns:
+		      - {name: id, type: integer, primary_key: true}
+		      - {name: user_id, type: bigint}
+		    foreign_keys:
+		      - columns: [user_id]
+		        references: {table: users, columns: [id]}
+		        on_delete: cascade
+		`,
+	))
+	defer os.Remove("schema.yml")
+
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.yml")
+	assertEquals(t, apply, stripHeredoc(`
+		-- Apply --
+		CREATE TABLE "users" (
+		  "id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		  "email" varchar(100) NOT NULL,
+		  "active" boolean NOT NULL DEFAULT 1,
+		  "bio" text
+		);
+		CREATE TABLE "posts" (
+		  "id" integer NOT NULL,
+		  "user_id" bigint NOT NULL,
+		  PRIMARY KEY ("id"),
+		  CONSTRAINT "posts_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+		);
+		`,
+	))
+	assertEquals(t, assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.yml"), nothingModified)
+}
+
+func TestSQLite3defSeed(t *testing.T) {
+	resetTestDatabase()
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE roles (id integer NOT NULL PRIMARY KEY, name text NOT NULL, admin boolean NOT NULL, created_at text);
+		-- @seed
+		INSERT INTO roles (id, name, admin) VALUES (1, 'admin', true), (2, 'member; it''s', false);
+		`,
+	))
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	assertEquals(t, apply, stripHeredoc(`
+		-- Apply --
+		CREATE TABLE roles (id integer NOT NULL PRIMARY KEY, name text NOT NULL, admin boolean NOT NULL, created_at text);
+		INSERT INTO `+"`roles` (`id`, `name`, `admin`)"+` VALUES (1, 'admin', 1);
+		INSERT INTO `+"`roles` (`id`, `name`, `admin`)"+` VALUES (2, 'member; it''s', 0);
+		`,
+	))
+	assertEquals(t, assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql"), nothingModified)
+
+	// Rows are compared by primary keys, and columns not given by INSERT are not managed
+	mustExecute("sqlite3", "sqlite3def_test", "UPDATE roles SET created_at = 'today'")
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE roles (id integer NOT NULL PRIMARY KEY, name text NOT NULL, admin boolean NOT NULL, created_at text);
+		-- @seed
+		INSERT INTO roles (id, name, admin) VALUES (1, 'owner', true), (3, 'guest', false);
+		`,
+	))
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	assertEquals(t, apply, stripHeredoc(`
+		-- Apply --
+		-- Skipped: DELETE FROM `+"`roles` WHERE `id`"+` = '2';
+		UPDATE `+"`roles` SET `name` = 'owner' WHERE `id`"+` = 1;
+		INSERT INTO `+"`roles` (`id`, `name`, `admin`)"+` VALUES (3, 'guest', 0);
+		-- Skipped destructive DDLs: 1 --
+		`,
+	))
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, apply, "-- Apply --\nDELETE FROM `roles` WHERE `id` = '2';\n")
+
+	writeFile("schema.sql", "CREATE TABLE roles (id integer NOT NULL PRIMARY KEY);\nINSERT INTO roles (id) VALUES (1);\n")
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run")
+	if err == nil || !strings.Contains(out, "-- @seed") {
+		t.Errorf("expected an error for INSERT without -- @seed, but got: %s", out)
+	}
+}
+
+func TestSQLite3defSeedColumnsPerInsert(t *testing.T) {
+	resetTestDatabase()
+
+	// Seeds of a table with different columns share its rows
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE roles (id integer NOT NULL PRIMARY KEY, name text NOT NULL, note text);
+		-- @seed
+		INSERT INTO roles (id, name) VALUES (1, 'admin');
+		-- @seed
+		INSERT INTO roles (id, name, note) VALUES (2, 'member', 'x');
+		`,
+	))
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	assertEquals(t, apply, stripHeredoc(`
+		-- Apply --
+		CREATE TABLE roles (id integer NOT NULL PRIMARY KEY, name text NOT NULL, note text);
+		INSERT INTO `+"`roles` (`id`, `name`)"+` VALUES (1, 'admin');
+		INSERT INTO `+"`roles` (`id`, `name`, `note`)"+` VALUES (2, 'member', 'x');
+		`,
+	))
+	assertEquals(t, assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop"), nothingModified)
+
+	mustExecute("sqlite3", "sqlite3def_test", "INSERT INTO roles (id, name) VALUES (3, 'guest')")
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, apply, "-- Apply --\nDELETE FROM `roles` WHERE `id` = '3';\n")
+}
+
+func TestSQLite3defDependencyOrder(t *testing.T) {
+	resetTestDatabase()
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE VIEW book_authors AS SELECT books.id, authors.name FROM books JOIN authors ON books.author_id = authors.id;
+		CREATE TABLE books (id integer NOT NULL PRIMARY KEY, author_id integer REFERENCES authors (id));
+		CREATE TABLE authors (id integer NOT NULL PRIMARY KEY, name text);
+		`,
+	))
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	assertEquals(t, apply, applyPrefix+stripHeredoc(`
+		CREATE TABLE authors (id integer NOT NULL PRIMARY KEY, name text);
+		CREATE TABLE books (id integer NOT NULL PRIMARY KEY, author_id integer REFERENCES authors (id));
+		CREATE VIEW book_authors AS SELECT books.id, authors.name FROM books JOIN authors ON books.author_id = authors.id;
+		`,
+	))
+
+	// Drop them in the reverse order
+	writeFile("schema.sql", "")
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, apply, applyPrefix+stripHeredoc(`
+		DROP VIEW `+"`book_authors`"+`;
+		DROP TABLE `+"`books`"+`;
+		DROP TABLE `+"`authors`"+`;
+		`,
+	))
+}
+
 func TestSQLite3defDryRun(t *testing.T) {
 	resetTestDatabase()
-	writeFile("schema.sql", stripHeredoc(`
-	    CREATE TABLE users (
-	        id integer NOT NULL PRIMARY KEY,
-	        age integer
-	    );`,
+	writeFile("schema.sql", stripHeredoc(`
+	    CREATE TABLE users (
+	        id integer NOT NULL PRIMARY KEY,
+	        age integer
+	    );`,
+	))
+
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--dry-run", "--file", "schema.sql")
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	assertEquals(t, dryRun, strings.Replace(apply, "Apply", "dry run", 1))
+}
+
+func TestSQLite3defJSONOutput(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY); CREATE TABLE bigdata (data integer);")
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);")
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run", "--output", "json")
+	assertEquals(t, dryRun, stripHeredoc(`
+		[
+		  {
+		    "statement": "ALTER TABLE `+"`users`"+` ADD COLUMN `+"`name`"+` text",
+		    "operation": "ALTER TABLE",
+		    "object": "users",
+		    "destructive": false,
+		    "skipped": false,
+		    "risk": "safe"
+		  },
+		  {
+		    "statement": "DROP TABLE `+"`bigdata`"+`",
+		    "operation": "DROP TABLE",
+		    "object": "bigdata",
+		    "destructive": true,
+		    "skipped": true,
+		    "risk": "destructive"
+		  }
+		]
+		`,
+	))
+
+	mustExecute("sqlite3", "sqlite3def_test", "DROP TABLE bigdata; ALTER TABLE users ADD COLUMN name text;")
+	dryRun = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run", "--output", "json")
+	assertEquals(t, dryRun, "[]\n")
+}
+
+func TestSQLite3defMarkdownOutput(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY); CREATE TABLE bigdata (data integer);")
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);\nCREATE INDEX index_name ON users (name);\n")
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run", "--output", "markdown")
+	assertEquals(t, dryRun, stripHeredoc(`
+		### Schema changes
+
+		DDLs: 3, destructive: 1 (skipped without `+"`--enable-drop`"+`)
+
+		<details>
+		<summary>table: users (DDLs: 2)</summary>
+
+		`+"```sql"+`
+		ALTER TABLE `+"`users`"+` ADD COLUMN `+"`name`"+` text;
+		CREATE INDEX index_name ON users (name);
+		`+"```"+`
+
+		</details>
+		<details open>
+		<summary>⚠️ <b>table: bigdata</b> (DDLs: 1, destructive: 1)</summary>
+
+		`+"```sql"+`
+		-- Skipped: DROP TABLE `+"`bigdata`"+`;
+		`+"```"+`
+
+		</details>
+		`,
+	))
+
+	mustExecute("sqlite3", "sqlite3def_test", "DROP TABLE bigdata; ALTER TABLE users ADD COLUMN name text;")
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);\n")
+	dryRun = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run", "--output", "markdown")
+	assertEquals(t, dryRun, "### Schema changes\n\nNothing is modified.\n")
+}
+
+func TestSQLite3defMaxRisk(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY); CREATE TABLE bigdata (data integer);")
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);\nCREATE INDEX index_name ON users (name);\n")
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--max-risk=safe")
+	if err == nil {
+		t.Errorf("expected --max-risk=safe to abort, but succeeded with: %s", out)
+	}
+	if !strings.Contains(out, "Aborted by --max-risk=safe, since 1 DDLs exceed it:\n-- blocking\nCREATE INDEX index_name ON users (name);") {
+		t.Errorf("expected the blocking DDL to be reported, but got: %s", out)
+	}
+
+	// DROP TABLE is destructive, but it's skipped without --enable-drop
+	out = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--max-risk=blocking")
+	assertEquals(t, out, applyPrefix+stripHeredoc(`
+		ALTER TABLE `+"`users`"+` ADD COLUMN `+"`name`"+` text;
+		CREATE INDEX index_name ON users (name);
+		-- Skipped: DROP TABLE `+"`bigdata`"+`;
+		-- Skipped destructive DDLs: 1 --
+		`,
+	))
+	if out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--max-risk=blocking", "--enable-drop"); err == nil {
+		t.Errorf("expected DROP TABLE to exceed --max-risk=blocking, but succeeded with: %s", out)
+	}
+}
+
+func TestSQLite3defPolicy(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("policy.yml")
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text); CREATE TABLE audit_logs (id integer NOT NULL PRIMARY KEY);")
+
+	writeFile("policy.yml", stripHeredoc(`
+		deny:
+		  - operation: DROP COLUMN
+		  - operation: ALTER
+		    table: audit_%
+		    reason: audit logs are append-only
+		`,
+	))
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\nCREATE TABLE audit_logs (id integer NOT NULL PRIMARY KEY, note text);\n")
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--policy", "policy.yml", "--enable-drop")
+	if err == nil {
+		t.Errorf("expected --policy to abort, but succeeded with: %s", out)
+	}
+	if !strings.Contains(out, stripHeredoc(`
+		Aborted by --policy=policy.yml, since 2 DDLs violate it:
+		-- denied: ALTER on audit_%: audit logs are append-only
+		ALTER TABLE `+"`audit_logs`"+` ADD COLUMN `+"`note`"+` text;
+		-- denied: DROP COLUMN
+		ALTER TABLE `+"`users`"+` DROP COLUMN `+"`name`"+`;
+		`,
+	)) {
+		t.Errorf("expected the denied DDLs to be reported, but got: %s", out)
+	}
+
+	// DROP COLUMN is skipped without --enable-drop
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\nCREATE TABLE audit_logs (id integer NOT NULL PRIMARY KEY);\n")
+	out = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--policy", "policy.yml")
+	assertEquals(t, out, applyPrefix+stripHeredoc(`
+		-- Skipped: ALTER TABLE `+"`users`"+` DROP COLUMN `+"`name`"+`;
+		-- Skipped destructive DDLs: 1 --
+		`,
+	))
+}
+
+func TestSQLite3defLint(t *testing.T) {
+	resetTestDatabase()
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		    id integer NOT NULL PRIMARY KEY,
+		    "order" integer,
+		    active boolean
+		);
+
+		CREATE TABLE posts (
+		    id integer NOT NULL PRIMARY KEY,
+		    user_id integer REFERENCES users (id)
+		);
+
+		CREATE TABLE logs (message text);
+		`,
+	))
+	out := assertedExecute(t, "./sqlite3def", "--file", "schema.sql", "--lint")
+	assertEquals(t, out, stripHeredoc(`
+		schema.sql:3: warning: reserved-word: Column name 'users.order' is a reserved word
+		schema.sql:4: warning: nullable-boolean: Boolean column 'users.active' is nullable
+		schema.sql:9: warning: foreign-key-without-index: Foreign key 'posts(user_id)' has no index
+		schema.sql:12: warning: missing-primary-key: Table 'logs' has no primary key
+		`,
+	))
+	_, err := execute("./sqlite3def", "--file", "schema.sql", "--lint", "--check")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Errorf("expected exit status 2 for violations, but got: %v", err)
+	}
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n")
+	out = assertedExecute(t, "./sqlite3def", "--file", "schema.sql", "--lint", "--check")
+	assertEquals(t, out, "-- No lint violations --\n")
+}
+
+func TestSQLite3defLintConfig(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("config.yml")
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		    id integer NOT NULL PRIMARY KEY,
+		    "order" integer,
+		    name text
+		);
+		CREATE INDEX index_users_on_name ON users (name);
+		CREATE INDEX idx_users_order ON users ("order");
+
+		CREATE TABLE logs (message text);
+		`,
+	))
+	writeFile("config.yml", stripHeredoc(`
+		lint:
+		  rules:
+		    missing-primary-key: error
+		    reserved-word: "off"
+		  naming:
+		    - name: index-name
+		      object: index
+		      pattern: ^idx_{table}_{columns}$
+		`,
+	))
+	out, err := execute("./sqlite3def", "--config", "config.yml", "--file", "schema.sql", "--lint")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Errorf("expected exit status 2 for errors, but got: %v", err)
+	}
+	assertEquals(t, out, stripHeredoc(`
+		schema.sql:6: warning: index-name: Index name 'index_users_on_name' on 'users' doesn't match '^idx_{table}_{columns}$'
+		schema.sql:9: error: missing-primary-key: Table 'logs' has no primary key
+		`,
+	))
+
+	writeFile("config.yml", "lint:\n  rules:\n    unknown-rule: error\n")
+	out, err = execute("./sqlite3def", "--config", "config.yml", "--file", "schema.sql", "--lint")
+	if err == nil {
+		t.Errorf("expected an unknown rule to be rejected, but got: %s", out)
+	}
+}
+
+func TestSQLite3defGenerateGo(t *testing.T) {
+	resetTestDatabase()
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE user_accounts (
+		    id integer NOT NULL PRIMARY KEY,
+		    name text NOT NULL,
+		    api_url text,
+		    created_at datetime NOT NULL
+		);
+		`,
+	))
+	expected := "// Code generated by sqldef --generate-go. DO NOT EDIT.\n\n" +
+		"package models\n\n" +
+		"import (\n\t\"database/sql\"\n\t\"time\"\n)\n\n" +
+		"// UserAccounts is a row of the table user_accounts\n" +
+		"type UserAccounts struct {\n" +
+		"\tID        int64          `db:\"id\" json:\"id\"`\n" +
+		"\tName      string         `db:\"name\" json:\"name\"`\n" +
+		"\tAPIURL    sql.NullString `db:\"api_url\" json:\"api_url\"`\n" +
+		"\tCreatedAt time.Time      `db:\"created_at\" json:\"created_at\"`\n" +
+		"}\n"
+	assertEquals(t, assertedExecute(t, "./sqlite3def", "--file", "schema.sql", "--generate-go"), expected)
+
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	out := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export", "--generate-go=db")
+	assertEquals(t, out, strings.Replace(expected, "package models", "package db", 1))
+}
+
+func TestSQLite3defExportFormat(t *testing.T) {
+	resetTestDatabase()
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		    id integer NOT NULL PRIMARY KEY,
+		    name text NOT NULL
+		);
+		CREATE TABLE posts (
+		    id integer NOT NULL PRIMARY KEY,
+		    user_id integer,
+		    CONSTRAINT posts_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id)
+		);
+		`,
+	))
+	expected := stripHeredoc(`
+		erDiagram
+		    users {
+		        integer id PK
+		        text name
+		    }
+		    posts {
+		        integer id PK
+		        integer user_id FK
+		    }
+		    users |o--o{ posts : "posts_user_id_fkey"
+		`,
+	)
+	assertEquals(t, assertedExecute(t, "./sqlite3def", "--file", "schema.sql", "--export-format=mermaid"), expected)
+
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	assertEquals(t, assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export", "--export-format=mermaid"), expected)
+
+	out := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export", "--export-format=dot")
+	if !strings.Contains(out, `"posts":"user_id" -> "users":"id" [label="posts_user_id_fkey", style=dashed];`) {
+		t.Errorf("expected an edge of the foreign key, but got: %s", out)
+	}
+
+	out = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export", "--export-format=json")
+	var inventory struct {
+		Tables []struct {
+			Name    string
+			Columns []struct {
+				Name     string
+				Type     string
+				Nullable bool
+			}
+			PrimaryKey  []string `json:"primary_key"`
+			ForeignKeys []struct {
+				Columns         []string
+				ReferencedTable string `json:"referenced_table"`
+			} `json:"foreign_keys"`
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &inventory); err != nil {
+		t.Fatalf("failed to parse --export-format=json: %s\n%s", err, out)
+	}
+	if len(inventory.Tables) != 2 || inventory.Tables[1].Name != "posts" || inventory.Tables[1].Columns[1].Type != "integer" ||
+		!inventory.Tables[1].Columns[1].Nullable || inventory.Tables[1].PrimaryKey[0] != "id" ||
+		len(inventory.Tables[1].ForeignKeys) != 1 || inventory.Tables[1].ForeignKeys[0].ReferencedTable != "users" {
+		t.Errorf("unexpected --export-format=json: %s", out)
+	}
+}
+
+func TestSQLite3defPlan(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("plan.sql")
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);")
+
+	addColumn := "ALTER TABLE `users` ADD COLUMN `name` text;\n"
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);")
+	plan := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--plan", "plan.sql")
+	assertEquals(t, plan, "-- dry run --\n"+addColumn)
+
+	// Refuse to apply the plan when the schema has changed since --plan
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE posts (id integer);")
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--apply", "plan.sql")
+	if err == nil {
+		t.Errorf("expected a changed schema to be rejected, but got: %s", out)
+	}
+
+	mustExecute("sqlite3", "sqlite3def_test", "DROP TABLE posts;")
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--apply", "plan.sql")
+	assertEquals(t, apply, applyPrefix+addColumn)
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	assertEquals(t, apply, nothingModified)
+}
+
+func TestSQLite3defRollback(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("plan.sql")
+	defer os.Remove("rollback.sql")
+	current := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text NOT NULL DEFAULT 'x');\nCREATE TABLE logs (id integer);"
+	mustExecute("sqlite3", "sqlite3def_test", current)
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, email text);\nCREATE TABLE posts (id integer);\n")
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--plan", "plan.sql", "--rollback", "rollback.sql")
+	rollback, err := os.ReadFile("rollback.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Skipped DROPs don't need to be reverted
+	assertEquals(t, string(rollback), "-- sqldef rollback: DDLs to revert the schema to the one before applying the generated DDLs\n"+
+		"ALTER TABLE `users` DROP COLUMN `email`;\n"+
+		"DROP TABLE `posts`;\n")
+
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop", "--rollback", "rollback.sql")
+	rollback, err = os.ReadFile("rollback.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, string(rollback), "-- sqldef rollback: DDLs to revert the schema to the one before applying the generated DDLs\n"+
+		"ALTER TABLE `users` ADD COLUMN `name` text NOT NULL DEFAULT 'x';\n"+
+		"CREATE TABLE logs (id integer);\n"+
+		"ALTER TABLE `users` DROP COLUMN `email`;\n"+
+		"DROP TABLE `posts`;\n")
+
+	mustExecute("sqlite3", "sqlite3def_test", ".read rollback.sql")
+	writeFile("schema.sql", current)
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop", "--check")
+}
+
+func TestSQLite3defMigrationOutput(t *testing.T) {
+	resetTestDatabase()
+	defer os.RemoveAll("migrations")
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);")
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);")
+	output := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--output", "migration", "--migration-dir", "migrations")
+	files, err := filepath.Glob("migrations/*_sqldef.*.sql")
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected a pair of migration files, but got: %v (%v)", files, err)
+	}
+	down, up := files[0], files[1] // in lexical order
+	assertEquals(t, output, "-- Migration --\n"+up+"\n"+down+"\n")
+	for path, expected := range map[string]string{
+		up:   "ALTER TABLE `users` ADD COLUMN `name` text;\n",
+		down: "ALTER TABLE `users` DROP COLUMN `name`;\n",
+	} {
+		actual, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, string(actual), expected)
+	}
+
+	// DDLs are not applied
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\nALTER TABLE `users` ADD COLUMN `name` text;\n")
+}
+
+func TestSQLite3defLiquibaseOutput(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("changelog.yml")
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);")
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);")
+	output := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--output", "liquibase")
+	output = regexp.MustCompile(`id="\d+"`).ReplaceAllString(output, `id="ID"`)
+	assertEquals(t, output, stripHeredoc(`
+		<?xml version="1.0" encoding="UTF-8"?>
+		<databaseChangeLog xmlns="http://www.liquibase.org/xml/ns/dbchangelog" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.liquibase.org/xml/ns/dbchangelog http://www.liquibase.org/xml/ns/dbchangelog/dbchangelog-latest.xsd">
+		    <changeSet id="ID" author="sqldef" dbms="sqlite">
+		        <comment>Generated by sqldef</comment>
+		        <sql splitStatements="false"><![CDATA[ALTER TABLE `+"`users` ADD COLUMN `name`"+` text]]></sql>
+		        <rollback>
+		            <sql splitStatements="false"><![CDATA[ALTER TABLE `+"`users` DROP COLUMN `name`"+`]]></sql>
+		        </rollback>
+		    </changeSet>
+		</databaseChangeLog>
+		`,
+	))
+
+	// The changelog is YAML by the extension of --output-file
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--output", "liquibase", "--output-file", "changelog.yml")
+	changelog, err := os.ReadFile("changelog.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(changelog), "databaseChangeLog:\n- changeSet:\n") ||
+		!strings.Contains(string(changelog), "        sql: ALTER TABLE `users` ADD COLUMN `name` text\n") {
+		t.Errorf("unexpected YAML changelog: %s", changelog)
+	}
+
+	// DDLs are not applied
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\nALTER TABLE `users` ADD COLUMN `name` text;\n")
+}
+
+func TestSQLite3defCheck(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n"
+	writeFile("schema.sql", createTable)
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--check")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Errorf("expected exit status 2 for differences, but got: %v", err)
+	}
+	assertEquals(t, out, "-- dry run --\n"+createTable)
+
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+	out = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--check")
+	assertEquals(t, out, nothingModified)
+}
+
+func TestSQLite3defOutputFile(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("output.sql")
+
+	createTable := "CREATE TABLE users (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	writeFile("schema.sql", createTable)
+	out := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run", "-o", "output.sql")
+	assertEquals(t, out, "")
+	buf, err := os.ReadFile("output.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, string(buf), "-- dry run --\n"+createTable)
+
+	out, err = execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--quiet")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Errorf("expected exit status 2 for differences, but got: %v", err)
+	}
+	assertEquals(t, out, "")
+
+	assertApplyOutput(t, createTable, applyPrefix+createTable)
+	out = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--quiet")
+	assertEquals(t, out, "")
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export", "--output-file", "output.sql")
+	buf, err = os.ReadFile("output.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, string(buf), createTable)
+}
+
+func TestSQLite3defCompact(t *testing.T) {
+	resetTestDatabase()
+
+	// CREATE TABLE written in the schema is printed as it's written, with or without --compact
+	createTable := stripHeredoc(`
+		CREATE TABLE users (id integer NOT NULL,   name text DEFAULT 'a,  b',
+		    age integer, CONSTRAINT age_check CHECK (age > 0), PRIMARY KEY (id));
+		`,
+	)
+	writeFile("schema.sql", createTable)
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createTable)
+
+	dryRun = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run", "--compact")
+	assertEquals(t, dryRun, "-- dry run --\n"+createTable)
+}
+
+func TestSQLite3defQuoteIdentifiers(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);")
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text, \"order\" integer, \"Full Name\" text);")
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run", "--quote-identifiers", "auto")
+	assertEquals(t, dryRun, stripHeredoc(`
+		-- dry run --
+		ALTER TABLE users ADD COLUMN name text;
+		ALTER TABLE users ADD COLUMN `+"`order`"+` integer;
+		ALTER TABLE users ADD COLUMN `+"`Full Name`"+` text;
+		`,
+	))
+
+	dryRun = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run", "--quote-identifiers", "never")
+	assertEquals(t, dryRun, stripHeredoc(`
+		-- dry run --
+		ALTER TABLE users ADD COLUMN name text;
+		ALTER TABLE users ADD COLUMN order integer;
+		ALTER TABLE users ADD COLUMN Full Name text;
+		`,
+	))
+}
+
+func TestSQLite3defWatch(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);")
+
+	payloads := make(chan map[string]interface{}, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		payloads <- payload
+	}))
+	defer webhook.Close()
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n")
+	cmd := exec.Command("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--watch", "--interval", "100ms", "--webhook", webhook.URL)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	receive := func() map[string]interface{} {
+		t.Helper()
+		select {
+		case payload := <-payloads:
+			return payload
+		case <-time.After(10 * time.Second):
+			t.Fatal("no payload is posted to --webhook")
+			return nil
+		}
+	}
+	payload := receive()
+	assertEquals(t, payload["text"].(string), "The database has drifted from schema.sql, needing 1 DDLs\n```\nALTER TABLE `users` DROP COLUMN `name`;\n```")
+	if payload["drifted"] != true {
+		t.Errorf("expected drifted to be true, but got: %v", payload)
+	}
+
+	// The same drift is not reported again, and the resolution is reported once
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);\n")
+	payload = receive()
+	assertEquals(t, payload["text"].(string), "The database matches schema.sql again")
+	if payload["drifted"] != false {
+		t.Errorf("expected drifted to be false, but got: %v", payload)
+	}
+}
+
+func TestSQLite3defLogLevel(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n"
+	writeFile("schema.sql", createTable)
+	out := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "-v")
+	for _, query := range []string{"select tbl_name from sqlite_master", "BEGIN", "CREATE TABLE users", "COMMIT"} {
+		if !regexp.MustCompile(`(?m)^-- debug \([^)]+\): ` + regexp.QuoteMeta(query)).MatchString(out) {
+			t.Errorf("expected '%s' to be logged, but got:\n%s", query, out)
+		}
+	}
+
+	out = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--log-level=info")
+	assertEquals(t, out, nothingModified)
+}
+
+func TestSQLite3defLogFormat(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE bigdata (data integer);")
+
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n")
+	out := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--log-format=json")
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("expected a JSON object per line, but got: %s", out)
+		}
+		switch event["event"] {
+		case "ddl":
+			events = append(events, fmt.Sprintf("ddl: %s (skipped: %v)", event["statement"], event["skipped"] == true))
+		case "summary":
+			events = append(events, fmt.Sprintf("summary: applied %v, skipped %v", event["applied"], event["skipped"]))
+		}
+	}
+	assertEquals(t, strings.Join(events, "\n"), stripHeredoc(`
+		ddl: CREATE TABLE users (id integer NOT NULL PRIMARY KEY) (skipped: false)
+		ddl: DROP TABLE `+"`bigdata`"+` (skipped: true)
+		summary: applied 1, skipped 1`,
 	))
 
-	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--dry-run", "--file", "schema.sql")
-	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
-	assertEquals(t, dryRun, strings.Replace(apply, "Apply", "dry run", 1))
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY,);\n")
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--log-format=json")
+	if err == nil {
+		t.Error("expected a syntax error")
+	}
+	if !strings.HasPrefix(out, `{"event":"error","message":"found syntax error`) {
+		t.Errorf("expected an error event, but got: %s", out)
+	}
 }
 
 func TestSQLite3defSkipDrop(t *testing.T) {
@@ -86,8 +970,604 @@ func TestSQLite3defSkipDrop(t *testing.T) {
 	writeFile("schema.sql", "")
 
 	skipDrop := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--skip-drop", "--file", "schema.sql")
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, skipDrop, strings.Replace(apply, "DROP", "-- Skipped: DROP", 1)+"-- Skipped destructive DDLs: 1 --\n")
+}
+
+func TestSQLite3defSkipDropWithAdditiveChanges(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", stripHeredoc(`
+		CREATE TABLE users (
+		    id integer NOT NULL PRIMARY KEY,
+		    age integer
+		);
+		CREATE TABLE posts (
+		    id integer NOT NULL PRIMARY KEY
+		);`,
+	))
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		    id integer NOT NULL PRIMARY KEY,
+		    age integer,
+		    name text
+		);`,
+	))
+
+	output := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--skip-drop", "--file", "schema.sql")
+	assertEquals(t, output, applyPrefix+
+		"ALTER TABLE `users` ADD COLUMN `name` text;\n"+
+		"-- Skipped: DROP TABLE `posts`;\n"+
+		"-- Skipped destructive DDLs: 1 --\n",
+	)
+}
+
+func TestSQLite3defEnableDrop(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", stripHeredoc(`
+		CREATE TABLE users (
+		    id integer NOT NULL PRIMARY KEY,
+		    age integer
+		);`,
+	))
+
+	writeFile("schema.sql", "")
+
+	skipped := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	assertEquals(t, skipped, applyPrefix+"-- Skipped: DROP TABLE `users`;\n-- Skipped destructive DDLs: 1 --\n")
+	dropped := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop")
+	assertEquals(t, dropped, applyPrefix+"DROP TABLE `users`;\n")
+}
+
+func TestSQLite3defSkipTable(t *testing.T) {
+	resetTestDatabase()
+
+	createUsers := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);"
+	mustExecute("sqlite3", "sqlite3def_test", createUsers+stripHeredoc(`
+		CREATE TABLE schema_migrations (version text);
+		CREATE TABLE awsdms_apply_exceptions (task_name text);
+		CREATE INDEX index_task_name ON awsdms_apply_exceptions (task_name);
+		`,
+	))
+
+	export := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export", "--skip-table", "schema_migrations", "--skip-table", "awsdms_.*")
+	assertEquals(t, export, createUsers+"\n")
+
+	writeFile("schema.sql", createUsers+"\nCREATE TABLE awsdms_apply_exceptions (error text);\n")
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop", "--skip-table", "schema_migrations", "--skip-table", "awsdms_.*")
+	assertEquals(t, apply, nothingModified)
+}
+
+func TestSQLite3defTargetTable(t *testing.T) {
+	resetTestDatabase()
+
+	createUsers := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);"
+	createPosts := "CREATE TABLE posts (id integer NOT NULL PRIMARY KEY);"
+	mustExecute("sqlite3", "sqlite3def_test", createUsers+createPosts+"CREATE TABLE comments (id integer);")
+
+	export := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export", "--target-table", "users", "--target-table", "posts")
+	assertEquals(t, export, createUsers+"\n\n"+createPosts+"\n")
+
+	writeFile("schema.sql", createUsers+"\nCREATE TABLE posts (id integer NOT NULL PRIMARY KEY, title text);\n")
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop", "--target-table", "users", "--target-table", "posts")
+	assertEquals(t, apply, applyPrefix+"ALTER TABLE `posts` ADD COLUMN `title` text;\n")
+
+	// --skip-table takes precedence over --target-table
+	writeFile("schema.sql", createUsers)
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop", "--target-table", "users|posts", "--skip-table", "posts")
+	assertEquals(t, apply, nothingModified)
+}
+
+func TestSQLite3defManifest(t *testing.T) {
+	resetTestDatabase()
+	os.Remove("manifest.txt")
+	defer os.Remove("manifest.txt")
+
+	// Tables created by others are foreign
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE ar_internal_metadata (key text);")
+
+	createUsers := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n"
+	writeFile("schema.sql", createUsers)
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop", "--manifest", "manifest.txt")
+	assertEquals(t, apply, applyPrefix+createUsers)
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop", "--manifest", "manifest.txt")
+	assertEquals(t, apply, nothingModified)
+
+	// Tables created by sqldef are recorded
+	manifest, err := os.ReadFile("manifest.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, string(manifest), "users\n")
+
+	writeFile("schema.sql", "")
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop", "--manifest", "manifest.txt")
+	assertEquals(t, apply, applyPrefix+"DROP TABLE `users`;\n")
+}
+
+func TestSQLite3defBeforeAndAfterApply(t *testing.T) {
+	resetTestDatabase()
+
+	beforeApply := "CREATE TABLE migrations (name text);"
+	afterApply := "INSERT INTO migrations VALUES ('users');"
+	createTable := stripHeredoc(`
+		CREATE TABLE users (
+		    id integer NOT NULL PRIMARY KEY
+		);`,
+	)
+	writeFile("schema.sql", createTable)
+
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--before-apply", beforeApply, "--after-apply", afterApply, "--dry-run")
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--before-apply", beforeApply, "--after-apply", afterApply)
+	assertEquals(t, dryRun, strings.Replace(apply, "Apply", "dry run", 1))
+	assertEquals(t, apply, applyPrefix+beforeApply+"\n"+createTable+"\n"+afterApply+"\n")
+
+	out, err := execute("sqlite3", "sqlite3def_test", "SELECT name FROM migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, out, "users\n")
+}
+
+func TestSQLite3defApplyHooks(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("hooks.log")
+
+	createTable := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n"
+	writeFile("schema.sql", createTable)
+
+	// A failing pre-apply hook aborts applying DDLs
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--pre-apply-hook", "exit 3")
+	if err == nil {
+		t.Errorf("expected a failing --pre-apply-hook to abort, but succeeded with: %s", out)
+	}
+	assertEquals(t, assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export"), "-- No table exists --\n")
+
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql",
+		"--pre-apply-hook", `echo "$SQLDEF_HOOK" >> hooks.log && cat >> hooks.log`,
+		"--post-apply-hook", `echo "$SQLDEF_HOOK" >> hooks.log`)
+	assertEquals(t, apply, applyPrefix+createTable)
+	hooks, err := os.ReadFile("hooks.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, string(hooks), "pre\n"+createTable+"post\n")
+
+	// Hooks are not run when nothing is modified
+	os.Remove("hooks.log")
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--pre-apply-hook", "touch hooks.log")
+	assertEquals(t, apply, nothingModified)
+	if _, err := os.Stat("hooks.log"); err == nil {
+		t.Error("expected --pre-apply-hook not to run without DDLs")
+	}
+}
+
+func TestSQLite3defSnapshot(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("snapshot.json")
+	defer os.Remove("plan.sql")
+
+	createTable := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);"
+	mustExecute("sqlite3", "sqlite3def_test", createTable)
+	assertEquals(t, assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--snapshot", "snapshot.json"), "")
+
+	// The database is not touched by --against-snapshot
+	mustExecute("sqlite3", "sqlite3def_test", "DROP TABLE users;")
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);")
+	dryRun := assertedExecute(t, "./sqlite3def", "--against-snapshot", "snapshot.json", "--file", "schema.sql")
+	assertEquals(t, dryRun, "-- dry run --\nALTER TABLE `users` ADD COLUMN `name` text;\n")
+
+	// A plan against the snapshot is applied only when the database still matches it
+	assertedExecute(t, "./sqlite3def", "--against-snapshot", "snapshot.json", "--file", "schema.sql", "--plan", "plan.sql")
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--apply", "plan.sql")
+	if err == nil {
+		t.Errorf("expected --apply to fail for a changed schema, but succeeded with: %s", out)
+	}
+	mustExecute("sqlite3", "sqlite3def_test", createTable)
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--apply", "plan.sql")
+	assertEquals(t, apply, applyPrefix+"ALTER TABLE `users` ADD COLUMN `name` text;\n")
+}
+
+func TestSQLite3defProgress(t *testing.T) {
+	resetTestDatabase()
+
+	createUsers := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);"
+	createPosts := "CREATE TABLE posts (id integer NOT NULL PRIMARY KEY);"
+	writeFile("schema.sql", createUsers+"\n"+createPosts)
+	out := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--progress")
+	out = regexp.MustCompile(`[\d.]+(µs|ms|s)\b`).ReplaceAllString(out, "N")
+	out = regexp.MustCompile(`took N: CREATE TABLE (users|posts) .+`).ReplaceAllString(out, "took N: ...") // either can be the slowest
+	assertEquals(t, out, applyPrefix+stripHeredoc(`
+		-- [1/2] N elapsed: CREATE TABLE users (id integer NOT NULL PRIMARY KEY) --
+		CREATE TABLE users (id integer NOT NULL PRIMARY KEY);
+		-- [1/2] Done in N --
+		-- [2/2] N elapsed: CREATE TABLE posts (id integer NOT NULL PRIMARY KEY) --
+		CREATE TABLE posts (id integer NOT NULL PRIMARY KEY);
+		-- [2/2] Done in N --
+		-- Applied 2 DDLs in N. The slowest one took N: ...
+		`,
+	))
+
+	// Nothing is printed without DDLs
+	assertEquals(t, assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--progress"), nothingModified)
+}
+
+func TestSQLite3defLockFile(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("sqldef.lock")
+
+	createTable := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);\n"
+	writeFile("schema.sql", createTable)
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--lock-file", "sqldef.lock")
+	assertEquals(t, apply, applyPrefix+createTable)
+	lock, err := os.ReadFile("sqldef.lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`"desired_checksum": "sha256:[0-9a-f]{64}",\n  "database_fingerprint": "sha256:[0-9a-f]{64}"`).Match(lock) {
+		t.Errorf("unexpected lock file: %s", lock)
+	}
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--lock-file", "sqldef.lock", "--dry-run")
+	assertEquals(t, dryRun, nothingModified)
+
+	// Out-of-band changes are warned until the desired schema is applied again
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE manual (id integer);")
+	dryRun = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--lock-file", "sqldef.lock", "--dry-run")
+	assertEquals(t, dryRun, "-- WARNING: The database has changed since the last apply recorded in 'sqldef.lock', e.g. by a manual DDL --\n"+
+		"-- dry run --\n-- Skipped: DROP TABLE `manual`;\n-- Skipped destructive DDLs: 1 --\n")
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--lock-file", "sqldef.lock", "--enable-drop")
+	dryRun = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--lock-file", "sqldef.lock", "--dry-run")
+	assertEquals(t, dryRun, nothingModified)
+}
+
+func TestSQLite3defResume(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("sqldef.resume")
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);")
+
+	// The failed apply is rolled back, and the DDL is kept to be resumed
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);")
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--resume", "sqldef.resume", "--after-apply", "INSERT INTO missing VALUES (1);")
+	if err == nil {
+		t.Fatalf("expected the apply to fail, but got: %s", out)
+	}
+	resume, err := os.ReadFile("sqldef.resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, string(resume), "-- sqldef resume\n-- sqldef:statement\nALTER TABLE `users` ADD COLUMN `name` text;\n")
+
+	// DDLs in the file are applied instead of the ones for the schema file
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);")
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--resume", "sqldef.resume")
+	assertEquals(t, apply, "-- Resuming 1 DDLs not applied yet in 'sqldef.resume' --\n"+applyPrefix+"ALTER TABLE `users` ADD COLUMN `name` text;\n")
+	if _, err := os.Stat("sqldef.resume"); !os.IsNotExist(err) {
+		t.Errorf("expected sqldef.resume to be removed, but got: %v", err)
+	}
+}
+
+func TestSQLite3defHistory(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	writeFile("schema.sql", createTable)
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--history")
+	assertEquals(t, apply, applyPrefix+createTable)
+	out := assertedExecute(t, "sqlite3", "sqlite3def_test", "SELECT statement, length(plan_checksum) FROM sqldef_history")
+	assertEquals(t, out, strings.TrimSuffix(createTable, ";\n")+"|71\n")
+
+	// The history table is not dropped or exported
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--history", "--enable-drop")
+	assertEquals(t, apply, nothingModified)
+	export := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export")
+	assertEquals(t, export, createTable)
+}
+
+func TestSQLite3defAuditLog(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("audit.jsonl")
+
+	createTable := "CREATE TABLE users (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	writeFile("schema.sql", createTable)
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--audit-log", "audit.jsonl", "--dry-run")
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--audit-log", "audit.jsonl")
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--audit-log", "audit.jsonl")
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);")
+	if _, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--audit-log", "audit.jsonl", "--before-apply", "INSERT INTO missing VALUES (1);"); err == nil {
+		t.Error("expected the apply to fail")
+	}
+
+	buf, err := os.ReadFile("audit.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outcomes []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record["target"] != "sqlite3def_test" {
+			t.Errorf("unexpected target: %v", record["target"])
+		}
+		outcomes = append(outcomes, record["outcome"].(string))
+	}
+	assertEquals(t, strings.Join(outcomes, ","), "planned,applied,unchanged,failed")
+}
+
+func TestSQLite3defOnly(t *testing.T) {
+	resetTestDatabase()
+
+	createUsers := "CREATE TABLE users (\n  id integer NOT NULL PRIMARY KEY,\n  name text\n);\n"
+	createIndex := "CREATE INDEX index_users_on_name ON users (name);\n"
+	createPosts := "CREATE TABLE posts (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	writeFile("schema.sql", createUsers+createIndex+createPosts)
+
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--only", "table:users", "--only", "table:comments")
+	assertEquals(t, apply, "-- WARNING: --only=table:comments selected no DDL --\n-- Deferred 2 DDLs not selected by --only --\n"+applyPrefix+createUsers)
+
+	// Numbers select DDLs of the remaining plan
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--only", "2", "--dry-run")
+	assertEquals(t, dryRun, "-- Deferred 1 DDLs not selected by --only --\n-- dry run --\n"+createPosts)
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--only", "index:index_users_%")
+	assertEquals(t, apply, "-- Deferred 1 DDLs not selected by --only --\n"+applyPrefix+createIndex)
+
+	_, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--only", "users")
+	if err == nil {
+		t.Error("expected --only without a kind to fail")
+	}
+}
+
+func TestSQLite3defInteractive(t *testing.T) {
+	resetTestDatabase()
+
+	createUsers := "CREATE TABLE users (\n  id integer NOT NULL PRIMARY KEY,\n  name text\n);\n"
+	createIndex := "CREATE INDEX index_users_on_name ON users (name);\n"
+	createPosts := "CREATE TABLE posts (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	writeFile("schema.sql", createUsers+createIndex+createPosts)
+
+	// Postponing CREATE TABLE users is refused since the next DDL depends on it, and the index is postponed and skipped
+	cmd := exec.Command("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--interactive")
+	cmd.Stdin = strings.NewReader("l\ny\nl\ny\nn\n")
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run sqlite3def --interactive: %s\n%s", err, stderr.String())
+	}
+	assertEquals(t, stdout.String(), applyPrefix+createUsers+createPosts)
+	if !strings.Contains(stderr.String(), "-- The next DDL depends on this one, e.g. by touching the same table --\n") {
+		t.Errorf("unexpected prompts: %s", stderr.String())
+	}
+
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createIndex)
+}
+
+func TestSQLite3defInteractiveSkippedDependency(t *testing.T) {
+	resetTestDatabase()
+
+	createUsers := "CREATE TABLE users (\n  id integer NOT NULL PRIMARY KEY,\n  name text\n);\n"
+	createPosts := "CREATE TABLE posts (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	createIndex := "CREATE INDEX index_users_on_name ON users (name);\n"
+	writeFile("schema.sql", createUsers+createPosts+createIndex)
+
+	// The index on the skipped table is asked with a warning even after `a`
+	cmd := exec.Command("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--interactive")
+	cmd.Stdin = strings.NewReader("n\na\nn\n")
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run sqlite3def --interactive: %s\n%s", err, stderr.String())
+	}
+	assertEquals(t, stdout.String(), applyPrefix+createPosts)
+	if !strings.Contains(stderr.String(), "-- WARNING: This DDL may depend on a skipped DDL: "+strings.TrimSuffix(createUsers, ";\n")+"; --\n"+
+		strings.TrimSuffix(createIndex, "\n")+"\n(3/3) Apply this DDL") {
+		t.Errorf("unexpected prompts: %s", stderr.String())
+	}
+}
+
+func TestSQLite3defDryRunOnTerminal(t *testing.T) {
+	if _, err := exec.LookPath("script"); err != nil {
+		t.Skip("script command is needed to run sqlite3def on a terminal")
+	}
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE logs (id integer); CREATE TABLE users (id integer PRIMARY KEY);")
+	writeFile("schema.sql", "CREATE TABLE users (id integer PRIMARY KEY, name text);\n"+
+		"CREATE INDEX index_users_on_name ON users (name);\n"+
+		"CREATE TABLE posts (id integer PRIMARY KEY);\n")
+
+	// DDLs are grouped by tables, colored by their kinds, and annotated with their risks
+	out := assertedExecute(t, "script", "-qec", "./sqlite3def sqlite3def_test --file schema.sql --dry-run --enable-drop", "/dev/null")
+	assertEquals(t, strings.ReplaceAll(out, "\r\n", "\n"), "-- dry run --\n"+
+		"\n-- table: users --\n"+
+		"\x1b[33mALTER TABLE `users` ADD COLUMN `name` text;\x1b[0m\n-- risk: safe\n"+
+		"\x1b[32mCREATE INDEX index_users_on_name ON users (name);\x1b[0m\n-- risk: blocking\n"+
+		"\n-- table: posts --\n"+
+		"\x1b[32mCREATE TABLE posts (id integer PRIMARY KEY);\x1b[0m\n-- risk: safe\n"+
+		"\n-- table: logs --\n"+
+		"\x1b[31mDROP TABLE `logs`;\x1b[0m\n-- risk: destructive\n")
+
+	// --no-color keeps the groups
+	out = assertedExecute(t, "script", "-qec", "./sqlite3def sqlite3def_test --file schema.sql --dry-run --enable-drop --no-color", "/dev/null")
+	if strings.Contains(out, "\x1b[") || !strings.Contains(out, "-- table: users --") {
+		t.Errorf("expected grouped output without colors, but got: %q", out)
+	}
+
+	// Neither is done when stdout is not a terminal
+	out = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run", "--enable-drop")
+	if strings.Contains(out, "\x1b[") || strings.Contains(out, "-- table: users --") {
+		t.Errorf("expected plain output, but got: %q", out)
+	}
+}
+
+func TestSQLite3defSummary(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY); CREATE TABLE logs (id integer);")
+
+	createPosts := "CREATE TABLE posts (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	createIndex := "CREATE INDEX index_users_on_name ON users (name);\n"
+	writeFile("schema.sql", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, name text);\n"+createIndex+createPosts)
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--summary", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\nALTER TABLE `users` ADD COLUMN `name` text;\n"+createIndex+createPosts+
+		"-- Skipped: DROP TABLE `logs`;\n-- Skipped destructive DDLs: 1 --\n"+
+		"-- Summary: 1 table created, 1 table altered, 1 column added, 1 index created, 1 destructive DDL skipped --\n")
+
+	out := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--summary", "--dry-run", "--output", "json")
+	var plan struct {
+		DDLs    []map[string]interface{} `json:"ddls"`
+		Summary map[string]interface{}   `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(out), &plan); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.DDLs) != 4 || plan.Summary["statements"] != 3.0 || plan.Summary["skipped"] != 1.0 {
+		t.Errorf("unexpected JSON output: %s", out)
+	}
+
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--summary", "--enable-drop")
+	if !strings.Contains(apply, "\n-- Summary: 1 table created, 1 table altered, 1 table dropped, 1 column added, 1 index created, 1 destructive DDL; took ") {
+		t.Errorf("unexpected output: %s", apply)
+	}
+}
+
+func TestSQLite3defBackfillAnnotation(t *testing.T) {
+	resetTestDatabase()
+	mustExecute("sqlite3", "sqlite3def_test", "CREATE TABLE users (id integer NOT NULL PRIMARY KEY, email text); INSERT INTO users VALUES (1, 'A@example.com');")
+
+	writeFile("schema.sql", stripHeredoc(`
+		CREATE TABLE users (
+		  id integer NOT NULL PRIMARY KEY,
+		  email text,
+		  normalized_email text -- sqldef:backfill UPDATE users SET normalized_email = lower(email) WHERE normalized_email IS NULL;
+		);
+		`,
+	))
 	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
-	assertEquals(t, skipDrop, strings.Replace(apply, "DROP", "-- Skipped: DROP", 1))
+	assertEquals(t, apply, applyPrefix+"ALTER TABLE `users` ADD COLUMN `normalized_email` text;\n"+
+		"UPDATE users SET normalized_email = lower(email) WHERE normalized_email IS NULL;\n")
+	out := assertedExecute(t, "sqlite3", "sqlite3def_test", "SELECT normalized_email FROM users")
+	assertEquals(t, out, "a@example.com\n")
+
+	// The annotation is used only when the column is added
+	apply = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	assertEquals(t, apply, nothingModified)
+}
+
+func TestSQLite3defEnvSections(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE orders (\n  id integer NOT NULL PRIMARY KEY,\n  created_at text\n);\n"
+	createIndex := "CREATE INDEX index_orders_on_created_at ON orders (created_at);\n"
+	createDebugLogs := "CREATE TABLE debug_logs (\n  id integer NOT NULL PRIMARY KEY\n);\n"
+	writeFile("schema.sql", createTable+
+		"-- sqldef:if env=production,staging\n"+createIndex+
+		"-- sqldef:if env!=production\n"+createDebugLogs+"-- sqldef:endif\n"+
+		"-- sqldef:else\nCREATE TABLE dev_only (id integer);\n-- sqldef:endif\n")
+
+	dryRun := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--env", "production", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createTable+createIndex)
+	dryRun = assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--env", "staging", "--dry-run")
+	assertEquals(t, dryRun, "-- dry run --\n"+createTable+createIndex+createDebugLogs)
+
+	out, err := execute("./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--dry-run")
+	if err == nil || !strings.Contains(out, "line 5: --env is required by '-- sqldef:if env=production,staging'") {
+		t.Errorf("expected an error without --env, but got: %s", out)
+	}
+}
+
+func TestSQLite3defTargets(t *testing.T) {
+	resetTestDatabase()
+	defer os.Remove("shard1")
+	defer os.Remove("shard2")
+
+	createTable := "CREATE TABLE users (\n  id integer NOT NULL PRIMARY KEY,\n  name text\n);\n"
+	writeFile("schema.sql", createTable)
+	writeFile("targets.yml", stripHeredoc(`
+		- name: shard-1
+		  database: shard1
+		- database: shard2
+		  skip-drop: true
+		`))
+
+	out := assertedExecute(t, "./sqlite3def", "--targets", "targets.yml", "--file", "schema.sql", "--dry-run")
+	assertEquals(t, out, "-- Target shard-1 --\n-- dry run --\n"+createTable+"-- Target shard2 --\n-- dry run --\n"+createTable+
+		"-- Targets: 2 succeeded --\n")
+
+	// --output-file is written for each target
+	out = assertedExecute(t, "./sqlite3def", "--targets", "targets.yml", "--file", "schema.sql", "--dry-run", "--output-file", "plan.sql")
+	assertEquals(t, out, "-- Target shard-1 --\n-- Target shard2 --\n-- Targets: 2 succeeded --\n")
+	for _, file := range []string{"plan.shard-1.sql", "plan.shard2.sql"} {
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(file)
+		assertEquals(t, string(buf), "-- dry run --\n"+createTable)
+	}
+
+	out = assertedExecute(t, "./sqlite3def", "--targets", "targets.yml", "--targets-concurrency", "1", "--file", "schema.sql")
+	assertEquals(t, out, "-- Target shard-1 --\n"+applyPrefix+createTable+"-- Target shard2 --\n"+applyPrefix+createTable+
+		"-- Targets: 2 succeeded --\n")
+
+	writeFile("targets.yml", stripHeredoc(`
+		- name: shard-1
+		  database: shard1
+		- name: shard-3
+		  database: missing/shard3
+		`))
+	out, err := execute("./sqlite3def", "--targets", "targets.yml", "--file", "schema.sql", "--check")
+	if err == nil || !strings.HasPrefix(out, "-- Target shard-1 --\n"+nothingModified+"-- Target shard-3 --\n") ||
+		!strings.HasSuffix(out, "-- Targets: 1 succeeded, 1 failed (shard-3) --\n") {
+		t.Errorf("expected shard-3 to fail, but got: %s", out)
+	}
+}
+
+func TestSQLite3defCompletion(t *testing.T) {
+	out := assertedExecute(t, "./sqlite3def", "--completion", "bash")
+	if !strings.Contains(out, "    --output) COMPREPLY=($(compgen -W \"text json markdown migration liquibase\" -- \"$cur\")); return ;;\n") ||
+		!strings.HasSuffix(out, "complete -o default -F _sqlite3def sqlite3def\n") || strings.Contains(out, "--completion") {
+		t.Errorf("unexpected bash completion: %s", out)
+	}
+	out = assertedExecute(t, "./sqlite3def", "--completion", "zsh")
+	if !strings.HasPrefix(out, "#compdef sqlite3def\n") || !strings.Contains(out, "  '-f[Read schema SQL from the file, rather than stdin]:filename:_files' \\\n") {
+		t.Errorf("unexpected zsh completion: %s", out)
+	}
+	out = assertedExecute(t, "./sqlite3def", "--completion", "fish")
+	if !strings.Contains(out, "complete -c sqlite3def -l dry-run -d 'Don\\'t run DDLs but just show them'\n") {
+		t.Errorf("unexpected fish completion: %s", out)
+	}
+}
+
+func TestSQLite3defConfig(t *testing.T) {
+	resetTestDatabase()
+
+	createTable := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);"
+	mustExecute("sqlite3", "sqlite3def_test", createTable+"CREATE TABLE bigdata (data integer);")
+	writeFile("schema.sql", createTable)
+	writeFile("config.yml", stripHeredoc(`
+		database: sqlite3def_test
+		file: schema.sql
+		enable-drop: true
+		dry-run: true
+		`,
+	))
+	defer os.Remove("config.yml")
+
+	// The database name and options are read from the config file
+	dryRun := assertedExecute(t, "./sqlite3def", "--config", "config.yml")
+	assertEquals(t, dryRun, "-- dry run --\nDROP TABLE `bigdata`;\n")
+
+	// Command line arguments take precedence over the config file
+	writeFile("empty.sql", "")
+	defer os.Remove("empty.sql")
+	dryRun = assertedExecute(t, "./sqlite3def", "--config", "config.yml", "--file", "empty.sql")
+	assertEquals(t, dryRun, "-- dry run --\nDROP TABLE `users`;\nDROP TABLE `bigdata`;\n")
+
+	writeFile("config.yml", "unknown-option: true\n")
+	out, err := execute("./sqlite3def", "--config", "config.yml", "sqlite3def_test")
+	if err == nil {
+		t.Errorf("expected an error for an unknown option, but got: %s", out)
+	}
 }
 
 func TestSQLite3defExport(t *testing.T) {
@@ -111,6 +1591,41 @@ func TestSQLite3defExport(t *testing.T) {
 	))
 }
 
+func TestSQLite3defExportDir(t *testing.T) {
+	resetTestDatabase()
+	defer os.RemoveAll("export")
+
+	createUsers := "CREATE TABLE users (id integer NOT NULL PRIMARY KEY);"
+	createPosts := "CREATE TABLE posts (id integer NOT NULL PRIMARY KEY);"
+	createView := "CREATE VIEW user_ids AS SELECT id FROM users;"
+	mustExecute("sqlite3", "sqlite3def_test", createUsers+createPosts+createView)
+
+	out := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export-dir", "export")
+	assertEquals(t, out, "")
+	for file, expected := range map[string]string{
+		"export/tables/users.sql":   createUsers + "\n",
+		"export/tables/posts.sql":   createPosts + "\n",
+		"export/views/user_ids.sql": createView + "\n",
+	} {
+		actual, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, string(actual), expected)
+	}
+
+	// The exported directory can be used as the desired schema
+	apply := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "export/")
+	assertEquals(t, apply, nothingModified)
+
+	// Files of dropped tables are removed
+	mustExecute("sqlite3", "sqlite3def_test", "DROP TABLE posts;")
+	assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--export-dir", "export")
+	if _, err := os.Stat("export/tables/posts.sql"); !os.IsNotExist(err) {
+		t.Errorf("expected export/tables/posts.sql to be removed, but got: %v", err)
+	}
+}
+
 func TestSQLite3defHelp(t *testing.T) {
 	_, err := execute("./sqlite3def", "--help")
 	if err != nil {
@@ -136,7 +1651,7 @@ func TestMain(m *testing.M) {
 func assertApplyOutput(t *testing.T, schema string, expected string) {
 	t.Helper()
 	writeFile("schema.sql", schema)
-	actual := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql")
+	actual := assertedExecute(t, "./sqlite3def", "sqlite3def_test", "--file", "schema.sql", "--enable-drop")
 	assertEquals(t, actual, expected)
 }
 
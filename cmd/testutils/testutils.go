@@ -78,11 +78,11 @@ func RunTest(t *testing.T, db adapter.Database, test TestCase, mode schema.Gener
 	}
 
 	// Test idempotency
-	dumpDDLs, err := adapter.DumpDDLs(db)
+	dumpDDLs, err := adapter.DumpDDLs(db, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
-	ddls, err := schema.GenerateIdempotentDDLs(mode, test.Current, dumpDDLs)
+	ddls, err := schema.GenerateIdempotentDDLs(mode, test.Current, dumpDDLs, schema.GeneratorConfig{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -91,11 +91,11 @@ func RunTest(t *testing.T, db adapter.Database, test TestCase, mode schema.Gener
 	}
 
 	// Main test
-	dumpDDLs, err = adapter.DumpDDLs(db)
+	dumpDDLs, err = adapter.DumpDDLs(db, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
-	ddls, err = schema.GenerateIdempotentDDLs(mode, test.Desired, dumpDDLs)
+	ddls, err = schema.GenerateIdempotentDDLs(mode, test.Desired, dumpDDLs, schema.GeneratorConfig{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -110,11 +110,11 @@ func RunTest(t *testing.T, db adapter.Database, test TestCase, mode schema.Gener
 	}
 
 	// Test idempotency
-	dumpDDLs, err = adapter.DumpDDLs(db)
+	dumpDDLs, err = adapter.DumpDDLs(db, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
-	ddls, err = schema.GenerateIdempotentDDLs(mode, test.Desired, dumpDDLs)
+	ddls, err = schema.GenerateIdempotentDDLs(mode, test.Desired, dumpDDLs, schema.GeneratorConfig{})
 	if err != nil {
 		t.Fatal(err)
 	}
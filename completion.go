@@ -0,0 +1,141 @@
+package sqldef
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// Print a completion script of the shell for options of the parser, which is given by the hidden --completion option
+func WriteCompletion(out io.Writer, parser *flags.Parser, shell string) error {
+	options := completionOptions(parser.Group)
+	switch shell {
+	case "bash":
+		writeBashCompletion(out, parser.Name, options)
+	case "zsh":
+		writeZshCompletion(out, parser.Name, options)
+	case "fish":
+		writeFishCompletion(out, parser.Name, options)
+	default:
+		return fmt.Errorf("unsupported shell for --completion: %s", shell)
+	}
+	return nil
+}
+
+// Options visible in --help, including ones in sub groups
+func completionOptions(group *flags.Group) []*flags.Option {
+	var options []*flags.Option
+	for _, option := range group.Options() {
+		if !option.Hidden && len(option.LongName) > 0 {
+			options = append(options, option)
+		}
+	}
+	for _, subGroup := range group.Groups() {
+		options = append(options, completionOptions(subGroup)...)
+	}
+	return options
+}
+
+// An option with an optional value, e.g. --password prompting it, is completed like a boolean one
+func takesValue(option *flags.Option) bool {
+	kind := option.Field().Type.Kind()
+	if kind == reflect.Slice {
+		kind = option.Field().Type.Elem().Kind()
+	}
+	return kind != reflect.Bool && !option.OptionalArgument
+}
+
+// Complete file names only for values named like filename, plan_file, directory, or path
+func takesFile(option *flags.Option) bool {
+	switch option.ValueName {
+	case "path", "socket", "audit_log":
+		return true
+	}
+	return strings.Contains(option.ValueName, "file") || strings.Contains(option.ValueName, "directory")
+}
+
+func optionNames(option *flags.Option) []string {
+	names := []string{"--" + option.LongName}
+	if option.ShortName != 0 {
+		names = append(names, "-"+string(option.ShortName))
+	}
+	return names
+}
+
+func writeBashCompletion(out io.Writer, command string, options []*flags.Option) {
+	function := "_" + strings.ReplaceAll(command, "-", "_")
+	var words []string
+	fmt.Fprintf(out, "%s() {\n", function)
+	fmt.Fprintln(out, `  local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(out, `  case "$prev" in`)
+	for _, option := range options {
+		words = append(words, optionNames(option)...)
+		if !takesValue(option) {
+			continue
+		}
+		if len(option.Choices) > 0 {
+			fmt.Fprintf(out, "    %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", strings.Join(optionNames(option), "|"), strings.Join(option.Choices, " "))
+		} else if takesFile(option) {
+			fmt.Fprintf(out, "    %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(optionNames(option), "|"))
+		} else {
+			fmt.Fprintf(out, "    %s) COMPREPLY=(); return ;;\n", strings.Join(optionNames(option), "|"))
+		}
+	}
+	fmt.Fprintln(out, "  esac")
+	fmt.Fprintln(out, `  if [[ "$cur" == -* ]]; then`)
+	fmt.Fprintf(out, "    COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(words, " "))
+	fmt.Fprintln(out, "  fi")
+	fmt.Fprintln(out, "}")
+	fmt.Fprintf(out, "complete -o default -F %s %s\n", function, command)
+}
+
+var zshDescriptionReplacer = strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+
+func writeZshCompletion(out io.Writer, command string, options []*flags.Option) {
+	fmt.Fprintf(out, "#compdef %s\n\n", command)
+	fmt.Fprintln(out, "_arguments -s \\")
+	for _, option := range options {
+		description := zshDescriptionReplacer.Replace(option.Description)
+		action := ""
+		if takesValue(option) {
+			valueName := option.ValueName
+			if len(valueName) == 0 {
+				valueName = "value"
+			}
+			if len(option.Choices) > 0 {
+				action = fmt.Sprintf(":%s:(%s)", valueName, strings.Join(option.Choices, " "))
+			} else if takesFile(option) {
+				action = fmt.Sprintf(":%s:_files", valueName)
+			} else {
+				action = fmt.Sprintf(":%s: ", valueName)
+			}
+		}
+		for _, name := range optionNames(option) {
+			if takesValue(option) && strings.HasPrefix(name, "--") {
+				name += "="
+			}
+			fmt.Fprintf(out, "  '%s[%s]%s' \\\n", name, description, action)
+		}
+	}
+	fmt.Fprintln(out, "  '*:argument:_files'")
+}
+
+func writeFishCompletion(out io.Writer, command string, options []*flags.Option) {
+	for _, option := range options {
+		line := fmt.Sprintf("complete -c %s -l %s", command, option.LongName)
+		if option.ShortName != 0 {
+			line += fmt.Sprintf(" -s %c", option.ShortName)
+		}
+		if len(option.Choices) > 0 {
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(option.Choices, " "))
+		} else if takesValue(option) && takesFile(option) {
+			line += " -r -F"
+		} else if takesValue(option) {
+			line += " -x"
+		}
+		fmt.Fprintf(out, "%s -d '%s'\n", line, strings.ReplaceAll(option.Description, "'", `\'`))
+	}
+}
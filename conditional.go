@@ -0,0 +1,72 @@
+package sqldef
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// e.g. `-- sqldef:if env=production`, `-- sqldef:else`, and `-- sqldef:endif`
+var conditionalRegexp = regexp.MustCompile(`(?i)^\s*--\s*sqldef:(if|else|endif)\b\s*(.*?)\s*$`)
+
+// e.g. env=production, env!=production, and env=staging,production
+var envConditionRegexp = regexp.MustCompile(`^env\s*(!?=)\s*([^\s=]+)$`)
+
+// Keep sections of `-- sqldef:if env=name` ... `-- sqldef:endif` blocks only for the environment given by --env.
+// Blocks may have `-- sqldef:else` and be nested. Lines of other environments are blanked to keep line numbers of errors.
+func applyConditionals(sql string, env string) (string, error) {
+	lines := strings.Split(sql, "\n")
+	type block struct {
+		line     int
+		matched  bool
+		inElse   bool
+		enclosed bool // whether the block itself is kept by the enclosing ones
+	}
+	var blocks []block
+	keeping := func() bool {
+		return len(blocks) == 0 || (blocks[len(blocks)-1].enclosed && blocks[len(blocks)-1].matched != blocks[len(blocks)-1].inElse)
+	}
+
+	for i, line := range lines {
+		m := conditionalRegexp.FindStringSubmatch(line)
+		if m == nil {
+			if !keeping() {
+				lines[i] = ""
+			}
+			continue
+		}
+
+		switch strings.ToLower(m[1]) {
+		case "if":
+			condition := envConditionRegexp.FindStringSubmatch(m[2])
+			if condition == nil {
+				return "", fmt.Errorf("line %d: '-- sqldef:if %s' should be env=name, env!=name, or env=name1,name2", i+1, m[2])
+			}
+			if env == "" {
+				return "", fmt.Errorf("line %d: --env is required by '-- sqldef:if %s'", i+1, m[2])
+			}
+			matched := false
+			for _, name := range strings.Split(condition[2], ",") {
+				matched = matched || name == env
+			}
+			if condition[1] == "!=" {
+				matched = !matched
+			}
+			blocks = append(blocks, block{line: i + 1, matched: matched, enclosed: keeping()})
+		case "else":
+			if len(blocks) == 0 || blocks[len(blocks)-1].inElse {
+				return "", fmt.Errorf("line %d: '-- sqldef:else' without '-- sqldef:if'", i+1)
+			}
+			blocks[len(blocks)-1].inElse = true
+		case "endif":
+			if len(blocks) == 0 {
+				return "", fmt.Errorf("line %d: '-- sqldef:endif' without '-- sqldef:if'", i+1)
+			}
+			blocks = blocks[:len(blocks)-1]
+		}
+	}
+	if len(blocks) > 0 {
+		return "", fmt.Errorf("line %d: '-- sqldef:if' is not closed by '-- sqldef:endif'", blocks[len(blocks)-1].line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,97 @@
+package sqldef
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v2"
+)
+
+// Config file loaded when --config is not given
+const DefaultConfigFile = "sqldef.yml"
+
+// Parse command line arguments on top of a config file, which lets a project declare its defaults, e.g.
+//
+//	host: db.example.com
+//	database: app
+//	enable-drop: true
+//	before-apply: SET ROLE owner;
+//
+// Keys are long option names except `lint`, which configures --lint, and `database` is used when no database is given by the command line.
+// Options given by the command line take precedence over the config file.
+func ParseArgs(parser *flags.Parser, args []string) ([]string, error) {
+	// Find --config before parsing the other options, which should override the config file
+	var preOpts struct {
+		Config string `long:"config"`
+	}
+	if _, err := flags.NewParser(&preOpts, flags.IgnoreUnknown).ParseArgs(args); err != nil {
+		return nil, err
+	}
+	configFile := DefaultConfigFile
+	explicit := false
+	if preOpts.Config != "" {
+		configFile = preOpts.Config
+		explicit = true
+	}
+
+	buf, err := ioutil.ReadFile(configFile)
+	if os.IsNotExist(err) && !explicit {
+		return parser.ParseArgs(args)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var config yaml.MapSlice
+	if err := yaml.Unmarshal(buf, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", configFile, err)
+	}
+
+	database := ""
+	var ini strings.Builder
+	for _, item := range config {
+		key := fmt.Sprint(item.Key)
+		if key == "lint" {
+			continue // read by --lint
+		}
+		var values []string
+		switch value := item.Value.(type) {
+		case []interface{}:
+			for _, v := range value {
+				values = append(values, fmt.Sprint(v))
+			}
+		case yaml.MapSlice:
+			return nil, fmt.Errorf("unexpected mapping for '%s' in %s", key, configFile)
+		case nil:
+			continue
+		default:
+			values = []string{fmt.Sprint(value)}
+		}
+
+		if key == "database" {
+			database = values[0]
+			continue
+		}
+		if parser.FindOptionByLongName(key) == nil {
+			return nil, fmt.Errorf("unknown option '%s' in %s", key, configFile)
+		}
+		for _, value := range values {
+			fmt.Fprintf(&ini, "%s = %s\n", key, strconv.Quote(value))
+		}
+	}
+	if err := flags.NewIniParser(parser).Parse(strings.NewReader(ini.String())); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", configFile, err)
+	}
+
+	args, err = parser.ParseArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 && database != "" {
+		args = []string{database}
+	}
+	return args, nil
+}
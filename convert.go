@@ -0,0 +1,48 @@
+package sqldef
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// Convert a schema file of another tool to the schema SQL of the dialect, choosing its format by the file name:
+// Rails' schema.rb by `.rb`, Rails' structure.sql by `structure.sql`, Prisma's schema by `.prisma`, and sqldef's
+// YAML schema by `.yml` or `.yaml`
+func ConvertSchema(generatorMode schema.GeneratorMode, file string) (string, error) {
+	src, err := ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	switch name := filepath.Base(file); {
+	case strings.HasSuffix(name, ".rb"):
+		return convertRailsSchema(generatorMode, src)
+	case strings.HasSuffix(name, "structure.sql"):
+		return convertRailsStructure(src), nil
+	case strings.HasSuffix(name, ".prisma"):
+		return convertPrismaSchema(generatorMode, src)
+	case isYAMLSchema(name):
+		return convertYAMLSchema(generatorMode, src)
+	default:
+		return "", fmt.Errorf("unsupported format of '%s', which needs to be schema.rb or structure.sql of Rails, schema.prisma, or a YAML schema", file)
+	}
+}
+
+// Warn a statement of a schema file which can't be converted, which needs to be managed in SQL
+func warnNotConverted(statement string) {
+	fmt.Fprintf(os.Stderr, "-- WARNING: '%s' is not converted --\n", statement)
+}
+
+func quoteConvertedName(generatorMode schema.GeneratorMode, name string) string {
+	switch generatorMode {
+	case schema.GeneratorModeMysql:
+		return "`" + name + "`"
+	case schema.GeneratorModeMssql:
+		return "[" + name + "]"
+	default:
+		return `"` + name + `"`
+	}
+}
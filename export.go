@@ -0,0 +1,101 @@
+package sqldef
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+)
+
+// Name of CREATE VIEW, CREATE TRIGGER, CREATE TYPE, and so on, which are dumped as DDL strings
+var objectNameRegexp = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:OR\s+(?:REPLACE|ALTER)\s+)?(?:(?:ALGORITHM\s*=\s*\w+|DEFINER\s*=\s*\S+|SQL\s+SECURITY\s+\w+|MATERIALIZED|TEMP|TEMPORARY)\s+)*(?:VIEW|TRIGGER|TYPE|SEQUENCE|DOMAIN)\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)`)
+
+// Dump the current schema to a directory, one file per object like tables/users.sql,
+// so that it can be committed and diffed in git. Stale files of dropped objects are removed.
+func exportDir(db adapter.Database, dir string, skipTable func(table string) bool) error {
+	types, err := db.Types()
+	if err != nil {
+		return err
+	}
+	if err := writeObjects(dir, "types", types); err != nil {
+		return err
+	}
+
+	tableNames, err := db.TableNames()
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	tables := []string{}
+	for _, tableName := range tableNames {
+		if skipTable != nil && skipTable(tableName) {
+			continue
+		}
+		ddl, err := db.DumpTableDDL(tableName)
+		if err != nil {
+			return err
+		}
+		names = append(names, tableName)
+		tables = append(tables, ddl)
+	}
+	if err := writeObjectsWithNames(dir, "tables", names, tables); err != nil {
+		return err
+	}
+
+	views, err := db.Views()
+	if err != nil {
+		return err
+	}
+	if err := writeObjects(dir, "views", views); err != nil {
+		return err
+	}
+
+	triggers, err := db.Triggers()
+	if err != nil {
+		return err
+	}
+	return writeObjects(dir, "triggers", triggers)
+}
+
+func writeObjects(dir string, kind string, ddls []string) error {
+	names := []string{}
+	for i, ddl := range ddls {
+		name := fmt.Sprintf("%s_%d", strings.TrimSuffix(kind, "s"), i+1)
+		if m := objectNameRegexp.FindStringSubmatch(ddl); m != nil {
+			name = m[1]
+		}
+		names = append(names, name)
+	}
+	return writeObjectsWithNames(dir, kind, names, ddls)
+}
+
+func writeObjectsWithNames(dir string, kind string, names []string, ddls []string) error {
+	kindDir := filepath.Join(dir, kind)
+	stale, err := filepath.Glob(filepath.Join(kindDir, "*.sql"))
+	if err != nil {
+		return err
+	}
+	for _, file := range stale {
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+	if len(ddls) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(kindDir, 0755); err != nil {
+		return err
+	}
+	for i, ddl := range ddls {
+		name := strings.NewReplacer("`", "", "\"", "", "[", "", "]", "", "/", "_").Replace(names[i])
+		if err := ioutil.WriteFile(filepath.Join(kindDir, name+".sql"), []byte(ddl+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package sqldef
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/k0kubun/sqldef/schema"
+)
+
+var (
+	createTableHeadRegexp  = regexp.MustCompile(`(?is)^CREATE\s+(?:[A-Z]+\s+)*TABLE\s`)
+	derivedTableHeadRegexp = regexp.MustCompile(`(?is)\s(AS|OF|USING|PARTITION)(\s|$)`) // CREATE TABLE ... AS SELECT, OF type, USING fts5(...)
+)
+
+// Format CREATE TABLE built by sqldef, i.e. the one whose foreign keys are deferred or the one dumped from --desired-db,
+// with one column or constraint per line so that plans diff cleanly between runs. DDLs written in the desired schema
+// are kept as they are written, and other DDLs are a single clause already.
+func formatDDLs(generatorMode schema.GeneratorMode, ddls []string, desiredDDLs string) []string {
+	formatted := make([]string, len(ddls))
+	for i, ddl := range ddls {
+		if strings.Contains(desiredDDLs, strings.TrimSpace(ddl)) {
+			formatted[i] = ddl
+		} else {
+			formatted[i] = formatCreateTable(generatorMode, ddl)
+		}
+	}
+	return formatted
+}
+
+// Return a DDL as is unless it's CREATE TABLE with a list of columns, or it has comments to be kept in place.
+// Only the columns and constraints are re-indented, and the text of each of them is kept.
+func formatCreateTable(generatorMode schema.GeneratorMode, ddl string) string {
+	tokens, ok := splitTopLevel(generatorMode, strings.TrimSpace(ddl))
+	if !ok || len(tokens) < 2 {
+		return ddl
+	}
+	head := strings.TrimSpace(tokens[0])
+	if !createTableHeadRegexp.MatchString(head+" ") || derivedTableHeadRegexp.MatchString(head) {
+		return ddl
+	}
+	elements, tail := tokens[1:len(tokens)-1], strings.TrimSpace(tokens[len(tokens)-1])
+	if len(elements) == 0 {
+		return ddl
+	}
+	for i, element := range elements {
+		if elements[i] = strings.TrimSpace(element); len(elements[i]) == 0 {
+			return ddl
+		}
+	}
+
+	formatted := head + " (\n  " + strings.Join(elements, ",\n  ") + "\n)"
+	if len(tail) > 0 {
+		formatted += " " + tail
+	}
+	return formatted
+}
+
+// Split `CREATE TABLE t (a, b) tail` into "CREATE TABLE t", "a", "b", and "tail" by commas and parentheses outside
+// literals. ok is false for a DDL without parentheses or with comments.
+func splitTopLevel(generatorMode schema.GeneratorMode, ddl string) (tokens []string, ok bool) {
+	if hasComments(generatorMode, ddl) {
+		return nil, false
+	}
+	start, depth := 0, 0
+	for i := 0; i < len(ddl); i++ {
+		switch c := ddl[i]; {
+		case isQuoteStart(generatorMode, ddl, i):
+			i = skipQuoted(generatorMode, ddl, i)
+		case c == '(' || c == '[':
+			if depth == 0 && c == '(' {
+				tokens = append(tokens, ddl[start:i])
+				start = i + 1
+			}
+			depth++
+		case c == ')' || c == ']':
+			depth--
+			if depth == 0 && c == ')' {
+				return append(tokens, ddl[start:i], ddl[i+1:]), true
+			} else if depth < 0 {
+				return nil, false
+			}
+		case c == ',' && depth == 1:
+			tokens = append(tokens, ddl[start:i])
+			start = i + 1
+		}
+	}
+	return nil, false
+}
+
+// Comments, dollar-quoted strings, and unclosed literals are not formatted
+func hasComments(generatorMode schema.GeneratorMode, ddl string) bool {
+	for i := 0; i < len(ddl); i++ {
+		switch c := ddl[i]; {
+		case isQuoteStart(generatorMode, ddl, i):
+			if i = skipQuoted(generatorMode, ddl, i); i < 0 {
+				return true
+			}
+		case strings.HasPrefix(ddl[i:], "--") || strings.HasPrefix(ddl[i:], "/*") || strings.HasPrefix(ddl[i:], "$$"),
+			c == '#' && generatorMode == schema.GeneratorModeMysql:
+			return true
+		}
+	}
+	return false
+}
+
+// Return true if a literal or a quoted identifier starts at i, i.e. 'a', "a", `a`, and [a] of MSSQL
+func isQuoteStart(generatorMode schema.GeneratorMode, s string, i int) bool {
+	switch s[i] {
+	case '\'', '"', '`':
+		return true
+	case '[':
+		return generatorMode == schema.GeneratorModeMssql
+	}
+	return false
+}
+
+// Return the index of the closing quote of a literal or a quoted identifier starting at i, or -1 if it's not closed
+func skipQuoted(generatorMode schema.GeneratorMode, s string, i int) int {
+	quote, closing := s[i], s[i]
+	if quote == '[' {
+		closing = ']'
+	}
+	// Backslashes escape quotes in literals of MySQL, and in escape strings of PostgreSQL like E'it\'s'
+	backslash := quote == '\'' && (generatorMode == schema.GeneratorModeMysql || isEscapeString(generatorMode, s, i))
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case s[j] == '\\' && backslash:
+			j++
+		case s[j] == closing && j+1 < len(s) && s[j+1] == closing:
+			j++
+		case s[j] == closing:
+			return j
+		}
+	}
+	return -1
+}
+
+func isEscapeString(generatorMode schema.GeneratorMode, s string, i int) bool {
+	switch generatorMode {
+	case schema.GeneratorModePostgres, schema.GeneratorModeCockroach, schema.GeneratorModeRedshift:
+	default:
+		return false
+	}
+	if i == 0 || (s[i-1] != 'E' && s[i-1] != 'e') {
+		return false
+	}
+	return i == 1 || !isIdentifierByte(s[i-2])
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c >= 0x80
+}
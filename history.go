@@ -0,0 +1,80 @@
+package sqldef
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// A table recording applied DDLs by --history, which is never exported or compared with the desired schema
+const historyTable = "sqldef_history"
+
+type appliedDDL struct {
+	statement string
+	duration  time.Duration
+}
+
+func createHistoryTableDDL(generatorMode schema.GeneratorMode) string {
+	switch generatorMode {
+	case schema.GeneratorModeMysql:
+		return "CREATE TABLE IF NOT EXISTS " + historyTable + " (applied_at datetime(6) NOT NULL, applied_by varchar(255), statement longtext NOT NULL, " +
+			"duration_ms bigint NOT NULL, version varchar(255), plan_checksum varchar(100) NOT NULL)"
+	case schema.GeneratorModeMssql:
+		return "IF OBJECT_ID('" + historyTable + "') IS NULL CREATE TABLE " + historyTable + " (applied_at datetime2 NOT NULL, applied_by nvarchar(255), " +
+			"statement nvarchar(max) NOT NULL, duration_ms bigint NOT NULL, version nvarchar(255), plan_checksum nvarchar(100) NOT NULL)"
+	case schema.GeneratorModeRedshift: // TEXT is VARCHAR(256)
+		return "CREATE TABLE IF NOT EXISTS " + historyTable + " (applied_at timestamp NOT NULL, applied_by varchar(255), statement varchar(65535) NOT NULL, " +
+			"duration_ms bigint NOT NULL, version varchar(255), plan_checksum varchar(100) NOT NULL)"
+	default:
+		return "CREATE TABLE IF NOT EXISTS " + historyTable + " (applied_at timestamp NOT NULL, applied_by varchar(255), statement text NOT NULL, " +
+			"duration_ms bigint NOT NULL, version varchar(255), plan_checksum varchar(100) NOT NULL)"
+	}
+}
+
+func insertHistoryDML(generatorMode schema.GeneratorMode) string {
+	var placeholders []string
+	for i := 1; i <= 6; i++ {
+		switch generatorMode {
+		case schema.GeneratorModePostgres, schema.GeneratorModeCockroach, schema.GeneratorModeRedshift:
+			placeholders = append(placeholders, fmt.Sprintf("$%d", i))
+		case schema.GeneratorModeMssql:
+			placeholders = append(placeholders, fmt.Sprintf("@p%d", i))
+		default:
+			placeholders = append(placeholders, "?")
+		}
+	}
+	return fmt.Sprintf("INSERT INTO %s (applied_at, applied_by, statement, duration_ms, version, plan_checksum) VALUES (%s)",
+		historyTable, strings.Join(placeholders, ", "))
+}
+
+// Record applied DDLs with who applied them and the checksum of all DDLs in the apply, creating the table if needed
+func recordHistory(generatorMode schema.GeneratorMode, db adapter.Database, applied []appliedDDL, ddls []string, version string) error {
+	if len(applied) == 0 {
+		return nil
+	}
+	if _, err := db.DB().Exec(createHistoryTableDDL(generatorMode)); err != nil {
+		return err
+	}
+	planChecksum := schemaFingerprint(strings.Join(ddls, ";\n"))
+	appliedAt := time.Now().UTC()
+	for _, ddl := range applied {
+		if _, err := db.DB().Exec(insertHistoryDML(generatorMode), appliedAt, currentUser(), ddl.statement,
+			ddl.duration.Milliseconds(), version, planChecksum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The OS user running sqldef, since the database user is often shared by deployments
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
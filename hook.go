@@ -0,0 +1,47 @@
+package sqldef
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+)
+
+// Run a command of --pre-apply-hook or --post-apply-hook by the shell, e.g. to pause a connection pooler or bust caches.
+// DDLs to apply are given on stdin, and SQLDEF_HOOK is set to "pre" or "post".
+func runApplyHook(hook string, command string, ddls []string, skipDrop bool) error {
+	var plan strings.Builder
+	for _, ddl := range ddls {
+		if !skipDrop || !adapter.IsDropDDLIn(ddls, ddl) {
+			plan.WriteString(ddl + ";\n")
+		}
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = strings.NewReader(plan.String())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "SQLDEF_HOOK="+hook)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("--%s-apply-hook '%s' failed: %s", hook, command, err)
+	}
+	return nil
+}
+
+// Return true if any DDL is applied, not to run hooks only for skipped DDLs
+func hasAppliedDDLs(ddls []string, skipDrop bool) bool {
+	for _, ddl := range ddls {
+		if !skipDrop || !adapter.IsDropDDLIn(ddls, ddl) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,362 @@
+package sqldef
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// Expected impact of a planned DDL given by --impact
+type ddlImpact struct {
+	Lock     string `json:"lock"`               // e.g. ACCESS EXCLUSIVE for PostgreSQL, and NONE, SHARED, or EXCLUSIVE for MySQL
+	Rewrite  bool   `json:"rewrite"`            // the whole table is rewritten
+	Rows     *int64 `json:"rows,omitempty"`     // estimated rows of the table, if known
+	Size     *int64 `json:"size,omitempty"`     // bytes of the table and its indexes, if known
+	Duration *int64 `json:"duration,omitempty"` // estimated seconds to process the table, if it's read and its size is known
+
+	throughput int64 // bytes per second to process the table, or 0 if the table is not read
+}
+
+// Rough throughputs of a single backend, only to tell whether a DDL takes seconds or hours
+const (
+	scanThroughput    = 256 << 20 // e.g. validating a constraint
+	indexThroughput   = 64 << 20  // sorting and writing an index
+	rewriteThroughput = 32 << 20  // writing the table and rebuilding its indexes
+)
+
+func (i ddlImpact) String() string {
+	rewrite := "no"
+	if i.Rewrite {
+		rewrite = "yes"
+	}
+	description := fmt.Sprintf("lock: %s, rewrite: %s", i.Lock, rewrite)
+	if i.Rows != nil {
+		description += fmt.Sprintf(", rows: ~%d", *i.Rows)
+	}
+	if i.Size != nil {
+		description += ", size: " + formatBytes(*i.Size)
+	}
+	if i.Duration != nil {
+		description += ", duration: ~" + formatDuration(*i.Duration)
+	}
+	return description
+}
+
+// e.g. 120 GB
+func formatBytes(bytes int64) string {
+	units := []string{"bytes", "kB", "MB", "GB", "TB"}
+	size, unit := float64(bytes), 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 || size >= 10 {
+		return fmt.Sprintf("%.0f %s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
+}
+
+// e.g. 41m, rounded to a unit large enough for an estimation
+func formatDuration(seconds int64) string {
+	duration := time.Duration(seconds) * time.Second
+	switch {
+	case duration < time.Minute:
+		return fmt.Sprintf("%ds", seconds)
+	case duration < time.Hour:
+		return fmt.Sprintf("%dm", int64(duration.Round(time.Minute)/time.Minute))
+	default:
+		return fmt.Sprintf("%.1fh", duration.Hours())
+	}
+}
+
+// Estimate risks, lock levels, and table rewrites of DDLs by the rules of each engine and version
+type impactAnalyzer struct {
+	mode      schema.GeneratorMode
+	estimator adapter.ImpactEstimator // nil when the database has no statistics, e.g. --file
+	version   *string                 // lazily fetched by serverVersion()
+	rows      map[string]*int64
+	sizes     map[string]*int64
+	ddls      []string // to find views dropped to be created again
+}
+
+func newImpactAnalyzer(mode schema.GeneratorMode, db adapter.Database, ddls []string) *impactAnalyzer {
+	analyzer := &impactAnalyzer{mode: mode, rows: map[string]*int64{}, sizes: map[string]*int64{}, ddls: ddls}
+	if estimator, ok := db.(adapter.ImpactEstimator); ok {
+		analyzer.estimator = estimator
+	}
+	return analyzer
+}
+
+// Return empty if unknown, assuming the latest version
+func (a *impactAnalyzer) serverVersion() string {
+	if a.version == nil {
+		version := ""
+		if a.estimator != nil {
+			var err error
+			if version, err = a.estimator.ServerVersion(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		a.version = &version
+	}
+	return *a.version
+}
+
+// Return safe, blocking, or destructive
+func (a *impactAnalyzer) risk(ddl string) string {
+	if adapter.IsDropDDL(ddl) && !adapter.IsDropDDLIn(a.ddls, ddl) {
+		return riskSafe // a view is created again without losing data
+	}
+	version := ""
+	if a.mode == schema.GeneratorModePostgres || a.mode == schema.GeneratorModeMysql {
+		version = a.serverVersion()
+	}
+	return classifyRisk(a.mode, ddl, version)
+}
+
+// Return nil for engines without rules
+func (a *impactAnalyzer) impact(ddl string) *ddlImpact {
+	var impact ddlImpact
+	switch a.mode {
+	case schema.GeneratorModePostgres:
+		impact = postgresImpact(ddl, a.serverVersion())
+	case schema.GeneratorModeMysql:
+		impact = mysqlImpact(ddl, a.serverVersion())
+	default:
+		return nil
+	}
+	if table := impactedTable(ddl); table != "" {
+		impact.Rows = a.estimatedRows(table)
+		impact.Size = a.tableSize(table)
+		if impact.Size != nil && impact.throughput > 0 {
+			duration := *impact.Size / impact.throughput
+			impact.Duration = &duration
+		}
+	}
+	return &impact
+}
+
+// Annotation of a DDL in --dry-run output, e.g. "-- risk: blocking, lock: SHARE, rewrite: no, rows: ~100"
+func (a *impactAnalyzer) annotate(ddl string, withImpact bool) string {
+	annotation := "-- risk: " + a.risk(ddl)
+	if withImpact {
+		if impact := a.impact(ddl); impact != nil {
+			annotation += ", " + impact.String()
+		}
+	}
+	return annotation
+}
+
+func (a *impactAnalyzer) estimatedRows(table string) *int64 {
+	if a.estimator == nil {
+		return nil
+	}
+	if rows, ok := a.rows[table]; ok {
+		return rows
+	}
+	rows, err := a.estimator.EstimatedRows(table)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rows < 0 {
+		a.rows[table] = nil
+	} else {
+		a.rows[table] = &rows
+	}
+	return a.rows[table]
+}
+
+func (a *impactAnalyzer) tableSize(table string) *int64 {
+	if a.estimator == nil {
+		return nil
+	}
+	if size, ok := a.sizes[table]; ok {
+		return size
+	}
+	size, err := a.estimator.TableSize(table)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if size < 0 {
+		a.sizes[table] = nil
+	} else {
+		a.sizes[table] = &size
+	}
+	return a.sizes[table]
+}
+
+var updateTableRegexp = regexp.MustCompile(`(?is)^\s*UPDATE\s+([^\s(;]+)`)
+
+// Return the existing table locked by the DDL, e.g. users of ALTER TABLE users and CREATE INDEX ... ON users
+func impactedTable(ddl string) string {
+	unquote := strings.NewReplacer("`", "", "\"", "", "[", "", "]", "")
+	if m := updateTableRegexp.FindStringSubmatch(ddl); m != nil {
+		return unquote.Replace(m[1])
+	}
+	planned := describeDDL(ddl, false)
+	switch planned.Operation {
+	case "ALTER TABLE", "DROP TABLE":
+		return planned.Object
+	case "CREATE INDEX", "CREATE TRIGGER", "CREATE POLICY":
+		if m := onTableRegexp.FindStringSubmatch(ddl); m != nil {
+			return unquote.Replace(m[1])
+		}
+	}
+	return ""
+}
+
+var (
+	postgresVersionRegexp          = regexp.MustCompile(`^PostgreSQL (\d+)`)
+	postgresAddForeignKeyRegexp    = regexp.MustCompile(`(?is)\sADD\s+(CONSTRAINT\s+\S+\s+)?FOREIGN\s+KEY\s`)
+	postgresValidateRegexp         = regexp.MustCompile(`(?is)\sVALIDATE\s+CONSTRAINT\s`)
+	postgresAlterTypeRegexp        = regexp.MustCompile(`(?is)\sALTER\s+(COLUMN\s+)?\S+\s+(SET\s+DATA\s+)?TYPE\s`)
+	postgresAddColumnRegexp        = regexp.MustCompile(`(?is)\sADD\s+COLUMN\s`)
+	postgresSerialRegexp           = regexp.MustCompile(`(?i)\s(small|big)?serial\b|\sGENERATED\s+ALWAYS\s+AS\s*\(.*\)\s*STORED`)
+	postgresVolatileDefaultRegexp  = regexp.MustCompile(`(?is)\sDEFAULT\s.*\b(random|clock_timestamp|timeofday|nextval|gen_random_uuid|uuid_generate_v[14])\s*\(`)
+	postgresConstantDefaultRegexp  = regexp.MustCompile(`(?is)\sDEFAULT\s`)
+	postgresSetDistributedRegexp   = regexp.MustCompile(`(?is)\sSET\s+DISTRIBUTED\s`)
+	postgresConcurrentlyRegexp     = regexp.MustCompile(`(?is)^\s*(CREATE|DROP)\s+(UNIQUE\s+)?INDEX\s+CONCURRENTLY\s`)
+	postgresIndexConstraintRegexp  = regexp.MustCompile(`(?is)\sADD\s+(CONSTRAINT\s+\S+\s+)?(PRIMARY\s+KEY|UNIQUE|EXCLUDE)\b`)
+	postgresCreateIndexRegexp      = regexp.MustCompile(`(?is)^\s*CREATE\s+(UNIQUE\s+)?INDEX\s`)
+	postgresCreateNewObjectRegexp  = regexp.MustCompile(`(?is)^\s*CREATE\s+(TABLE|VIEW|MATERIALIZED\s+VIEW|TYPE|SEQUENCE|SCHEMA|EXTENSION|FUNCTION|PROCEDURE)\s`)
+	postgresCreateTriggerRegexp    = regexp.MustCompile(`(?is)^\s*CREATE\s+(OR\s+REPLACE\s+)?(CONSTRAINT\s+)?TRIGGER\s`)
+	postgresCommentRegexp          = regexp.MustCompile(`(?is)^\s*COMMENT\s+ON\s`)
+	postgresUpdateRegexp           = regexp.MustCompile(`(?is)^\s*UPDATE\s`)
+	postgresAlterTableActionRegexp = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s`)
+)
+
+// https://www.postgresql.org/docs/current/explicit-locking.html
+func postgresImpact(ddl string, version string) ddlImpact {
+	major := 0 // unknown, assuming the latest version
+	if m := postgresVersionRegexp.FindStringSubmatch(version); m != nil {
+		major, _ = strconv.Atoi(m[1])
+	}
+
+	switch {
+	case postgresUpdateRegexp.MatchString(ddl):
+		return ddlImpact{Lock: "ROW EXCLUSIVE", throughput: rewriteThroughput}
+	case postgresConcurrentlyRegexp.MatchString(ddl):
+		if strings.EqualFold(postgresConcurrentlyRegexp.FindStringSubmatch(ddl)[1], "DROP") {
+			return ddlImpact{Lock: "SHARE UPDATE EXCLUSIVE"}
+		}
+		return ddlImpact{Lock: "SHARE UPDATE EXCLUSIVE", throughput: indexThroughput / 2} // scanning the table twice
+	case postgresCreateIndexRegexp.MatchString(ddl):
+		return ddlImpact{Lock: "SHARE", throughput: indexThroughput}
+	case postgresCreateNewObjectRegexp.MatchString(ddl):
+		return ddlImpact{Lock: "NONE"}
+	case postgresCreateTriggerRegexp.MatchString(ddl):
+		return ddlImpact{Lock: "SHARE ROW EXCLUSIVE"}
+	case postgresCommentRegexp.MatchString(ddl):
+		return ddlImpact{Lock: "SHARE UPDATE EXCLUSIVE"}
+	case postgresAlterTableActionRegexp.MatchString(ddl):
+		notValid := postgresNotValidRegexp.MatchString(ddl)
+		if postgresAddForeignKeyRegexp.MatchString(ddl) {
+			if notValid {
+				return ddlImpact{Lock: "SHARE ROW EXCLUSIVE"}
+			}
+			return ddlImpact{Lock: "SHARE ROW EXCLUSIVE", throughput: scanThroughput}
+		}
+		if postgresValidateRegexp.MatchString(ddl) {
+			return ddlImpact{Lock: "SHARE UPDATE EXCLUSIVE", throughput: scanThroughput}
+		}
+		impact := ddlImpact{Lock: "ACCESS EXCLUSIVE"}
+		if postgresIndexConstraintRegexp.MatchString(ddl) {
+			impact.throughput = indexThroughput
+		} else if postgresScanRegexp.MatchString(ddl) && !notValid {
+			impact.throughput = scanThroughput
+		}
+		if postgresAlterTypeRegexp.MatchString(ddl) || postgresSetDistributedRegexp.MatchString(ddl) {
+			impact.Rewrite = true
+		} else if postgresAddColumnRegexp.MatchString(ddl) {
+			// Since PostgreSQL 11, a non-volatile default is stored in the catalog without a rewrite
+			impact.Rewrite = postgresSerialRegexp.MatchString(ddl) || postgresVolatileDefaultRegexp.MatchString(ddl) ||
+				(major > 0 && major < 11 && postgresConstantDefaultRegexp.MatchString(ddl))
+		}
+		if impact.Rewrite {
+			impact.throughput = rewriteThroughput
+		}
+		return impact
+	default:
+		return ddlImpact{Lock: "ACCESS EXCLUSIVE"}
+	}
+}
+
+var (
+	mysqlVersionRegexp          = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+	mysqlUpdateRegexp           = regexp.MustCompile(`(?is)^\s*UPDATE\s`)
+	mysqlCreateIndexRegexp      = regexp.MustCompile(`(?is)^\s*CREATE\s+((UNIQUE|FULLTEXT|SPATIAL)\s+)?INDEX\s|^\s*ALTER\s+TABLE\s+\S+\s+ADD\s+((UNIQUE|FULLTEXT|SPATIAL)\s+)?(INDEX|KEY)\s`)
+	mysqlFulltextIndexRegexp    = regexp.MustCompile(`(?is)\b(FULLTEXT|SPATIAL)\s`)
+	mysqlCreateNewObjectRegexp  = regexp.MustCompile(`(?is)^\s*CREATE\s+(TABLE|VIEW|TRIGGER|FUNCTION|PROCEDURE|SEQUENCE)\s`)
+	mysqlDropTableRegexp        = regexp.MustCompile(`(?is)^\s*DROP\s+TABLE\s`)
+	mysqlDropIndexRegexp        = regexp.MustCompile(`(?is)^\s*DROP\s+INDEX\s|\sDROP\s+(INDEX|KEY|FOREIGN\s+KEY)\s`)
+	mysqlAddColumnRegexp        = regexp.MustCompile(`(?is)\sADD\s+COLUMN\s`)
+	mysqlColumnPositionRegexp   = regexp.MustCompile(`(?is)\s(AFTER\s+\S+|FIRST)\s*$`)
+	mysqlDropColumnRegexp       = regexp.MustCompile(`(?is)\sDROP\s+COLUMN\s`)
+	mysqlAddPrimaryKeyRegexp    = regexp.MustCompile(`(?is)\sADD\s+(CONSTRAINT\s+\S+\s+)?PRIMARY\s+KEY\b`)
+	mysqlMetadataOnlyRegexp     = regexp.MustCompile(`(?is)\sRENAME\s+(COLUMN|TO)\s|\sALTER\s+(COLUMN\s+)?\S+\s+(SET|DROP)\s+DEFAULT\b|^\s*RENAME\s+TABLE\s`)
+	mysqlAlterTableActionRegexp = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s`)
+)
+
+// https://dev.mysql.com/doc/refman/8.0/en/innodb-online-ddl-operations.html
+func mysqlImpact(ddl string, version string) ddlImpact {
+	mariadb := strings.Contains(version, "MariaDB")
+	atLeast := func(major, minor, patch int) bool {
+		m := mysqlVersionRegexp.FindStringSubmatch(version)
+		if m == nil {
+			return true // unknown, assuming the latest version
+		}
+		v := [3]int{}
+		for i := range v {
+			v[i], _ = strconv.Atoi(m[i+1])
+		}
+		return v[0] > major || (v[0] == major && (v[1] > minor || (v[1] == minor && v[2] >= patch)))
+	}
+
+	switch {
+	case mysqlUpdateRegexp.MatchString(ddl):
+		return ddlImpact{Lock: "NONE", throughput: rewriteThroughput}
+	case mysqlCreateIndexRegexp.MatchString(ddl):
+		if mysqlFulltextIndexRegexp.MatchString(ddl) {
+			return ddlImpact{Lock: "SHARED", throughput: indexThroughput}
+		}
+		return ddlImpact{Lock: "NONE", throughput: indexThroughput}
+	case mysqlCreateNewObjectRegexp.MatchString(ddl):
+		return ddlImpact{Lock: "NONE"}
+	case mysqlDropTableRegexp.MatchString(ddl):
+		return ddlImpact{Lock: "EXCLUSIVE"}
+	case mysqlDropIndexRegexp.MatchString(ddl), mysqlMetadataOnlyRegexp.MatchString(ddl):
+		return ddlImpact{Lock: "NONE"}
+	case mysqlAddColumnRegexp.MatchString(ddl):
+		// ALGORITHM=INSTANT is used for columns added at any position since MySQL 8.0.29, and at the last since 8.0.12
+		var instant bool
+		if mariadb {
+			instant = atLeast(10, 4, 0) || (atLeast(10, 3, 2) && !mysqlColumnPositionRegexp.MatchString(ddl))
+		} else {
+			instant = atLeast(8, 0, 29) || (atLeast(8, 0, 12) && !mysqlColumnPositionRegexp.MatchString(ddl))
+		}
+		return mysqlRewrite(ddlImpact{Lock: "NONE", Rewrite: !instant})
+	case mysqlDropColumnRegexp.MatchString(ddl):
+		instant := (mariadb && atLeast(10, 4, 0)) || (!mariadb && atLeast(8, 0, 29))
+		return mysqlRewrite(ddlImpact{Lock: "NONE", Rewrite: !instant})
+	case mysqlAddPrimaryKeyRegexp.MatchString(ddl):
+		return mysqlRewrite(ddlImpact{Lock: "NONE", Rewrite: true})
+	case mysqlAlterTableActionRegexp.MatchString(ddl):
+		// CHANGE COLUMN, DROP PRIMARY KEY, and foreign keys with foreign_key_checks use ALGORITHM=COPY
+		return mysqlRewrite(ddlImpact{Lock: "SHARED", Rewrite: true})
+	default:
+		return ddlImpact{Lock: "EXCLUSIVE"}
+	}
+}
+
+func mysqlRewrite(impact ddlImpact) ddlImpact {
+	if impact.Rewrite {
+		impact.throughput = rewriteThroughput
+	}
+	return impact
+}
@@ -0,0 +1,67 @@
+package sqldef
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// e.g. `-- sqldef:include tables/users.sql`, and psql's `\i tables/users.sql`, `\ir tables/users.sql`, and their long forms
+var includeRegexp = regexp.MustCompile(`(?im)^[ \t]*(?:--[ \t]*sqldef:include|\\(?:include_relative|include|ir|i))[ \t]+('[^'\n]*'|[^\s;]+)[ \t]*;?[ \t]*$`)
+
+// Replace include directives with the included files, resolved relative to the including file, or the current directory for stdin.
+// The path may be a directory or a glob like `-f`, whose files are included in lexical order. Included files may include others.
+func expandIncludes(sql string, file string) (string, error) {
+	dir := "."
+	if file != "-" {
+		dir = filepath.Dir(file)
+	}
+	return expandIncludesIn(sql, dir, []string{file})
+}
+
+func expandIncludesIn(sql string, dir string, including []string) (string, error) {
+	var err error
+	expanded := includeRegexp.ReplaceAllStringFunc(sql, func(directive string) string {
+		if err != nil {
+			return directive
+		}
+		path := strings.Trim(includeRegexp.FindStringSubmatch(directive)[1], "'")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		var files []string
+		if files, err = expandFiles(path); err != nil {
+			err = fmt.Errorf("failed to include '%s' from '%s': %s", path, including[len(including)-1], err)
+			return directive
+		}
+
+		var sqls []string
+		for _, file := range files {
+			for _, parent := range including {
+				if sameFile(parent, file) {
+					err = fmt.Errorf("'%s' is included recursively: %s -> %s", file, strings.Join(including, " -> "), file)
+					return directive
+				}
+			}
+			var buf []byte
+			if buf, err = ioutil.ReadFile(file); err != nil {
+				return directive
+			}
+			var included string
+			if included, err = expandIncludesIn(string(buf), filepath.Dir(file), append(including, file)); err != nil {
+				return directive
+			}
+			sqls = append(sqls, included)
+		}
+		return joinFiles(sqls)
+	})
+	return expanded, err
+}
+
+func sameFile(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
@@ -0,0 +1,35 @@
+package sqldef
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Exit when --timeout expires before DDLs are applied, which has nothing to clean up
+func exitOnTimeout(timeout time.Duration) *time.Timer {
+	if timeout <= 0 {
+		return nil
+	}
+	return time.AfterFunc(timeout, func() {
+		log.Fatalf("Timed out by --timeout=%s", timeout)
+	})
+}
+
+// Context to apply DDLs, cancelled by the rest of --timeout, SIGINT, or SIGTERM,
+// so that the running DDL is cancelled and the transaction is rolled back instead of leaving locks behind.
+func applyContext(timer *time.Timer, deadline time.Time) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if timer == nil {
+		return ctx, stop
+	}
+	timer.Stop()
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
@@ -0,0 +1,175 @@
+package sqldef
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/k0kubun/sqldef/schema"
+	"gopkg.in/yaml.v2"
+)
+
+// The `lint` section of the config file, e.g.
+//
+//	lint:
+//	  rules:
+//	    missing-primary-key: error
+//	    reserved-word: off
+//	  naming:
+//	    - name: index-name
+//	      object: index
+//	      pattern: ^idx_{table}_{columns}$
+type lintConfig struct {
+	Rules  map[string]string `yaml:"rules"` // rule name -> error, warning, or off
+	Naming []struct {
+		Name    string `yaml:"name"`
+		Object  string `yaml:"object"`
+		Pattern string `yaml:"pattern"`
+	} `yaml:"naming"`
+}
+
+const (
+	lintError   = "error"
+	lintWarning = "warning" // default
+	lintOff     = "off"
+)
+
+// Load the `lint` section of --config, or sqldef.yml if it exists
+func loadLintConfig(configFile string) (lintConfig, error) {
+	var config struct {
+		Lint lintConfig `yaml:"lint"`
+	}
+	if configFile == "" {
+		configFile = DefaultConfigFile
+		if _, err := os.Stat(configFile); os.IsNotExist(err) {
+			return config.Lint, nil
+		}
+	}
+	buf, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return config.Lint, err
+	}
+	if err := yaml.Unmarshal(buf, &config); err != nil {
+		return config.Lint, fmt.Errorf("failed to parse %s: %s", configFile, err)
+	}
+
+	rules := map[string]bool{}
+	for _, rule := range schema.LintRules {
+		rules[rule] = true
+	}
+	for _, rule := range config.Lint.Naming {
+		if rule.Name == "" || rule.Pattern == "" {
+			return config.Lint, fmt.Errorf("lint naming rules need name and pattern in %s", configFile)
+		}
+		rules[rule.Name] = true
+	}
+	for rule, severity := range config.Lint.Rules {
+		if !rules[rule] {
+			return config.Lint, fmt.Errorf("unknown lint rule '%s' in %s", rule, configFile)
+		}
+		if severity != lintError && severity != lintWarning && severity != lintOff {
+			return config.Lint, fmt.Errorf("unexpected severity '%s' of lint rule '%s' in %s, which should be error, warning, or off", severity, rule, configFile)
+		}
+	}
+	return config.Lint, nil
+}
+
+// Print problems of the desired schema found by --lint. Exit with 2 if any of them is an error, or any with --check.
+func lintSchema(generatorMode schema.GeneratorMode, options *Options) {
+	config, err := loadLintConfig(options.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	namingRules := []schema.NamingRule{}
+	for _, rule := range config.Naming {
+		namingRules = append(namingRules, schema.NamingRule{Name: rule.Name, Object: rule.Object, Pattern: rule.Pattern})
+	}
+
+	sqls := readFiles(generatorMode, options.DesiredFiles)
+	violations, err := schema.Lint(generatorMode, readDesiredDDLs(generatorMode, sqls, options), namingRules)
+	if err != nil {
+		fmt.Fprintln(log.Writer(), err)
+		os.Exit(1)
+	}
+
+	sources := lintSources(options.DesiredFiles, sqls)
+	reported := 0
+	failed := false
+	for _, violation := range violations {
+		severity := config.Rules[violation.Rule]
+		if severity == "" {
+			severity = lintWarning
+		}
+		if severity == lintOff {
+			continue
+		}
+		position := locateViolation(sources, violation)
+		if position != "" {
+			position += ": "
+		}
+		fmt.Printf("%s%s: %s: %s\n", position, severity, violation.Rule, violation.Message)
+		reported++
+		failed = failed || severity == lintError
+	}
+	if reported == 0 {
+		fmt.Println("-- No lint violations --")
+		return
+	}
+	if failed || options.Check {
+		os.Exit(2)
+	}
+}
+
+type lintSource struct {
+	file string
+	sql  string
+}
+
+// Read each file expanded from directories and globs again, except for stdin
+func lintSources(files []string, sqls []string) []lintSource {
+	sources := []lintSource{}
+	for i, file := range files {
+		if file == "-" {
+			sources = append(sources, lintSource{file: "stdin", sql: sqls[i]})
+			continue
+		}
+		expanded, err := expandFiles(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, path := range expanded {
+			buf, err := ioutil.ReadFile(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sources = append(sources, lintSource{file: path, sql: string(buf)})
+		}
+	}
+	return sources
+}
+
+// Return "file:line" of the table or column by the first line of its statement, or empty if it's not found,
+// e.g. when the statement is generated by --template.
+func locateViolation(sources []lintSource, violation schema.LintViolation) string {
+	firstLine := strings.TrimSpace(strings.SplitN(strings.TrimSpace(violation.Statement), "\n", 2)[0])
+	name := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(violation.Name) + `\b`)
+	for _, source := range sources {
+		start := strings.Index(source.sql, firstLine)
+		if start < 0 {
+			continue
+		}
+		end := len(source.sql)
+		if i := strings.Index(source.sql[start:], ";"); i >= 0 {
+			end = start + i
+		}
+		offset := start
+		if loc := name.FindStringIndex(source.sql[start:end]); loc != nil {
+			offset += loc[0]
+		}
+		return fmt.Sprintf("%s:%d", source.file, strings.Count(source.sql[:offset], "\n")+1)
+	}
+	return ""
+}
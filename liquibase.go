@@ -0,0 +1,124 @@
+package sqldef
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+	"gopkg.in/yaml.v2"
+)
+
+// Values of dbms of a changeset, which Liquibase runs only against the database
+var liquibaseDBMS = map[schema.GeneratorMode]string{
+	schema.GeneratorModeMysql:     "mysql",
+	schema.GeneratorModePostgres:  "postgresql",
+	schema.GeneratorModeSQLite3:   "sqlite",
+	schema.GeneratorModeMssql:     "mssql",
+	schema.GeneratorModeCockroach: "cockroachdb",
+	schema.GeneratorModeRedshift:  "redshift",
+}
+
+type liquibaseChangeLog struct {
+	XMLName        xml.Name           `xml:"databaseChangeLog"`
+	XMLNS          string             `xml:"xmlns,attr"`
+	XSI            string             `xml:"xmlns:xsi,attr"`
+	SchemaLocation string             `xml:"xsi:schemaLocation,attr"`
+	ChangeSet      liquibaseChangeSet `xml:"changeSet"`
+}
+
+type liquibaseChangeSet struct {
+	ID       string         `xml:"id,attr"`
+	Author   string         `xml:"author,attr"`
+	DBMS     string         `xml:"dbms,attr"`
+	Comment  string         `xml:"comment"`
+	Changes  []liquibaseSQL `xml:"sql"`
+	Rollback struct {
+		Changes []liquibaseSQL `xml:"sql"`
+	} `xml:"rollback"`
+}
+
+type liquibaseSQL struct {
+	SplitStatements bool   `xml:"splitStatements,attr"`
+	SQL             string `xml:",cdata"`
+}
+
+// Print generated DDLs as a changeset of a Liquibase changelog by --output=liquibase, with DDLs reverting them as its rollback,
+// so that Liquibase applies DDLs generated by sqldef. It's YAML when --output-file is `.yml` or `.yaml`, or XML otherwise.
+func showLiquibase(generatorMode schema.GeneratorMode, ddls []string, skipDrop bool, currentDDLs string, desiredDDLs string, config schema.GeneratorConfig, options *Options) {
+	var changes []liquibaseSQL
+	for _, ddl := range ddls {
+		if !skipDrop || !adapter.IsDropDDLIn(ddls, ddl) {
+			changes = append(changes, liquibaseSQL{SQL: ddl})
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Println("-- Nothing is modified --")
+		return
+	}
+	downDDLs, err := generateRollbackDDLs(generatorMode, ddls, skipDrop, currentDDLs, desiredDDLs, config)
+	if err != nil {
+		log.Fatalf("Failed to generate the rollback of --output=liquibase: %s", err)
+	}
+
+	changeSet := liquibaseChangeSet{
+		ID:      time.Now().UTC().Format("20060102150405"),
+		Author:  "sqldef",
+		DBMS:    liquibaseDBMS[generatorMode],
+		Comment: "Generated by sqldef",
+		Changes: changes,
+	}
+	for _, ddl := range downDDLs {
+		changeSet.Rollback.Changes = append(changeSet.Rollback.Changes, liquibaseSQL{SQL: ddl})
+	}
+
+	ext := strings.ToLower(filepath.Ext(options.OutputFile))
+	if ext == ".yml" || ext == ".yaml" {
+		fmt.Print(liquibaseYAML(changeSet))
+	} else {
+		fmt.Print(liquibaseXML(changeSet))
+	}
+	exitOnDrift(ddls, options)
+}
+
+func liquibaseXML(changeSet liquibaseChangeSet) string {
+	buf, err := xml.MarshalIndent(liquibaseChangeLog{
+		XMLNS:          "http://www.liquibase.org/xml/ns/dbchangelog",
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.liquibase.org/xml/ns/dbchangelog http://www.liquibase.org/xml/ns/dbchangelog/dbchangelog-latest.xsd",
+		ChangeSet:      changeSet,
+	}, "", "    ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	return xml.Header + string(buf) + "\n"
+}
+
+func liquibaseYAML(changeSet liquibaseChangeSet) string {
+	sqls := func(changes []liquibaseSQL) []yaml.MapSlice {
+		items := []yaml.MapSlice{}
+		for _, change := range changes {
+			items = append(items, yaml.MapSlice{{Key: "sql", Value: yaml.MapSlice{
+				{Key: "splitStatements", Value: change.SplitStatements},
+				{Key: "sql", Value: change.SQL},
+			}}})
+		}
+		return items
+	}
+	buf, err := yaml.Marshal(yaml.MapSlice{{Key: "databaseChangeLog", Value: []yaml.MapSlice{{{Key: "changeSet", Value: yaml.MapSlice{
+		{Key: "id", Value: changeSet.ID},
+		{Key: "author", Value: changeSet.Author},
+		{Key: "dbms", Value: changeSet.DBMS},
+		{Key: "comment", Value: changeSet.Comment},
+		{Key: "changes", Value: sqls(changeSet.Changes)},
+		{Key: "rollback", Value: sqls(changeSet.Rollback.Changes)},
+	}}}}}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return string(buf)
+}
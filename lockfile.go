@@ -0,0 +1,45 @@
+package sqldef
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Written by --lock-file after applying the desired schema, to detect out-of-band changes of the database in later runs
+type schemaLock struct {
+	DesiredChecksum     string `json:"desired_checksum,omitempty"` // of the desired schema SQL, which is unknown for --apply
+	DatabaseFingerprint string `json:"database_fingerprint"`       // of the current schema after applying the desired one
+}
+
+// Warn when the current schema doesn't match the one recorded by the last apply. A missing lock file is not warned.
+func checkLockFile(path string, currentDDLs string) error {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var lock schemaLock
+	if err := json.Unmarshal(buf, &lock); err != nil || len(lock.DatabaseFingerprint) == 0 {
+		return fmt.Errorf("'%s' is not a lock file written by --lock-file", path)
+	}
+	if lock.DatabaseFingerprint != schemaFingerprint(currentDDLs) {
+		fmt.Fprintf(os.Stderr, "-- WARNING: The database has changed since the last apply recorded in '%s', e.g. by a manual DDL --\n", path)
+	}
+	return nil
+}
+
+func writeLockFile(path string, desiredDDLs string, currentDDLs string) error {
+	lock := schemaLock{DatabaseFingerprint: schemaFingerprint(currentDDLs)}
+	if len(desiredDDLs) > 0 {
+		lock.DesiredChecksum = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(desiredDDLs)))
+	}
+	buf, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(buf, '\n'), 0644)
+}
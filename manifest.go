@@ -0,0 +1,59 @@
+package sqldef
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Read names of tables and views managed by sqldef from --manifest, one per line.
+// A missing manifest is treated as an empty one, and lines starting with # are ignored.
+func readManifest(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, scanner.Err()
+}
+
+// Record tables and views created by sqldef, so that they're managed in later runs
+func appendManifest(path string, managed []string, created []string) error {
+	known := map[string]bool{}
+	for _, name := range managed {
+		known[name] = true
+	}
+
+	var lines []string
+	for _, name := range created {
+		name = strings.ReplaceAll(name, "\"", "")
+		if !known[name] {
+			lines = append(lines, name)
+			known[name] = true
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, strings.Join(lines, "\n"))
+	return err
+}
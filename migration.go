@@ -0,0 +1,55 @@
+package sqldef
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// Return file names of up and down migrations in --migration-format, for migration runners applying them in order of versions
+func migrationFileNames(format string, version string) (string, string) {
+	switch format {
+	case "flyway":
+		return fmt.Sprintf("V%s__sqldef.sql", version), fmt.Sprintf("U%s__sqldef.sql", version) // undo migrations of Flyway Teams
+	default: // golang-migrate
+		return fmt.Sprintf("%s_sqldef.up.sql", version), fmt.Sprintf("%s_sqldef.down.sql", version)
+	}
+}
+
+// Write generated DDLs and DDLs reverting them to a pair of timestamped migration files by --output=migration,
+// and return their paths. Skipped DDLs are excluded.
+func writeMigration(dir string, format string, generatorMode schema.GeneratorMode, ddls []string, skipDrop bool, currentDDLs string, desiredDDLs string, config schema.GeneratorConfig) ([]string, error) {
+	downDDLs, err := generateRollbackDDLs(generatorMode, ddls, skipDrop, currentDDLs, desiredDDLs, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var up, down strings.Builder
+	for _, ddl := range ddls {
+		if skipDrop && adapter.IsDropDDLIn(ddls, ddl) {
+			continue
+		}
+		up.WriteString(ddl + ";\n")
+	}
+	for _, ddl := range downDDLs {
+		down.WriteString(ddl + ";\n")
+	}
+
+	upFile, downFile := migrationFileNames(format, time.Now().UTC().Format("20060102150405"))
+	paths := []string{filepath.Join(dir, upFile), filepath.Join(dir, downFile)}
+	for i, content := range []string{up.String(), down.String()} {
+		if err := ioutil.WriteFile(paths[i], []byte(content), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,86 @@
+package sqldef
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// An object selected by --only, e.g. table:users and index:index_users_on_name, or a number of a DDL in the plan
+type onlySelector struct {
+	selector string
+	number   int            // 1-based index of a DDL, or 0 for an object
+	kind     string         // e.g. table, index, and view
+	name     *regexp.Regexp // a LIKE pattern matched with or without schema
+}
+
+func parseOnlySelector(selector string) (onlySelector, error) {
+	if number, err := strconv.Atoi(selector); err == nil {
+		if number <= 0 {
+			return onlySelector{}, fmt.Errorf("--only=%s should be 1 or more to select a DDL by its number", selector)
+		}
+		return onlySelector{selector: selector, number: number}, nil
+	}
+	kindAndName := strings.SplitN(selector, ":", 2)
+	if len(kindAndName) != 2 || kindAndName[0] == "" || kindAndName[1] == "" {
+		return onlySelector{}, fmt.Errorf("--only=%s should be kind:name, e.g. table:users, or a number of a DDL", selector)
+	}
+	return onlySelector{
+		selector: selector,
+		kind:     strings.ToLower(strings.TrimSpace(kindAndName[0])),
+		name:     likeRegexp(strings.TrimSpace(kindAndName[1])),
+	}, nil
+}
+
+// Return true if the DDL at the 0-based index of the plan is selected, e.g. ALTER TABLE users and COMMENT ON TABLE users by table:users
+func (s onlySelector) selects(index int, ddl string) bool {
+	if s.number > 0 {
+		return s.number == index+1
+	}
+	planned := describeDDL(ddl, false)
+	fields := strings.Fields(planned.Operation)
+	if len(fields) < 2 || strings.ToLower(fields[len(fields)-1]) != s.kind {
+		return false
+	}
+	unqualified := planned.Object[strings.LastIndex(planned.Object, ".")+1:]
+	return s.name.MatchString(planned.Object) || s.name.MatchString(unqualified)
+}
+
+// Return DDLs selected by --only in the order of the plan, deferring the others to later runs.
+// A selector matching no DDL is warned, since it may be a typo or already applied by a previous run.
+func selectOnlyDDLs(ddls []string, selectors []string) ([]string, error) {
+	var parsed []onlySelector
+	for _, selector := range selectors {
+		s, err := parseOnlySelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, s)
+	}
+
+	selected := []string{}
+	matched := make([]bool, len(parsed))
+	for i, ddl := range ddls {
+		found := false
+		for j, s := range parsed {
+			if s.selects(i, ddl) {
+				matched[j] = true
+				found = true
+			}
+		}
+		if found {
+			selected = append(selected, ddl)
+		}
+	}
+	for j, s := range parsed {
+		if !matched[j] {
+			fmt.Fprintf(os.Stderr, "-- WARNING: --only=%s selected no DDL --\n", s.selector)
+		}
+	}
+	if deferred := len(ddls) - len(selected); deferred > 0 {
+		fmt.Fprintf(os.Stderr, "-- Deferred %d DDLs not selected by --only --\n", deferred)
+	}
+	return selected, nil
+}
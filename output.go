@@ -0,0 +1,226 @@
+package sqldef
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"golang.org/x/term"
+)
+
+// Replace stdout with the file given by --output-file, e.g. for --export and --dry-run, or discard it for --quiet
+func redirectStdout(path string, quiet bool) (restore func(), err error) {
+	if quiet {
+		path = os.DevNull
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	stdout := os.Stdout
+	os.Stdout = file
+	return func() {
+		os.Stdout = stdout
+		file.Close()
+	}, nil
+}
+
+// A planned DDL in `--dry-run --output=json`
+type plannedDDL struct {
+	Statement   string     `json:"statement"`
+	Operation   string     `json:"operation"` // e.g. CREATE TABLE, ALTER TABLE, DROP INDEX
+	Object      string     `json:"object"`    // e.g. a table name for ALTER TABLE, and an index name for DROP INDEX
+	Destructive bool       `json:"destructive"`
+	Skipped     bool       `json:"skipped"`          // a destructive DDL without --enable-drop
+	Risk        string     `json:"risk"`             // safe, blocking, or destructive
+	Impact      *ddlImpact `json:"impact,omitempty"` // given by --impact
+}
+
+var ddlObjectRegexp = regexp.MustCompile(`(?is)^\s*(CREATE(?:\s+OR\s+REPLACE)?|ALTER|DROP|COMMENT\s+ON)\s+` +
+	`(?:(?:UNIQUE|CLUSTERED|NONCLUSTERED|MATERIALIZED|TEMPORARY|TEMP)\s+)*` +
+	`(TABLE|INDEX|VIEW|TYPE|TRIGGER|SEQUENCE|POLICY|FUNCTION|PROCEDURE|SCHEMA|EXTENSION|COLUMN|CONSTRAINT)\s+` +
+	`(?:CONCURRENTLY\s+)?(?:IF\s+(?:NOT\s+)?EXISTS\s+)?([^\s(;]+)`)
+
+func describeDDL(ddl string, skipDrop bool) plannedDDL {
+	planned := plannedDDL{
+		Statement:   ddl,
+		Destructive: adapter.IsDropDDL(ddl),
+	}
+	planned.Skipped = skipDrop && planned.Destructive
+
+	if m := ddlObjectRegexp.FindStringSubmatch(ddl); m != nil {
+		planned.Operation = strings.ToUpper(strings.Join(strings.Fields(m[1]), " ") + " " + m[2])
+		planned.Object = strings.NewReplacer("`", "", "\"", "", "[", "", "]", "").Replace(m[3])
+	} else if fields := strings.Fields(ddl); len(fields) > 0 {
+		planned.Operation = strings.ToUpper(fields[0]) // e.g. EXEC sp_addextendedproperty
+	}
+	return planned
+}
+
+// Print planned DDLs as a JSON array for bots and review tooling, or as "ddls" of an object with "summary" by --summary
+func showJSONDDLs(ddls []string, skipDrop bool, analyzer *impactAnalyzer, withImpact bool, summary *planSummary) {
+	planned := []plannedDDL{}
+	for _, ddl := range ddls {
+		described := describeDDL(ddl, skipDrop)
+		described.Destructive = adapter.IsDropDDLIn(ddls, ddl)
+		described.Skipped = skipDrop && described.Destructive
+		described.Risk = analyzer.risk(ddl)
+		if withImpact {
+			described.Impact = analyzer.impact(ddl)
+		}
+		planned = append(planned, described)
+	}
+	var output interface{} = planned
+	if summary != nil {
+		output = struct {
+			DDLs    []plannedDDL `json:"ddls"`
+			Summary *planSummary `json:"summary"`
+		}{planned, summary}
+	}
+	out, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+}
+
+// Print planned DDLs as a markdown summary for pull requests and $GITHUB_STEP_SUMMARY.
+// DDLs are grouped by tables in collapsible sections, and the ones with destructive DDLs are expanded and marked.
+func showMarkdownDDLs(ddls []string, skipDrop bool, analyzer *impactAnalyzer, withImpact bool) {
+	type ddlSection struct {
+		group       string
+		lines       []string
+		count       int
+		destructive int
+	}
+	var sections []*ddlSection
+	destructive := 0
+	for _, ddl := range ddls {
+		group := ddlGroup(ddl)
+		var section *ddlSection
+		for _, s := range sections {
+			if s.group == group {
+				section = s
+			}
+		}
+		if section == nil {
+			section = &ddlSection{group: group}
+			sections = append(sections, section)
+		}
+
+		section.count++
+		line := ddl + ";"
+		if adapter.IsDropDDLIn(ddls, ddl) {
+			section.destructive++
+			destructive++
+			if skipDrop {
+				line = "-- Skipped: " + line
+			}
+		}
+		section.lines = append(section.lines, line)
+		if withImpact {
+			section.lines = append(section.lines, analyzer.annotate(ddl, true))
+		}
+	}
+
+	fmt.Print("### Schema changes\n\n")
+	if len(ddls) == 0 {
+		fmt.Println("Nothing is modified.")
+		return
+	}
+	summary := fmt.Sprintf("DDLs: %d", len(ddls))
+	if destructive > 0 {
+		summary += fmt.Sprintf(", destructive: %d", destructive)
+		if skipDrop {
+			summary += " (skipped without `--enable-drop`)"
+		}
+	}
+	fmt.Printf("%s\n\n", summary)
+
+	for _, section := range sections {
+		details, title := "<details>", html.EscapeString(section.group)
+		if section.destructive > 0 {
+			details = "<details open>"
+			title = fmt.Sprintf("\u26a0\ufe0f <b>%s</b> (DDLs: %d, destructive: %d)", title, section.count, section.destructive)
+		} else {
+			title = fmt.Sprintf("%s (DDLs: %d)", title, section.count)
+		}
+		fmt.Printf("%s\n<summary>%s</summary>\n\n```sql\n%s\n```\n\n</details>\n", details, title, strings.Join(section.lines, "\n"))
+	}
+}
+
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// e.g. CREATE INDEX index_name ON users
+var onTableRegexp = regexp.MustCompile(`(?is)\sON\s+(?:ONLY\s+)?([^\s(;]+)`)
+
+// Decoration of --dry-run output for humans, enabled only when stdout is a terminal
+type ddlFormatter struct {
+	grouped bool // print a header when the table of DDLs changes
+	colored bool // additions in green, drops in red, and alters in yellow
+	group   string
+
+	// Annotate each DDL with its risk, which is done on a terminal or with --impact, and its impact with --impact
+	analyzer   *impactAnalyzer
+	annotated  bool
+	withImpact bool
+}
+
+func newDDLFormatter(noColor bool, analyzer *impactAnalyzer, withImpact bool) *ddlFormatter {
+	terminal := term.IsTerminal(int(os.Stdout.Fd()))
+	return &ddlFormatter{
+		grouped:    terminal,
+		colored:    terminal && !noColor && os.Getenv("NO_COLOR") == "",
+		analyzer:   analyzer,
+		annotated:  terminal || withImpact,
+		withImpact: withImpact,
+	}
+}
+
+func (f *ddlFormatter) println(ddl string, line string) {
+	if f.grouped {
+		if group := ddlGroup(ddl); group != f.group {
+			fmt.Printf("\n-- %s --\n", group)
+			f.group = group
+		}
+	}
+	if f.colored {
+		color := colorYellow
+		if adapter.IsDropDDL(ddl) {
+			color = colorRed
+		} else if strings.HasPrefix(describeDDL(ddl, false).Operation, "CREATE") {
+			color = colorGreen
+		}
+		line = color + line + colorReset
+	}
+	fmt.Println(line)
+	if f.annotated {
+		fmt.Println(f.analyzer.annotate(ddl, f.withImpact))
+	}
+}
+
+// Group DDLs by tables. Indexes, triggers, and policies belong to their tables.
+func ddlGroup(ddl string) string {
+	planned := describeDDL(ddl, false)
+	fields := strings.Fields(planned.Operation)
+	if len(fields) < 2 {
+		return "other"
+	}
+	kind := strings.ToLower(fields[len(fields)-1])
+	if kind == "index" || kind == "trigger" || kind == "policy" {
+		if m := onTableRegexp.FindStringSubmatch(ddl); m != nil {
+			return "table: " + strings.NewReplacer("`", "", "\"", "", "[", "", "]", "").Replace(m[1])
+		}
+	}
+	return kind + ": " + planned.Object
+}
@@ -0,0 +1,50 @@
+package sqldef
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Value of --password given without a value, e.g. `-W`. It's never a real password because arguments can't include NUL.
+const PasswordPrompt = "\x00"
+
+// Prompt a password on the terminal like psql, which works even when the schema is given by stdin
+func PromptPassword() (string, error) {
+	in, out := os.Stdin, os.Stdout
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		in, out = tty, tty
+	}
+	if !term.IsTerminal(int(in.Fd())) {
+		return "", fmt.Errorf("failed to prompt a password: no terminal is available")
+	}
+
+	fmt.Fprint(out, "Enter Password: ")
+	pass, err := term.ReadPassword(int(in.Fd()))
+	fmt.Fprintln(out)
+	return string(pass), err
+}
+
+// Read a password given by --password-env or --password-file, e.g. by orchestration systems
+func LookupPassword(env string, file string) (string, error) {
+	if len(env) > 0 && len(file) > 0 {
+		return "", fmt.Errorf("--password-env and --password-file can't be used together")
+	}
+	if len(env) > 0 {
+		password, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("environment variable '%s' of --password-env is not set", env)
+		}
+		return password, nil
+	}
+
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read --password-file: %s", err)
+	}
+	return strings.TrimRight(string(buf), "\r\n"), nil // secrets often end with a newline
+}
@@ -0,0 +1,244 @@
+package sqldef
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// The header of pg_dump's output, e.g. `-- PostgreSQL database dump`
+var pgDumpHeaderRegexp = regexp.MustCompile(`(?m)^-- PostgreSQL database (?:cluster )?dump\s*$`)
+
+var (
+	// Statements of pg_dump which don't describe the schema, e.g. settings of the session, owners, and privileges
+	pgDumpSessionRegexp = regexp.MustCompile(`(?is)^(?:SET\s|RESET\s|SELECT\s+pg_catalog\.|GRANT\s|REVOKE\s|ALTER\s+DEFAULT\s+PRIVILEGES\s|ALTER\s.*\sOWNER\s+TO\s)`)
+
+	// Objects sqldef doesn't manage, which are skipped with a notice
+	pgDumpUnmanagedRegexp = regexp.MustCompile(`(?is)^(?:CREATE\s+(?:EXTENSION|SCHEMA)\s|COMMENT\s+ON\s+(?:EXTENSION|SCHEMA|DATABASE)\s)`)
+
+	pgDumpTableRegexp      = regexp.MustCompile(`(?is)^CREATE\s+(?:UNLOGGED\s+)?TABLE\s+(\S+)\s*\(`)
+	pgDumpConstraintRegexp = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?(\S+)\s+ADD\s+(CONSTRAINT\s+\S+\s+(?:PRIMARY\s+KEY|CHECK)\s.*)$`)
+	pgDumpDefaultRegexp    = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?(\S+)\s+ALTER\s+COLUMN\s+(\S+)\s+SET\s+DEFAULT\s+(.*)$`)
+	pgDumpIdentityRegexp   = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?(\S+)\s+ALTER\s+COLUMN\s+(\S+)\s+ADD\s+(GENERATED\s+(?:ALWAYS|BY\s+DEFAULT)\s+AS\s+IDENTITY)\b`)
+	pgDumpSequenceRegexp   = regexp.MustCompile(`(?is)^CREATE\s+SEQUENCE\s+(\S+)`)
+	pgDumpOwnedByRegexp    = regexp.MustCompile(`(?is)^ALTER\s+SEQUENCE\s+(\S+)\s+OWNED\s+BY\s+(\S+)\.(\S+)$`)
+	pgDumpNextvalRegexp    = regexp.MustCompile(`(?is)^nextval\('([^']+)'::regclass\)$`)
+	pgDumpCopyRegexp       = regexp.MustCompile(`(?is)^COPY\s.*\sFROM\s+stdin$`)
+	pgDumpAlterOnlyRegexp  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+ONLY\s+`)
+)
+
+// Types of columns whose default is a sequence owned by them, i.e. serial columns
+var pgDumpSerialTypes = map[string]string{
+	"smallint": "smallserial",
+	"integer":  "serial",
+	"bigint":   "bigserial",
+}
+
+func isPgDump(sql string) bool {
+	return pgDumpHeaderRegexp.MatchString(sql)
+}
+
+// Convert the output of pg_dump, e.g. `pg_dump --schema-only`, into the schema SQL sqldef understands.
+// Settings of the session, owners, privileges, and data are removed, and primary keys, check constraints,
+// defaults, and identities added by ALTER TABLE are moved into CREATE TABLE. Columns using sequences owned by them become serial.
+func convertPgDump(sql string) string {
+	statements := splitPgDump(sql)
+
+	tables := map[string]int{} // table name -> index of CREATE TABLE
+	sequences := map[string]int{}
+	ownedBy := map[string]string{} // sequence -> table.column
+	for i, statement := range statements {
+		if m := pgDumpTableRegexp.FindStringSubmatch(statement); m != nil {
+			tables[unquotePgDumpName(m[1])] = i
+		} else if m := pgDumpSequenceRegexp.FindStringSubmatch(statement); m != nil {
+			sequences[unquotePgDumpName(m[1])] = i
+		} else if m := pgDumpOwnedByRegexp.FindStringSubmatch(statement); m != nil {
+			ownedBy[unquotePgDumpName(m[1])] = unquotePgDumpName(m[2] + "." + m[3])
+		}
+	}
+
+	removed := map[int]bool{}
+	for i, statement := range statements {
+		if pgDumpSessionRegexp.MatchString(statement) {
+			removed[i] = true
+		} else if pgDumpUnmanagedRegexp.MatchString(statement) {
+			fmt.Fprintf(os.Stderr, "-- Skipped '%s' of pg_dump, which sqldef doesn't manage --\n", strings.Join(strings.Fields(statement), " "))
+			removed[i] = true
+		} else if m := pgDumpOwnedByRegexp.FindStringSubmatch(statement); m != nil {
+			removed[i] = true // removed with the sequence, or kept by the default
+		} else if m := pgDumpConstraintRegexp.FindStringSubmatch(statement); m != nil {
+			if table, ok := tables[unquotePgDumpName(m[1])]; ok {
+				statements[table] = addPgDumpTableElement(statements[table], m[2])
+				removed[i] = true
+			}
+		} else if m := pgDumpIdentityRegexp.FindStringSubmatch(statement); m != nil {
+			if table, ok := tables[unquotePgDumpName(m[1])]; ok {
+				if converted, ok := changePgDumpColumn(statements[table], m[2], "", m[3]); ok {
+					statements[table] = converted
+					removed[i] = true
+				}
+			}
+		} else if m := pgDumpDefaultRegexp.FindStringSubmatch(statement); m != nil {
+			table, ok := tables[unquotePgDumpName(m[1])]
+			if !ok {
+				continue
+			}
+			column := unquotePgDumpName(m[2])
+			if nextval := pgDumpNextvalRegexp.FindStringSubmatch(m[3]); nextval != nil {
+				sequence := unquotePgDumpName(nextval[1])
+				if index, ok := sequences[sequence]; ok && ownedBy[sequence] == unquotePgDumpName(m[1])+"."+column {
+					if converted, ok := changePgDumpColumn(statements[table], m[2], "serial", ""); ok {
+						statements[table] = converted
+						removed[i], removed[index] = true, true
+						continue
+					}
+				}
+			}
+			if converted, ok := changePgDumpColumn(statements[table], m[2], "", "DEFAULT "+m[3]); ok {
+				statements[table] = converted
+				removed[i] = true
+			}
+		}
+	}
+
+	var result []string
+	for i, statement := range statements {
+		if !removed[i] && pgDumpSequenceRegexp.MatchString(statement) {
+			fmt.Fprintf(os.Stderr, "-- Skipped '%s' of pg_dump, which isn't owned by a serial column --\n", strings.Join(strings.Fields(statement), " "))
+			removed[i] = true
+		}
+		if !removed[i] {
+			statement = pgDumpAlterOnlyRegexp.ReplaceAllString(statement, "ALTER TABLE ") // e.g. of unique constraints and foreign keys
+			result = append(result, statement+";")
+		}
+	}
+	return strings.Join(result, "\n\n") + "\n"
+}
+
+// Split pg_dump's output into statements without `;`, dropping comments, psql's meta-commands, and data of COPY
+func splitPgDump(sql string) []string {
+	var statements []string
+	var statement strings.Builder
+	quote := "" // a closing quote of the current string, identifier, or dollar-quoted body
+	lines := strings.SplitAfter(sql, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if quote == "" && strings.TrimSpace(statement.String()) == "" {
+			if trimmed == "" || strings.HasPrefix(trimmed, "--") || strings.HasPrefix(trimmed, "\\") {
+				continue
+			}
+		}
+
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			switch {
+			case quote != "":
+				if strings.HasPrefix(line[j:], quote) {
+					statement.WriteString(quote)
+					j += len(quote) - 1
+					quote = ""
+					continue
+				}
+			case strings.HasPrefix(line[j:], "--"):
+				j = len(line) - 1
+				c = '\n'
+			case c == '\'' || c == '"':
+				quote = string(c)
+			case c == '$':
+				if tag := dollarQuoteRegexp.FindString(line[j:]); tag != "" {
+					quote = tag
+					statement.WriteString(tag)
+					j += len(tag) - 1
+					continue
+				}
+			case c == ';':
+				s := strings.TrimSpace(statement.String())
+				statement.Reset()
+				if pgDumpCopyRegexp.MatchString(s) {
+					for i+1 < len(lines) && strings.TrimRight(lines[i+1], "\r\n") != `\.` {
+						i++
+					}
+					i++
+				} else if s != "" {
+					statements = append(statements, s)
+				}
+				continue
+			}
+			statement.WriteByte(c)
+		}
+	}
+	if s := strings.TrimSpace(statement.String()); s != "" {
+		statements = append(statements, s)
+	}
+	return statements
+}
+
+// Add a table constraint, e.g. `CONSTRAINT users_pkey PRIMARY KEY (id)`, before the closing parenthesis of CREATE TABLE
+func addPgDumpTableElement(createTable string, element string) string {
+	end := pgDumpTableEnd(createTable)
+	if end < 0 {
+		return createTable
+	}
+	body := strings.TrimRight(createTable[:end], " \t\n")
+	separator := ","
+	if strings.HasSuffix(body, "(") {
+		separator = ""
+	}
+	return body + separator + "\n    " + strings.Join(strings.Fields(element), " ") + "\n" + createTable[end:]
+}
+
+// Change a column of CREATE TABLE to serial by "serial", or append the clause to it, e.g. DEFAULT and GENERATED AS IDENTITY
+func changePgDumpColumn(createTable string, column string, serial string, clause string) (string, bool) {
+	end := pgDumpTableEnd(createTable)
+	if end < 0 {
+		return createTable, false
+	}
+	columnRegexp := regexp.MustCompile(`(?m)^(\s+` + regexp.QuoteMeta(column) + `\s+)(\w+)(.*?)(,?)$`)
+	loc := columnRegexp.FindStringSubmatchIndex(createTable[:end])
+	if loc == nil {
+		return createTable, false
+	}
+	prefix, dataType, rest, comma := createTable[loc[2]:loc[3]], createTable[loc[4]:loc[5]], createTable[loc[6]:loc[7]], createTable[loc[8]:loc[9]]
+	if serial != "" {
+		serialType, ok := pgDumpSerialTypes[strings.ToLower(dataType)]
+		if !ok {
+			return createTable, false
+		}
+		dataType = serialType
+	} else {
+		rest += " " + clause
+	}
+	return createTable[:loc[0]] + prefix + dataType + rest + comma + createTable[loc[1]:], true
+}
+
+// Return the index of the parenthesis closing columns of CREATE TABLE
+func pgDumpTableEnd(createTable string) int {
+	depth := 0
+	quote := byte(0)
+	for i := 0; i < len(createTable); i++ {
+		c := createTable[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// e.g. public.users of "public"."users", which are compared with names in other statements
+func unquotePgDumpName(name string) string {
+	return strings.ReplaceAll(name, `"`, "")
+}
@@ -0,0 +1,81 @@
+package sqldef
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+)
+
+const (
+	planHeader           = "-- sqldef plan"
+	planFingerprintLabel = "-- fingerprint: "
+	// Statements may have semicolons inside, e.g. CREATE TRIGGER, so each of them follows this line
+	planStatementMarker = "-- sqldef:statement"
+)
+
+// Fingerprint of the current schema, to detect changes between --plan and --apply
+func schemaFingerprint(currentDDLs string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(currentDDLs)))
+}
+
+// Write DDLs to run with the fingerprint of the current schema. Skipped DDLs are excluded.
+func writePlan(path string, ddls []string, skipDrop bool, currentDDLs string) error {
+	var plan strings.Builder
+	plan.WriteString(planHeader + "\n")
+	plan.WriteString(planFingerprintLabel + schemaFingerprint(currentDDLs) + "\n")
+	writePlanStatements(&plan, appliedDDLs(ddls, skipDrop))
+	return ioutil.WriteFile(path, []byte(plan.String()), 0644)
+}
+
+// Read DDLs in the plan, unless the current schema has changed since it's planned
+func readPlan(path string, currentDDLs string) ([]string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.SplitN(string(buf), "\n", 3)
+	if len(lines) < 2 || lines[0] != planHeader || !strings.HasPrefix(lines[1], planFingerprintLabel) {
+		return nil, fmt.Errorf("'%s' is not a plan file generated by --plan", path)
+	}
+	fingerprint := strings.TrimPrefix(lines[1], planFingerprintLabel)
+	if fingerprint != schemaFingerprint(currentDDLs) {
+		return nil, fmt.Errorf("the current schema has changed since '%s' was planned. Please generate a plan again", path)
+	}
+
+	if len(lines) < 3 {
+		return []string{}, nil
+	}
+	return splitPlanStatements(lines[2]), nil
+}
+
+// Exclude DDLs skipped by skipDrop
+func appliedDDLs(ddls []string, skipDrop bool) []string {
+	applied := []string{}
+	for _, ddl := range ddls {
+		if !skipDrop || !adapter.IsDropDDLIn(ddls, ddl) {
+			applied = append(applied, ddl)
+		}
+	}
+	return applied
+}
+
+func writePlanStatements(plan *strings.Builder, ddls []string) {
+	for _, ddl := range ddls {
+		plan.WriteString(planStatementMarker + "\n")
+		plan.WriteString(ddl + ";\n")
+	}
+}
+
+func splitPlanStatements(statements string) []string {
+	ddls := []string{}
+	for _, ddl := range strings.Split(statements, planStatementMarker+"\n") {
+		ddl = strings.TrimSuffix(strings.TrimSpace(ddl), ";")
+		if len(ddl) > 0 {
+			ddls = append(ddls, ddl)
+		}
+	}
+	return ddls
+}
@@ -0,0 +1,141 @@
+package sqldef
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"gopkg.in/yaml.v2"
+)
+
+// A file given by --policy, which forbids categories of DDLs, e.g.
+//
+//	deny:
+//	  - operation: DROP COLUMN
+//	  - operation: ALTER
+//	    table: audit_%
+//	    reason: audit logs are append-only
+type policy struct {
+	Deny []policyRule `yaml:"deny"`
+}
+
+type policyRule struct {
+	Operation string `yaml:"operation"` // e.g. DROP TABLE, DROP COLUMN, or ALTER for any of ALTER statements
+	Table     string `yaml:"table"`     // a LIKE pattern of table names, with or without schema
+	Reason    string `yaml:"reason"`
+}
+
+func readPolicy(policyFile string) (policy, error) {
+	var p policy
+	buf, err := ioutil.ReadFile(policyFile)
+	if err != nil {
+		return p, err
+	}
+	if err := yaml.UnmarshalStrict(buf, &p); err != nil {
+		return p, fmt.Errorf("failed to parse %s: %s", policyFile, err)
+	}
+	for _, rule := range p.Deny {
+		if rule.Operation == "" && rule.Table == "" {
+			return p, fmt.Errorf("rules of %s need operation or table", policyFile)
+		}
+	}
+	return p, nil
+}
+
+// e.g. DROP COLUMN of `ALTER TABLE users DROP COLUMN name`, and ADD COLUMN of `ALTER TABLE [users] ADD [name] text`
+var alterActionRegexp = regexp.MustCompile(`(?is)(?:^\s*ALTER\s+TABLE\s+(?:ONLY\s+)?(?:IF\s+EXISTS\s+)?\S+|,)\s+` +
+	`(ADD|DROP|ALTER|CHANGE|MODIFY|RENAME)(?:\s+(COLUMN|CONSTRAINT|INDEX|KEY|PRIMARY\s+KEY|FOREIGN\s+KEY|UNIQUE|TO)\b)?`)
+
+// Return operations of a DDL to be matched with policy rules, e.g. ALTER TABLE and DROP COLUMN
+func ddlOperations(ddl string) []string {
+	operation := describeDDL(ddl, false).Operation
+	operations := []string{operation}
+	if operation == "ALTER TABLE" {
+		for _, m := range alterActionRegexp.FindAllStringSubmatch(ddl, -1) {
+			action := strings.ToUpper(m[1])
+			if m[2] != "" {
+				action += " " + strings.ToUpper(strings.Join(strings.Fields(m[2]), " "))
+			} else if action != "RENAME" {
+				action += " COLUMN" // COLUMN is optional, and SQL Server has no COLUMN
+			}
+			operations = append(operations, action)
+		}
+	}
+	return operations
+}
+
+// Convert a LIKE pattern to a regular expression
+func likeRegexp(pattern string) *regexp.Regexp {
+	re := strings.NewReplacer("%", ".*", "_", ".").Replace(regexp.QuoteMeta(pattern))
+	return regexp.MustCompile("(?i)^" + re + "$")
+}
+
+func (r policyRule) denies(ddl string) bool {
+	if r.Operation != "" {
+		denied := strings.ToUpper(strings.Join(strings.Fields(r.Operation), " "))
+		matched := false
+		for _, operation := range ddlOperations(ddl) {
+			matched = matched || operation == denied || strings.HasPrefix(operation, denied+" ")
+		}
+		if !matched {
+			return false
+		}
+	}
+	if r.Table != "" {
+		table := impactedTable(ddl)
+		if planned := describeDDL(ddl, false); table == "" && strings.HasSuffix(planned.Operation, " TABLE") {
+			table = planned.Object // CREATE TABLE
+		}
+		if table == "" {
+			return false
+		}
+		pattern := likeRegexp(r.Table)
+		unqualified := table[strings.LastIndex(table, ".")+1:]
+		if !pattern.MatchString(table) && !pattern.MatchString(unqualified) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r policyRule) String() string {
+	var description string
+	switch {
+	case r.Operation != "" && r.Table != "":
+		description = fmt.Sprintf("%s on %s", r.Operation, r.Table)
+	case r.Operation != "":
+		description = r.Operation
+	default:
+		description = "any on " + r.Table
+	}
+	if r.Reason != "" {
+		description += ": " + r.Reason
+	}
+	return description
+}
+
+// Return an error when DDLs to run are denied by --policy, to abort before applying or showing any of them
+func checkPolicy(policyFile string, ddls []string, skipDrop bool) error {
+	p, err := readPolicy(policyFile)
+	if err != nil {
+		return err
+	}
+	denied := []string{}
+	for _, ddl := range ddls {
+		if skipDrop && adapter.IsDropDDLIn(ddls, ddl) {
+			continue // never run
+		}
+		for _, rule := range p.Deny {
+			if rule.denies(ddl) {
+				denied = append(denied, fmt.Sprintf("-- denied: %s\n%s;", rule, ddl))
+				break
+			}
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("Aborted by --policy=%s, since %d DDLs violate it:\n%s", policyFile, len(denied), strings.Join(denied, "\n"))
+	}
+	return nil
+}
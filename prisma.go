@@ -0,0 +1,844 @@
+package sqldef
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// A value in attributes of schema.prisma, e.g. "users", 255, Cascade, [id, email], now(), or title(sort: Desc)
+type prismaValue struct {
+	str     string        // a string, a number, an identifier, or a function name
+	quoted  bool          // a string
+	isArray bool          // a list
+	array   []prismaValue // elements of a list
+	args    *prismaArgs   // arguments of a function call
+}
+
+// Arguments of an attribute or a function call, e.g. `fields: [authorId], references: [id]`
+type prismaArgs struct {
+	positional []prismaValue
+	named      map[string]prismaValue
+}
+
+// Return an argument given by the name, or the i-th positional one if it's omitted like @map("users")
+func (a prismaArgs) value(name string, i int) (prismaValue, bool) {
+	if value, ok := a.named[name]; ok {
+		return value, true
+	}
+	if i >= 0 && i < len(a.positional) {
+		return a.positional[i], true
+	}
+	return prismaValue{}, false
+}
+
+// @id, @default(now()), @db.VarChar(255), @@index([email]), and so on
+type prismaAttribute struct {
+	name string // with the leading @ or @@
+	args prismaArgs
+}
+
+type prismaAttributes []prismaAttribute
+
+func (a prismaAttributes) find(name string) (prismaArgs, bool) {
+	for _, attribute := range a {
+		if attribute.name == name {
+			return attribute.args, true
+		}
+	}
+	return prismaArgs{}, false
+}
+
+type prismaParser struct {
+	src string
+	pos int
+}
+
+var (
+	prismaAttributeRegexp = regexp.MustCompile(`^@@?[A-Za-z_][\w.]*`)
+	prismaKeyRegexp       = regexp.MustCompile(`^([A-Za-z_]\w*)\s*:`)
+	prismaNumberRegexp    = regexp.MustCompile(`^-?\d+(?:\.\d+)?`)
+	prismaWordRegexp      = regexp.MustCompile(`^[A-Za-z_][\w.]*`)
+)
+
+// Parse attributes following a field or given as a block attribute
+func parsePrismaAttributes(src string) (prismaAttributes, error) {
+	p := &prismaParser{src: src}
+	attributes := prismaAttributes{}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.src) {
+			return attributes, nil
+		}
+		name := prismaAttributeRegexp.FindString(p.src[p.pos:])
+		if name == "" {
+			return nil, fmt.Errorf("unexpected '%s' in: %s", p.src[p.pos:], p.src)
+		}
+		p.pos += len(name)
+		attribute := prismaAttribute{name: name, args: prismaArgs{named: map[string]prismaValue{}}}
+		if p.pos < len(p.src) && p.src[p.pos] == '(' {
+			p.pos++
+			args, err := p.parseArgs(')')
+			if err != nil {
+				return nil, err
+			}
+			attribute.args = args
+		}
+		attributes = append(attributes, attribute)
+	}
+}
+
+func (p *prismaParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// Parse comma-separated values and `name: value`s until the closing character
+func (p *prismaParser) parseArgs(closing byte) (prismaArgs, error) {
+	args := prismaArgs{named: map[string]prismaValue{}}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.src) {
+			return args, fmt.Errorf("'%c' is missing in: %s", closing, p.src)
+		}
+		if p.src[p.pos] == closing {
+			p.pos++
+			return args, nil
+		}
+
+		key := ""
+		if m := prismaKeyRegexp.FindStringSubmatch(p.src[p.pos:]); m != nil {
+			key = m[1]
+			p.pos += len(m[0])
+			p.skipSpaces()
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return args, err
+		}
+		if key == "" {
+			args.positional = append(args.positional, value)
+		} else {
+			args.named[key] = value
+		}
+
+		p.skipSpaces()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+		} else if p.pos < len(p.src) && p.src[p.pos] != closing {
+			return args, fmt.Errorf("unexpected '%s' in: %s", p.src[p.pos:], p.src)
+		}
+	}
+}
+
+func (p *prismaParser) parseValue() (prismaValue, error) {
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, `"`):
+		var str strings.Builder
+		for p.pos++; p.pos < len(p.src); p.pos++ {
+			switch c := p.src[p.pos]; {
+			case c == '"':
+				p.pos++
+				return prismaValue{str: str.String(), quoted: true}, nil
+			case c == '\\' && p.pos+1 < len(p.src):
+				p.pos++
+				str.WriteByte(p.src[p.pos])
+			default:
+				str.WriteByte(c)
+			}
+		}
+		return prismaValue{}, fmt.Errorf("unterminated string in: %s", p.src)
+	case strings.HasPrefix(rest, "["):
+		p.pos++
+		args, err := p.parseArgs(']')
+		return prismaValue{isArray: true, array: args.positional}, err
+	case prismaNumberRegexp.MatchString(rest):
+		number := prismaNumberRegexp.FindString(rest)
+		p.pos += len(number)
+		return prismaValue{str: number}, nil
+	case prismaWordRegexp.MatchString(rest):
+		value := prismaValue{str: prismaWordRegexp.FindString(rest)}
+		p.pos += len(value.str)
+		if p.pos < len(p.src) && p.src[p.pos] == '(' {
+			p.pos++
+			args, err := p.parseArgs(')')
+			if err != nil {
+				return value, err
+			}
+			value.args = &args
+		}
+		return value, nil
+	}
+	return prismaValue{}, fmt.Errorf("unsupported value '%s' in: %s", rest, p.src)
+}
+
+// A field of a model, e.g. `email String? @unique @db.VarChar(255)`
+type prismaField struct {
+	name       string
+	typeName   string // a scalar type, an enum, or a model
+	rawType    string // a type given by Unsupported("...")
+	optional   bool
+	list       bool
+	attributes prismaAttributes
+}
+
+func (f *prismaField) column() string {
+	if args, ok := f.attributes.find("@map"); ok {
+		if name, ok := args.value("name", 0); ok {
+			return name.str
+		}
+	}
+	return f.name
+}
+
+type prismaModel struct {
+	name       string
+	fields     []*prismaField
+	attributes prismaAttributes
+}
+
+func (m *prismaModel) table() string {
+	if args, ok := m.attributes.find("@@map"); ok {
+		if name, ok := args.value("name", 0); ok {
+			return name.str
+		}
+	}
+	return m.name
+}
+
+func (m *prismaModel) field(name string) *prismaField {
+	for _, field := range m.fields {
+		if field.name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+type prismaEnum struct {
+	name       string
+	values     []string
+	attributes prismaAttributes
+	valueNames map[string]string // values given by @map
+}
+
+func (e *prismaEnum) typeName() string {
+	if args, ok := e.attributes.find("@@map"); ok {
+		if name, ok := args.value("name", 0); ok {
+			return name.str
+		}
+	}
+	return e.name
+}
+
+// Converter of Prisma's schema.prisma to CREATE statements of the dialect, as `prisma migrate` creates them
+type prismaConverter struct {
+	mode   schema.GeneratorMode
+	models []*prismaModel
+	enums  []*prismaEnum
+}
+
+var (
+	prismaBlockRegexp = regexp.MustCompile(`^(model|enum|view|type|datasource|generator)\s+(\w+)\s*\{$`)
+	prismaFieldRegexp = regexp.MustCompile(`^(\w+)\s+(\w+)(?:\(\s*"([^"]*)"\s*\))?(\[\])?(\?)?(?:\s+(.*))?$`)
+	prismaValueRegexp = regexp.MustCompile(`^(\w+)(?:\s+(.*))?$`)
+)
+
+// Convert Prisma's schema.prisma to the schema SQL of the dialect, warning blocks which can't be converted
+func convertPrismaSchema(generatorMode schema.GeneratorMode, src string) (string, error) {
+	if generatorMode == schema.GeneratorModeCockroach || generatorMode == schema.GeneratorModeRedshift {
+		generatorMode = schema.GeneratorModePostgres
+	}
+	c := &prismaConverter{mode: generatorMode}
+	if err := c.parse(src); err != nil {
+		return "", err
+	}
+
+	ddls := []string{}
+	for _, enum := range c.enums {
+		if c.mode != schema.GeneratorModePostgres {
+			break // inlined to columns, or unsupported
+		}
+		values := []string{}
+		for _, value := range enum.values {
+			values = append(values, "'"+enum.valueNames[value]+"'")
+		}
+		ddls = append(ddls, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", c.quote(enum.typeName()), strings.Join(values, ", ")))
+	}
+	for _, model := range c.models {
+		tableDDLs, err := c.createTable(model)
+		if err != nil {
+			return "", err
+		}
+		ddls = append(ddls, tableDDLs...)
+	}
+	joinDDLs, err := c.createJoinTables()
+	if err != nil {
+		return "", err
+	}
+	ddls = append(ddls, joinDDLs...)
+
+	if len(ddls) == 0 {
+		return "", nil
+	}
+	return strings.Join(ddls, ";\n") + ";\n", nil
+}
+
+// Return a line without a comment, leaving `//` in strings
+func stripPrismaComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && quoted:
+			i++
+		case line[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(line[i:], "//"):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func (c *prismaConverter) parse(src string) error {
+	var model *prismaModel
+	var enum *prismaEnum
+	skipping := false // in a block which is not converted
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(stripPrismaComment(line))
+		if line == "" {
+			continue
+		}
+
+		var err error
+		switch m := prismaBlockRegexp.FindStringSubmatch(line); {
+		case line == "}":
+			model, enum, skipping = nil, nil, false
+		case skipping:
+			continue
+		case m != nil && m[1] == "model":
+			model = &prismaModel{name: m[2]}
+			c.models = append(c.models, model)
+		case m != nil && m[1] == "enum":
+			enum = &prismaEnum{name: m[2], valueNames: map[string]string{}}
+			c.enums = append(c.enums, enum)
+		case m != nil:
+			if m[1] == "view" || m[1] == "type" {
+				warnNotConverted(fmt.Sprintf("%s %s", m[1], m[2]))
+			}
+			skipping = true // datasource and generator are not a part of the schema
+		case strings.HasPrefix(line, "@@") && model != nil:
+			var attributes prismaAttributes
+			attributes, err = parsePrismaAttributes(line)
+			model.attributes = append(model.attributes, attributes...)
+		case strings.HasPrefix(line, "@@") && enum != nil:
+			var attributes prismaAttributes
+			attributes, err = parsePrismaAttributes(line)
+			enum.attributes = append(enum.attributes, attributes...)
+		case model != nil:
+			err = c.addField(model, line)
+		case enum != nil:
+			err = c.addEnumValue(enum, line)
+		default:
+			err = fmt.Errorf("unexpected '%s'", line)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (c *prismaConverter) addField(model *prismaModel, line string) error {
+	m := prismaFieldRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("unsupported field '%s' of model '%s'", line, model.name)
+	}
+	field := &prismaField{name: m[1], typeName: m[2], list: m[4] != "", optional: m[5] != ""}
+	if field.typeName == "Unsupported" {
+		field.rawType = m[3]
+	}
+	attributes, err := parsePrismaAttributes(m[6])
+	if err != nil {
+		return err
+	}
+	field.attributes = attributes
+	model.fields = append(model.fields, field)
+	return nil
+}
+
+func (c *prismaConverter) addEnumValue(enum *prismaEnum, line string) error {
+	m := prismaValueRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("unsupported value '%s' of enum '%s'", line, enum.name)
+	}
+	attributes, err := parsePrismaAttributes(m[2])
+	if err != nil {
+		return err
+	}
+	enum.values = append(enum.values, m[1])
+	enum.valueNames[m[1]] = m[1]
+	if args, ok := attributes.find("@map"); ok {
+		if name, ok := args.value("name", 0); ok {
+			enum.valueNames[m[1]] = name.str
+		}
+	}
+	return nil
+}
+
+func (c *prismaConverter) quote(name string) string {
+	return quoteConvertedName(c.mode, name)
+}
+
+// Return a table name qualified by @@schema if it's given
+func (c *prismaConverter) quoteTable(model *prismaModel) string {
+	if args, ok := model.attributes.find("@@schema"); ok {
+		if name, ok := args.value("name", 0); ok {
+			return c.quote(name.str) + "." + c.quote(model.table())
+		}
+	}
+	return c.quote(model.table())
+}
+
+func (c *prismaConverter) findModel(name string) *prismaModel {
+	for _, model := range c.models {
+		if model.name == name {
+			return model
+		}
+	}
+	return nil
+}
+
+func (c *prismaConverter) findEnum(name string) *prismaEnum {
+	for _, enum := range c.enums {
+		if enum.name == name {
+			return enum
+		}
+	}
+	return nil
+}
+
+// Data types of Prisma's scalar types, which are used unless a native type is given by @db
+var prismaColumnTypes = map[schema.GeneratorMode]map[string]string{
+	schema.GeneratorModePostgres: {
+		"String": "text", "Boolean": "boolean", "Int": "integer", "BigInt": "bigint", "Float": "double precision",
+		"Decimal": "decimal(65, 30)", "DateTime": "timestamp(3)", "Json": "jsonb", "Bytes": "bytea",
+	},
+	schema.GeneratorModeMysql: {
+		"String": "varchar(191)", "Boolean": "tinyint(1)", "Int": "int", "BigInt": "bigint", "Float": "double",
+		"Decimal": "decimal(65, 30)", "DateTime": "datetime(3)", "Json": "json", "Bytes": "longblob",
+	},
+	schema.GeneratorModeSQLite3: {
+		"String": "text", "Boolean": "boolean", "Int": "integer", "BigInt": "bigint", "Float": "real",
+		"Decimal": "decimal", "DateTime": "datetime", "Json": "text", "Bytes": "blob",
+	},
+	schema.GeneratorModeMssql: {
+		"String": "nvarchar(1000)", "Boolean": "bit", "Int": "int", "BigInt": "bigint", "Float": "float",
+		"Decimal": "decimal(32, 16)", "DateTime": "datetime2", "Json": "text", "Bytes": "varbinary(8000)",
+	},
+}
+
+// Native types of @db which are not their lower-cased names, e.g. @db.DoublePrecision
+var prismaNativeTypes = map[string]string{
+	"DoublePrecision": "double precision", "VarBit": "bit varying", "UnsignedInt": "int unsigned",
+	"UnsignedBigInt": "bigint unsigned", "UnsignedSmallInt": "smallint unsigned", "UnsignedMediumInt": "mediumint unsigned",
+	"UnsignedTinyInt": "tinyint unsigned",
+}
+
+func (c *prismaConverter) columnType(model *prismaModel, field *prismaField) (string, error) {
+	var sqlType string
+	if enum := c.findEnum(field.typeName); enum != nil {
+		switch c.mode {
+		case schema.GeneratorModePostgres:
+			sqlType = c.quote(enum.typeName())
+		case schema.GeneratorModeMysql:
+			values := []string{}
+			for _, value := range enum.values {
+				values = append(values, "'"+enum.valueNames[value]+"'")
+			}
+			sqlType = fmt.Sprintf("enum(%s)", strings.Join(values, ", "))
+		default:
+			return "", fmt.Errorf("enum '%s' of '%s.%s' is not supported by the dialect", enum.name, model.name, field.name)
+		}
+	} else if native, ok := c.nativeType(field); ok {
+		sqlType = native
+	} else if scalarType, ok := prismaColumnTypes[c.mode][field.typeName]; ok {
+		sqlType = scalarType
+	} else if field.rawType != "" {
+		sqlType = field.rawType
+	} else {
+		return "", fmt.Errorf("unsupported type '%s' of '%s.%s'", field.typeName, model.name, field.name)
+	}
+
+	if field.list {
+		if c.mode != schema.GeneratorModePostgres {
+			return "", fmt.Errorf("list '%s.%s' is supported only by PostgreSQL", model.name, field.name)
+		}
+		sqlType += "[]"
+	}
+	return sqlType, nil
+}
+
+// Return a type given by @db, e.g. varchar(255) of @db.VarChar(255)
+func (c *prismaConverter) nativeType(field *prismaField) (string, bool) {
+	for _, attribute := range field.attributes {
+		if !strings.HasPrefix(attribute.name, "@db.") {
+			continue
+		}
+		name := strings.TrimPrefix(attribute.name, "@db.")
+		sqlType, ok := prismaNativeTypes[name]
+		if !ok {
+			sqlType = strings.ToLower(name)
+		}
+		if len(attribute.args.positional) > 0 {
+			args := []string{}
+			for _, arg := range attribute.args.positional {
+				args = append(args, strings.ToLower(arg.str))
+			}
+			sqlType = strings.TrimSuffix(sqlType, " unsigned") + "(" + strings.Join(args, ", ") + ")" +
+				map[bool]string{true: " unsigned", false: ""}[strings.HasSuffix(sqlType, " unsigned")]
+		}
+		return sqlType, true
+	}
+	return "", false
+}
+
+// Return the function of @default, e.g. "autoincrement" of @default(autoincrement())
+func prismaDefaultFunction(field *prismaField) string {
+	if args, ok := field.attributes.find("@default"); ok {
+		if value, ok := args.value("value", 0); ok && value.args != nil {
+			return value.str
+		}
+	}
+	return ""
+}
+
+func (c *prismaConverter) createTable(model *prismaModel) ([]string, error) {
+	table := model.table()
+	definitions := []string{}
+	foreignKeys := []string{}
+	indexes := []string{}
+	primaryKey := []string{}
+	primaryKeyName := table + "_pkey"
+	inlinePrimaryKey := false
+
+	for _, field := range model.fields {
+		if related := c.findModel(field.typeName); related != nil {
+			fk, err := c.foreignKey(model, field, related)
+			if err != nil {
+				return nil, err
+			}
+			if fk != "" {
+				foreignKeys = append(foreignKeys, fk)
+			}
+			continue
+		}
+
+		sqlType, err := c.columnType(model, field)
+		if err != nil {
+			return nil, err
+		}
+		definition := c.quote(field.column()) + " "
+		autoIncrement := prismaDefaultFunction(field) == "autoincrement"
+		if autoIncrement && c.mode == schema.GeneratorModePostgres {
+			sqlType = map[string]string{"smallint": "smallserial", "bigint": "bigserial"}[sqlType]
+			if sqlType == "" {
+				sqlType = "serial"
+			}
+		}
+		definition += sqlType
+		if !field.optional && !field.list {
+			definition += " NOT NULL"
+		}
+		_, isID := field.attributes.find("@id")
+		if autoIncrement {
+			switch c.mode {
+			case schema.GeneratorModeMysql:
+				definition += " AUTO_INCREMENT"
+			case schema.GeneratorModeMssql:
+				definition += " IDENTITY(1,1)"
+			case schema.GeneratorModeSQLite3:
+				if isID {
+					definition += " PRIMARY KEY AUTOINCREMENT"
+					inlinePrimaryKey = true
+				}
+			}
+		}
+		if value, ok := c.defaultValue(field); ok {
+			definition += " DEFAULT " + value
+		}
+		definitions = append(definitions, definition)
+
+		if isID {
+			primaryKey = append(primaryKey, c.quote(field.column()))
+			args, _ := field.attributes.find("@id")
+			if name, ok := args.value("map", -1); ok {
+				primaryKeyName = name.str
+			}
+		}
+		if args, ok := field.attributes.find("@unique"); ok {
+			indexes = append(indexes, c.createIndex(model, "UNIQUE ", "key", []prismaValue{{str: field.name, args: &args}}, args))
+		}
+	}
+
+	for _, attribute := range model.attributes {
+		fields, _ := attribute.args.value("fields", 0)
+		switch attribute.name {
+		case "@@id":
+			primaryKey = []string{}
+			for _, field := range fields.array {
+				primaryKey = append(primaryKey, c.quote(c.columnName(model, field.str)))
+			}
+			if name, ok := attribute.args.value("map", -1); ok {
+				primaryKeyName = name.str
+			}
+		case "@@unique":
+			indexes = append(indexes, c.createIndex(model, "UNIQUE ", "key", fields.array, attribute.args))
+		case "@@index":
+			indexes = append(indexes, c.createIndex(model, "", "idx", fields.array, attribute.args))
+		case "@@fulltext":
+			if c.mode != schema.GeneratorModeMysql {
+				warnNotConverted(fmt.Sprintf("@@fulltext of model %s", model.name))
+				continue
+			}
+			indexes = append(indexes, c.createIndex(model, "FULLTEXT ", "idx", fields.array, attribute.args))
+		}
+	}
+
+	if len(primaryKey) > 0 && !inlinePrimaryKey {
+		definition := fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKey, ", "))
+		if c.mode == schema.GeneratorModePostgres || c.mode == schema.GeneratorModeMssql {
+			definition = fmt.Sprintf("CONSTRAINT %s %s", c.quote(primaryKeyName), definition)
+		}
+		definitions = append(definitions, definition)
+	}
+	definitions = append(definitions, foreignKeys...)
+	ddls := []string{fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", c.quoteTable(model), strings.Join(definitions, ",\n  "))}
+	return append(ddls, indexes...), nil
+}
+
+// Return a column name of a field, given by @map
+func (c *prismaConverter) columnName(model *prismaModel, fieldName string) string {
+	if field := model.field(fieldName); field != nil {
+		return field.column()
+	}
+	return fieldName
+}
+
+// Return an index of @unique, @@unique, or @@index, named as `prisma migrate` does unless it's given by map
+func (c *prismaConverter) createIndex(model *prismaModel, kind string, suffix string, fields []prismaValue, args prismaArgs) string {
+	columnNames, columns := []string{}, []string{}
+	for _, field := range fields {
+		columnName := c.columnName(model, field.str)
+		column := c.quote(columnName)
+		if field.args != nil {
+			if length, ok := field.args.value("length", -1); ok && c.mode == schema.GeneratorModeMysql {
+				column += "(" + length.str + ")"
+			}
+			if sort, ok := field.args.value("sort", -1); ok && sort.str == "Desc" {
+				column += " DESC"
+			}
+		}
+		columnNames = append(columnNames, columnName)
+		columns = append(columns, column)
+	}
+
+	name := fmt.Sprintf("%s_%s_%s", model.table(), strings.Join(columnNames, "_"), suffix)
+	if value, ok := args.value("map", -1); ok {
+		name = value.str
+	}
+	ddl := fmt.Sprintf("CREATE %sINDEX %s ON %s", kind, c.quote(name), c.quoteTable(model))
+	if indexType, ok := args.value("type", -1); ok && c.mode == schema.GeneratorModePostgres && indexType.str != "BTree" {
+		ddl += " USING " + strings.ToLower(indexType.str)
+	}
+	return ddl + fmt.Sprintf(" (%s)", strings.Join(columns, ", "))
+}
+
+var prismaReferentialActions = map[string]string{
+	"Cascade": "CASCADE", "Restrict": "RESTRICT", "NoAction": "NO ACTION", "SetNull": "SET NULL", "SetDefault": "SET DEFAULT",
+}
+
+// Return a foreign key of a relation field having `fields`, or "" for the other side of the relation
+func (c *prismaConverter) foreignKey(model *prismaModel, field *prismaField, related *prismaModel) (string, error) {
+	args, _ := field.attributes.find("@relation")
+	fields, ok := args.value("fields", -1)
+	if !ok {
+		return "", nil
+	}
+	references, ok := args.value("references", -1)
+	if !ok || len(references.array) != len(fields.array) {
+		return "", fmt.Errorf("references of relation '%s.%s' don't match its fields", model.name, field.name)
+	}
+
+	columnNames, columns, referencedColumns := []string{}, []string{}, []string{}
+	optional := false
+	for i, value := range fields.array {
+		columnNames = append(columnNames, c.columnName(model, value.str))
+		columns = append(columns, c.quote(c.columnName(model, value.str)))
+		referencedColumns = append(referencedColumns, c.quote(c.columnName(related, references.array[i].str)))
+		if scalar := model.field(value.str); scalar != nil && scalar.optional {
+			optional = true
+		}
+	}
+	name := fmt.Sprintf("%s_%s_fkey", model.table(), strings.Join(columnNames, "_"))
+	if value, ok := args.value("map", -1); ok {
+		name = value.str
+	}
+
+	// Default referential actions of Prisma
+	onDelete, onUpdate := "Restrict", "Cascade"
+	if optional {
+		onDelete = "SetNull"
+	}
+	if value, ok := args.value("onDelete", -1); ok {
+		onDelete = value.str
+	}
+	if value, ok := args.value("onUpdate", -1); ok {
+		onUpdate = value.str
+	}
+	actions := []string{}
+	for _, action := range []string{onDelete, onUpdate} {
+		if action == "Restrict" && c.mode == schema.GeneratorModeMssql {
+			action = "NoAction"
+		}
+		actions = append(actions, prismaReferentialActions[action])
+	}
+
+	return fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s) ON DELETE %s ON UPDATE %s", c.quote(name),
+		strings.Join(columns, ", "), c.quoteTable(related), strings.Join(referencedColumns, ", "), actions[0], actions[1]), nil
+}
+
+// Create join tables of implicit many-to-many relations, e.g. _CategoryToPost of `posts Post[]` and `categories Category[]`
+func (c *prismaConverter) createJoinTables() ([]string, error) {
+	ddls := []string{}
+	created := map[string]bool{}
+	for _, model := range c.models {
+		for _, field := range model.fields {
+			related := c.findModel(field.typeName)
+			if related == nil || !field.list {
+				continue
+			}
+			args, _ := field.attributes.find("@relation")
+			relationName, _ := args.value("name", 0)
+			opposite := c.oppositeField(model, field, related, relationName.str)
+			if opposite == nil || !opposite.list {
+				continue // a one-to-many relation
+			}
+
+			models := []*prismaModel{model, related}
+			sort.SliceStable(models, func(i, j int) bool { return models[i].name < models[j].name })
+			table := relationName.str
+			if table == "" {
+				table = fmt.Sprintf("%sTo%s", models[0].name, models[1].name)
+			}
+			table = "_" + table
+			if created[table] {
+				continue
+			}
+			created[table] = true
+
+			definitions := []string{}
+			foreignKeys := []string{}
+			for i, column := range []string{"A", "B"} {
+				id, err := c.idField(models[i])
+				if err != nil {
+					return nil, err
+				}
+				sqlType, err := c.columnType(models[i], id)
+				if err != nil {
+					return nil, err
+				}
+				definitions = append(definitions, fmt.Sprintf("%s %s NOT NULL", c.quote(column), sqlType))
+				foreignKeys = append(foreignKeys, fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s) ON DELETE CASCADE ON UPDATE CASCADE",
+					c.quote(table+"_"+column+"_fkey"), c.quote(column), c.quoteTable(models[i]), c.quote(id.column())))
+			}
+			ddls = append(ddls,
+				fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", c.quote(table), strings.Join(append(definitions, foreignKeys...), ",\n  ")),
+				fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s, %s)", c.quote(table+"_AB_unique"), c.quote(table), c.quote("A"), c.quote("B")),
+				fmt.Sprintf("CREATE INDEX %s ON %s (%s)", c.quote(table+"_B_index"), c.quote(table), c.quote("B")),
+			)
+		}
+	}
+	return ddls, nil
+}
+
+// Return a relation field of the related model pointing back to the model
+func (c *prismaConverter) oppositeField(model *prismaModel, field *prismaField, related *prismaModel, relationName string) *prismaField {
+	for _, candidate := range related.fields {
+		if candidate == field || candidate.typeName != model.name {
+			continue
+		}
+		args, _ := candidate.attributes.find("@relation")
+		if name, _ := args.value("name", 0); name.str == relationName {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// Return the single @id field of a model referenced by a join table
+func (c *prismaConverter) idField(model *prismaModel) (*prismaField, error) {
+	for _, field := range model.fields {
+		if _, ok := field.attributes.find("@id"); ok {
+			return field, nil
+		}
+	}
+	return nil, fmt.Errorf("model '%s' of an implicit many-to-many relation needs a single @id field", model.name)
+}
+
+// Return a DEFAULT value of @default, which is omitted for values generated by Prisma Client like uuid() and cuid()
+func (c *prismaConverter) defaultValue(field *prismaField) (string, bool) {
+	args, ok := field.attributes.find("@default")
+	if !ok {
+		return "", false
+	}
+	value, ok := args.value("value", 0)
+	if !ok {
+		return "", false
+	}
+
+	switch {
+	case value.args != nil:
+		switch value.str {
+		case "now":
+			if c.mode == schema.GeneratorModeMysql {
+				if sqlType, ok := c.nativeType(field); !ok || strings.Contains(sqlType, "(3)") {
+					return "CURRENT_TIMESTAMP(3)", true
+				}
+			}
+			return "CURRENT_TIMESTAMP", true
+		case "dbgenerated":
+			if expression, ok := value.args.value("expression", 0); ok {
+				return expression.str, true
+			}
+		}
+		return "", false
+	case value.isArray: // PostgreSQL arrays
+		elements := []string{}
+		for _, element := range value.array {
+			elements = append(elements, strings.ReplaceAll(element.str, `"`, `\"`))
+		}
+		return "'{" + strings.Join(elements, ",") + "}'", true
+	case value.quoted:
+		return "'" + strings.ReplaceAll(value.str, "'", "''") + "'", true
+	case value.str == "true" || value.str == "false":
+		if c.mode == schema.GeneratorModePostgres {
+			return value.str, true
+		}
+		return map[bool]string{true: "1", false: "0"}[value.str == "true"], true
+	case prismaNumberRegexp.FindString(value.str) == value.str:
+		return value.str, true
+	default: // an enum value
+		if enum := c.findEnum(field.typeName); enum != nil {
+			if name, ok := enum.valueNames[value.str]; ok {
+				return "'" + name + "'", true
+			}
+		}
+		return "'" + value.str + "'", true
+	}
+}
@@ -0,0 +1,765 @@
+package sqldef
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// A literal in arguments of schema.rb, e.g. "users", :cascade, 8, true, nil, ["id"], { name: :desc }, or -> { "now()" }
+type rubyValue struct {
+	kind  rubyKind
+	str   string               // a string, a symbol, a number, true, false, or a string in a lambda
+	array []rubyValue          // rubyArray
+	hash  map[string]rubyValue // rubyHash
+}
+
+type rubyKind int
+
+const (
+	rubyString = rubyKind(iota)
+	rubySymbol
+	rubyNumber
+	rubyBool
+	rubyNil
+	rubyArray
+	rubyHash
+	rubyLambda
+)
+
+// Arguments of a method call in schema.rb, e.g. `"users", column: "author_id"`
+type rubyArgs struct {
+	positional []rubyValue
+	keywords   map[string]rubyValue
+}
+
+func (a rubyArgs) keyword(name string) (rubyValue, bool) {
+	value, ok := a.keywords[name]
+	return value, ok && value.kind != rubyNil
+}
+
+func (a rubyArgs) stringArg(i int) (string, bool) {
+	if i >= len(a.positional) || (a.positional[i].kind != rubyString && a.positional[i].kind != rubySymbol) {
+		return "", false
+	}
+	return a.positional[i].str, true
+}
+
+type rubyParser struct {
+	src string
+	pos int
+}
+
+func parseRubyArgs(src string) (rubyArgs, error) {
+	p := &rubyParser{src: src}
+	args := rubyArgs{keywords: map[string]rubyValue{}}
+	err := p.parseElements(0, func(key string, value rubyValue) {
+		if key == "" {
+			args.positional = append(args.positional, value)
+		} else {
+			args.keywords[key] = value
+		}
+	})
+	return args, err
+}
+
+func (p *rubyParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+var rubyKeyRegexp = regexp.MustCompile(`^(?:([A-Za-z_]\w*[?!]?)|"([^"]*)"):(?:\s|$)`)
+
+// Parse comma-separated values and `key: value`s until the closing character, or the end if it's 0
+func (p *rubyParser) parseElements(closing byte, yield func(key string, value rubyValue)) error {
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.src) {
+			if closing != 0 {
+				return fmt.Errorf("'%c' is missing in: %s", closing, p.src)
+			}
+			return nil
+		}
+		if p.src[p.pos] == closing {
+			p.pos++
+			return nil
+		}
+
+		key := ""
+		if m := rubyKeyRegexp.FindStringSubmatch(p.src[p.pos:]); m != nil {
+			key = m[1] + m[2]
+			p.pos += len(m[0])
+			p.skipSpaces()
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		yield(key, value)
+
+		p.skipSpaces()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+		} else if p.pos < len(p.src) && p.src[p.pos] != closing {
+			return fmt.Errorf("unexpected '%s' in: %s", p.src[p.pos:], p.src)
+		}
+	}
+}
+
+var (
+	rubyNumberRegexp = regexp.MustCompile(`^-?[\d_]+(?:\.\d+)?(?:e-?\d+)?`)
+	rubySymbolRegexp = regexp.MustCompile(`^:([A-Za-z_]\w*[?!]?)`)
+	rubyWordRegexp   = regexp.MustCompile(`^[A-Za-z_]\w*`)
+)
+
+func (p *rubyParser) parseValue() (rubyValue, error) {
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, `"`) || strings.HasPrefix(rest, "'"):
+		str, err := p.parseString()
+		return rubyValue{kind: rubyString, str: str}, err
+	case strings.HasPrefix(rest, `:"`):
+		p.pos++
+		str, err := p.parseString()
+		return rubyValue{kind: rubySymbol, str: str}, err
+	case rubySymbolRegexp.MatchString(rest):
+		m := rubySymbolRegexp.FindStringSubmatch(rest)
+		p.pos += len(m[0])
+		return rubyValue{kind: rubySymbol, str: m[1]}, nil
+	case rubyNumberRegexp.MatchString(rest):
+		m := rubyNumberRegexp.FindString(rest)
+		p.pos += len(m)
+		return rubyValue{kind: rubyNumber, str: strings.ReplaceAll(m, "_", "")}, nil
+	case strings.HasPrefix(rest, "["):
+		p.pos++
+		value := rubyValue{kind: rubyArray}
+		err := p.parseElements(']', func(_ string, element rubyValue) { value.array = append(value.array, element) })
+		return value, err
+	case strings.HasPrefix(rest, "{"):
+		p.pos++
+		value := rubyValue{kind: rubyHash, hash: map[string]rubyValue{}}
+		err := p.parseElements('}', func(key string, element rubyValue) { value.hash[key] = element })
+		return value, err
+	case strings.HasPrefix(rest, "->"):
+		// e.g. -> { "CURRENT_TIMESTAMP" }
+		p.pos += 2
+		p.skipSpaces()
+		if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+			return rubyValue{}, fmt.Errorf("unsupported lambda in: %s", p.src)
+		}
+		p.pos++
+		p.skipSpaces()
+		str, err := p.parseString()
+		if err != nil {
+			return rubyValue{}, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.src) || p.src[p.pos] != '}' {
+			return rubyValue{}, fmt.Errorf("unsupported lambda in: %s", p.src)
+		}
+		p.pos++
+		return rubyValue{kind: rubyLambda, str: str}, nil
+	case rubyWordRegexp.MatchString(rest):
+		word := rubyWordRegexp.FindString(rest)
+		p.pos += len(word)
+		switch word {
+		case "true", "false":
+			return rubyValue{kind: rubyBool, str: word}, nil
+		case "nil":
+			return rubyValue{kind: rubyNil}, nil
+		}
+	}
+	return rubyValue{}, fmt.Errorf("unsupported value '%s' in: %s", rest, p.src)
+}
+
+func (p *rubyParser) parseString() (string, error) {
+	if p.pos >= len(p.src) || (p.src[p.pos] != '"' && p.src[p.pos] != '\'') {
+		return "", fmt.Errorf("a string is expected in: %s", p.src)
+	}
+	quote := p.src[p.pos]
+	var str strings.Builder
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return str.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			switch c = p.src[p.pos]; {
+			case c == 'n' && quote == '"':
+				str.WriteByte('\n')
+			case c == 't' && quote == '"':
+				str.WriteByte('\t')
+			case c == quote || c == '\\':
+				str.WriteByte(c)
+			default:
+				str.WriteByte('\\')
+				str.WriteByte(c)
+			}
+		default:
+			str.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string in: %s", p.src)
+}
+
+// A table defined by create_table of schema.rb
+type railsTable struct {
+	name        string
+	definitions []string // columns and constraints
+	indexes     []string
+}
+
+// Converter of Rails' db/schema.rb to CREATE statements of the dialect
+type railsConverter struct {
+	mode   schema.GeneratorMode
+	types  []string
+	tables []*railsTable
+}
+
+var (
+	railsCreateTableRegexp = regexp.MustCompile(`^create_table\s*\(?(.*?)\)?\s+do\s*\|(\w+)\|$`)
+	railsMethodRegexp      = regexp.MustCompile(`^(?:(\w+)\.)?(\w+)(?:\s+(.*?)|\((.*)\))?$`)
+)
+
+// Convert Rails' db/schema.rb to the schema SQL of the dialect, warning statements which can't be converted
+func convertRailsSchema(generatorMode schema.GeneratorMode, src string) (string, error) {
+	c := &railsConverter{mode: generatorMode}
+	var table *railsTable
+	var tableVar string
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "ActiveRecord::Schema") {
+			continue
+		}
+
+		var err error
+		if m := railsCreateTableRegexp.FindStringSubmatch(line); m != nil {
+			table, err = c.createTable(m[1])
+			tableVar = m[2]
+		} else if line == "end" {
+			table = nil
+		} else if m := railsMethodRegexp.FindStringSubmatch(line); m != nil && table != nil && m[1] == tableVar {
+			err = c.tableMethod(table, m[2], m[3]+m[4])
+		} else if m != nil && m[1] == "" {
+			err = c.schemaMethod(m[2], m[3]+m[4], line)
+		} else {
+			warnNotConverted(line)
+		}
+		if err != nil {
+			return "", fmt.Errorf("line %d: %s", i+1, err)
+		}
+	}
+
+	ddls := append([]string{}, c.types...)
+	for _, table := range c.tables {
+		ddls = append(ddls, fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", c.quote(table.name), strings.Join(table.definitions, ",\n  ")))
+		ddls = append(ddls, table.indexes...)
+	}
+	if len(ddls) == 0 {
+		return "", nil
+	}
+	return strings.Join(ddls, ";\n") + ";\n", nil
+}
+
+func (c *railsConverter) quote(name string) string {
+	return quoteConvertedName(c.mode, name)
+}
+
+func (c *railsConverter) findTable(name string) *railsTable {
+	for _, table := range c.tables {
+		if table.name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+// create_table "users", id: :uuid, force: :cascade do |t|
+func (c *railsConverter) createTable(src string) (*railsTable, error) {
+	args, err := parseRubyArgs(src)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := args.stringArg(0)
+	if !ok {
+		return nil, fmt.Errorf("no table name is given to create_table")
+	}
+	table := &railsTable{name: name}
+	c.tables = append(c.tables, table)
+
+	primaryKey, hasPrimaryKey := args.keyword("primary_key")
+	if hasPrimaryKey && primaryKey.kind == rubyArray { // composite primary keys are defined by t.xxx
+		columns := []string{}
+		for _, column := range primaryKey.array {
+			columns = append(columns, c.quote(column.str))
+		}
+		table.definitions = append(table.definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(columns, ", ")))
+		return table, nil
+	}
+	id, hasID := args.keyword("id")
+	if hasID && id.kind == rubyBool && id.str == "false" {
+		return table, nil
+	}
+
+	idName := "id"
+	if hasPrimaryKey {
+		idName = primaryKey.str
+	}
+	idType := "bigint"
+	if hasID && id.kind != rubyBool {
+		idType = id.str
+	}
+	var definition string
+	switch {
+	case idType == "uuid" && c.mode == schema.GeneratorModePostgres:
+		definition = "uuid DEFAULT gen_random_uuid() NOT NULL"
+	case idType == "bigint" || idType == "integer" || idType == "primary_key" || strings.HasSuffix(idType, "serial"):
+		switch c.mode {
+		case schema.GeneratorModeMysql:
+			definition = map[bool]string{true: "int", false: "bigint"}[idType == "integer" || idType == "serial"] + " NOT NULL AUTO_INCREMENT"
+		case schema.GeneratorModeSQLite3:
+			definition = "integer NOT NULL"
+		case schema.GeneratorModeMssql:
+			definition = map[bool]string{true: "int", false: "bigint"}[idType == "integer" || idType == "serial"] + " IDENTITY(1,1) NOT NULL"
+		default:
+			definition = map[bool]string{true: "serial", false: "bigserial"}[idType == "integer" || idType == "serial"] + " NOT NULL"
+		}
+	default:
+		sqlType, err := c.columnType(idType, rubyArgs{keywords: args.keywords})
+		if err != nil {
+			return nil, err
+		}
+		definition = sqlType + " NOT NULL"
+	}
+	table.definitions = append(table.definitions, fmt.Sprintf("%s %s PRIMARY KEY", c.quote(idName), definition))
+	return table, nil
+}
+
+// t.string "name", null: false, t.index ["name"], unique: true, and so on
+func (c *railsConverter) tableMethod(table *railsTable, method string, src string) error {
+	args, err := parseRubyArgs(src)
+	if err != nil {
+		return err
+	}
+	switch method {
+	case "index":
+		return c.addIndex(table, args.positional, args)
+	case "check_constraint":
+		expression, _ := args.stringArg(0)
+		return c.addCheckConstraint(table, expression, args)
+	case "timestamps":
+		for _, name := range []string{"created_at", "updated_at"} {
+			if err := c.addColumn(table, "datetime", name, rubyArgs{keywords: map[string]rubyValue{"null": {kind: rubyBool, str: "false"}}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "virtual": // generated columns
+		warnNotConverted(fmt.Sprintf("t.virtual %s", src))
+		return nil
+	case "column": // t.column "name", "sql_type"
+		name, ok := args.stringArg(0)
+		sqlType, ok2 := args.stringArg(1)
+		if !ok || !ok2 {
+			return fmt.Errorf("t.column needs a name and a type")
+		}
+		return c.addColumn(table, sqlType, name, args)
+	default:
+		for _, value := range args.positional {
+			if value.kind != rubyString && value.kind != rubySymbol {
+				return fmt.Errorf("unsupported column name of t.%s", method)
+			}
+			if err := c.addColumn(table, method, value.str, args); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// add_index, add_foreign_key, create_enum, and so on outside create_table
+func (c *railsConverter) schemaMethod(method string, src string, line string) error {
+	args, err := parseRubyArgs(src)
+	if err != nil {
+		return err
+	}
+	tableName, _ := args.stringArg(0)
+	switch method {
+	case "add_index":
+		table := c.findTable(tableName)
+		if table == nil {
+			return fmt.Errorf("table '%s' of add_index is not defined", tableName)
+		}
+		return c.addIndex(table, args.positional[1:], args)
+	case "add_foreign_key":
+		table := c.findTable(tableName)
+		if table == nil {
+			return fmt.Errorf("table '%s' of add_foreign_key is not defined", tableName)
+		}
+		toTable, _ := args.stringArg(1)
+		return c.addForeignKey(table, toTable, args)
+	case "add_check_constraint":
+		table := c.findTable(tableName)
+		if table == nil {
+			return fmt.Errorf("table '%s' of add_check_constraint is not defined", tableName)
+		}
+		expression, _ := args.stringArg(1)
+		return c.addCheckConstraint(table, expression, args)
+	case "create_enum": // create_enum "mood", ["happy", "sad"]
+		if len(args.positional) != 2 || args.positional[1].kind != rubyArray {
+			return fmt.Errorf("create_enum needs a name and values")
+		}
+		values := []string{}
+		for _, value := range args.positional[1].array {
+			values = append(values, "'"+strings.ReplaceAll(value.str, "'", "''")+"'")
+		}
+		c.types = append(c.types, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", tableName, strings.Join(values, ", ")))
+		return nil
+	case "enable_extension":
+		if tableName != "plpgsql" { // always enabled
+			warnNotConverted(line)
+		}
+		return nil
+	default:
+		warnNotConverted(line)
+		return nil
+	}
+}
+
+// Data types of Rails' abstract types, where lengths and so on are added by options
+var railsColumnTypes = map[schema.GeneratorMode]map[string]string{
+	schema.GeneratorModePostgres: {
+		"string": "character varying", "text": "text", "integer": "integer", "bigint": "bigint", "float": "double precision",
+		"decimal": "numeric", "datetime": "timestamp", "timestamp": "timestamp", "timestamptz": "timestamp with time zone",
+		"time": "time", "date": "date", "boolean": "boolean", "binary": "bytea", "json": "json", "jsonb": "jsonb", "uuid": "uuid",
+		"inet": "inet", "cidr": "cidr", "macaddr": "macaddr", "hstore": "hstore", "citext": "citext", "tsvector": "tsvector",
+		"interval": "interval", "money": "money", "xml": "xml", "point": "point", "bit": "bit", "bit_varying": "bit varying",
+		"int4range": "int4range", "int8range": "int8range", "numrange": "numrange", "tsrange": "tsrange", "tstzrange": "tstzrange",
+		"daterange": "daterange", "oid": "oid", "ltree": "ltree",
+	},
+	schema.GeneratorModeMysql: {
+		"string": "varchar", "text": "text", "integer": "int", "bigint": "bigint", "float": "float", "decimal": "decimal",
+		"datetime": "datetime", "timestamp": "timestamp", "time": "time", "date": "date", "boolean": "tinyint(1)",
+		"binary": "blob", "blob": "blob", "json": "json",
+	},
+	schema.GeneratorModeSQLite3: {
+		"string": "varchar", "text": "text", "integer": "integer", "bigint": "bigint", "float": "float", "decimal": "decimal",
+		"datetime": "datetime", "timestamp": "datetime", "time": "time", "date": "date", "boolean": "boolean",
+		"binary": "blob", "json": "json",
+	},
+	schema.GeneratorModeMssql: {
+		"string": "nvarchar", "text": "text", "integer": "int", "bigint": "bigint", "float": "float", "decimal": "decimal",
+		"datetime": "datetime2", "timestamp": "datetime2", "time": "time", "date": "date", "boolean": "bit",
+		"binary": "varbinary(8000)", "json": "text", "uuid": "uniqueidentifier",
+	},
+}
+
+func (c *railsConverter) columnType(railsType string, args rubyArgs) (string, error) {
+	mode := c.mode
+	if mode == schema.GeneratorModeCockroach || mode == schema.GeneratorModeRedshift {
+		mode = schema.GeneratorModePostgres
+	}
+	if railsType == "enum" { // t.enum "mood", enum_type: "mood"
+		if enumType, ok := args.keyword("enum_type"); ok {
+			return enumType.str, nil
+		}
+		return "", fmt.Errorf("t.enum needs enum_type")
+	}
+	sqlType, ok := railsColumnTypes[mode][railsType]
+	if !ok {
+		if strings.ContainsAny(railsType, " (") { // a type of t.column
+			return railsType, nil
+		}
+		return "", fmt.Errorf("unsupported column type '%s'", railsType)
+	}
+
+	limit, hasLimit := args.keyword("limit")
+	switch {
+	case railsType == "integer" && hasLimit:
+		switch limit.str {
+		case "1":
+			sqlType = map[bool]string{true: "tinyint", false: "smallint"}[mode == schema.GeneratorModeMysql || mode == schema.GeneratorModeMssql]
+		case "2":
+			sqlType = "smallint"
+		case "3":
+			sqlType = map[bool]string{true: "mediumint", false: "integer"}[mode == schema.GeneratorModeMysql]
+		case "5", "6", "7", "8":
+			sqlType = "bigint"
+		}
+	case railsType == "string" && hasLimit:
+		sqlType += "(" + limit.str + ")"
+	case railsType == "string" && mode == schema.GeneratorModeMysql:
+		sqlType += "(255)"
+	case railsType == "text" && hasLimit && mode == schema.GeneratorModeMysql:
+		if n, err := strconv.Atoi(limit.str); err == nil {
+			switch {
+			case n <= 255:
+				sqlType = "tinytext"
+			case n <= 65535:
+				sqlType = "text"
+			case n <= 16777215:
+				sqlType = "mediumtext"
+			default:
+				sqlType = "longtext"
+			}
+		}
+	case railsType == "binary" && hasLimit && mode == schema.GeneratorModeMysql:
+		if n, err := strconv.Atoi(limit.str); err == nil && n > 65535 {
+			sqlType = map[bool]string{true: "mediumblob", false: "longblob"}[n <= 16777215]
+		}
+	}
+	if railsType == "string" && mode == schema.GeneratorModeMssql && !hasLimit {
+		sqlType += "(4000)"
+	}
+
+	precision, hasPrecision := args.keyword("precision")
+	scale, hasScale := args.keyword("scale")
+	switch {
+	case railsType == "decimal" && hasPrecision && hasScale:
+		sqlType += fmt.Sprintf("(%s, %s)", precision.str, scale.str)
+	case railsType == "decimal" && hasPrecision:
+		sqlType += fmt.Sprintf("(%s)", precision.str)
+	case (railsType == "datetime" || railsType == "time") && hasPrecision && mode != schema.GeneratorModeSQLite3 && mode != schema.GeneratorModeMssql:
+		// Rails 7 dumps precision: nil for datetime without precision, since 6 is the default
+		if mode == schema.GeneratorModePostgres && strings.HasPrefix(sqlType, "timestamp") {
+			sqlType = strings.Replace(sqlType, "timestamp", fmt.Sprintf("timestamp(%s)", precision.str), 1)
+		} else {
+			sqlType += fmt.Sprintf("(%s)", precision.str)
+		}
+	}
+
+	if array, ok := args.keyword("array"); ok && array.str == "true" {
+		sqlType += "[]"
+	}
+	return sqlType, nil
+}
+
+func (c *railsConverter) addColumn(table *railsTable, railsType string, name string, args rubyArgs) error {
+	sqlType, err := c.columnType(railsType, args)
+	if err != nil {
+		return fmt.Errorf("%s of column '%s.%s'", err, table.name, name)
+	}
+	definition := fmt.Sprintf("%s %s", c.quote(name), sqlType)
+	if null, ok := args.keyword("null"); ok && null.str == "false" {
+		definition += " NOT NULL"
+	}
+	if value, ok := args.keyword("default"); ok {
+		definition += " DEFAULT " + c.defaultValue(value)
+	}
+	if comment, ok := args.keyword("comment"); ok && c.mode == schema.GeneratorModeMysql {
+		definition += " COMMENT '" + strings.ReplaceAll(comment.str, "'", "''") + "'"
+	}
+	table.definitions = append(table.definitions, definition)
+	return nil
+}
+
+func (c *railsConverter) defaultValue(value rubyValue) string {
+	switch value.kind {
+	case rubyLambda, rubyNumber:
+		return value.str
+	case rubyBool:
+		if c.mode == schema.GeneratorModePostgres || c.mode == schema.GeneratorModeCockroach || c.mode == schema.GeneratorModeRedshift {
+			return value.str
+		}
+		return map[bool]string{true: "1", false: "0"}[value.str == "true"]
+	case rubyHash: // JSON
+		return "'" + strings.ReplaceAll(rubyJSON(value), "'", "''") + "'"
+	case rubyArray: // PostgreSQL arrays
+		elements := []string{}
+		for _, element := range value.array {
+			elements = append(elements, strings.ReplaceAll(element.str, `"`, `\"`))
+		}
+		return "'{" + strings.Join(elements, ",") + "}'"
+	default:
+		return "'" + strings.ReplaceAll(value.str, "'", "''") + "'"
+	}
+}
+
+// Return a hash of a default value of json columns as JSON
+func rubyJSON(value rubyValue) string {
+	switch value.kind {
+	case rubyHash:
+		keys := []string{}
+		for key := range value.hash {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		members := []string{}
+		for _, key := range keys {
+			members = append(members, strconv.Quote(key)+": "+rubyJSON(value.hash[key]))
+		}
+		return "{" + strings.Join(members, ", ") + "}"
+	case rubyArray:
+		elements := []string{}
+		for _, element := range value.array {
+			elements = append(elements, rubyJSON(element))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case rubyNumber, rubyBool:
+		return value.str
+	case rubyNil:
+		return "null"
+	default:
+		return strconv.Quote(value.str)
+	}
+}
+
+// t.index ["user_id", "created_at"], name: "index_posts_on_user_id_and_created_at", unique: true
+func (c *railsConverter) addIndex(table *railsTable, positional []rubyValue, args rubyArgs) error {
+	if len(positional) == 0 {
+		return fmt.Errorf("no column is given to the index of '%s'", table.name)
+	}
+	order, _ := args.keyword("order")
+	length, _ := args.keyword("length")
+
+	var columnNames, columns []string
+	if positional[0].kind == rubyString && len(positional) == 1 {
+		// An expression index like t.index "lower((email)::text)", which is a single column in old Rails versions
+		if rubyWordRegexp.FindString(positional[0].str) == positional[0].str {
+			columnNames, columns = []string{positional[0].str}, []string{c.quote(positional[0].str)}
+		} else {
+			columnNames, columns = []string{positional[0].str}, []string{positional[0].str}
+		}
+	} else {
+		values := positional
+		if positional[0].kind == rubyArray {
+			values = positional[0].array
+		}
+		for _, value := range values {
+			column := c.quote(value.str)
+			if n, ok := length.hash[value.str]; ok && c.mode == schema.GeneratorModeMysql {
+				column += "(" + n.str + ")"
+			} else if length.kind == rubyNumber && c.mode == schema.GeneratorModeMysql {
+				column += "(" + length.str + ")"
+			}
+			if direction, ok := order.hash[value.str]; ok && strings.EqualFold(direction.str, "desc") {
+				column += " DESC"
+			}
+			columnNames = append(columnNames, value.str)
+			columns = append(columns, column)
+		}
+	}
+
+	name := fmt.Sprintf("index_%s_on_%s", table.name, strings.Join(columnNames, "_and_"))
+	if value, ok := args.keyword("name"); ok {
+		name = value.str
+	}
+	ddl := "CREATE"
+	if unique, ok := args.keyword("unique"); ok && unique.str == "true" {
+		ddl += " UNIQUE"
+	}
+	ddl += fmt.Sprintf(" INDEX %s ON %s", c.quote(name), c.quote(table.name))
+	if using, ok := args.keyword("using"); ok && c.mode != schema.GeneratorModeMysql && using.str != "btree" {
+		ddl += " USING " + using.str
+	}
+	ddl += fmt.Sprintf(" (%s)", strings.Join(columns, ", "))
+	if where, ok := args.keyword("where"); ok {
+		ddl += " WHERE " + where.str
+	}
+	table.indexes = append(table.indexes, ddl)
+	return nil
+}
+
+// Return a name of a constraint generated by Rails, e.g. fk_rails_0123456789
+func railsConstraintName(prefix string, identifier string) string {
+	return fmt.Sprintf("%s_rails_%x", prefix, sha256.Sum256([]byte(identifier)))[:len(prefix)+17]
+}
+
+// Return the singular form of a table name for a foreign key column, as ActiveSupport does for most of table names
+func singularize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"), strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "ss"):
+		return name
+	default:
+		return strings.TrimSuffix(name, "s")
+	}
+}
+
+// add_foreign_key "posts", "users", column: "author_id", on_delete: :cascade
+func (c *railsConverter) addForeignKey(table *railsTable, toTable string, args rubyArgs) error {
+	if toTable == "" {
+		return fmt.Errorf("no referenced table is given to add_foreign_key of '%s'", table.name)
+	}
+	column := singularize(toTable[strings.LastIndex(toTable, ".")+1:]) + "_id"
+	if value, ok := args.keyword("column"); ok {
+		column = value.str
+	}
+	primaryKey := "id"
+	if value, ok := args.keyword("primary_key"); ok {
+		primaryKey = value.str
+	}
+	name := railsConstraintName("fk", fmt.Sprintf("%s_%s_fk", table.name, column))
+	if value, ok := args.keyword("name"); ok {
+		name = value.str
+	}
+
+	definition := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)", c.quote(name), c.quote(column), c.quote(toTable), c.quote(primaryKey))
+	actions := map[string]string{"cascade": "CASCADE", "nullify": "SET NULL", "restrict": "RESTRICT"}
+	if value, ok := args.keyword("on_delete"); ok {
+		definition += " ON DELETE " + actions[value.str]
+	}
+	if value, ok := args.keyword("on_update"); ok {
+		definition += " ON UPDATE " + actions[value.str]
+	}
+	if value, ok := args.keyword("deferrable"); ok && value.kind == rubySymbol {
+		definition += " DEFERRABLE INITIALLY " + strings.ToUpper(value.str)
+	}
+	table.definitions = append(table.definitions, definition)
+	return nil
+}
+
+// t.check_constraint "price > 0", name: "price_check"
+func (c *railsConverter) addCheckConstraint(table *railsTable, expression string, args rubyArgs) error {
+	if expression == "" {
+		return fmt.Errorf("no expression is given to the check constraint of '%s'", table.name)
+	}
+	name := railsConstraintName("chk", fmt.Sprintf("%s_%s_chk", table.name, expression))
+	if value, ok := args.keyword("name"); ok {
+		name = value.str
+	}
+	table.definitions = append(table.definitions, fmt.Sprintf("CONSTRAINT %s CHECK (%s)", c.quote(name), expression))
+	return nil
+}
+
+// Statements of pg_dump in db/structure.sql which are not a part of the schema, e.g. SET and Rails' own tables
+var railsStructureNoiseRegexp = regexp.MustCompile(`(?is)^(?:SET\s|SELECT\s+pg_catalog\.set_config|COMMENT\s+ON\s+EXTENSION\s|` +
+	`/\*!|DROP\s+TABLE\s+IF\s+EXISTS\s|(?:UN)?LOCK\s+TABLES|` +
+	`INSERT\s+INTO\s+"?schema_migrations"?|` +
+	`(?:CREATE\s+TABLE\s+|ALTER\s+TABLE\s+(?:ONLY\s+)?)(?:"?public"?\.)?"?(?:schema_migrations|ar_internal_metadata)"?\s)`)
+
+// Convert Rails' db/structure.sql, which is given by pg_dump or mysqldump, by removing statements other than the schema
+func convertRailsStructure(src string) string {
+	ddls := []string{}
+	for _, ddl := range strings.SplitAfter(src, ";\n") {
+		lines := []string{}
+		for _, line := range strings.Split(ddl, "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				lines = append(lines, line)
+			}
+		}
+		ddl = strings.TrimSpace(strings.Join(lines, "\n"))
+		if ddl == "" || ddl == ";" || railsStructureNoiseRegexp.MatchString(ddl) {
+			continue
+		}
+		ddls = append(ddls, ddl)
+	}
+	if len(ddls) == 0 {
+		return ""
+	}
+	return strings.Join(ddls, "\n\n") + "\n"
+}
@@ -0,0 +1,79 @@
+package sqldef
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// Header of a file written by --resume, which has DDLs not applied yet in the format of a plan file
+const resumeHeader = "-- sqldef resume"
+
+// DDLs not applied yet, recorded by --resume so that a failed apply can be continued from the first of them
+type resumeFile struct {
+	path      string
+	mutex     sync.Mutex
+	remaining []string
+}
+
+// Read DDLs recorded by a failed apply, or return nil if there's no file to resume
+func readResumeFile(path string) ([]string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	lines := strings.SplitN(string(buf), "\n", 2)
+	if lines[0] != resumeHeader {
+		return nil, fmt.Errorf("'%s' is not a file written by --resume", path)
+	}
+	if len(lines) < 2 {
+		return []string{}, nil
+	}
+	return splitPlanStatements(lines[1]), nil
+}
+
+func newResumeFile(path string, ddls []string, skipDrop bool) (*resumeFile, error) {
+	r := &resumeFile{path: path, remaining: appliedDDLs(ddls, skipDrop)}
+	return r, r.write()
+}
+
+// Remove an applied DDL from the file. DDLs may be applied concurrently by --apply-concurrency.
+func (r *resumeFile) applied(ddl string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i, remaining := range r.remaining {
+		if remaining == ddl {
+			r.remaining = append(r.remaining[:i:i], r.remaining[i+1:]...)
+			break
+		}
+	}
+	if err := r.write(); err != nil {
+		fmt.Fprintf(os.Stderr, "-- WARNING: Failed to update '%s': %s --\n", r.path, err)
+	}
+}
+
+func (r *resumeFile) write() error {
+	var resume strings.Builder
+	resume.WriteString(resumeHeader + "\n")
+	writePlanStatements(&resume, r.remaining)
+	return ioutil.WriteFile(r.path, []byte(resume.String()), 0644)
+}
+
+// Remove the file after all DDLs are applied
+func (r *resumeFile) remove() error {
+	if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Return true if a failed apply is rolled back entirely. MySQL commits each DDL implicitly even in a transaction.
+func transactionalApply(generatorMode schema.GeneratorMode, options *Options) bool {
+	return !options.NoTransaction && !options.PgBouncer && options.ApplyConcurrency <= 1 && generatorMode != schema.GeneratorModeMysql
+}
@@ -0,0 +1,116 @@
+package sqldef
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+)
+
+const reviewHelp = `y - apply this DDL
+n - skip this DDL
+a - apply this DDL and all the later ones
+d - skip this DDL and all the later ones
+l - apply this DDL later, after the next one, unless the next one depends on it
+i - inspect the risk and impact of this DDL
+q - quit without applying any DDL
+? - print help
+`
+
+// Ask which DDLs to apply by --interactive, like `git add -p`, returning the approved ones in the order to apply them.
+// Destructive DDLs skipped by skipDrop are kept without asking. quit is true when the user aborts the whole apply.
+// A DDL which may depend on a skipped one, e.g. by touching the same table, is asked with a warning even after `a`.
+func reviewDDLs(ddls []string, skipDrop bool, analyzer *impactAnalyzer, in io.Reader, out io.Writer) (approved []string, quit bool, err error) {
+	pending := append([]string{}, ddls...)
+	approved = []string{}
+	skipped := []string{}
+	reader := bufio.NewReader(in)
+	rest := "" // "a" or "d" for all the later DDLs
+	for i := 0; i < len(pending); i++ {
+		ddl := pending[i]
+		if skipDrop && adapter.IsDropDDLIn(ddls, ddl) {
+			approved = append(approved, ddl) // printed as skipped
+			continue
+		}
+		if rest == "d" || (rest == "a" && skippedDependency(ddl, skipped) == "") {
+			if rest == "a" {
+				approved = append(approved, ddl)
+			}
+			continue
+		}
+
+		for answered := false; !answered; {
+			if dependency := skippedDependency(ddl, skipped); dependency != "" {
+				fmt.Fprintf(out, "-- WARNING: This DDL may depend on a skipped DDL: %s; --\n", dependency)
+			}
+			fmt.Fprintf(out, "%s;\n(%d/%d) Apply this DDL [y,n,a,d,l,i,q,?]? ", ddl, i+1, len(pending))
+			line, err := reader.ReadString('\n')
+			if err != nil && line == "" {
+				if err == io.EOF {
+					return nil, false, fmt.Errorf("--interactive ended before all DDLs were reviewed")
+				}
+				return nil, false, err
+			}
+
+			switch answer := strings.TrimSpace(line); answer {
+			case "y", "a":
+				approved = append(approved, ddl)
+				answered = true
+				if answer == "a" {
+					rest = answer
+				}
+			case "n", "d":
+				skipped = append(skipped, ddl)
+				answered = true
+				if answer == "d" {
+					rest = answer
+				}
+			case "l":
+				if i+1 == len(pending) {
+					fmt.Fprintln(out, "-- This is the last DDL already --")
+				} else if adapter.DependsOn(pending[i+1], ddl) {
+					fmt.Fprintln(out, "-- The next DDL depends on this one, e.g. by touching the same table --")
+				} else {
+					pending[i], pending[i+1] = pending[i+1], pending[i]
+					ddl = pending[i]
+				}
+			case "i":
+				fmt.Fprintln(out, analyzer.annotate(ddl, true))
+			case "q":
+				return nil, true, nil
+			default:
+				fmt.Fprint(out, reviewHelp)
+			}
+		}
+	}
+	return approved, false, nil
+}
+
+// Return the skipped DDL which the DDL may depend on, or an empty string
+func skippedDependency(ddl string, skipped []string) string {
+	for _, skippedDDL := range skipped {
+		if adapter.DependsOn(ddl, skippedDDL) {
+			return skippedDDL
+		}
+	}
+	return ""
+}
+
+// Return the terminal to review DDLs on. It's stdin and stderr unless the schema is given by stdin, which needs /dev/tty.
+func openReviewTerminal(options *Options) (io.Reader, io.Writer, func(), error) {
+	schemaFromStdin := false
+	for _, file := range options.DesiredFiles {
+		schemaFromStdin = schemaFromStdin || (file == "-" && options.DesiredDB == nil)
+	}
+	if !schemaFromStdin {
+		return os.Stdin, os.Stderr, func() {}, nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("--interactive needs a terminal when the schema is given by stdin: %s", err)
+	}
+	return tty, tty, func() { tty.Close() }, nil
+}
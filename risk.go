@@ -0,0 +1,81 @@
+package sqldef
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// Risk levels of DDLs in ascending order, given to --max-risk
+const (
+	riskSafe        = "safe"        // neither blocks writes for long nor loses data
+	riskBlocking    = "blocking"    // blocks writes while scanning or rewriting a table
+	riskDestructive = "destructive" // loses data, e.g. DROP TABLE and DROP COLUMN
+)
+
+var riskLevels = []string{riskSafe, riskBlocking, riskDestructive}
+
+func riskLevel(risk string) int {
+	for i, level := range riskLevels {
+		if level == risk {
+			return i
+		}
+	}
+	panic(fmt.Sprintf("unexpected risk: %s", risk)) // validated by choice tags
+}
+
+var (
+	// Operations scanning a table under ACCESS EXCLUSIVE lock of PostgreSQL, unless NOT VALID is given
+	postgresScanRegexp     = regexp.MustCompile(`(?is)\sSET\s+NOT\s+NULL\b|\sADD\s+(CONSTRAINT\s+\S+\s+)?(PRIMARY\s+KEY|UNIQUE|CHECK|FOREIGN\s+KEY|EXCLUDE)\b`)
+	postgresNotValidRegexp = regexp.MustCompile(`(?is)\sNOT\s+VALID\s*$`)
+	// Operations of other engines, which mostly rewrite or scan a table
+	alterColumnRegexp  = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s.*\s(ALTER|CHANGE|MODIFY)\s+(COLUMN\s+)?\S+\s`)
+	createIndexRegexp  = regexp.MustCompile(`(?is)^\s*CREATE\s+(\S+\s+)*INDEX\s`)
+	onlineIndexRegexp  = regexp.MustCompile(`(?is)\sONLINE\s*=\s*ON\b`)
+	updateTablesRegexp = regexp.MustCompile(`(?is)^\s*UPDATE\s`)
+)
+
+// Classify a DDL as safe, blocking, or destructive. version is empty when the database has no version, e.g. --file.
+func classifyRisk(mode schema.GeneratorMode, ddl string, version string) string {
+	if adapter.IsDropDDL(ddl) {
+		return riskDestructive
+	}
+
+	var blocking bool
+	switch mode {
+	case schema.GeneratorModePostgres:
+		impact := postgresImpact(ddl, version)
+		blocking = impact.Rewrite || impact.Lock == "SHARE" || impact.Lock == "ROW EXCLUSIVE" ||
+			(postgresScanRegexp.MatchString(ddl) && !postgresNotValidRegexp.MatchString(ddl))
+	case schema.GeneratorModeMysql:
+		impact := mysqlImpact(ddl, version)
+		blocking = impact.Rewrite || impact.Lock == "SHARED" || impact.Lock == "EXCLUSIVE" || updateTablesRegexp.MatchString(ddl)
+	default:
+		blocking = alterColumnRegexp.MatchString(ddl) || updateTablesRegexp.MatchString(ddl) ||
+			(createIndexRegexp.MatchString(ddl) && !onlineIndexRegexp.MatchString(ddl))
+	}
+	if blocking {
+		return riskBlocking
+	}
+	return riskSafe
+}
+
+// Return an error when DDLs to run exceed --max-risk, to abort before applying or showing any of them
+func checkMaxRisk(analyzer *impactAnalyzer, ddls []string, skipDrop bool, maxRisk string) error {
+	exceeded := []string{}
+	for _, ddl := range ddls {
+		if skipDrop && adapter.IsDropDDLIn(ddls, ddl) {
+			continue // never run
+		}
+		if risk := analyzer.risk(ddl); riskLevel(risk) > riskLevel(maxRisk) {
+			exceeded = append(exceeded, fmt.Sprintf("-- %s\n%s;", risk, ddl))
+		}
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("Aborted by --max-risk=%s, since %d DDLs exceed it:\n%s", maxRisk, len(exceeded), strings.Join(exceeded, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package sqldef
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+const rollbackHeader = "-- sqldef rollback: DDLs to revert the schema to the one before applying the generated DDLs"
+
+// e.g. `ALTER TABLE users DROP COLUMN name` and `ALTER TABLE [users] ADD [name] text`
+var alterObjectRegexp = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(?:ONLY\s+)?(?:IF\s+EXISTS\s+)?(\S+)\s+(?:ADD|DROP)\s+` +
+	`(?:(COLUMN|CONSTRAINT|INDEX|KEY|UNIQUE\s+KEY|FOREIGN\s+KEY)\s+)?(?:IF\s+(?:NOT\s+)?EXISTS\s+)?([^\s(;]+)`)
+
+// Return an object created or dropped by a DDL, e.g. "COLUMN users.name" for `ALTER TABLE users DROP COLUMN name`,
+// to find DDLs in the rollback which revert a skipped DROP
+func ddlTarget(ddl string) string {
+	unquote := strings.NewReplacer("`", "", "\"", "", "[", "", "]", "")
+	unqualify := func(name string) string {
+		name = unquote.Replace(name)
+		if i := strings.LastIndexAny(name, ".@"); i >= 0 {
+			name = name[i+1:]
+		}
+		return strings.ToLower(name)
+	}
+
+	if m := alterObjectRegexp.FindStringSubmatch(ddl); m != nil {
+		switch kind := strings.ToUpper(strings.Join(strings.Fields(m[2]), " ")); kind {
+		case "CONSTRAINT", "FOREIGN KEY":
+			return "CONSTRAINT " + unqualify(m[3])
+		case "INDEX", "KEY", "UNIQUE KEY":
+			return "INDEX " + unqualify(m[3])
+		default: // COLUMN is optional
+			return "COLUMN " + unqualify(m[1]) + "." + unqualify(m[3])
+		}
+	}
+	planned := describeDDL(ddl, false)
+	if operation := strings.Fields(planned.Operation); len(operation) == 2 && (operation[0] == "CREATE" || operation[0] == "DROP") {
+		return operation[1] + " " + unqualify(planned.Object)
+	}
+	return ""
+}
+
+// Generate DDLs to revert the generated ones, by comparing the schemas in the opposite direction.
+// Objects whose DROPs are skipped are not dropped, so DDLs to create them again are excluded.
+func generateRollbackDDLs(generatorMode schema.GeneratorMode, ddls []string, skipDrop bool, currentDDLs string, desiredDDLs string, config schema.GeneratorConfig) ([]string, error) {
+	rollbackDDLs, err := schema.GenerateIdempotentDDLs(generatorMode, currentDDLs, desiredDDLs, config)
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := map[string]bool{}
+	for _, ddl := range ddls {
+		if skipDrop && adapter.IsDropDDLIn(ddls, ddl) {
+			if target := ddlTarget(ddl); target != "" {
+				skipped[target] = true
+			}
+		}
+	}
+	result := []string{}
+	for _, ddl := range rollbackDDLs {
+		if !adapter.IsDropDDL(ddl) && skipped[ddlTarget(ddl)] {
+			continue
+		}
+		result = append(result, ddl)
+	}
+	return result, nil
+}
+
+// Write DDLs to revert the generated ones, e.g. for an emergency revert of a deployment
+func writeRollback(path string, generatorMode schema.GeneratorMode, ddls []string, skipDrop bool, currentDDLs string, desiredDDLs string, config schema.GeneratorConfig) error {
+	rollbackDDLs, err := generateRollbackDDLs(generatorMode, ddls, skipDrop, currentDDLs, desiredDDLs, config)
+	if err != nil {
+		return err
+	}
+	var rollback strings.Builder
+	rollback.WriteString(rollbackHeader + "\n")
+	for _, ddl := range rollbackDDLs {
+		rollback.WriteString(ddl + ";\n")
+	}
+	return ioutil.WriteFile(path, []byte(rollback.String()), 0644)
+}
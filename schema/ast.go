@@ -40,13 +40,43 @@ type AddPolicy struct {
 	policy    Policy
 }
 
+type AddComment struct {
+	statement string
+	tableName string
+	comment   Comment
+}
+
+type DistributeTable struct {
+	statement          string
+	tableName          string
+	tableType          string // distributed or reference
+	distributionColumn string
+}
+
+type CreateSequence struct { // for MariaDB
+	statement string
+	sequence  Sequence
+}
+
 type Table struct {
-	name        string
-	columns     []Column
-	indexes     []Index
-	checks      []CheckDefinition
-	foreignKeys []ForeignKey
-	policies    []Policy
+	name             string
+	columns          []Column
+	indexes          []Index
+	checks           []CheckDefinition
+	foreignKeys      []ForeignKey
+	policies         []Policy
+	comments         []Comment
+	distStyle        string    // for Redshift. Empty for AUTO
+	distKey          string    // for Redshift
+	sortStyle        string    // for Redshift. COMPOUND or INTERLEAVED
+	sortKeys         []string  // for Redshift and SingleStore. Empty for AUTO or unordered
+	shardKeys        *[]string // for SingleStore. nil if not specified
+	tableType        string    // for SingleStore. e.g. ROWSTORE, REFERENCE
+	distribution     string    // for Greenplum. BY, RANDOMLY, or REPLICATED. Empty if not specified
+	distributionKeys []string  // for Greenplum `DISTRIBUTED BY`
+	citusTableType   string    // for Citus. distributed or reference. Empty for local tables
+	citusColumn      string    // for Citus. Distribution column of a distributed table
+	renamedFrom      string    // by `CREATE TABLE new_name ( -- @renamed from=old_name`
 	// XXX: have options and alter on its change?
 }
 
@@ -72,6 +102,10 @@ type Column struct {
 	references    string
 	identity      *Identity
 	sequence      *Sequence
+	encoding      string // for Redshift `ENCODE`
+	renamedFrom   string // by `-- @renamed from=old_name`
+	backfill      string // by `-- @backfill expression`
+	backfillDML   string // by `-- sqldef:backfill UPDATE ...`
 	// TODO: keyopt
 	// XXX: zerofill?
 }
@@ -139,6 +173,13 @@ type Policy struct {
 	withCheck     string
 }
 
+// MSSQL's `MS_Description` extended property or PostgreSQL's `COMMENT ON`, on a table or a column
+type Comment struct {
+	columnName string // empty for a table comment
+	value      string
+	schemaName string // level0name of an extended property of MSSQL, whose table names don't have schemas
+}
+
 type View struct {
 	statement  string
 	name       string
@@ -252,6 +293,18 @@ func (a *AddPolicy) Statement() string {
 	return a.statement
 }
 
+func (a *AddComment) Statement() string {
+	return a.statement
+}
+
+func (d *DistributeTable) Statement() string {
+	return d.statement
+}
+
+func (c *CreateSequence) Statement() string {
+	return c.statement
+}
+
 func (v *View) Statement() string {
 	return v.statement
 }
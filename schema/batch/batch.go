@@ -0,0 +1,5 @@
+// Annotation of statements run in batches, shared by schema generating them and adapter running them
+package batch
+
+// Suffix of a statement repeated until it affects no row, e.g. a backfill in batches by --safe-type-change
+const Suffix = " /* sqldef:batch */"
@@ -0,0 +1,143 @@
+package schema
+
+import (
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Functions returning the start time of the transaction, which are printed as the first one by SHOW CREATE TABLE
+	mysqlDefaultAliases = map[string]string{
+		"now":            "current_timestamp",
+		"localtime":      "current_timestamp",
+		"localtimestamp": "current_timestamp",
+	}
+	postgresDefaultAliases = map[string]string{
+		"now":                   "current_timestamp",
+		"transaction_timestamp": "current_timestamp",
+	}
+
+	// CURRENT_TIMESTAMP(0) is the same as CURRENT_TIMESTAMP in MySQL, while it truncates fractional seconds in PostgreSQL
+	zeroPrecisionRegexp = regexp.MustCompile(`^(current_timestamp|localtime|localtimestamp)\(0\)$`)
+
+	// Decimal numbers like `1.50`, `-1`, and `1e3`, which are compared exactly
+	decimalRegexp = regexp.MustCompile(`^[+-]?(?:\d+\.?\d*|\.\d+)(?:[eE][+-]?\d+)?$`)
+
+	numericDataTypes = map[string]bool{
+		"tinyint": true, "smallint": true, "mediumint": true, "int": true, "integer": true, "bigint": true,
+		"int2": true, "int4": true, "int8": true, "decimal": true, "numeric": true, "float": true, "float4": true,
+		"float8": true, "double": true, "double precision": true, "real": true,
+	}
+	booleanDataTypes = map[string]bool{
+		"bool": true, "boolean": true,
+	}
+	postgresBooleanLiterals = map[string]string{
+		"t": "true", "true": "true", "yes": "true", "on": "true", "1": "true",
+		"f": "false", "false": "false", "no": "false", "off": "false", "0": "false",
+	}
+)
+
+// Compare DEFAULT expressions by what they evaluate to, so that `'0'::integer` and `0`, `now()` and `CURRENT_TIMESTAMP`,
+// or `1.50` and `1.5` of a numeric column don't generate ALTER on every run. Aliases of functions apply only to functions
+// and keywords, so a string literal like `'now'` or `'true'` is never the same as them.
+func (g *Generator) areSameDefaultValue(currentColumn Column, desiredColumn Column) bool {
+	if g.isSameSerial(currentColumn, desiredColumn) {
+		return true // the sequence is not printed as a default of serial
+	}
+	currentDefault, desiredDefault, typeName := currentColumn.defaultDef, desiredColumn.defaultDef, strings.ToLower(desiredColumn.typeName)
+
+	var current *Value
+	var desired *Value
+	if currentDefault != nil && !isNullValue(currentDefault.value) {
+		current = currentDefault.value
+	}
+	if desiredDefault != nil && !isNullValue(desiredDefault.value) {
+		desired = desiredDefault.value
+	}
+	if current == nil || desired == nil {
+		return areSameValue(current, desired)
+	}
+
+	// Numbers may be quoted, e.g. by SHOW CREATE TABLE of MySQL, and they are the same only when they are exactly equal
+	if numericDataTypes[typeName] {
+		currentNumber, currentOk := numericDefault(current)
+		desiredNumber, desiredOk := numericDefault(desired)
+		if currentOk && desiredOk {
+			return currentNumber.Cmp(desiredNumber) == 0
+		}
+	}
+
+	currentRaw, currentExpression := g.normalizeDefault(current, typeName)
+	desiredRaw, desiredExpression := g.normalizeDefault(desired, typeName)
+	if currentExpression || desiredExpression {
+		return currentExpression == desiredExpression && currentRaw == desiredRaw
+	}
+	return areSameValue(current, desired)
+}
+
+// Return the canonical form of a function or a keyword, e.g. `now()` and `true`, and whether the value is one of them.
+// The parser has already stripped casts and parentheses from the value.
+func (g *Generator) normalizeDefault(value *Value, typeName string) (string, bool) {
+	lowered := strings.ToLower(string(value.raw))
+	switch value.valueType {
+	case ValueTypeValArg, ValueTypeBool:
+	case ValueTypeBit:
+		if lowered != "now" { // the parser gives NOW() as a bit value
+			return string(value.raw), false
+		}
+	case ValueTypeStr:
+		// A string literal of a boolean column is cast to the boolean when the table is created
+		if (g.mode == GeneratorModePostgres || g.mode == GeneratorModeCockroach || g.mode == GeneratorModeRedshift) && booleanDataTypes[typeName] {
+			if literal, ok := postgresBooleanLiterals[lowered]; ok {
+				return literal, true
+			}
+		}
+		return string(value.raw), false
+	default:
+		return string(value.raw), false
+	}
+
+	lowered = strings.TrimSuffix(lowered, "()")
+	switch g.mode {
+	case GeneratorModeMysql:
+		if alias, ok := mysqlDefaultAliases[lowered]; ok {
+			return alias, true
+		}
+		if zeroPrecisionRegexp.MatchString(lowered) {
+			return zeroPrecisionRegexp.ReplaceAllString(lowered, "$1"), true
+		}
+		// BOOLEAN is TINYINT(1), whose default is printed as '1' or '0'
+		switch lowered {
+		case "true":
+			return "1", true
+		case "false":
+			return "0", true
+		}
+	case GeneratorModePostgres, GeneratorModeCockroach, GeneratorModeRedshift:
+		if alias, ok := postgresDefaultAliases[lowered]; ok {
+			return alias, true
+		}
+	}
+	return lowered, true
+}
+
+// Return the exact number of a default of a numeric column, which may be a string literal like `'1.50'`
+func numericDefault(value *Value) (*big.Rat, bool) {
+	raw := string(value.raw)
+	switch value.valueType {
+	case ValueTypeInt, ValueTypeFloat, ValueTypeStr:
+	case ValueTypeBool: // BOOLEAN of MySQL is TINYINT(1)
+		if strings.EqualFold(raw, "true") {
+			return big.NewRat(1, 1), true
+		}
+		return big.NewRat(0, 1), true
+	default:
+		return nil, false
+	}
+	if !decimalRegexp.MatchString(raw) {
+		return nil, false
+	}
+	number, ok := new(big.Rat).SetString(raw)
+	return number, ok
+}
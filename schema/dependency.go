@@ -0,0 +1,245 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Identifiers in a view definition, which may be tables or views it selects from
+var identifierRegexp = regexp.MustCompile("[\"`\\[]?[A-Za-z_][\\w$]*[\"`\\]]?(?:\\.[\"`\\[]?[A-Za-z_][\\w$]*[\"`\\]]?)?")
+
+// Names of tables, views, and types mapped to objects defining them. A name is looked up with its schema first,
+// and then without the schema, since a schema may be omitted on either side.
+type dependencyNames struct {
+	qualified   map[string][]int
+	unqualified map[string][]int
+}
+
+func newDependencyNames() dependencyNames {
+	return dependencyNames{qualified: map[string][]int{}, unqualified: map[string][]int{}}
+}
+
+func (d dependencyNames) add(name string, i int) {
+	qualified, unqualified := splitQualifiedTableName(strings.ToLower(name))
+	d.qualified[qualified] = append(d.qualified[qualified], i)
+	d.unqualified[unqualified] = append(d.unqualified[unqualified], i)
+}
+
+func (d dependencyNames) lookup(name string) []int {
+	qualified, unqualified := splitQualifiedTableName(strings.NewReplacer("`", "", "[", "", "]", "").Replace(strings.ToLower(name)))
+	if found, ok := d.qualified[qualified]; ok {
+		return found
+	}
+	return d.unqualified[unqualified]
+}
+
+// Return indexes of n objects ordered so that each object comes after its dependencies, keeping the given order
+// unless it's needed. A cyclic dependency is broken at the object visited first, which comes last in the cycle.
+func orderByDependency(n int, dependencies func(i int) []int) []int {
+	order := []int{}
+	visited := make([]bool, n)
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, j := range dependencies(i) {
+			if j != i {
+				visit(j)
+			}
+		}
+		order = append(order, i)
+	}
+	for i := 0; i < n; i++ {
+		visit(i)
+	}
+	return order
+}
+
+// Sort desired DDLs so that they're applicable to an empty database: types before tables using them,
+// referenced tables before foreign keys, and tables before their indexes, triggers, and views.
+func sortDDLsByDependency(ddls []DDL) []DDL {
+	tables := newDependencyNames() // tables and views
+	types := newDependencyNames()
+	for i, ddl := range ddls {
+		switch stmt := ddl.(type) {
+		case *CreateTable:
+			tables.add(stmt.table.name, i)
+		case *View:
+			tables.add(stmt.name, i)
+		case *Type:
+			types.add(stmt.name, i)
+		}
+	}
+
+	order := orderByDependency(len(ddls), func(i int) []int {
+		var dependencies []int
+		switch stmt := ddls[i].(type) {
+		case *CreateTable:
+			for _, column := range stmt.table.columns {
+				dependencies = append(dependencies, types.lookup(column.typeName)...)
+			}
+			for _, name := range referencedTableNames(stmt.table) {
+				dependencies = append(dependencies, tables.lookup(name)...)
+			}
+		case *CreateIndex:
+			dependencies = tables.lookup(stmt.tableName)
+		case *AddIndex:
+			dependencies = tables.lookup(stmt.tableName)
+		case *AddPrimaryKey:
+			dependencies = tables.lookup(stmt.tableName)
+		case *AddForeignKey:
+			dependencies = append(tables.lookup(stmt.tableName), tables.lookup(stmt.foreignKey.referenceName)...)
+		case *AddPolicy:
+			dependencies = tables.lookup(stmt.tableName)
+		case *AddComment:
+			dependencies = tables.lookup(stmt.tableName)
+		case *DistributeTable:
+			dependencies = tables.lookup(stmt.tableName)
+		case *Trigger:
+			dependencies = tables.lookup(stmt.tableName)
+		case *View:
+			for _, name := range identifierRegexp.FindAllString(stmt.definition, -1) {
+				dependencies = append(dependencies, tables.lookup(name)...)
+			}
+		}
+		return dependencies
+	})
+
+	sorted := make([]DDL, len(ddls))
+	for i, j := range order {
+		sorted[i] = ddls[j]
+	}
+	return sorted
+}
+
+// Return tables referenced by foreign keys of a table, including REFERENCES of columns
+func referencedTableNames(table Table) []string {
+	names := []string{}
+	for _, column := range table.columns {
+		if column.references != "" {
+			names = append(names, column.references)
+		}
+	}
+	for _, foreignKey := range table.foreignKeys {
+		names = append(names, foreignKey.referenceName)
+	}
+	return names
+}
+
+// Sort tables to drop them in the reverse order of creation: tables referencing others by foreign keys first
+func sortTablesForDrop(tables []*Table) []*Table {
+	names := newDependencyNames()
+	for i, table := range tables {
+		names.add(table.name, i)
+	}
+	referencedBy := make([][]int, len(tables))
+	for i, table := range tables {
+		for _, name := range referencedTableNames(*table) {
+			for _, j := range names.lookup(name) {
+				referencedBy[j] = append(referencedBy[j], i)
+			}
+		}
+	}
+
+	sorted := []*Table{}
+	for _, i := range orderByDependency(len(tables), func(i int) []int { return referencedBy[i] }) {
+		sorted = append(sorted, tables[i])
+	}
+	return sorted
+}
+
+// Sort views to drop them in the reverse order of creation: views selecting from other views first
+func sortViewsForDrop(views []*View) []*View {
+	names := newDependencyNames()
+	for i, view := range views {
+		names.add(view.name, i)
+	}
+	selectedBy := make([][]int, len(views))
+	for i, view := range views {
+		for _, name := range identifierRegexp.FindAllString(view.definition, -1) {
+			for _, j := range names.lookup(name) {
+				selectedBy[j] = append(selectedBy[j], i)
+			}
+		}
+	}
+
+	sorted := []*View{}
+	for _, i := range orderByDependency(len(views), func(i int) []int { return selectedBy[i] }) {
+		sorted = append(sorted, views[i])
+	}
+	return sorted
+}
+
+// Return views selecting from a table, including ones selecting from such views, in the order to drop them
+func dependentViews(views []*View, table string) []*View {
+	names := newDependencyNames()
+	names.add(table, -1)
+	dependent := map[*View]bool{}
+	for found := true; found; {
+		found = false
+		for i, view := range views {
+			if dependent[view] {
+				continue
+			}
+			for _, name := range identifierRegexp.FindAllString(view.definition, -1) {
+				if len(names.lookup(name)) > 0 {
+					names.add(view.name, i)
+					dependent[view] = true
+					found = true
+					break
+				}
+			}
+		}
+	}
+
+	sorted := []*View{}
+	for _, view := range sortViewsForDrop(views) {
+		if dependent[view] {
+			sorted = append(sorted, view)
+		}
+	}
+	return sorted
+}
+
+var (
+	foreignKeyIdentifier = "(?:\"[^\"]+\"|`[^`]+`|\\[[^\\]]+\\]|[\\w$]+)"
+	foreignKeyConstraint = `(?:CONSTRAINT\s+` + foreignKeyIdentifier + `\s+)?`
+	referencesClause     = `REFERENCES\s+(` + foreignKeyIdentifier + `(?:\.` + foreignKeyIdentifier + `)*)(?:\s*\([^)]*\))?` +
+		`(?:\s+(?:ON\s+(?:DELETE|UPDATE)\s+(?:NO\s+ACTION|SET\s+NULL|SET\s+DEFAULT|CASCADE|RESTRICT)|MATCH\s+(?:FULL|PARTIAL|SIMPLE)|` +
+		`NOT\s+DEFERRABLE|DEFERRABLE|INITIALLY\s+(?:DEFERRED|IMMEDIATE)|NOT\s+FOR\s+REPLICATION))*`
+
+	// e.g. `, CONSTRAINT posts_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id)` in CREATE TABLE
+	tableForeignKeyRegexp = regexp.MustCompile(`(?is),\s*(` + foreignKeyConstraint + `FOREIGN\s+KEY\s*(?:` + foreignKeyIdentifier + `\s*)?\([^)]*\)\s*` + referencesClause + `)`)
+	// e.g. `user_id bigint REFERENCES users (id)` in CREATE TABLE
+	columnForeignKeyRegexp = regexp.MustCompile(`(?is)([(,]\s*)(` + foreignKeyIdentifier + `)((?:[^,()]|\([^)]*\))*?)\s+(` + foreignKeyConstraint + `)(` + referencesClause + `)`)
+)
+
+// Remove foreign keys referencing tables created later from CREATE TABLE, which would fail on a cyclic reference,
+// and return ALTER TABLE to add them after creating the tables.
+func (g *Generator) deferForeignKeys(statement string, tableName string, createdLater func(table string) bool) (string, []string) {
+	if g.mode == GeneratorModeSQLite3 {
+		return statement, nil // SQLite3 accepts foreign keys to inexistent tables, and can't add them by ALTER TABLE
+	}
+
+	var ddls []string
+	statement = tableForeignKeyRegexp.ReplaceAllStringFunc(statement, func(clause string) string {
+		m := tableForeignKeyRegexp.FindStringSubmatch(clause)
+		if !createdLater(m[2]) {
+			return clause
+		}
+		ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ADD %s", g.escapeTableName(tableName), m[1]))
+		return ""
+	})
+	statement = columnForeignKeyRegexp.ReplaceAllStringFunc(statement, func(clause string) string {
+		m := columnForeignKeyRegexp.FindStringSubmatch(clause)
+		if g.mode == GeneratorModeMysql || !createdLater(m[6]) { // MySQL ignores REFERENCES of columns
+			return clause
+		}
+		ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ADD %sFOREIGN KEY (%s) %s", g.escapeTableName(tableName), m[4], m[2], m[5]))
+		return m[1] + m[2] + m[3]
+	})
+	return statement, ddls
+}
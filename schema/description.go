@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// e.g. `-- description: Users signed up by email` above `CREATE TABLE users (` or a column in it
+	descriptionRegexp = regexp.MustCompile(`^\s*--\s*description:\s*(.*?)\s*$`)
+
+	descriptionTableRegexp  = regexp.MustCompile(`(?i)^\s*CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)`)
+	descriptionColumnRegexp = regexp.MustCompile("^\\s*([`\"\\[]?[^\\s`\"\\[\\],()]+[`\"\\]]?)")
+	stringLiteralRegexp     = regexp.MustCompile(`'(?:[^'\\]|''|\\.)*'`)
+)
+
+// Words starting a line of CREATE TABLE which is not a column
+var tableConstraintWords = []string{"CONSTRAINT", "PRIMARY", "UNIQUE", "KEY", "INDEX", "FOREIGN", "CHECK", "FULLTEXT", "SPATIAL", "EXCLUDE"}
+
+// Translate `-- description:` comments above tables and columns into COMMENT ON of PostgreSQL, or COMMENT clauses of MySQL,
+// to keep the documentation of the schema file in the database. Consecutive lines of them are joined by newlines.
+func ConvertDescriptions(mode GeneratorMode, sql string) (string, error) {
+	switch mode {
+	case GeneratorModePostgres, GeneratorModeMysql:
+	default:
+		return "", fmt.Errorf("-- description: is not supported by this database")
+	}
+	literal := func(description string) string {
+		if mode == GeneratorModeMysql {
+			description = strings.ReplaceAll(description, `\`, `\\`)
+		}
+		return escapeStringLiteral(description)
+	}
+
+	var lines, commentOns, descriptions []string
+	table := ""        // CREATE TABLE whose columns are being read
+	tableComment := "" // COMMENT of the table for MySQL
+	depth := 0
+	for _, line := range strings.Split(sql, "\n") {
+		if match := descriptionRegexp.FindStringSubmatch(line); match != nil {
+			descriptions = append(descriptions, match[1])
+			lines = append(lines, line)
+			continue
+		}
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			lines = append(lines, line)
+			continue
+		}
+
+		// Literals and comments are masked not to count their parentheses
+		code := stringLiteralRegexp.ReplaceAllStringFunc(line, func(literal string) string {
+			return strings.Repeat("_", len(literal))
+		})
+		if i := strings.Index(code, "--"); i >= 0 {
+			code = code[:i]
+		}
+
+		description := strings.Join(descriptions, "\n")
+		if table == "" {
+			if match := descriptionTableRegexp.FindStringSubmatch(line); match != nil {
+				table = match[1]
+				depth = 0
+				if len(descriptions) > 0 && mode == GeneratorModeMysql {
+					tableComment = description
+				} else if len(descriptions) > 0 {
+					commentOns = append(commentOns, fmt.Sprintf("COMMENT ON TABLE %s IS %s;", table, literal(description)))
+				}
+				descriptions = nil
+			}
+		}
+		if len(descriptions) > 0 {
+			match := descriptionColumnRegexp.FindStringSubmatch(line)
+			if table == "" || depth != 1 || match == nil || containsString(tableConstraintWords, strings.ToUpper(match[1])) {
+				return "", fmt.Errorf("-- description: needs to be above CREATE TABLE or a column: %s", strings.TrimSpace(line))
+			}
+			if mode == GeneratorModeMysql {
+				end := len(strings.TrimRight(code, " \t\r"))
+				if strings.HasSuffix(code[:end], ",") {
+					end--
+				}
+				line = line[:end] + " COMMENT " + literal(description) + line[end:]
+				code = code[:end] + strings.Repeat("_", len(" COMMENT "+literal(description))) + code[end:]
+			} else {
+				commentOns = append(commentOns, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;", table, match[1], literal(description)))
+			}
+			descriptions = nil
+		}
+
+		if table != "" {
+			for i, c := range code {
+				switch c {
+				case '(':
+					depth++
+				case ')':
+					depth--
+					if depth == 0 {
+						if tableComment != "" {
+							line = line[:i+1] + " COMMENT=" + literal(tableComment) + line[i+1:]
+							tableComment = ""
+						}
+						table = ""
+					}
+				case ';':
+					if depth == 0 {
+						table = "" // e.g. CREATE TABLE ... AS SELECT
+					}
+				}
+				if table == "" {
+					break
+				}
+			}
+		}
+		lines = append(lines, line)
+	}
+	if len(descriptions) > 0 {
+		return "", fmt.Errorf("-- description: needs to be above CREATE TABLE or a column: %s", strings.Join(descriptions, "\n"))
+	}
+
+	sql = strings.Join(lines, "\n")
+	if len(commentOns) > 0 {
+		sql = strings.TrimRight(sql, " \t\r\n")
+		if !strings.HasSuffix(sql, ";") {
+			sql += ";"
+		}
+		sql += "\n" + strings.Join(commentOns, "\n") + "\n"
+	}
+	return sql, nil
+}
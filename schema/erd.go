@@ -0,0 +1,205 @@
+package schema
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// A relationship drawn from a foreign key, from the referencing table to the referenced one
+type erdRelationship struct {
+	name             string
+	table            *Table
+	columns          []string
+	referenced       *Table
+	referencedColumn string // the first referenced column, or "" for a column's REFERENCES without columns
+	optional         bool   // any of the columns is nullable
+	unique           bool   // the columns are unique, which makes it one-to-one
+}
+
+var mermaidInvalidCharsRegexp = regexp.MustCompile(`[^\w-]+`)
+
+// Render tables and their foreign keys in the schema SQL as an ER diagram of Mermaid's erDiagram or Graphviz's dot,
+// so that documents can be generated from a schema file or a database
+func GenerateERD(mode GeneratorMode, sql string, config GeneratorConfig, format string) (string, error) {
+	ddls, err := ParseDDLs(mode, sql)
+	if err != nil {
+		return "", err
+	}
+	ddls = filterDDLs(ddls, config.skipDesiredTable)
+	tables, err := convertDDLsToTables(ddls)
+	if err != nil {
+		return "", err
+	}
+	relationships := erdRelationships(mode, tables)
+
+	switch format {
+	case "mermaid":
+		return generateMermaidERD(tables, relationships), nil
+	case "dot":
+		return generateDotERD(tables, relationships), nil
+	default:
+		return "", fmt.Errorf("unsupported ER diagram format '%s'", format)
+	}
+}
+
+func erdRelationships(mode GeneratorMode, tables []*Table) []erdRelationship {
+	relationships := []erdRelationship{}
+	for _, table := range tables {
+		for _, fk := range table.foreignKeys {
+			referenced := findTableByName(tables, normalizedTable(mode, fk.referenceName))
+			if referenced == nil {
+				continue // e.g. a table skipped by --skip-table
+			}
+			relationship := erdRelationship{name: fk.constraintName, table: table, columns: fk.indexColumns, referenced: referenced}
+			if len(fk.referenceColumns) > 0 {
+				relationship.referencedColumn = fk.referenceColumns[0]
+			}
+			relationships = append(relationships, relationship)
+		}
+		for _, column := range table.columns {
+			if column.references == "" {
+				continue
+			}
+			if referenced := findTableByName(tables, column.references); referenced != nil {
+				relationships = append(relationships, erdRelationship{table: table, columns: []string{column.name}, referenced: referenced})
+			}
+		}
+	}
+
+	for i, relationship := range relationships {
+		for _, name := range relationship.columns {
+			if column := findColumnByName(relationship.table.columns, name); column != nil && (column.notNull == nil || !*column.notNull) &&
+				!isPrimaryKey(*column, *relationship.table) {
+				relationships[i].optional = true
+			}
+		}
+		relationships[i].unique = isUniqueColumns(*relationship.table, relationship.columns)
+	}
+	return relationships
+}
+
+// Return true if a primary key or a unique index consists of the columns
+func isUniqueColumns(table Table, columns []string) bool {
+	if len(columns) == 1 {
+		if column := findColumnByName(table.columns, columns[0]); column != nil && (column.keyOption == ColumnKeyPrimary || column.keyOption.isUnique()) {
+			return true
+		}
+	}
+	for _, index := range table.indexes {
+		if !index.primary && !index.unique || len(index.columns) != len(columns) {
+			continue
+		}
+		matched := true
+		for i, indexColumn := range index.columns {
+			if indexColumn.column != columns[i] {
+				matched = false
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Return a table name without the default schema, e.g. users of public.users
+func erdTableName(table *Table) string {
+	return strings.TrimPrefix(table.name, "public.")
+}
+
+// Return PK, FK, or UK of a column, which are shown as keys of Mermaid
+func erdColumnKeys(table *Table, column Column, relationships []erdRelationship) []string {
+	keys := []string{}
+	if isPrimaryKey(column, *table) {
+		keys = append(keys, "PK")
+	}
+	for _, relationship := range relationships {
+		if relationship.table == table && containsString(relationship.columns, column.name) {
+			keys = append(keys, "FK")
+			break
+		}
+	}
+	if len(keys) == 0 && isUniqueColumns(*table, []string{column.name}) {
+		keys = append(keys, "UK")
+	}
+	return keys
+}
+
+func generateMermaidERD(tables []*Table, relationships []erdRelationship) string {
+	mermaidName := func(name string) string {
+		return mermaidInvalidCharsRegexp.ReplaceAllString(name, "_")
+	}
+
+	var diagram strings.Builder
+	diagram.WriteString("erDiagram\n")
+	for _, table := range tables {
+		diagram.WriteString(fmt.Sprintf("    %s {\n", mermaidName(erdTableName(table))))
+		for _, column := range table.columns {
+			line := fmt.Sprintf("        %s %s", mermaidName(column.typeName), mermaidName(column.name))
+			if keys := erdColumnKeys(table, column, relationships); len(keys) > 0 {
+				line += " " + strings.Join(keys, ", ")
+			}
+			diagram.WriteString(line + "\n")
+		}
+		diagram.WriteString("    }\n")
+	}
+	for _, relationship := range relationships {
+		// The referenced side is exactly one, or zero or one if the foreign key is nullable
+		left := "||"
+		if relationship.optional {
+			left = "|o"
+		}
+		right := "o{"
+		if relationship.unique {
+			right = "o|"
+		}
+		label := relationship.name
+		if label == "" {
+			label = strings.Join(relationship.columns, ", ")
+		}
+		diagram.WriteString(fmt.Sprintf("    %s %s--%s %s : %q\n", mermaidName(erdTableName(relationship.referenced)), left, right,
+			mermaidName(erdTableName(relationship.table)), label))
+	}
+	return diagram.String()
+}
+
+func generateDotERD(tables []*Table, relationships []erdRelationship) string {
+	var diagram strings.Builder
+	diagram.WriteString("digraph schema {\n")
+	diagram.WriteString("  graph [rankdir=LR];\n")
+	diagram.WriteString("  node [shape=plaintext];\n")
+	for _, table := range tables {
+		diagram.WriteString(fmt.Sprintf("  %q [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\">", erdTableName(table)))
+		diagram.WriteString(fmt.Sprintf("<tr><td bgcolor=\"lightgray\"><b>%s</b></td></tr>", html.EscapeString(erdTableName(table))))
+		for _, column := range table.columns {
+			text := html.EscapeString(fmt.Sprintf("%s: %s", column.name, column.typeName))
+			if keys := erdColumnKeys(table, column, relationships); len(keys) > 0 {
+				text += " (" + strings.Join(keys, ", ") + ")"
+			}
+			diagram.WriteString(fmt.Sprintf("<tr><td port=%q align=\"left\">%s</td></tr>", html.EscapeString(column.name), text))
+		}
+		diagram.WriteString("</table>>];\n")
+	}
+	for _, relationship := range relationships {
+		head := fmt.Sprintf("%q", erdTableName(relationship.referenced))
+		if relationship.referencedColumn != "" {
+			head += fmt.Sprintf(":%q", relationship.referencedColumn)
+		}
+		attributes := []string{}
+		if relationship.name != "" {
+			attributes = append(attributes, fmt.Sprintf("label=%q", relationship.name))
+		}
+		if relationship.optional {
+			attributes = append(attributes, "style=dashed")
+		}
+		edge := fmt.Sprintf("  %q:%q -> %s", erdTableName(relationship.table), relationship.columns[0], head)
+		if len(attributes) > 0 {
+			edge += fmt.Sprintf(" [%s]", strings.Join(attributes, ", "))
+		}
+		diagram.WriteString(edge + ";\n")
+	}
+	diagram.WriteString("}\n")
+	return diagram.String()
+}
@@ -4,11 +4,15 @@ package schema
 import (
 	"fmt"
 	"log"
+	"os"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/k0kubun/sqldef/adapter/postgres"
+	"github.com/k0kubun/sqldef/schema/batch"
+	"github.com/k0kubun/sqldef/sqlparser"
 )
 
 type GeneratorMode int
@@ -18,6 +22,8 @@ const (
 	GeneratorModePostgres
 	GeneratorModeSQLite3
 	GeneratorModeMssql
+	GeneratorModeCockroach
+	GeneratorModeRedshift
 )
 
 var (
@@ -26,9 +32,39 @@ var (
 		"int":     "integer",
 		"char":    "character",
 		"varchar": "character varying",
+		"int2":    "smallint",
+		"int4":    "integer",
+		"int8":    "bigint",
 	}
+	postgresDataTypeAliases = map[string]string{
+		"decimal": "numeric",
+		"float":   "double precision",
+		"float4":  "real",
+		"float8":  "double precision",
+	}
+	// REAL is DOUBLE unless REAL_AS_FLOAT is enabled
 	mysqlDataTypeAliases = map[string]string{
-		"boolean": "tinyint",
+		"boolean":          "tinyint",
+		"numeric":          "decimal",
+		"double precision": "double",
+		"real":             "double",
+		"float4":           "float",
+		"float8":           "double",
+	}
+	// A column printed as serial has a DEFAULT nextval() of its sequence
+	serialDataTypes = map[string]string{
+		"smallserial": "smallint",
+		"serial":      "integer",
+		"bigserial":   "bigint",
+	}
+	// INT is INT8 by default, and SERIAL becomes INT8 DEFAULT unique_rowid() with serial_normalization=rowid
+	cockroachDataTypeAliases = map[string]string{
+		"integer":     "bigint",
+		"int8":        "bigint",
+		"serial":      "bigserial",
+		"serial4":     "bigserial",
+		"serial8":     "bigserial",
+		"smallserial": "bigserial",
 	}
 )
 
@@ -46,20 +82,276 @@ type Generator struct {
 
 	desiredTypes []*Type
 	currentTypes []*Type
+
+	desiredSequences []*CreateSequence
+	currentSequences []*CreateSequence
+
+	safeTypeChange   bool
+	safeNotNull      bool
+	quoteIdentifiers string // "always", "auto", or "never"
+}
+
+// Options of GenerateIdempotentDDLs() given by the command line
+type GeneratorConfig struct {
+	targetTables        []*regexp.Regexp
+	skipTables          []*regexp.Regexp
+	managedTables       map[string]bool // nil unless --manifest is given
+	safeTypeChange      bool
+	safeNotNull         bool
+	quoteIdentifiers    string
+	foldIdentifiers     bool
+	lowerCaseTableNames bool
+	schema              string // a schema qualifying names of the desired schema instead of public
+}
+
+// Build GeneratorConfig from --target-table and --skip-table patterns, which are regular expressions matching whole table names
+func ParseGeneratorConfig(targetTables []string, skipTables []string) (GeneratorConfig, error) {
+	config := GeneratorConfig{}
+	var err error
+	if config.targetTables, err = compileTablePatterns("--target-table", targetTables); err != nil {
+		return config, err
+	}
+	if config.skipTables, err = compileTablePatterns("--skip-table", skipTables); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+func compileTablePatterns(option string, patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %s", option, pattern, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// Treat tables and views not in the list as foreign ones, which are never touched
+func (c GeneratorConfig) WithManagedTables(tables []string) GeneratorConfig {
+	c.managedTables = map[string]bool{}
+	for _, table := range tables {
+		c.managedTables[table] = true
+	}
+	return c
+}
+
+// Change column types by adding a new column, backfilling it in batches, and swapping them, instead of a blocking ALTER.
+// Only PostgreSQL and MySQL are supported.
+func (c GeneratorConfig) WithSafeTypeChange() GeneratorConfig {
+	c.safeTypeChange = true
+	return c
+}
+
+// Add NOT NULL columns without a default as nullable, backfill them by `-- @backfill expression`, and then set NOT NULL.
+// Only PostgreSQL, CockroachDB, and MySQL are supported.
+func (c GeneratorConfig) WithSafeNotNull() GeneratorConfig {
+	c.safeNotNull = true
+	return c
+}
+
+// Quote identifiers of generated DDLs "always", only when it's required by "auto", or "never".
+func (c GeneratorConfig) WithQuoteIdentifiers(quote string) GeneratorConfig {
+	c.quoteIdentifiers = quote
+	return c
+}
+
+// Lower unquoted identifiers of the desired schema as PostgreSQL does, so that `CREATE TABLE Users` matches the existing `users`.
+// Only PostgreSQL, CockroachDB, and Redshift are supported.
+func (c GeneratorConfig) WithFoldedIdentifiers() GeneratorConfig {
+	c.foldIdentifiers = true
+	return c
+}
+
+// Compare table names case-insensitively by lowering them in both schemas, for MySQL whose lower_case_table_names is not 0.
+func (c GeneratorConfig) WithLowerCaseTableNames() GeneratorConfig {
+	c.lowerCaseTableNames = true
+	return c
+}
+
+// Qualify names of the desired schema, which are parsed as ones in public unless qualified, with the schema instead,
+// so that the same schema is applied to each of schemas of tenants. Only PostgreSQL is supported.
+func (c GeneratorConfig) WithSchema(schema string) GeneratorConfig {
+	c.schema = schema
+	return c
+}
+
+// Return true if the table is never touched, i.e. not given by --target-table, given by --skip-table, or not in --manifest.
+func (c GeneratorConfig) SkipTable(table string) bool {
+	if c.managedTables != nil && !c.isManagedTable(table) {
+		return true
+	}
+	return c.skipDesiredTable(table)
+}
+
+// Tables in the desired schema are created even if they're not in --manifest yet
+func (c GeneratorConfig) skipDesiredTable(table string) bool {
+	if len(c.targetTables) > 0 && !matchTableName(c.targetTables, table) {
+		return true
+	}
+	return matchTableName(c.skipTables, table)
+}
+
+func (c GeneratorConfig) isManagedTable(table string) bool {
+	table, name := splitQualifiedTableName(table)
+	return c.managedTables[table] || c.managedTables[name]
+}
+
+func matchTableName(res []*regexp.Regexp, table string) bool {
+	table, name := splitQualifiedTableName(table)
+	for _, re := range res {
+		if re.MatchString(table) || re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the unquoted table name, and the one without a schema, so that the schema can be omitted in options.
+func splitQualifiedTableName(table string) (string, string) {
+	table = strings.ReplaceAll(table, "\"", "")
+	name := table
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		name = table[i+1:]
+	}
+	return table, name
+}
+
+// Remove DDLs for the tables not managed in this run
+func filterDDLs(ddls []DDL, skipTable func(table string) bool) []DDL {
+	filtered := []DDL{}
+	for _, ddl := range ddls {
+		tableName := ""
+		switch stmt := ddl.(type) {
+		case *CreateTable:
+			tableName = stmt.table.name
+		case *CreateIndex:
+			tableName = stmt.tableName
+		case *AddIndex:
+			tableName = stmt.tableName
+		case *AddPrimaryKey:
+			tableName = stmt.tableName
+		case *AddForeignKey:
+			tableName = stmt.tableName
+		case *AddPolicy:
+			tableName = stmt.tableName
+		case *AddComment:
+			tableName = stmt.tableName
+		case *DistributeTable:
+			tableName = stmt.tableName
+		case *Trigger:
+			tableName = stmt.tableName
+		case *View:
+			tableName = stmt.name
+		}
+		if tableName == "" || !skipTable(tableName) {
+			filtered = append(filtered, ddl)
+		}
+	}
+	return filtered
+}
+
+// Lower names of tables, and the ones referenced by foreign keys, as MySQL does with lower_case_table_names
+func lowerTableNames(ddls []DDL) {
+	lowerForeignKeys := func(foreignKeys []ForeignKey) {
+		for i := range foreignKeys {
+			foreignKeys[i].referenceName = strings.ToLower(foreignKeys[i].referenceName)
+		}
+	}
+	for _, ddl := range ddls {
+		switch stmt := ddl.(type) {
+		case *CreateTable:
+			stmt.table.name = strings.ToLower(stmt.table.name)
+			stmt.table.renamedFrom = strings.ToLower(stmt.table.renamedFrom)
+			for i := range stmt.table.columns {
+				stmt.table.columns[i].references = strings.ToLower(stmt.table.columns[i].references)
+			}
+			lowerForeignKeys(stmt.table.foreignKeys)
+		case *CreateIndex:
+			stmt.tableName = strings.ToLower(stmt.tableName)
+		case *AddIndex:
+			stmt.tableName = strings.ToLower(stmt.tableName)
+		case *AddPrimaryKey:
+			stmt.tableName = strings.ToLower(stmt.tableName)
+		case *AddForeignKey:
+			stmt.tableName = strings.ToLower(stmt.tableName)
+			stmt.foreignKey.referenceName = strings.ToLower(stmt.foreignKey.referenceName)
+		case *AddComment:
+			stmt.tableName = strings.ToLower(stmt.tableName)
+		case *Trigger:
+			stmt.tableName = strings.ToLower(stmt.tableName)
+		case *View:
+			stmt.name = strings.ToLower(stmt.name)
+		}
+	}
+}
+
+// Move names in public to the schema given by WithSchema
+func qualifyTableNames(ddls []DDL, schema string) {
+	qualify := func(name string) string {
+		if strings.HasPrefix(name, "public.") {
+			return schema + strings.TrimPrefix(name, "public")
+		}
+		return name
+	}
+	for _, ddl := range ddls {
+		switch stmt := ddl.(type) {
+		case *CreateTable:
+			stmt.table.name = qualify(stmt.table.name)
+			stmt.table.renamedFrom = qualify(stmt.table.renamedFrom)
+			for i := range stmt.table.columns {
+				stmt.table.columns[i].references = qualify(stmt.table.columns[i].references)
+			}
+			for i := range stmt.table.foreignKeys {
+				stmt.table.foreignKeys[i].referenceName = qualify(stmt.table.foreignKeys[i].referenceName)
+			}
+		case *CreateIndex:
+			stmt.tableName = qualify(stmt.tableName)
+		case *AddIndex:
+			stmt.tableName = qualify(stmt.tableName)
+		case *AddPrimaryKey:
+			stmt.tableName = qualify(stmt.tableName)
+		case *AddForeignKey:
+			stmt.tableName = qualify(stmt.tableName)
+			stmt.foreignKey.referenceName = qualify(stmt.foreignKey.referenceName)
+		case *AddPolicy:
+			stmt.tableName = qualify(stmt.tableName)
+		case *AddComment:
+			stmt.tableName = qualify(stmt.tableName)
+		case *DistributeTable:
+			stmt.tableName = qualify(stmt.tableName)
+		case *Trigger:
+			stmt.tableName = qualify(stmt.tableName)
+		case *View:
+			stmt.name = qualify(stmt.name)
+		case *Type:
+			stmt.name = qualify(stmt.name)
+		}
+	}
 }
 
 // Parse argument DDLs and call `generateDDLs()`
-func GenerateIdempotentDDLs(mode GeneratorMode, desiredSQL string, currentSQL string) ([]string, error) {
+func GenerateIdempotentDDLs(mode GeneratorMode, desiredSQL string, currentSQL string, config GeneratorConfig) ([]string, error) {
 	// TODO: invalidate duplicated tables, columns
-	desiredDDLs, err := ParseDDLs(mode, desiredSQL)
+	desiredDDLs, err := parseDDLs(mode, desiredSQL, config.foldIdentifiers)
 	if err != nil {
 		return nil, err
 	}
-
 	currentDDLs, err := ParseDDLs(mode, currentSQL)
 	if err != nil {
 		return nil, err
 	}
+	if config.lowerCaseTableNames {
+		lowerTableNames(desiredDDLs)
+		lowerTableNames(currentDDLs)
+	}
+	if config.schema != "" {
+		qualifyTableNames(desiredDDLs, config.schema)
+	}
+	desiredDDLs = sortDDLsByDependency(filterDDLs(desiredDDLs, config.skipDesiredTable))
+	currentDDLs = filterDDLs(currentDDLs, config.SkipTable)
 
 	tables, err := convertDDLsToTables(currentDDLs)
 	if err != nil {
@@ -69,31 +361,84 @@ func GenerateIdempotentDDLs(mode GeneratorMode, desiredSQL string, currentSQL st
 	views := convertDDLsToViews(currentDDLs)
 	triggers := convertDDLsToTriggers(currentDDLs)
 	types := convertDDLsToTypes(currentDDLs)
+	sequences := convertDDLsToSequences(currentDDLs)
 
 	generator := Generator{
-		mode:            mode,
-		desiredTables:   []*Table{},
-		currentTables:   tables,
-		desiredViews:    []*View{},
-		currentViews:    views,
-		desiredTriggers: []*Trigger{},
-		currentTriggers: triggers,
-		desiredTypes:    []*Type{},
-		currentTypes:    types,
+		mode:             mode,
+		desiredTables:    []*Table{},
+		currentTables:    tables,
+		desiredViews:     []*View{},
+		currentViews:     views,
+		desiredTriggers:  []*Trigger{},
+		currentTriggers:  triggers,
+		desiredTypes:     []*Type{},
+		currentTypes:     types,
+		desiredSequences: []*CreateSequence{},
+		currentSequences: sequences,
+		safeTypeChange:   config.safeTypeChange,
+		safeNotNull:      config.safeNotNull,
+		quoteIdentifiers: config.quoteIdentifiers,
 	}
 	return generator.generateDDLs(desiredDDLs)
 }
 
+// Return the names of tables and views created by the generated DDLs, which are recorded in --manifest
+func CreatedTableNames(mode GeneratorMode, ddls []string) []string {
+	names := []string{}
+	for _, ddl := range ddls {
+		parsed, err := parseDDL(mode, ddl, false)
+		if err != nil {
+			continue // not CREATE TABLE or CREATE VIEW
+		}
+		switch stmt := parsed.(type) {
+		case *CreateTable:
+			names = append(names, stmt.table.name)
+		case *View:
+			names = append(names, stmt.name)
+		}
+	}
+	return names
+}
+
 // Main part of DDL genearation
 func (g *Generator) generateDDLs(desiredDDLs []DDL) ([]string, error) {
 	ddls := []string{}
 
+	// Tables to be created, to find foreign keys to tables created later, which happen on cyclic references
+	newTables := newDependencyNames()
+	for i, ddl := range desiredDDLs {
+		if desired, ok := ddl.(*CreateTable); ok && findTableByName(g.currentTables, desired.table.name) == nil {
+			newTables.add(desired.table.name, i)
+		}
+	}
+	created := map[int]bool{}
+	createdLater := func(table string) bool {
+		for _, i := range newTables.lookup(table) {
+			if !created[i] {
+				return true
+			}
+		}
+		return false
+	}
+	var foreignKeyDDLs []string
+
 	// Incrementally examine desiredDDLs
-	for _, ddl := range desiredDDLs {
+	for i, ddl := range desiredDDLs {
 		switch desired := ddl.(type) {
 		case *CreateTable:
-			if currentTable := findTableByName(g.currentTables, desired.table.name); currentTable != nil {
+			ddls = append(ddls, g.generateDDLsForRenamedTable(desired.table)...)
+			if currentTable := findTableByName(g.currentTables, desired.table.name); currentTable != nil && g.mode == GeneratorModeMysql && !haveSameSingleStoreLayout(*currentTable, desired.table) {
+				// SingleStore can't change a shard key, a sort key, or a table type of an existing table.
+				ddls = append(ddls, fmt.Sprintf("DROP TABLE %s", g.escapeTableName(currentTable.name)))
+				ddls = append(ddls, desired.statement)
+				*currentTable = desired.table // copy table
+			} else if currentTable != nil {
 				// Table already exists, guess required DDLs.
+				renameDDLs, err := g.generateDDLsForRenamedColumns(currentTable, desired.table)
+				if err != nil {
+					return ddls, err
+				}
+				ddls = append(ddls, renameDDLs...)
 				tableDDLs, err := g.generateDDLsForCreateTable(*currentTable, *desired)
 				if err != nil {
 					return ddls, err
@@ -101,8 +446,11 @@ func (g *Generator) generateDDLs(desiredDDLs []DDL) ([]string, error) {
 				ddls = append(ddls, tableDDLs...)
 				mergeTable(currentTable, desired.table)
 			} else {
-				// Table not found, create table.
-				ddls = append(ddls, desired.statement)
+				// Table not found, create table. Foreign keys to tables created later are added after the tables.
+				created[i] = true
+				statement, deferredDDLs := g.deferForeignKeys(desired.statement, desired.table.name, createdLater)
+				ddls = append(ddls, statement)
+				foreignKeyDDLs = append(foreignKeyDDLs, deferredDDLs...)
 				table := desired.table // copy table
 				g.currentTables = append(g.currentTables, &table)
 			}
@@ -132,6 +480,24 @@ func (g *Generator) generateDDLs(desiredDDLs []DDL) ([]string, error) {
 				return ddls, err
 			}
 			ddls = append(ddls, policyDDLs...)
+		case *AddComment:
+			commentDDLs, err := g.generateDDLsForAddComment(desired.tableName, desired.comment, ddl.Statement())
+			if err != nil {
+				return ddls, err
+			}
+			ddls = append(ddls, commentDDLs...)
+		case *DistributeTable:
+			distributeDDLs, err := g.generateDDLsForDistributeTable(*desired)
+			if err != nil {
+				return ddls, err
+			}
+			ddls = append(ddls, distributeDDLs...)
+		case *CreateSequence:
+			sequenceDDLs, err := g.generateDDLsForCreateSequence(desired)
+			if err != nil {
+				return ddls, err
+			}
+			ddls = append(ddls, sequenceDDLs...)
 		case *View:
 			viewDDLs, err := g.generateDDLsForCreateView(desired.name, desired)
 			if err != nil {
@@ -154,9 +520,18 @@ func (g *Generator) generateDDLs(desiredDDLs []DDL) ([]string, error) {
 			return nil, fmt.Errorf("unexpected ddl type in generateDDLs: %v", desired)
 		}
 	}
+	ddls = append(ddls, foreignKeyDDLs...)
 
-	// Clean up obsoleted tables, indexes, columns
-	for _, currentTable := range g.currentTables {
+	// Clean up obsoleted views before tables they select from
+	for _, currentView := range sortViewsForDrop(g.currentViews) {
+		if containsString(convertViewNames(g.desiredViews), currentView.name) {
+			continue
+		}
+		ddls = append(ddls, fmt.Sprintf("DROP VIEW %s", g.escapeTableName(currentView.name)))
+	}
+
+	// Clean up obsoleted tables, indexes, columns. Tables referencing others are dropped first.
+	for _, currentTable := range sortTablesForDrop(g.currentTables) {
 		desiredTable := findTableByName(g.desiredTables, currentTable.name)
 		if desiredTable == nil {
 			// Obsoleted table found. Drop table.
@@ -213,6 +588,17 @@ func (g *Generator) generateDDLs(desiredDDLs []DDL) ([]string, error) {
 			ddls = append(ddls, fmt.Sprintf("DROP POLICY %s ON %s", g.escapeSQLName(policy.name), g.escapeTableName(currentTable.name)))
 		}
 
+		// Check comments. PostgreSQL's comments not in the desired schema are left, which may be given by other tools.
+		for _, comment := range currentTable.comments {
+			if g.mode != GeneratorModeMssql || findCommentByColumnName(desiredTable.comments, comment.columnName) != nil {
+				continue
+			}
+			if comment.columnName != "" && !containsString(convertColumnsToColumnNames(desiredTable.columns), comment.columnName) {
+				continue // The extended property is dropped with the column.
+			}
+			ddls = append(ddls, generateExtendedProperty("sp_dropextendedproperty", currentTable.name, comment, false))
+		}
+
 		// Check checks.
 		for _, check := range currentTable.checks {
 			if containsString(convertCheckConstraintNames(desiredTable.checks), check.constraintName) {
@@ -222,14 +608,19 @@ func (g *Generator) generateDDLs(desiredDDLs []DDL) ([]string, error) {
 				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", g.escapeTableName(currentTable.name), g.escapeSQLName(check.constraintName)))
 			}
 		}
+
+		// Check Citus distribution.
+		if currentTable.citusTableType != "" && desiredTable.citusTableType == "" {
+			ddls = append(ddls, fmt.Sprintf("SELECT undistribute_table(%s)", g.citusTableName(currentTable.name)))
+		}
 	}
 
-	// Clean up obsoleted views
-	for _, currentView := range g.currentViews {
-		if containsString(convertViewNames(g.desiredViews), currentView.name) {
+	// Clean up obsoleted sequences
+	for _, currentSequence := range g.currentSequences {
+		if findSequenceByName(g.desiredSequences, currentSequence.sequence.Name) != nil {
 			continue
 		}
-		ddls = append(ddls, fmt.Sprintf("DROP VIEW %s", g.escapeTableName(currentView.name)))
+		ddls = append(ddls, fmt.Sprintf("DROP SEQUENCE %s", g.escapeSQLName(currentSequence.sequence.Name)))
 	}
 
 	return ddls, nil
@@ -249,9 +640,209 @@ func (g *Generator) generateDDLsForAbsentColumn(currentTable *Table, columnName
 	}
 
 	ddl := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", g.escapeTableName(currentTable.name), g.escapeSQLName(columnName))
+	if currentTable.citusTableType == "distributed" && currentTable.citusColumn == columnName {
+		warnNonPropagatableDDL(ddl, "the distribution column can't be dropped")
+	}
 	return append(ddls, ddl)
 }
 
+// Number of rows updated by each backfill of --safe-type-change
+const backfillBatchSize = 1000
+
+// Return true if the column type can be changed by --safe-type-change. Otherwise, warn it.
+func (g *Generator) useSafeTypeChange(table Table, column Column) bool {
+	if !g.safeTypeChange || (g.mode != GeneratorModePostgres && g.mode != GeneratorModeMysql) {
+		return false
+	}
+
+	// Indexes, constraints, and sequences of the column would be lost with the old column
+	used := isPrimaryKey(column, table) || column.keyOption != ColumnKeyNone || column.check != nil || column.identity != nil || column.autoIncrement ||
+		containsString(table.distributionKeys, column.name) || table.citusColumn == column.name
+	for _, index := range table.indexes {
+		for _, indexColumn := range index.columns {
+			used = used || indexColumn.column == column.name
+		}
+	}
+	for _, foreignKey := range table.foreignKeys {
+		used = used || containsString(foreignKey.indexColumns, column.name)
+	}
+	if used {
+		fmt.Fprintf(os.Stderr, "-- WARNING: --safe-type-change can't change the type of '%s.%s' used by an index or a constraint --\n", table.name, column.name)
+	}
+	return !used
+}
+
+// Add a new column, backfill it in batches, swap their names, and drop the old column, which is skipped without --enable-drop.
+func (g *Generator) generateDDLsForSafeTypeChange(table Table, current Column, desired Column) ([]string, error) {
+	tableName := g.escapeTableName(table.name)
+	newName := g.escapeSQLName(desired.name + "__new")
+	oldName := g.escapeSQLName(desired.name + "__old")
+	name := g.escapeSQLName(desired.name)
+	ddls := []string{}
+
+	switch g.mode {
+	case GeneratorModeMysql:
+		// The new column must be nullable without a default to find rows to backfill
+		newColumn := desired
+		newColumn.name = desired.name + "__new"
+		newColumn.notNull = nil
+		newColumn.defaultDef = nil
+		newColumn.onUpdate = nil
+		newDefinition, err := g.generateColumnDefinition(newColumn, false)
+		if err != nil {
+			return ddls, err
+		}
+		current.name = desired.name + "__old"
+		oldDefinition, err := g.generateColumnDefinition(current, false)
+		if err != nil {
+			return ddls, err
+		}
+		definition, err := g.generateColumnDefinition(desired, false)
+		if err != nil {
+			return ddls, err
+		}
+		ddls = append(ddls,
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s AFTER %s", tableName, newDefinition, name),
+			fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL AND %s IS NOT NULL LIMIT %d", tableName, newName, name, newName, name, backfillBatchSize)+batch.Suffix,
+			fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s", tableName, name, oldDefinition),
+			fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s", tableName, newName, definition),
+		)
+	default:
+		dataType := generateDataType(desired)
+		ddls = append(ddls,
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, newName, dataType),
+			fmt.Sprintf("UPDATE %s SET %s = CAST(%s AS %s) WHERE ctid IN (SELECT ctid FROM %s WHERE %s IS NULL AND %s IS NOT NULL LIMIT %d)",
+				tableName, newName, name, dataType, tableName, newName, name, backfillBatchSize)+batch.Suffix,
+			fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", tableName, name, oldName),
+			fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", tableName, newName, name),
+		)
+	}
+	return append(ddls, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, oldName)), nil
+}
+
+// UPDATE statements with LIMIT update rows in batches, which are repeated until they update no row
+var limitedDMLRegexp = regexp.MustCompile(`(?i)\sLIMIT\s+\d+`)
+
+// Return the UPDATE statement annotated by `-- sqldef:backfill` to run after adding the column
+func backfillDML(column Column) string {
+	if limitedDMLRegexp.MatchString(column.backfillDML) {
+		return column.backfillDML + batch.Suffix
+	}
+	return column.backfillDML
+}
+
+// Return true if the new column should be added as nullable and then set NOT NULL by --safe-not-null or `-- sqldef:backfill`
+func (g *Generator) useSafeNotNull(table Table, column Column) bool {
+	if !(g.safeNotNull || column.backfillDML != "") || (g.mode != GeneratorModePostgres && g.mode != GeneratorModeCockroach && g.mode != GeneratorModeMysql) {
+		return false
+	}
+	// Columns filled by the database can be added as NOT NULL without failing
+	return column.notNull != nil && *column.notNull && column.defaultDef == nil && column.identity == nil && !column.autoIncrement && !isPrimaryKey(column, table)
+}
+
+// Backfill the column added as nullable by `-- sqldef:backfill UPDATE ...` or `-- @backfill expression`, and set NOT NULL to it.
+func (g *Generator) generateDDLsForSafeNotNull(table Table, column Column) ([]string, error) {
+	ddls := []string{}
+	if column.backfillDML != "" {
+		ddls = append(ddls, backfillDML(column))
+	} else if column.backfill != "" {
+		ddls = append(ddls, fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL", g.escapeTableName(table.name), g.escapeSQLName(column.name), column.backfill, g.escapeSQLName(column.name)))
+	} else {
+		fmt.Fprintf(os.Stderr, "-- WARNING: '%s.%s' is not backfilled without '-- @backfill expression', so SET NOT NULL fails if the table has rows --\n", table.name, column.name)
+	}
+
+	switch g.mode {
+	case GeneratorModeMysql:
+		definition, err := g.generateColumnDefinition(column, false)
+		if err != nil {
+			return ddls, err
+		}
+		ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s", g.escapeTableName(table.name), g.escapeSQLName(column.name), definition))
+	default:
+		ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", g.escapeTableName(table.name), g.escapeSQLName(column.name)))
+	}
+	return ddls, nil
+}
+
+// Rename a table annotated by `-- @renamed from=old_name` instead of dropping and creating it, which loses data.
+// g.currentTables is updated as renamed, so that the table is compared with the desired one.
+func (g *Generator) generateDDLsForRenamedTable(desired Table) []string {
+	if desired.renamedFrom == "" || findTableByName(g.currentTables, desired.name) != nil {
+		return nil // not renamed, or already renamed
+	}
+	currentTable := findTableByName(g.currentTables, desired.renamedFrom)
+	if currentTable == nil {
+		return nil
+	}
+
+	// The new name can't be qualified by a schema
+	name := desired.name[strings.LastIndex(desired.name, ".")+1:]
+	var ddl string
+	switch g.mode {
+	case GeneratorModeMysql:
+		ddl = fmt.Sprintf("RENAME TABLE %s TO %s", g.escapeTableName(currentTable.name), g.escapeTableName(desired.name))
+	case GeneratorModeMssql:
+		ddl = fmt.Sprintf("EXEC sp_rename %s, %s", escapeStringLiteral(g.escapeTableName(currentTable.name)), escapeStringLiteral(name))
+	default:
+		ddl = fmt.Sprintf("ALTER TABLE %s RENAME TO %s", g.escapeTableName(currentTable.name), g.escapeSQLName(name))
+	}
+	currentTable.name = desired.name
+	return []string{ddl}
+}
+
+// Rename columns annotated by `-- @renamed from=old_name` instead of dropping and adding them, which loses data.
+// currentTable is updated as renamed, so that the rest of the columns are compared with the desired ones.
+func (g *Generator) generateDDLsForRenamedColumns(currentTable *Table, desired Table) ([]string, error) {
+	ddls := []string{}
+	for _, desiredColumn := range desired.columns {
+		if desiredColumn.renamedFrom == "" || findColumnByName(currentTable.columns, desiredColumn.name) != nil {
+			continue // not renamed, or already renamed
+		}
+		for i, currentColumn := range currentTable.columns {
+			if currentColumn.name != desiredColumn.renamedFrom {
+				continue
+			}
+
+			switch g.mode {
+			case GeneratorModeMysql:
+				// RENAME COLUMN is not supported by MySQL 5.7
+				currentColumn.name = desiredColumn.name
+				definition, err := g.generateColumnDefinition(currentColumn, false)
+				if err != nil {
+					return ddls, err
+				}
+				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s", g.escapeTableName(desired.name), g.escapeSQLName(desiredColumn.renamedFrom), definition))
+			case GeneratorModeMssql:
+				column := g.escapeTableName(desired.name) + "." + g.escapeSQLName(desiredColumn.renamedFrom)
+				ddls = append(ddls, fmt.Sprintf("EXEC sp_rename %s, %s, 'COLUMN'", escapeStringLiteral(column), escapeStringLiteral(desiredColumn.name)))
+			default:
+				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", g.escapeTableName(desired.name), g.escapeSQLName(desiredColumn.renamedFrom), g.escapeSQLName(desiredColumn.name)))
+			}
+			currentTable.columns[i].name = desiredColumn.name
+			renameColumnReferences(currentTable, desiredColumn.renamedFrom, desiredColumn.name)
+		}
+	}
+	return ddls, nil
+}
+
+// Databases rename columns in indexes and foreign keys as well
+func renameColumnReferences(table *Table, from string, to string) {
+	for i := range table.indexes {
+		for j := range table.indexes[i].columns {
+			if table.indexes[i].columns[j].column == from {
+				table.indexes[i].columns[j].column = to
+			}
+		}
+	}
+	for i := range table.foreignKeys {
+		for j, column := range table.foreignKeys[i].indexColumns {
+			if column == from {
+				table.foreignKeys[i].indexColumns[j] = to
+			}
+		}
+	}
+}
+
 // In the caller, `mergeTable` manages `g.currentTables`.
 func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired CreateTable) ([]string, error) {
 	ddls := []string{}
@@ -264,6 +855,10 @@ func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired Creat
 			desiredColumn.autoIncrement = false
 		}
 		if currentColumn == nil {
+			nullable := g.useSafeNotNull(desired.table, desiredColumn)
+			if nullable {
+				desiredColumn.notNull = nil
+			}
 			definition, err := g.generateColumnDefinition(desiredColumn, true)
 			if err != nil {
 				return ddls, err
@@ -287,6 +882,15 @@ func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired Creat
 			}
 
 			ddls = append(ddls, ddl)
+			if nullable {
+				notNullDDLs, err := g.generateDDLsForSafeNotNull(desired.table, desired.table.columns[i])
+				if err != nil {
+					return ddls, err
+				}
+				ddls = append(ddls, notNullDDLs...)
+			} else if desiredColumn.backfillDML != "" {
+				ddls = append(ddls, backfillDML(desiredColumn))
+			}
 		} else {
 			// Change column data type or order as needed.
 			switch g.mode {
@@ -296,7 +900,13 @@ func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired Creat
 				changeOrder := currentPos > desiredPos && currentPos-desiredPos > len(currentTable.columns)-len(desired.table.columns)
 
 				// Change column type and orders, *except* AUTO_INCREMENT and UNIQUE KEY.
-				if !g.haveSameColumnDefinition(*currentColumn, desiredColumn) || !areSameDefaultValue(currentColumn.defaultDef, desiredColumn.defaultDef) || changeOrder {
+				if !g.haveSameDataType(*currentColumn, desiredColumn) && g.useSafeTypeChange(currentTable, *currentColumn) {
+					typeDDLs, err := g.generateDDLsForSafeTypeChange(desired.table, *currentColumn, desiredColumn)
+					if err != nil {
+						return ddls, err
+					}
+					ddls = append(ddls, typeDDLs...)
+				} else if !g.haveSameColumnDefinition(*currentColumn, desiredColumn) || !g.areSameDefaultValue(*currentColumn, desiredColumn) || changeOrder {
 					definition, err := g.generateColumnDefinition(desiredColumn, false)
 					if err != nil {
 						return ddls, err
@@ -319,11 +929,47 @@ func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired Creat
 					ddl := fmt.Sprintf("ALTER TABLE %s ADD UNIQUE KEY %s(%s)", g.escapeTableName(desired.table.name), g.escapeSQLName(desiredColumn.name), g.escapeSQLName(desiredColumn.name))
 					ddls = append(ddls, ddl)
 				}
-			case GeneratorModePostgres:
+			case GeneratorModeRedshift:
+				// Redshift can alter only the length of VARCHAR and the compression encoding
+				if !g.haveSameDataType(*currentColumn, desiredColumn) {
+					ddl := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", g.escapeTableName(desired.table.name), g.escapeSQLName(currentColumn.name), generateDataType(desiredColumn))
+					ddls = append(ddls, ddl)
+				}
+				if desiredColumn.encoding != "" && currentColumn.encoding != desiredColumn.encoding {
+					ddl := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ENCODE %s", g.escapeTableName(desired.table.name), g.escapeSQLName(currentColumn.name), desiredColumn.encoding)
+					ddls = append(ddls, ddl)
+				}
+			case GeneratorModePostgres, GeneratorModeCockroach:
 				if !g.haveSameDataType(*currentColumn, desiredColumn) {
+					// PostgreSQL can't change the type of a column used by a view
+					ddls = append(ddls, g.generateDDLsForDependentViews(currentTable.name)...)
+				}
+				if !g.haveSameDataType(*currentColumn, desiredColumn) && g.useSafeTypeChange(currentTable, *currentColumn) {
+					typeDDLs, err := g.generateDDLsForSafeTypeChange(desired.table, *currentColumn, desiredColumn)
+					if err != nil {
+						return ddls, err
+					}
+					ddls = append(ddls, typeDDLs...)
+					// NOT NULL and DEFAULT are set to the new column below
+					currentColumn.notNull = nil
+					currentColumn.defaultDef = nil
+				} else if !g.haveSameDataType(*currentColumn, desiredColumn) {
+					// Greenplum can't change the type of a column used by the distribution policy
+					distributionKey := containsString(currentTable.distributionKeys, currentColumn.name)
+					if distributionKey {
+						ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s SET DISTRIBUTED RANDOMLY", g.escapeTableName(desired.table.name)))
+					}
+
 					// Change type
 					ddl := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", g.escapeTableName(desired.table.name), g.escapeSQLName(currentColumn.name), generateDataType(desiredColumn))
 					ddls = append(ddls, ddl)
+					if currentTable.citusTableType == "distributed" && currentTable.citusColumn == currentColumn.name {
+						warnNonPropagatableDDL(ddl, "the distribution column can't be altered")
+					}
+
+					if distributionKey {
+						ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s SET DISTRIBUTED %s", g.escapeTableName(desired.table.name), g.generateDistribution(currentTable)))
+					}
 				}
 
 				if !isPrimaryKey(*currentColumn, currentTable) { // Primary Key implies NOT NULL
@@ -354,7 +1000,7 @@ func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired Creat
 				}
 
 				// default
-				if !areSameDefaultValue(currentColumn.defaultDef, desiredColumn.defaultDef) {
+				if !g.areSameDefaultValue(*currentColumn, desiredColumn) {
 					if desiredColumn.defaultDef == nil {
 						// drop
 						ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT", g.escapeTableName(currentTable.name), g.escapeSQLName(currentColumn.name)))
@@ -435,7 +1081,7 @@ func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired Creat
 				}
 
 				// DEFAULT
-				if !areSameDefaultValue(currentColumn.defaultDef, desiredColumn.defaultDef) {
+				if !g.areSameDefaultValue(*currentColumn, desiredColumn) {
 					if currentColumn.defaultDef != nil {
 						// drop
 						ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", g.escapeTableName(currentTable.name), g.escapeSQLName(currentColumn.defaultDef.constraintName)))
@@ -478,12 +1124,22 @@ func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired Creat
 	// Examine primary key
 	currentPrimaryKey := currentTable.PrimaryKey()
 	desiredPrimaryKey := desired.table.PrimaryKey()
-	if !areSamePrimaryKeys(currentPrimaryKey, desiredPrimaryKey) {
+	if !areSamePrimaryKeys(currentPrimaryKey, desiredPrimaryKey) && g.mode == GeneratorModeCockroach && currentPrimaryKey != nil {
+		// CockroachDB doesn't allow a table without a primary key, but it can swap it online.
+		if desiredPrimaryKey == nil {
+			return ddls, fmt.Errorf("dropping the primary key of '%s' is not supported by CockroachDB", desired.table.name)
+		}
+		columns := []string{}
+		for _, indexColumn := range desiredPrimaryKey.columns {
+			columns = append(columns, g.escapeSQLName(indexColumn.column))
+		}
+		ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER PRIMARY KEY USING COLUMNS (%s)", g.escapeTableName(desired.table.name), strings.Join(columns, ", ")))
+	} else if !areSamePrimaryKeys(currentPrimaryKey, desiredPrimaryKey) {
 		if currentPrimaryKey != nil {
 			switch g.mode {
 			case GeneratorModeMysql:
 				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY", g.escapeTableName(desired.table.name)))
-			case GeneratorModePostgres:
+			case GeneratorModePostgres, GeneratorModeRedshift:
 				tableName := strings.SplitN(desired.table.name, ".", 2)[1] // without schema
 				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", g.escapeTableName(desired.table.name), g.escapeSQLName(tableName+"_pkey")))
 			default:
@@ -543,7 +1199,7 @@ func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired Creat
 				switch g.mode {
 				case GeneratorModeMysql:
 					dropDDL = fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", g.escapeTableName(desired.table.name), g.escapeSQLName(currentForeignKey.constraintName))
-				case GeneratorModePostgres, GeneratorModeMssql:
+				case GeneratorModePostgres, GeneratorModeMssql, GeneratorModeCockroach, GeneratorModeRedshift:
 					dropDDL = fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", g.escapeTableName(desired.table.name), g.escapeSQLName(currentForeignKey.constraintName))
 				default:
 				}
@@ -559,12 +1215,46 @@ func (g *Generator) generateDDLsForCreateTable(currentTable Table, desired Creat
 		}
 	}
 
+	// Examine Greenplum's distribution policy. It's changed only when it's specified.
+	if g.mode == GeneratorModePostgres && desired.table.distribution != "" &&
+		(currentTable.distribution != desired.table.distribution || !reflect.DeepEqual(currentTable.distributionKeys, desired.table.distributionKeys)) {
+		ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s SET DISTRIBUTED %s", g.escapeTableName(desired.table.name), g.generateDistribution(desired.table)))
+	}
+
+	// Examine distribution style and sort key
+	if g.mode == GeneratorModeRedshift {
+		if currentTable.distStyle != desired.table.distStyle || currentTable.distKey != desired.table.distKey {
+			switch desired.table.distStyle {
+			case "":
+				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER DISTSTYLE AUTO", g.escapeTableName(desired.table.name)))
+			case "KEY":
+				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER DISTSTYLE KEY DISTKEY %s", g.escapeTableName(desired.table.name), g.escapeSQLName(desired.table.distKey)))
+			default:
+				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER DISTSTYLE %s", g.escapeTableName(desired.table.name), desired.table.distStyle))
+			}
+		}
+		if currentTable.sortStyle != desired.table.sortStyle || !reflect.DeepEqual(currentTable.sortKeys, desired.table.sortKeys) {
+			if currentTable.sortStyle == "INTERLEAVED" || desired.table.sortStyle == "INTERLEAVED" {
+				return ddls, fmt.Errorf("changing an INTERLEAVED SORTKEY of '%s' is not supported by Redshift", desired.table.name)
+			}
+			if len(desired.table.sortKeys) == 0 {
+				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER SORTKEY AUTO", g.escapeTableName(desired.table.name)))
+			} else {
+				sortKeys := []string{}
+				for _, sortKey := range desired.table.sortKeys {
+					sortKeys = append(sortKeys, g.escapeSQLName(sortKey))
+				}
+				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER COMPOUND SORTKEY (%s)", g.escapeTableName(desired.table.name), strings.Join(sortKeys, ", ")))
+			}
+		}
+	}
+
 	// Examine each check
 	for _, desiredCheck := range desired.table.checks {
 		if currentCheck := findCheckByName(currentTable.checks, desiredCheck.constraintName); currentCheck != nil {
 			if !areSameCheckDefinition(currentCheck, &desiredCheck) {
 				switch g.mode {
-				case GeneratorModePostgres:
+				case GeneratorModePostgres, GeneratorModeCockroach, GeneratorModeRedshift:
 					ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", g.escapeTableName(desired.table.name), g.escapeSQLName(currentCheck.constraintName)))
 					ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s)", g.escapeTableName(desired.table.name), g.escapeSQLName(desiredCheck.constraintName), desiredCheck.definition))
 				default:
@@ -588,6 +1278,11 @@ func (g *Generator) generateDDLsForCreateIndex(tableName string, desiredIndex In
 		return nil, fmt.Errorf("%s is performed for inexistent table '%s': '%s'", action, tableName, statement)
 	}
 
+	if currentTable.citusTableType == "distributed" && (desiredIndex.primary || desiredIndex.unique) &&
+		!containsString(convertIndexColumnsToColumnNames(desiredIndex.columns), currentTable.citusColumn) {
+		warnNonPropagatableDDL(statement, "a unique index of a distributed table must include the distribution column")
+	}
+
 	currentIndex := findIndexByName(currentTable.indexes, desiredIndex.name)
 	if currentIndex == nil {
 		// Index not found, add index.
@@ -676,6 +1371,76 @@ func (g *Generator) generateDDLsForCreatePolicy(tableName string, desiredPolicy
 	return ddls, nil
 }
 
+func (g *Generator) generateDDLsForAddComment(tableName string, desiredComment Comment, statement string) ([]string, error) {
+	var ddls []string
+
+	currentTable := findTableByName(g.currentTables, tableName)
+	if currentTable == nil {
+		return nil, fmt.Errorf("a comment is added to inexistent table '%s': '%s'", tableName, statement)
+	}
+
+	currentComment := findCommentByColumnName(currentTable.comments, desiredComment.columnName)
+	if currentComment == nil {
+		// Comment not found, add comment.
+		ddls = append(ddls, statement)
+		currentTable.comments = append(currentTable.comments, desiredComment)
+	} else if currentComment.value != desiredComment.value {
+		if g.mode == GeneratorModeMssql {
+			ddls = append(ddls, generateExtendedProperty("sp_updateextendedproperty", currentTable.name, desiredComment, true))
+		} else {
+			ddls = append(ddls, statement) // COMMENT ON replaces the comment
+		}
+	}
+
+	// Examine comments in desiredTable to delete obsoleted comments later
+	desiredTable := findTableByName(g.desiredTables, tableName)
+	if desiredTable == nil {
+		return nil, fmt.Errorf("a comment is added before create table '%s': '%s'", tableName, statement)
+	}
+	if findCommentByColumnName(desiredTable.comments, desiredComment.columnName) != nil {
+		return nil, fmt.Errorf("a comment is doubly added against table '%s': '%s'", tableName, statement)
+	}
+	desiredTable.comments = append(desiredTable.comments, desiredComment)
+
+	return ddls, nil
+}
+
+func (g *Generator) generateDDLsForDistributeTable(desired DistributeTable) ([]string, error) {
+	var ddls []string
+	function := fmt.Sprintf("create_%s_table", desired.tableType)
+
+	currentTable := findTableByName(g.currentTables, desired.tableName)
+	if currentTable == nil {
+		return nil, fmt.Errorf("%s is performed for inexistent table '%s': '%s'", function, desired.tableName, desired.statement)
+	}
+
+	if currentTable.citusTableType == "" {
+		ddls = append(ddls, desired.statement)
+	} else if currentTable.citusTableType == "distributed" && desired.tableType == "distributed" {
+		if currentTable.citusColumn != desired.distributionColumn {
+			ddls = append(ddls, fmt.Sprintf("SELECT alter_distributed_table(%s, distribution_column := %s)", g.citusTableName(currentTable.name), escapeStringLiteral(desired.distributionColumn)))
+		}
+	} else if currentTable.citusTableType != desired.tableType {
+		ddls = append(ddls, fmt.Sprintf("SELECT undistribute_table(%s)", g.citusTableName(currentTable.name)))
+		ddls = append(ddls, desired.statement)
+	}
+	currentTable.citusTableType = desired.tableType
+	currentTable.citusColumn = desired.distributionColumn
+
+	// Examine distribution in desiredTable to undistribute obsoleted ones later
+	desiredTable := findTableByName(g.desiredTables, desired.tableName)
+	if desiredTable == nil {
+		return nil, fmt.Errorf("%s is performed before create table '%s': '%s'", function, desired.tableName, desired.statement)
+	}
+	if desiredTable.citusTableType != "" {
+		return nil, fmt.Errorf("table '%s' is doubly distributed: '%s'", desired.tableName, desired.statement)
+	}
+	desiredTable.citusTableType = desired.tableType
+	desiredTable.citusColumn = desired.distributionColumn
+
+	return ddls, nil
+}
+
 func (g *Generator) generateDDLsForCreateView(viewName string, desiredView *View) ([]string, error) {
 	var ddls []string
 
@@ -704,6 +1469,17 @@ func (g *Generator) generateDDLsForCreateView(viewName string, desiredView *View
 	return ddls, nil
 }
 
+// Drop views selecting from a table before changing its columns. They're recreated from the desired schema
+// since they're removed from currentViews, and views not in the desired schema are not dropped again.
+func (g *Generator) generateDDLsForDependentViews(tableName string) []string {
+	var ddls []string
+	for _, view := range dependentViews(g.currentViews, tableName) {
+		ddls = append(ddls, fmt.Sprintf("DROP VIEW %s", g.escapeTableName(view.name)))
+		g.currentViews = removeViewByName(g.currentViews, view.name)
+	}
+	return ddls
+}
+
 func (g *Generator) generateDDLsForCreateTrigger(triggerName string, desiredTrigger *Trigger) ([]string, error) {
 	var ddls []string
 	currentTrigger := findTriggerByName(g.currentTriggers, triggerName)
@@ -752,6 +1528,54 @@ func (g *Generator) generateDDLsForCreateType(desired *Type) ([]string, error) {
 	return ddls, nil
 }
 
+func (g *Generator) generateDDLsForCreateSequence(desired *CreateSequence) ([]string, error) {
+	ddls := []string{}
+
+	if findSequenceByName(g.desiredSequences, desired.sequence.Name) != nil {
+		return nil, fmt.Errorf("sequence '%s' is doubly created: '%s'", desired.sequence.Name, desired.statement)
+	}
+	g.desiredSequences = append(g.desiredSequences, desired)
+
+	currentSequence := findSequenceByName(g.currentSequences, desired.sequence.Name)
+	if currentSequence == nil {
+		// Sequence not found, add sequence.
+		ddls = append(ddls, desired.statement)
+		return ddls, nil
+	}
+
+	// Sequence found. Alter only changed options, since recreating it would reset its value.
+	current := normalizeMariadbSequence(currentSequence.sequence)
+	target := normalizeMariadbSequence(desired.sequence)
+	var clauses []string
+	if *current.IncrementBy != *target.IncrementBy {
+		clauses = append(clauses, fmt.Sprintf("INCREMENT BY %d", *target.IncrementBy))
+	}
+	if *current.MinValue != *target.MinValue {
+		clauses = append(clauses, fmt.Sprintf("MINVALUE %d", *target.MinValue))
+	}
+	if *current.MaxValue != *target.MaxValue {
+		clauses = append(clauses, fmt.Sprintf("MAXVALUE %d", *target.MaxValue))
+	}
+	if *current.StartWith != *target.StartWith {
+		clauses = append(clauses, fmt.Sprintf("START WITH %d", *target.StartWith))
+	}
+	if *current.Cache != *target.Cache {
+		clauses = append(clauses, fmt.Sprintf("CACHE %d", *target.Cache))
+	}
+	if current.Cycle != target.Cycle {
+		if target.Cycle {
+			clauses = append(clauses, "CYCLE")
+		} else {
+			clauses = append(clauses, "NOCYCLE")
+		}
+	}
+	if len(clauses) > 0 {
+		ddls = append(ddls, fmt.Sprintf("ALTER SEQUENCE %s %s", g.escapeSQLName(desired.sequence.Name), strings.Join(clauses, " ")))
+	}
+
+	return ddls, nil
+}
+
 // Even though simulated table doesn't have a foreign key, references could exist in column definitions.
 // This carefully generates DROP CONSTRAINT for such situations.
 func (g *Generator) generateDDLsForAbsentForeignKey(currentForeignKey ForeignKey, currentTable Table, desiredTable Table) []string {
@@ -760,7 +1584,7 @@ func (g *Generator) generateDDLsForAbsentForeignKey(currentForeignKey ForeignKey
 	switch g.mode {
 	case GeneratorModeMysql:
 		ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", g.escapeTableName(currentTable.name), g.escapeSQLName(currentForeignKey.constraintName)))
-	case GeneratorModePostgres, GeneratorModeMssql:
+	case GeneratorModePostgres, GeneratorModeMssql, GeneratorModeCockroach, GeneratorModeRedshift:
 		var referencesColumn *Column
 		for _, column := range desiredTable.columns {
 			if column.references == currentForeignKey.referenceName {
@@ -888,12 +1712,20 @@ func (g *Generator) generateColumnDefinition(column Column, enableUnique bool) (
 		definition += "AUTO_INCREMENT "
 	}
 
+	if column.encoding != "" {
+		definition += fmt.Sprintf("ENCODE %s ", column.encoding)
+	}
+
 	if column.onUpdate != nil {
 		definition += fmt.Sprintf("ON UPDATE %s ", string(column.onUpdate.raw))
 	}
 
 	if column.comment != nil {
-		definition += fmt.Sprintf("COMMENT '%s' ", string(column.comment.raw))
+		comment := string(column.comment.raw)
+		if g.mode == GeneratorModeMysql {
+			comment = strings.ReplaceAll(comment, `\`, `\\`)
+		}
+		definition += fmt.Sprintf("COMMENT %s ", escapeStringLiteral(comment))
 	}
 
 	if column.check != nil {
@@ -1084,17 +1916,69 @@ func (g *Generator) generateForeignKeyDefinition(foreignKey ForeignKey) string {
 	return strings.TrimSuffix(definition, " ")
 }
 
+// Generate a call of sp_addextendedproperty, sp_updateextendedproperty or sp_dropextendedproperty for MS_Description.
+func generateExtendedProperty(procedure string, table string, comment Comment, withValue bool) string {
+	schemaName, tableName := "dbo", table
+	if schemaTable := strings.SplitN(table, ".", 2); len(schemaTable) == 2 {
+		schemaName, tableName = schemaTable[0], schemaTable[1]
+	}
+	if comment.schemaName != "" {
+		schemaName = comment.schemaName
+	}
+
+	args := []string{"@name = N'MS_Description'"}
+	if withValue {
+		args = append(args, fmt.Sprintf("@value = N'%s'", strings.ReplaceAll(comment.value, "'", "''")))
+	}
+	args = append(args,
+		fmt.Sprintf("@level0type = N'SCHEMA', @level0name = N'%s'", schemaName),
+		fmt.Sprintf("@level1type = N'TABLE', @level1name = N'%s'", tableName),
+	)
+	if comment.columnName != "" {
+		args = append(args, fmt.Sprintf("@level2type = N'COLUMN', @level2name = N'%s'", comment.columnName))
+	}
+	return fmt.Sprintf("EXEC %s %s", procedure, strings.Join(args, ", "))
+}
+
+// Quote a table name as a regclass literal for Citus functions
+func (g *Generator) citusTableName(table string) string {
+	return escapeStringLiteral(g.escapeTableName(table))
+}
+
+// Citus fails to run some DDLs against distributed tables since it can't propagate them to workers.
+func warnNonPropagatableDDL(ddl string, reason string) {
+	fmt.Fprintf(os.Stderr, "-- WARNING: Citus can't propagate '%s' to workers: %s\n", ddl, reason)
+}
+
+func (g *Generator) generateDistribution(table Table) string {
+	if table.distribution != "BY" {
+		return table.distribution
+	}
+	keys := []string{}
+	for _, key := range table.distributionKeys {
+		keys = append(keys, g.escapeSQLName(key))
+	}
+	return fmt.Sprintf("BY (%s)", strings.Join(keys, ", "))
+}
+
 func (g *Generator) generateDropIndex(tableName string, indexName string, constraint bool) string {
 	switch g.mode {
 	case GeneratorModeMysql:
 		return fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", g.escapeTableName(tableName), g.escapeSQLName(indexName))
-	case GeneratorModePostgres:
+	case GeneratorModePostgres, GeneratorModeRedshift:
 		if constraint {
 			return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", g.escapeTableName(tableName), g.escapeSQLName(indexName))
 		} else {
 			schema, _ := postgres.SplitTableName(tableName)
 			return fmt.Sprintf("DROP INDEX %s.%s", g.escapeSQLName(schema), g.escapeSQLName(indexName))
 		}
+	case GeneratorModeCockroach:
+		if constraint {
+			return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", g.escapeTableName(tableName), g.escapeSQLName(indexName))
+		} else {
+			// Index names are scoped by a table in CockroachDB
+			return fmt.Sprintf("DROP INDEX %s@%s", g.escapeTableName(tableName), g.escapeSQLName(indexName))
+		}
 	case GeneratorModeMssql:
 		return fmt.Sprintf("DROP INDEX %s ON %s", g.escapeSQLName(indexName), g.escapeTableName(tableName))
 	default:
@@ -1104,12 +1988,12 @@ func (g *Generator) generateDropIndex(tableName string, indexName string, constr
 
 func (g *Generator) escapeTableName(name string) string {
 	switch g.mode {
-	case GeneratorModePostgres, GeneratorModeMssql:
+	case GeneratorModePostgres, GeneratorModeMssql, GeneratorModeCockroach, GeneratorModeRedshift:
 		schemaTable := strings.SplitN(name, ".", 2)
 		var schemaName, tableName string
 		if len(schemaTable) == 1 {
 			switch g.mode {
-			case GeneratorModePostgres:
+			case GeneratorModePostgres, GeneratorModeCockroach, GeneratorModeRedshift:
 				schemaName, tableName = "public", schemaTable[0]
 			case GeneratorModeMssql:
 				schemaName, tableName = "dbo", schemaTable[0]
@@ -1124,9 +2008,32 @@ func (g *Generator) escapeTableName(name string) string {
 	}
 }
 
+func escapeStringLiteral(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", "''") + "'"
+}
+
+// Names which can be written without quotes, unless they're keywords. PostgreSQL lowers unquoted ones.
+var (
+	postgresBareNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+	bareNameRegexp         = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+)
+
 func (g *Generator) escapeSQLName(name string) string {
+	switch g.quoteIdentifiers {
+	case "never":
+		return name
+	case "auto":
+		bareName := bareNameRegexp
+		if g.mode == GeneratorModePostgres || g.mode == GeneratorModeCockroach || g.mode == GeneratorModeRedshift {
+			bareName = postgresBareNameRegexp
+		}
+		if bareName.MatchString(name) && !sqlparser.IsKeyword(name) {
+			return name
+		}
+	}
+
 	switch g.mode {
-	case GeneratorModePostgres:
+	case GeneratorModePostgres, GeneratorModeCockroach, GeneratorModeRedshift:
 		return fmt.Sprintf("\"%s\"", name)
 	case GeneratorModeMssql:
 		return fmt.Sprintf("[%s]", name)
@@ -1138,7 +2045,7 @@ func (g *Generator) escapeSQLName(name string) string {
 func (g *Generator) notNull(column Column) bool {
 	if column.notNull == nil {
 		switch g.mode {
-		case GeneratorModePostgres:
+		case GeneratorModePostgres, GeneratorModeCockroach, GeneratorModeRedshift:
 			return column.typeName == "serial" || column.typeName == "bigserial"
 		default:
 			return false
@@ -1229,6 +2136,23 @@ func convertDDLsToTables(ddls []DDL) ([]*Table, error) {
 			}
 
 			table.policies = append(table.policies, stmt.policy)
+		case *AddComment:
+			table := findTableByName(tables, stmt.tableName)
+			if table == nil {
+				return nil, fmt.Errorf("a comment is added before CREATE TABLE: %s", ddl.Statement())
+			}
+
+			table.comments = append(table.comments, stmt.comment)
+		case *DistributeTable:
+			table := findTableByName(tables, stmt.tableName)
+			if table == nil {
+				return nil, fmt.Errorf("create_%s_table is performed before CREATE TABLE: %s", stmt.tableType, ddl.Statement())
+			}
+
+			table.citusTableType = stmt.tableType
+			table.citusColumn = stmt.distributionColumn
+		case *CreateSequence:
+			// do nothing
 		case *View:
 			// do nothing
 		case *Trigger:
@@ -1272,6 +2196,16 @@ func convertDDLsToTypes(ddls []DDL) []*Type {
 	return types
 }
 
+func convertDDLsToSequences(ddls []DDL) []*CreateSequence {
+	var sequences []*CreateSequence
+	for _, ddl := range ddls {
+		if createSequence, ok := ddl.(*CreateSequence); ok {
+			sequences = append(sequences, createSequence)
+		}
+	}
+	return sequences
+}
+
 func findTableByName(tables []*Table, name string) *Table {
 	for _, table := range tables {
 		if table.name == name {
@@ -1335,6 +2269,15 @@ func findPolicyByName(policies []Policy, name string) *Policy {
 	return nil
 }
 
+func findCommentByColumnName(comments []Comment, columnName string) *Comment {
+	for _, comment := range comments {
+		if comment.columnName == columnName {
+			return &comment
+		}
+	}
+	return nil
+}
+
 func findViewByName(views []*View, name string) *View {
 	for _, view := range views {
 		if view.name == name {
@@ -1376,13 +2319,26 @@ func (g *Generator) haveSameColumnDefinition(current Column, desired Column) boo
 }
 
 func (g *Generator) haveSameDataType(current Column, desired Column) bool {
-	return g.normalizeDataType(current.typeName) == g.normalizeDataType(desired.typeName) &&
+	return (g.normalizeDataType(current.typeName) == g.normalizeDataType(desired.typeName) || g.isSameSerial(current, desired)) &&
 		reflect.DeepEqual(current.enumValues, desired.enumValues) &&
 		(current.length == nil || desired.length == nil || current.length.intVal == desired.length.intVal) && // detect change column only when both are set explicitly. TODO: maybe `current.length == nil` case needs another care
 		current.array == desired.array
 	// TODO: scale
 }
 
+// Return true if the desired column spells out the current serial column as an integer with DEFAULT nextval()
+func (g *Generator) isSameSerial(current Column, desired Column) bool {
+	if g.mode != GeneratorModePostgres {
+		return false
+	}
+	integerType, ok := serialDataTypes[current.typeName]
+	return ok && g.normalizeDataType(desired.typeName) == integerType && hasNextvalDefault(desired)
+}
+
+func hasNextvalDefault(column Column) bool {
+	return column.defaultDef != nil && column.defaultDef.value != nil && strings.EqualFold(string(column.defaultDef.value.raw), "nextval")
+}
+
 func areSameCheckDefinition(checkA *CheckDefinition, checkB *CheckDefinition) bool {
 	if checkA == nil && checkB == nil {
 		return true
@@ -1405,19 +2361,6 @@ func areSameIdentityDefinition(identityA *Identity, identityB *Identity) bool {
 	return identityA.behavior == identityB.behavior && identityA.notForReplication == identityB.notForReplication
 }
 
-func areSameDefaultValue(currentDefault *DefaultDefinition, desiredDefault *DefaultDefinition) bool {
-	var current *Value
-	var desired *Value
-	if currentDefault != nil && !isNullValue(currentDefault.value) {
-		current = currentDefault.value
-	}
-	if desiredDefault != nil && !isNullValue(desiredDefault.value) {
-		desired = desiredDefault.value
-	}
-
-	return areSameValue(current, desired)
-}
-
 func areSameValue(current, desired *Value) bool {
 	if current == nil && desired == nil {
 		return true
@@ -1474,15 +2417,51 @@ func (g *Generator) normalizeDataType(dataType string) string {
 	if ok {
 		dataType = alias
 	}
+	if g.mode == GeneratorModePostgres || g.mode == GeneratorModeCockroach || g.mode == GeneratorModeRedshift {
+		alias, ok = postgresDataTypeAliases[dataType]
+		if ok {
+			dataType = alias
+		}
+	}
 	if g.mode == GeneratorModeMysql {
 		alias, ok = mysqlDataTypeAliases[dataType]
 		if ok {
 			dataType = alias
 		}
 	}
+	if g.mode == GeneratorModeCockroach {
+		alias, ok = cockroachDataTypeAliases[dataType]
+		if ok {
+			dataType = alias
+		}
+	}
 	return dataType
 }
 
+func haveSameSingleStoreLayout(tableA Table, tableB Table) bool {
+	if tableA.shardKeys == nil && tableB.shardKeys == nil && len(tableA.sortKeys) == 0 && len(tableB.sortKeys) == 0 &&
+		tableA.tableType == "" && tableB.tableType == "" {
+		return true // not SingleStore
+	}
+	return tableA.tableType == tableB.tableType &&
+		reflect.DeepEqual(singleStoreShardKeys(tableA), singleStoreShardKeys(tableB)) &&
+		reflect.DeepEqual(tableA.sortKeys, tableB.sortKeys)
+}
+
+// SingleStore shards a table by its primary key if a shard key is not specified
+func singleStoreShardKeys(table Table) []string {
+	if table.shardKeys != nil {
+		return *table.shardKeys
+	}
+	shardKeys := []string{}
+	if primaryKey := table.PrimaryKey(); primaryKey != nil {
+		for _, indexColumn := range primaryKey.columns {
+			shardKeys = append(shardKeys, indexColumn.column)
+		}
+	}
+	return shardKeys
+}
+
 func areSamePrimaryKeys(primaryKeyA *Index, primaryKeyB *Index) bool {
 	if primaryKeyA != nil && primaryKeyB != nil {
 		return areSameIndexes(*primaryKeyA, *primaryKeyB)
@@ -1599,7 +2578,7 @@ func areSamePolicies(policyA, policyB Policy) bool {
 func (g *Generator) normalizeReferenceOption(action string) string {
 	if g.mode == GeneratorModeMysql && action == "" {
 		return "RESTRICT"
-	} else if (g.mode == GeneratorModePostgres || g.mode == GeneratorModeMssql) && action == "" {
+	} else if (g.mode == GeneratorModePostgres || g.mode == GeneratorModeMssql || g.mode == GeneratorModeCockroach || g.mode == GeneratorModeRedshift) && action == "" {
 		return "NO ACTION"
 	} else {
 		return action
@@ -1644,6 +2623,14 @@ func convertForeignKeysToIndexNames(foreignKeys []ForeignKey) []string {
 	return indexNames
 }
 
+func convertIndexColumnsToColumnNames(columns []IndexColumn) []string {
+	columnNames := []string{}
+	for _, column := range columns {
+		columnNames = append(columnNames, column.column)
+	}
+	return columnNames
+}
+
 func convertPolicyNames(policies []Policy) []string {
 	policyNames := make([]string, len(policies))
 	for i, policy := range policies {
@@ -1695,6 +2682,59 @@ func removeTableByName(tables []*Table, name string) []*Table {
 	return ret
 }
 
+func removeViewByName(views []*View, name string) []*View {
+	ret := []*View{}
+	for _, view := range views {
+		if view.name != name {
+			ret = append(ret, view)
+		}
+	}
+	return ret
+}
+
+func findSequenceByName(sequences []*CreateSequence, name string) *CreateSequence {
+	for _, sequence := range sequences {
+		if sequence.sequence.Name == name {
+			return sequence
+		}
+	}
+	return nil
+}
+
+// Fill omitted options of a MariaDB sequence with the server's defaults
+func normalizeMariadbSequence(sequence Sequence) Sequence {
+	intPtr := func(i int) *int { return &i }
+
+	if sequence.IncrementBy == nil || *sequence.IncrementBy == 0 {
+		sequence.IncrementBy = intPtr(1)
+	}
+	if sequence.MinValue == nil || sequence.NoMinValue {
+		if *sequence.IncrementBy > 0 {
+			sequence.MinValue = intPtr(1)
+		} else {
+			sequence.MinValue = intPtr(-9223372036854775807)
+		}
+	}
+	if sequence.MaxValue == nil || sequence.NoMaxValue {
+		if *sequence.IncrementBy > 0 {
+			sequence.MaxValue = intPtr(9223372036854775806)
+		} else {
+			sequence.MaxValue = intPtr(-1)
+		}
+	}
+	if sequence.StartWith == nil {
+		if *sequence.IncrementBy > 0 {
+			sequence.StartWith = sequence.MinValue
+		} else {
+			sequence.StartWith = sequence.MaxValue
+		}
+	}
+	if sequence.Cache == nil {
+		sequence.Cache = intPtr(1000)
+	}
+	return sequence
+}
+
 func generateSequenceClause(sequence *Sequence) string {
 	ddl := ""
 	if sequence.Name != "" {
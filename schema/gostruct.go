@@ -0,0 +1,180 @@
+package schema
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Words capitalized as a whole in Go names, as golint suggests, e.g. UserID of user_id
+var goInitialisms = map[string]bool{
+	"api": true, "ascii": true, "cpu": true, "css": true, "dns": true, "html": true, "http": true, "https": true,
+	"id": true, "ip": true, "json": true, "sql": true, "ssh": true, "tcp": true, "tls": true, "ttl": true,
+	"ui": true, "uid": true, "uri": true, "url": true, "utf8": true, "uuid": true, "xml": true,
+}
+
+// A Go type of a column, and the one used when the column is nullable
+type goType struct {
+	name     string
+	nullable string
+}
+
+var (
+	goStringType = goType{name: "string", nullable: "sql.NullString"}
+	goInt16Type  = goType{name: "int16", nullable: "sql.NullInt32"}
+	goInt32Type  = goType{name: "int32", nullable: "sql.NullInt32"}
+	goInt64Type  = goType{name: "int64", nullable: "sql.NullInt64"}
+	goFloatType  = goType{name: "float64", nullable: "sql.NullFloat64"}
+	goBoolType   = goType{name: "bool", nullable: "sql.NullBool"}
+	goTimeType   = goType{name: "time.Time", nullable: "sql.NullTime"}
+	goBytesType  = goType{name: "[]byte", nullable: "[]byte"}
+	goJSONType   = goType{name: "json.RawMessage", nullable: "json.RawMessage"}
+)
+
+// Packages of qualified Go types
+var goTypePackages = map[string]string{"sql": "database/sql", "time": "time", "json": "encoding/json", "pq": "github.com/lib/pq"}
+
+// Go types of data types, where types not listed here are scanned as strings, e.g. enums and uuid
+var goTypes = map[string]goType{
+	"smallint": goInt16Type, "int2": goInt16Type, "smallserial": goInt16Type, "tinyint": goInt16Type,
+	"integer": goInt32Type, "int": goInt32Type, "int4": goInt32Type, "mediumint": goInt32Type, "serial": goInt32Type,
+	"bigint": goInt64Type, "int8": goInt64Type, "bigserial": goInt64Type,
+	"real": goFloatType, "float": goFloatType, "float4": goFloatType, "float8": goFloatType, "double": goFloatType,
+	"double precision": goFloatType,
+	"boolean":          goBoolType, "bool": goBoolType,
+	"date": goTimeType, "datetime": goTimeType, "datetime2": goTimeType, "smalldatetime": goTimeType,
+	"datetimeoffset": goTimeType, "timestamp": goTimeType, "timestamptz": goTimeType,
+	"bytea": goBytesType, "blob": goBytesType, "tinyblob": goBytesType, "mediumblob": goBytesType, "longblob": goBytesType,
+	"binary": goBytesType, "varbinary": goBytesType, "image": goBytesType,
+	"json": goJSONType, "jsonb": goJSONType,
+}
+
+// Types of github.com/lib/pq for PostgreSQL arrays of the Go types
+var goArrayTypes = map[string]string{
+	"int16": "pq.Int64Array", "int32": "pq.Int64Array", "int64": "pq.Int64Array", "float64": "pq.Float64Array",
+	"bool": "pq.BoolArray", "[]byte": "pq.ByteaArray",
+}
+
+// Generate Go structs of tables in the schema SQL, having `db` and `json` tags of column names and types of database/sql
+// for nullable columns, so that application code can be kept in sync with the schema
+func GenerateGoStructs(mode GeneratorMode, sql string, config GeneratorConfig, packageName string) (string, error) {
+	ddls, err := ParseDDLs(mode, sql)
+	if err != nil {
+		return "", err
+	}
+	ddls = filterDDLs(ddls, config.skipDesiredTable)
+
+	imports := map[string]bool{}
+	structs := []string{}
+	for _, ddl := range ddls {
+		stmt, ok := ddl.(*CreateTable)
+		if !ok {
+			continue
+		}
+		table := stmt.table
+		name := table.name[strings.LastIndex(table.name, ".")+1:]
+
+		var definition strings.Builder
+		definition.WriteString(fmt.Sprintf("// %s is a row of the table %s\n", goName(name), table.name))
+		definition.WriteString(fmt.Sprintf("type %s struct {\n", goName(name)))
+		for _, column := range table.columns {
+			typeName := goColumnType(mode, column, table)
+			if i := strings.Index(typeName, "."); i >= 0 {
+				imports[goTypePackages[strings.TrimLeft(typeName[:i], "*[]")]] = true
+			}
+			definition.WriteString(fmt.Sprintf("%s %s `db:\"%s\" json:\"%s\"`\n", goName(column.name), typeName, column.name, column.name))
+		}
+		definition.WriteString("}\n")
+		structs = append(structs, definition.String())
+	}
+
+	var source strings.Builder
+	source.WriteString("// Code generated by sqldef --generate-go. DO NOT EDIT.\n\n")
+	source.WriteString(fmt.Sprintf("package %s\n\n", packageName))
+	if len(imports) > 0 {
+		var std, others []string // grouped as goimports does
+		for path := range imports {
+			if strings.Contains(strings.Split(path, "/")[0], ".") {
+				others = append(others, fmt.Sprintf("%q", path))
+			} else {
+				std = append(std, fmt.Sprintf("%q", path))
+			}
+		}
+		sort.Strings(std)
+		sort.Strings(others)
+		groups := []string{}
+		for _, paths := range [][]string{std, others} {
+			if len(paths) > 0 {
+				groups = append(groups, strings.Join(paths, "\n"))
+			}
+		}
+		if len(imports) == 1 {
+			source.WriteString(fmt.Sprintf("import %s\n\n", groups[0]))
+		} else {
+			source.WriteString(fmt.Sprintf("import (\n%s\n)\n\n", strings.Join(groups, "\n\n")))
+		}
+	}
+	source.WriteString(strings.Join(structs, "\n"))
+
+	formatted, err := format.Source([]byte(source.String()))
+	if err != nil {
+		return "", fmt.Errorf("failed to format Go structs: %s", err)
+	}
+	return string(formatted), nil
+}
+
+// Return an exported Go name of a table or a column, e.g. UserID of user_id
+func goName(name string) string {
+	var result strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if goInitialisms[strings.ToLower(word)] {
+			result.WriteString(strings.ToUpper(word))
+		} else {
+			result.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	if result.Len() == 0 || unicode.IsDigit(rune(result.String()[0])) {
+		return "X" + result.String()
+	}
+	return result.String()
+}
+
+// Return a Go type of a column
+func goColumnType(mode GeneratorMode, column Column, table Table) string {
+	typeName := strings.ToLower(column.typeName)
+	t, ok := goTypes[typeName]
+	if !ok {
+		t = goStringType // e.g. varchar, text, decimal, uuid, enum, and user-defined types
+	}
+	if isBooleanColumn(mode, column) {
+		t = goBoolType
+	}
+	if mode == GeneratorModeSQLite3 && (t.name == "int16" || t.name == "int32") {
+		t = goInt64Type // INTEGER of SQLite is 64-bit regardless of the declared type
+	}
+	if column.unsigned {
+		switch t.name {
+		case "int16":
+			t = goType{name: "uint16", nullable: "sql.NullInt32"}
+		case "int32":
+			t = goType{name: "uint32", nullable: "sql.NullInt64"}
+		case "int64":
+			t = goType{name: "uint64", nullable: "*uint64"} // overflows sql.NullInt64
+		}
+	}
+
+	if column.array {
+		if arrayType, ok := goArrayTypes[t.name]; ok {
+			return arrayType
+		}
+		return "pq.StringArray"
+	}
+	nullable := (column.notNull == nil || !*column.notNull) && !isPrimaryKey(column, table) &&
+		!column.autoIncrement && column.identity == nil && !strings.HasSuffix(typeName, "serial")
+	if nullable {
+		return t.nullable
+	}
+	return t.name
+}
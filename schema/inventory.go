@@ -0,0 +1,176 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// The schema as a JSON document of --export-format=json, for tools like data catalogs not to parse SQL
+type Inventory struct {
+	Tables []InventoryTable `json:"tables"`
+	Views  []InventoryView  `json:"views"`
+}
+
+type InventoryTable struct {
+	Name        string                `json:"name"`
+	Comment     string                `json:"comment,omitempty"`
+	Columns     []InventoryColumn     `json:"columns"`
+	PrimaryKey  []string              `json:"primary_key"`
+	Indexes     []InventoryIndex      `json:"indexes"`
+	ForeignKeys []InventoryForeignKey `json:"foreign_keys"`
+	Checks      []InventoryCheck      `json:"checks"`
+}
+
+type InventoryColumn struct {
+	Name          string  `json:"name"`
+	Type          string  `json:"type"` // e.g. varchar(255), int unsigned, integer[]
+	Nullable      bool    `json:"nullable"`
+	Default       *string `json:"default"` // an SQL expression, e.g. 'text', 0, or CURRENT_TIMESTAMP
+	AutoIncrement bool    `json:"auto_increment"`
+	Comment       string  `json:"comment,omitempty"`
+}
+
+type InventoryIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+	Where   string   `json:"where,omitempty"` // for a partial index
+}
+
+type InventoryForeignKey struct {
+	Name              string   `json:"name"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+	OnDelete          string   `json:"on_delete,omitempty"`
+	OnUpdate          string   `json:"on_update,omitempty"`
+}
+
+type InventoryCheck struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+type InventoryView struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// Return tables, columns, indexes, and constraints in the schema SQL as a JSON document
+func GenerateInventory(mode GeneratorMode, sql string, config GeneratorConfig) (string, error) {
+	ddls, err := ParseDDLs(mode, sql)
+	if err != nil {
+		return "", err
+	}
+	ddls = filterDDLs(ddls, config.skipDesiredTable)
+	tables, err := convertDDLsToTables(ddls)
+	if err != nil {
+		return "", err
+	}
+
+	inventory := Inventory{Tables: []InventoryTable{}, Views: []InventoryView{}}
+	for _, table := range tables {
+		inventory.Tables = append(inventory.Tables, inventoryTable(mode, table))
+	}
+	for _, view := range convertDDLsToViews(ddls) {
+		inventory.Views = append(inventory.Views, InventoryView{Name: view.name, Definition: view.definition})
+	}
+
+	out, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+func inventoryTable(mode GeneratorMode, table *Table) InventoryTable {
+	result := InventoryTable{
+		Name: table.name, Columns: []InventoryColumn{}, PrimaryKey: []string{}, Indexes: []InventoryIndex{},
+		ForeignKeys: []InventoryForeignKey{}, Checks: []InventoryCheck{},
+	}
+	comments := map[string]string{} // column name -> comment, and "" for the table
+	for _, comment := range table.comments {
+		comments[comment.columnName] = comment.value
+	}
+	result.Comment = comments[""]
+
+	for _, column := range table.columns {
+		dataType := generateDataType(column)
+		if column.unsigned {
+			dataType += " unsigned"
+		}
+		if column.timezone {
+			dataType += " with time zone"
+		}
+		primaryKey := isPrimaryKey(column, *table)
+		inventoryColumn := InventoryColumn{
+			Name:          column.name,
+			Type:          dataType,
+			Nullable:      (column.notNull == nil || !*column.notNull) && !primaryKey && column.identity == nil,
+			AutoIncrement: column.autoIncrement || column.identity != nil || strings.HasSuffix(strings.ToLower(column.typeName), "serial"),
+			Comment:       comments[column.name],
+		}
+		if column.comment != nil {
+			inventoryColumn.Comment = string(column.comment.raw)
+		}
+		if column.defaultDef != nil && column.defaultDef.value != nil && !isNullValue(column.defaultDef.value) {
+			if definition, err := generateDefaultDefinition(*column.defaultDef.value); err == nil {
+				value := strings.TrimPrefix(definition, "DEFAULT ")
+				inventoryColumn.Default = &value
+			}
+		}
+		result.Columns = append(result.Columns, inventoryColumn)
+
+		if primaryKey && column.keyOption == ColumnKeyPrimary {
+			result.PrimaryKey = append(result.PrimaryKey, column.name)
+		}
+		if column.keyOption.isUnique() {
+			result.Indexes = append(result.Indexes, InventoryIndex{
+				Name: inlineUniqueIndexName(mode, table.name, column.name), Columns: []string{column.name}, Unique: true,
+			})
+		}
+		if column.check != nil {
+			result.Checks = append(result.Checks, InventoryCheck{Name: column.check.constraintName, Expression: column.check.definition})
+		}
+		if column.references != "" {
+			result.ForeignKeys = append(result.ForeignKeys, InventoryForeignKey{
+				Columns: []string{column.name}, ReferencedTable: column.references, ReferencedColumns: []string{},
+			})
+		}
+	}
+
+	for _, index := range table.indexes {
+		columns := []string{}
+		for _, column := range index.columns {
+			columns = append(columns, column.column)
+		}
+		if index.primary {
+			result.PrimaryKey = columns
+			continue
+		}
+		result.Indexes = append(result.Indexes, InventoryIndex{Name: index.name, Columns: columns, Unique: index.unique, Where: index.where})
+	}
+	for _, fk := range table.foreignKeys {
+		result.ForeignKeys = append(result.ForeignKeys, InventoryForeignKey{
+			Name: fk.constraintName, Columns: fk.indexColumns, ReferencedTable: normalizedTable(mode, fk.referenceName),
+			ReferencedColumns: fk.referenceColumns, OnDelete: fk.onDelete, OnUpdate: fk.onUpdate,
+		})
+	}
+	for _, check := range table.checks {
+		result.Checks = append(result.Checks, InventoryCheck{Name: check.constraintName, Expression: check.definition})
+	}
+	return result
+}
+
+// Return a name given to UNIQUE of a column by the database, or "" if it's generated randomly
+func inlineUniqueIndexName(mode GeneratorMode, table string, column string) string {
+	switch mode {
+	case GeneratorModeMysql:
+		return column
+	case GeneratorModePostgres, GeneratorModeCockroach, GeneratorModeRedshift:
+		_, tableName := splitTableName(table)
+		return tableName + "_" + column + "_key"
+	default:
+		return ""
+	}
+}
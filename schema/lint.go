@@ -0,0 +1,220 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A problem of the desired schema found by Lint
+type LintViolation struct {
+	Rule      string // e.g. missing-primary-key
+	Message   string
+	Statement string // the DDL having the problem, to locate it in files
+	Name      string // the table or column having the problem
+}
+
+// Built-in rules of Lint
+var LintRules = []string{"missing-primary-key", "varchar-without-length", "foreign-key-without-index", "nullable-boolean", "reserved-word"}
+
+// Custom rule requiring names of tables, columns, or indexes to match a pattern, e.g. `^idx_{table}_{columns}$` for indexes
+type NamingRule struct {
+	Name    string // reported as the rule of violations
+	Object  string // table, column, or index
+	Pattern string // regular expression, where {table} is replaced with the table name and {columns} with index columns joined by _
+}
+
+func (r NamingRule) match(name string, table string, columns []string) (bool, error) {
+	pattern := strings.NewReplacer("{table}", regexp.QuoteMeta(table), "{columns}", regexp.QuoteMeta(strings.Join(columns, "_"))).Replace(r.Pattern)
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("invalid pattern of lint rule '%s': %s", r.Name, err)
+	}
+	return re.MatchString(name), nil
+}
+
+// Words reserved by the SQL standard or most of the supported databases, which need quoting everywhere
+var reservedWords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		all alter and any as asc between both by case check column constraint create cross
+		current_date current_time current_timestamp current_user default delete desc distinct drop
+		else end except exists false fetch for foreign from full grant group having in index inner
+		insert intersect into is join key leading left like limit natural not null offset on or order
+		outer primary references right select session_user table then to trailing true union unique
+		update user using values when where with`,
+	) {
+		reservedWords[word] = true
+	}
+}
+
+// Check the desired schema for common problems: missing primary keys, varchar without length,
+// foreign keys without an index, nullable booleans, reserved words used as identifiers, and names violating namingRules.
+func Lint(mode GeneratorMode, sql string, namingRules []NamingRule) ([]LintViolation, error) {
+	ddls, err := ParseDDLs(mode, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	// Indexes and foreign keys may be added by statements other than CREATE TABLE
+	tables := []*Table{}
+	statements := map[string]string{}      // table name -> CREATE TABLE
+	indexStatements := map[string]string{} // index name -> CREATE INDEX or ALTER TABLE
+	indexes := map[string][]Index{}
+	foreignKeys := map[string][]ForeignKey{}
+	for _, ddl := range ddls {
+		switch stmt := ddl.(type) {
+		case *CreateTable:
+			table := stmt.table
+			tables = append(tables, &table)
+			statements[table.name] = stmt.statement
+			indexes[table.name] = append(indexes[table.name], table.indexes...)
+			foreignKeys[table.name] = append(foreignKeys[table.name], table.foreignKeys...)
+		case *CreateIndex:
+			indexes[stmt.tableName] = append(indexes[stmt.tableName], stmt.index)
+			indexStatements[stmt.index.name] = stmt.statement
+		case *AddIndex:
+			indexes[stmt.tableName] = append(indexes[stmt.tableName], stmt.index)
+			indexStatements[stmt.index.name] = stmt.statement
+		case *AddPrimaryKey:
+			indexes[stmt.tableName] = append(indexes[stmt.tableName], stmt.index)
+		case *AddForeignKey:
+			foreignKeys[stmt.tableName] = append(foreignKeys[stmt.tableName], stmt.foreignKey)
+		}
+	}
+
+	violations := []LintViolation{}
+	for _, table := range tables {
+		table.indexes = indexes[table.name]
+		violation := func(rule string, name string, format string, args ...interface{}) {
+			statement, ok := indexStatements[name]
+			if !ok {
+				statement = statements[table.name]
+			}
+			violations = append(violations, LintViolation{Rule: rule, Message: fmt.Sprintf(format, args...), Statement: statement, Name: name})
+		}
+
+		_, tableName := splitTableName(table.name)
+		if reservedWords[strings.ToLower(tableName)] {
+			violation("reserved-word", tableName, "Table name '%s' is a reserved word", table.name)
+		}
+
+		primaryKey := false
+		for _, column := range table.columns {
+			primaryKey = primaryKey || isPrimaryKey(column, *table)
+		}
+		if !primaryKey {
+			violation("missing-primary-key", tableName, "Table '%s' has no primary key", table.name)
+		}
+
+		for _, column := range table.columns {
+			if reservedWords[strings.ToLower(column.name)] {
+				violation("reserved-word", column.name, "Column name '%s.%s' is a reserved word", table.name, column.name)
+			}
+			typeName := strings.ToLower(column.typeName)
+			if (mode == GeneratorModeMysql || mode == GeneratorModeMssql) && (typeName == "varchar" || typeName == "nvarchar") && column.length == nil {
+				violation("varchar-without-length", column.name, "Column '%s.%s' is %s without length", table.name, column.name, column.typeName)
+			}
+			if isBooleanColumn(mode, column) && (column.notNull == nil || !*column.notNull) && !isPrimaryKey(column, *table) {
+				violation("nullable-boolean", column.name, "Boolean column '%s.%s' is nullable", table.name, column.name)
+			}
+		}
+
+		// MySQL creates an index for a foreign key automatically
+		if mode != GeneratorModeMysql {
+			for _, column := range table.columns {
+				if column.references != "" {
+					foreignKeys[table.name] = append(foreignKeys[table.name], ForeignKey{indexColumns: []string{column.name}})
+				}
+			}
+			for _, foreignKey := range foreignKeys[table.name] {
+				if !hasIndexOn(*table, foreignKey.indexColumns) {
+					name := strings.Join(foreignKey.indexColumns, ", ")
+					violation("foreign-key-without-index", foreignKey.indexColumns[0], "Foreign key '%s(%s)' has no index", table.name, name)
+				}
+			}
+		}
+
+		for _, rule := range namingRules {
+			switch rule.Object {
+			case "table":
+				if ok, err := rule.match(tableName, tableName, nil); err != nil {
+					return nil, err
+				} else if !ok {
+					violation(rule.Name, tableName, "Table name '%s' doesn't match '%s'", table.name, rule.Pattern)
+				}
+			case "column":
+				for _, column := range table.columns {
+					if ok, err := rule.match(column.name, tableName, nil); err != nil {
+						return nil, err
+					} else if !ok {
+						violation(rule.Name, column.name, "Column name '%s.%s' doesn't match '%s'", table.name, column.name, rule.Pattern)
+					}
+				}
+			case "index":
+				for _, index := range table.indexes {
+					if index.primary {
+						continue
+					}
+					columns := []string{}
+					for _, column := range index.columns {
+						columns = append(columns, column.column)
+					}
+					if ok, err := rule.match(index.name, tableName, columns); err != nil {
+						return nil, err
+					} else if !ok {
+						violation(rule.Name, index.name, "Index name '%s' on '%s' doesn't match '%s'", index.name, table.name, rule.Pattern)
+					}
+				}
+			default:
+				return nil, fmt.Errorf("unknown object '%s' of lint rule '%s', which should be table, column, or index", rule.Object, rule.Name)
+			}
+		}
+	}
+	return violations, nil
+}
+
+func splitTableName(table string) (string, string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+func isBooleanColumn(mode GeneratorMode, column Column) bool {
+	switch typeName := strings.ToLower(column.typeName); typeName {
+	case "boolean", "bool":
+		return true
+	case "bit":
+		return mode == GeneratorModeMssql
+	case "tinyint":
+		return mode == GeneratorModeMysql && column.length != nil && string(column.length.raw) == "1"
+	default:
+		return false
+	}
+}
+
+// Return true if the columns are the leading columns of an index, which is used to look up the foreign key
+func hasIndexOn(table Table, columns []string) bool {
+	if len(columns) == 1 {
+		for _, column := range table.columns {
+			if column.name == columns[0] && (column.keyOption == ColumnKeyPrimary || column.keyOption.isUnique()) {
+				return true
+			}
+		}
+	}
+	for _, index := range table.indexes {
+		if len(index.columns) < len(columns) {
+			continue
+		}
+		covered := true
+		for i, column := range columns {
+			covered = covered && index.columns[i].column == column
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// e.g. `USING gin` of CREATE INDEX
+var indexMethodRegexp = regexp.MustCompile(`(?i)\sUSING\s+\w+`)
+
+// Type names printed by SHOW CREATE TABLE, where normalizeDataType's canonical names are not used
+var mysqlCanonicalDataTypes = map[string]string{
+	"integer": "int",
+	"bool":    "tinyint",
+	"boolean": "tinyint",
+}
+
+// Parse the desired schema and print it back in the form `--export` uses: canonical type names, expanded defaults,
+// qualified and quoted names, and objects ordered by dependencies, so that it's comparable with an exported schema as text.
+// Statements other than tables and indexes are kept as they're written.
+func NormalizeDDLs(mode GeneratorMode, desiredSQL string, config GeneratorConfig) ([]string, error) {
+	desiredDDLs, err := ParseDDLs(mode, desiredSQL)
+	if err != nil {
+		return nil, err
+	}
+	desiredDDLs = sortDDLsByDependency(filterDDLs(desiredDDLs, config.skipDesiredTable))
+
+	g := Generator{mode: mode, quoteIdentifiers: config.quoteIdentifiers}
+	ddls := []string{}
+	for _, ddl := range desiredDDLs {
+		switch stmt := ddl.(type) {
+		case *CreateTable:
+			normalized, err := g.normalizeCreateTable(stmt.table)
+			if err != nil {
+				return nil, err
+			}
+			ddls = append(ddls, normalized)
+		case *CreateIndex:
+			if len(stmt.index.columns) == 0 || indexMethodRegexp.MatchString(stmt.statement) { // an expression index, or USING which Index doesn't keep
+				ddls = append(ddls, strings.TrimSpace(stmt.statement))
+			} else {
+				ddls = append(ddls, g.normalizeCreateIndex(stmt.tableName, stmt.index))
+			}
+		default:
+			ddls = append(ddls, strings.TrimSpace(ddl.Statement()))
+		}
+	}
+	return ddls, nil
+}
+
+func (g *Generator) normalizeCreateTable(table Table) (string, error) {
+	indent := "    "
+	if g.mode == GeneratorModeMysql {
+		indent = "  "
+	}
+
+	definitions := []string{}
+	primaryKeys := []string{}
+	for _, column := range table.columns {
+		column = g.normalizeColumn(column)
+		definition, err := g.generateColumnDefinition(column, true)
+		if err != nil {
+			return "", err
+		}
+		if column.references != "" {
+			definition += " REFERENCES " + g.escapeTableName(column.references)
+		}
+		definitions = append(definitions, definition)
+		if column.keyOption == ColumnKeyPrimary {
+			primaryKeys = append(primaryKeys, g.escapeSQLName(column.name))
+		}
+	}
+
+	hasPrimaryIndex := false
+	for _, index := range table.indexes {
+		hasPrimaryIndex = hasPrimaryIndex || index.primary
+	}
+	if len(primaryKeys) > 0 && !hasPrimaryIndex {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKeys, ", ")))
+	}
+	for _, index := range table.indexes {
+		columns := g.normalizeIndexColumns(index.columns)
+		switch {
+		case index.primary:
+			definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", columns))
+		case g.mode == GeneratorModeMysql && index.unique:
+			definitions = append(definitions, fmt.Sprintf("UNIQUE KEY %s (%s)", g.escapeSQLName(index.name), columns))
+		case g.mode == GeneratorModeMysql:
+			definitions = append(definitions, fmt.Sprintf("KEY %s (%s)", g.escapeSQLName(index.name), columns))
+		case index.unique && index.name != "":
+			definitions = append(definitions, fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)", g.escapeSQLName(index.name), columns))
+		case index.unique:
+			definitions = append(definitions, fmt.Sprintf("UNIQUE (%s)", columns))
+		default:
+			definitions = append(definitions, fmt.Sprintf("INDEX %s (%s)", g.escapeSQLName(index.name), columns))
+		}
+	}
+	for _, check := range table.checks {
+		definition := fmt.Sprintf("CHECK (%s)", check.definition)
+		if check.constraintName != "" {
+			definition = fmt.Sprintf("CONSTRAINT %s %s", g.escapeSQLName(check.constraintName), definition)
+		}
+		definitions = append(definitions, definition)
+	}
+	for _, foreignKey := range table.foreignKeys {
+		definition := g.generateForeignKeyDefinition(foreignKey)
+		if foreignKey.constraintName == "" {
+			definition = strings.TrimPrefix(definition, fmt.Sprintf("CONSTRAINT %s ", g.escapeSQLName("")))
+		}
+		definitions = append(definitions, definition)
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s%s\n)", g.escapeTableName(table.name), indent,
+		strings.Join(definitions, ",\n"+indent)), nil
+}
+
+// Replace aliases of a type with its canonical name, and column options with their equivalents
+func (g *Generator) normalizeColumn(column Column) Column {
+	if g.mode == GeneratorModeMysql {
+		if canonical, ok := mysqlCanonicalDataTypes[column.typeName]; ok {
+			if canonical == "tinyint" && column.length == nil {
+				column.length = &Value{valueType: ValueTypeInt, raw: []byte("1"), intVal: 1}
+			}
+			column.typeName = canonical
+		}
+	} else {
+		column.typeName = g.normalizeDataType(column.typeName)
+		if column.timezone && column.length == nil {
+			column.typeName, column.timezone = column.typeName+" with time zone", false
+		}
+	}
+
+	// The parser gives NOW() as a bit value
+	if column.defaultDef != nil && column.defaultDef.value != nil && column.defaultDef.value.valueType == ValueTypeBit &&
+		strings.EqualFold(string(column.defaultDef.value.raw), "now") {
+		column.defaultDef = &DefaultDefinition{
+			value:          &Value{valueType: ValueTypeValArg, raw: []byte("now()")},
+			constraintName: column.defaultDef.constraintName,
+		}
+	}
+
+	switch column.keyOption {
+	case ColumnKey: // `KEY` of a column is PRIMARY KEY in MySQL
+		column.keyOption = ColumnKeyPrimary
+	case ColumnKeySpatialKey:
+		column.keyOption = ColumnKeyNone
+	}
+	return column
+}
+
+func (g *Generator) normalizeIndexColumns(indexColumns []IndexColumn) string {
+	columns := []string{}
+	for _, indexColumn := range indexColumns {
+		column := g.escapeSQLName(indexColumn.column)
+		if indexColumn.length != nil {
+			column += fmt.Sprintf("(%d)", *indexColumn.length)
+		}
+		if indexColumn.direction == DescScr {
+			column += fmt.Sprintf(" %s", indexColumn.direction)
+		}
+		columns = append(columns, column)
+	}
+	return strings.Join(columns, ", ")
+}
+
+func (g *Generator) normalizeCreateIndex(tableName string, index Index) string {
+	ddl := "CREATE"
+	if index.unique {
+		ddl += " UNIQUE"
+	}
+	if g.mode == GeneratorModeMssql {
+		if index.clustered {
+			ddl += " CLUSTERED"
+		} else {
+			ddl += " NONCLUSTERED"
+		}
+	}
+	ddl += fmt.Sprintf(" INDEX %s ON %s (%s)", g.escapeSQLName(index.name), g.escapeTableName(tableName), g.normalizeIndexColumns(index.columns))
+	if len(index.included) > 0 {
+		included := []string{}
+		for _, column := range index.included {
+			included = append(included, g.escapeSQLName(column))
+		}
+		ddl += fmt.Sprintf(" INCLUDE (%s)", strings.Join(included, ", "))
+	}
+	if index.where != "" {
+		ddl += fmt.Sprintf(" WHERE %s", index.where)
+	}
+	return ddl + g.generateIndexOptionDefinition(index.options)
+}
@@ -119,10 +119,12 @@ func parseTable(mode GeneratorMode, stmt *sqlparser.DDL) (Table, error) {
 			onUpdate:      parseValue(parsedCol.Type.OnUpdate),
 			comment:       parseValue(parsedCol.Type.Comment),
 			enumValues:    parsedCol.Type.EnumValues,
-			references:    normalizedTable(mode, parsedCol.Type.References),
 			identity:      parseIdentity(parsedCol.Type.Identity),
 			sequence:      parseIdentitySequence(parsedCol.Type.Identity),
 		}
+		if len(parsedCol.Type.References) > 0 {
+			column.references = normalizedTable(mode, parsedCol.Type.References)
+		}
 		if parsedCol.Type.Check != nil {
 			column.check = &CheckDefinition{
 				definition:        sqlparser.String(parsedCol.Type.Check.Where.Expr),
@@ -306,24 +308,80 @@ func parseIndex(stmt *sqlparser.DDL) (Index, error) {
 	}, nil
 }
 
-// Parse DDL like `CREATE TABLE` or `ALTER TABLE`.
-// This doesn't support destructive DDL like `DROP TABLE`.
-func parseDDL(mode GeneratorMode, ddl string) (DDL, error) {
-	var parserMode sqlparser.ParserMode
+func parserMode(mode GeneratorMode) sqlparser.ParserMode {
 	switch mode {
 	case GeneratorModeMysql:
-		parserMode = sqlparser.ParserModeMysql
-	case GeneratorModePostgres:
-		parserMode = sqlparser.ParserModePostgres
+		return sqlparser.ParserModeMysql
+	case GeneratorModePostgres, GeneratorModeCockroach, GeneratorModeRedshift:
+		return sqlparser.ParserModePostgres
 	case GeneratorModeSQLite3:
-		parserMode = sqlparser.ParserModeSQLite3
+		return sqlparser.ParserModeSQLite3
 	case GeneratorModeMssql:
-		parserMode = sqlparser.ParserModeMssql
+		return sqlparser.ParserModeMssql
 	default:
 		panic("unrecognized parser mode")
 	}
+}
+
+// Parse DDL like `CREATE TABLE` or `ALTER TABLE`.
+// This doesn't support destructive DDL like `DROP TABLE`.
+// Unquoted identifiers are lowered with foldIdentifiers, which is given only for the desired schema of PostgreSQL.
+func parseDDL(mode GeneratorMode, ddl string, foldIdentifiers bool) (DDL, error) {
+	parserMode := parserMode(mode)
+
+	// sqlparser doesn't support stored procedure calls
+	if mode == GeneratorModeMssql && addExtendedPropertyRegexp.MatchString(ddl) {
+		return parseAddExtendedProperty(ddl)
+	}
 
-	stmt, err := sqlparser.ParseStrictDDLWithMode(ddl, parserMode)
+	// sqlparser doesn't support COMMENT ON
+	if mode == GeneratorModePostgres && commentOnRegexp.MatchString(ddl) {
+		return parseCommentOn(mode, ddl)
+	}
+
+	// sqlparser doesn't support function calls of Citus
+	if mode == GeneratorModePostgres && citusDistributeTableRegexp.MatchString(ddl) {
+		return parseDistributeTable(mode, ddl)
+	}
+
+	// sqlparser doesn't support MariaDB's sequences
+	if mode == GeneratorModeMysql && mariadbCreateSequenceRegexp.MatchString(ddl) {
+		return parseMariadbCreateSequence(ddl)
+	}
+
+	// sqlparser doesn't support Redshift's distribution styles, sort keys, and compression encodings
+	parsedDDL := ddl
+	var redshiftAttributes *redshiftTableAttributes
+	if mode == GeneratorModeRedshift && createTableRegexp.MatchString(ddl) {
+		parsedDDL, redshiftAttributes = extractRedshiftTableAttributes(ddl)
+	}
+
+	// sqlparser doesn't support SingleStore's shard keys, sort keys, and table types
+	var singleStoreAttributes *singleStoreTableAttributes
+	if mode == GeneratorModeMysql && singleStoreCreateTableRegexp.MatchString(ddl) && singleStoreClauseRegexp.MatchString(ddl) {
+		parsedDDL, singleStoreAttributes = extractSingleStoreTableAttributes(ddl)
+	}
+
+	// YugabyteDB's storage clauses are kept only in the original statement
+	var greenplumDistribution string
+	var greenplumDistributionKeys []string
+	if mode == GeneratorModePostgres {
+		// CONCURRENTLY doesn't change the index. It's kept only in the original statement for `psqldef --no-transaction`.
+		parsedDDL = createIndexConcurrentlyRegexp.ReplaceAllString(parsedDDL, "$1")
+		parsedDDL = stripYugabyteStorageClauses(parsedDDL)
+		parsedDDL, greenplumDistribution, greenplumDistributionKeys = extractGreenplumDistribution(parsedDDL)
+	}
+
+	// sqlparser doesn't support FLOAT(p), which is REAL or DOUBLE PRECISION by the precision
+	if mode == GeneratorModePostgres || mode == GeneratorModeCockroach || mode == GeneratorModeRedshift {
+		parsedDDL = replaceFloatPrecision(parsedDDL)
+	}
+
+	parse := sqlparser.ParseStrictDDLWithMode
+	if foldIdentifiers && parserMode == sqlparser.ParserModePostgres {
+		parse = sqlparser.ParseStrictDDLFoldingIdentifiers
+	}
+	stmt, err := parse(parsedDDL, parserMode)
 	if err != nil {
 		return nil, err
 	}
@@ -336,6 +394,26 @@ func parseDDL(mode GeneratorMode, ddl string) (DDL, error) {
 			if err != nil {
 				return nil, err
 			}
+			if redshiftAttributes != nil {
+				redshiftAttributes.apply(&table)
+			}
+			if singleStoreAttributes != nil {
+				singleStoreAttributes.apply(&table)
+			}
+			table.distribution = greenplumDistribution
+			table.distributionKeys = greenplumDistributionKeys
+			if match := renamedTableRegexp.FindStringSubmatch(ddl); match != nil {
+				table.renamedFrom = normalizedTable(mode, strings.Trim(match[1], "`\"[]"))
+			}
+			renamedColumns := parseRenamedColumns(ddl)
+			for i, column := range table.columns {
+				table.columns[i].renamedFrom = renamedColumns[column.name]
+			}
+			backfills, backfillDMLs := parseBackfills(ddl), parseBackfillDMLs(ddl)
+			for i, column := range table.columns {
+				table.columns[i].backfill = backfills[column.name]
+				table.columns[i].backfillDML = backfillDMLs[column.name]
+			}
 			return &CreateTable{
 				statement: ddl,
 				table:     table,
@@ -449,16 +527,522 @@ func parseDDL(mode GeneratorMode, ddl string) (DDL, error) {
 				stmt.Action, ddl,
 			)
 		}
+	case *sqlparser.Insert:
+		if !seedRegexp.MatchString(ddl) {
+			return nil, fmt.Errorf("INSERT needs to be annotated by `-- @seed` to manage its rows: %s", ddl)
+		}
+		return nil, nil // applied by GenerateSeedDMLs
 	default:
 		return nil, fmt.Errorf("unsupported type of SQL (only DDL is supported): %s", ddl)
 	}
 }
 
+var (
+	addExtendedPropertyRegexp      = regexp.MustCompile(`(?is)^EXEC(UTE)?\s+(sys\.)?sp_addextendedproperty\s`)
+	extendedPropertyArgumentRegexp = regexp.MustCompile(`(?is)^\s*(?:@(\w+)\s*=\s*)?N?'((?:[^']|'')*)'\s*(,|$)`)
+	extendedPropertyParameters     = []string{"name", "value", "level0type", "level0name", "level1type", "level1name", "level2type", "level2name"}
+)
+
+// Parse `EXEC sp_addextendedproperty` for MS_Description, with either named or positional arguments.
+func parseAddExtendedProperty(ddl string) (DDL, error) {
+	args := map[string]string{}
+	rest := strings.TrimSpace(addExtendedPropertyRegexp.ReplaceAllString(ddl, ""))
+	for i := 0; rest != ""; i++ {
+		match := extendedPropertyArgumentRegexp.FindStringSubmatch(rest)
+		if match == nil || i >= len(extendedPropertyParameters) {
+			return nil, fmt.Errorf("unsupported arguments of sp_addextendedproperty: %s", ddl)
+		}
+		name := strings.ToLower(match[1])
+		if name == "" {
+			name = extendedPropertyParameters[i]
+		}
+		args[name] = strings.ReplaceAll(match[2], "''", "'")
+		rest = rest[len(match[0]):]
+	}
+
+	if !strings.EqualFold(args["name"], "MS_Description") {
+		return nil, fmt.Errorf("unsupported extended property '%s' (only MS_Description is supported): %s", args["name"], ddl)
+	}
+	if !strings.EqualFold(args["level0type"], "SCHEMA") || !strings.EqualFold(args["level1type"], "TABLE") ||
+		(args["level2type"] != "" && !strings.EqualFold(args["level2type"], "COLUMN")) {
+		return nil, fmt.Errorf("MS_Description is supported only for a table or a column: %s", ddl)
+	}
+
+	return &AddComment{
+		statement: ddl,
+		tableName: args["level1name"],
+		comment: Comment{
+			columnName: args["level2name"],
+			value:      args["value"],
+			schemaName: args["level0name"],
+		},
+	}, nil
+}
+
+var (
+	// e.g. `COMMENT ON COLUMN public.users.name IS 'Full name'`
+	commentOnRegexp           = regexp.MustCompile(`(?is)^COMMENT\s+ON\s+(TABLE|COLUMN)\s+(.+?)\s+IS\s+(NULL|'((?:[^']|'')*)')$`)
+	commentOnIdentifierRegexp = regexp.MustCompile(`"(?:[^"]|"")*"|[^."]+`)
+)
+
+// Parse PostgreSQL's `COMMENT ON` for a table or a column. `IS NULL` is ignored like an absent comment.
+func parseCommentOn(mode GeneratorMode, ddl string) (DDL, error) {
+	match := commentOnRegexp.FindStringSubmatch(ddl)
+	if strings.EqualFold(match[3], "NULL") {
+		return nil, nil
+	}
+
+	names := []string{}
+	for _, name := range commentOnIdentifierRegexp.FindAllString(match[2], -1) {
+		if strings.HasPrefix(name, `"`) {
+			name = strings.ReplaceAll(strings.Trim(name, `"`), `""`, `"`)
+		}
+		names = append(names, name)
+	}
+	columnName := ""
+	if strings.EqualFold(match[1], "COLUMN") {
+		if len(names) < 2 {
+			return nil, fmt.Errorf("COMMENT ON COLUMN needs a table name: %s", ddl)
+		}
+		columnName = names[len(names)-1]
+		names = names[:len(names)-1]
+	}
+
+	return &AddComment{
+		statement: ddl,
+		tableName: normalizedTable(mode, strings.Join(names, ".")),
+		comment: Comment{
+			columnName: columnName,
+			value:      strings.ReplaceAll(match[4], "''", "'"),
+		},
+	}, nil
+}
+
+var mariadbCreateSequenceRegexp = regexp.MustCompile("(?is)^CREATE\\s+SEQUENCE\\s+(IF\\s+NOT\\s+EXISTS\\s+)?(`[^`]+`|\\w+)(.*)$")
+
+// Parse MariaDB's `CREATE SEQUENCE`. Table options like ENGINE are ignored.
+func parseMariadbCreateSequence(ddl string) (DDL, error) {
+	match := mariadbCreateSequenceRegexp.FindStringSubmatch(ddl)
+	sequence := Sequence{
+		Name:        unquoteIdentifier(match[2]),
+		IfNotExists: match[1] != "",
+	}
+
+	tokens := strings.Fields(strings.ReplaceAll(match[3], "=", " = "))
+	// Return the number following an optional keyword like BY, WITH, or =
+	nextValue := func(i *int, keywords ...string) (*int, error) {
+		if *i+1 < len(tokens) && containsString(keywords, strings.ToUpper(tokens[*i+1])) {
+			*i++
+		}
+		if *i+1 >= len(tokens) {
+			return nil, fmt.Errorf("a value is missing after '%s': %s", tokens[*i], ddl)
+		}
+		*i++
+		value, err := strconv.Atoi(tokens[*i])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected value '%s' of a sequence: %s", tokens[*i], ddl)
+		}
+		return &value, nil
+	}
+
+	var err error
+	for i := 0; i < len(tokens); i++ {
+		switch strings.ToUpper(tokens[i]) {
+		case "START":
+			sequence.StartWith, err = nextValue(&i, "WITH", "=")
+		case "INCREMENT":
+			sequence.IncrementBy, err = nextValue(&i, "BY", "=")
+		case "MINVALUE":
+			sequence.MinValue, err = nextValue(&i, "=")
+		case "MAXVALUE":
+			sequence.MaxValue, err = nextValue(&i, "=")
+		case "CACHE":
+			sequence.Cache, err = nextValue(&i, "=")
+		case "NOMINVALUE":
+			sequence.NoMinValue = true
+		case "NOMAXVALUE":
+			sequence.NoMaxValue = true
+		case "NOCACHE":
+			noCache := 0
+			sequence.Cache = &noCache
+		case "CYCLE":
+			sequence.Cycle = true
+		case "NOCYCLE":
+			sequence.NoCycle = true
+		case "NO":
+			if i+1 < len(tokens) {
+				i++
+				switch strings.ToUpper(tokens[i]) {
+				case "MINVALUE":
+					sequence.NoMinValue = true
+				case "MAXVALUE":
+					sequence.NoMaxValue = true
+				case "CYCLE":
+					sequence.NoCycle = true
+				default:
+					err = fmt.Errorf("unexpected 'NO %s' of a sequence: %s", tokens[i], ddl)
+				}
+			}
+		case "ENGINE":
+			if i+1 < len(tokens) && tokens[i+1] == "=" {
+				i++
+			}
+			i++
+		default:
+			err = fmt.Errorf("unsupported option '%s' of a sequence: %s", tokens[i], ddl)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &CreateSequence{
+		statement: ddl,
+		sequence:  sequence,
+	}, nil
+}
+
+var (
+	citusDistributeTableRegexp = regexp.MustCompile(`(?is)^SELECT\s+(?:pg_catalog\.)?create_(distributed|reference)_table\s*\((.*)\)$`)
+	citusArgumentRegexp        = regexp.MustCompile(`(?is)^(?:(\w+)\s*(?:=>|:=)\s*)?'((?:[^']|'')*)'(?:::\w+)?$`)
+)
+
+// Parse Citus' `SELECT create_distributed_table('table', 'column')` and `SELECT create_reference_table('table')`.
+// Other arguments like colocate_with are kept only in the original statement.
+func parseDistributeTable(mode GeneratorMode, ddl string) (DDL, error) {
+	match := citusDistributeTableRegexp.FindStringSubmatch(ddl)
+	args := map[string]string{}
+	positionalNames := []string{"table_name", "distribution_column"}
+	for i, arg := range splitTopLevelCommas(match[2]) {
+		argMatch := citusArgumentRegexp.FindStringSubmatch(strings.TrimSpace(arg))
+		if argMatch == nil {
+			return nil, fmt.Errorf("unsupported arguments of create_%s_table: %s", strings.ToLower(match[1]), ddl)
+		}
+		name := strings.ToLower(argMatch[1])
+		if name == "" && i < len(positionalNames) {
+			name = positionalNames[i]
+		}
+		args[name] = strings.ReplaceAll(argMatch[2], "''", "'")
+	}
+
+	if args["table_name"] == "" {
+		return nil, fmt.Errorf("table name is not specified: %s", ddl)
+	}
+	tableType := strings.ToLower(match[1])
+	if tableType == "distributed" && args["distribution_column"] == "" {
+		return nil, fmt.Errorf("distribution column is not specified: %s", ddl)
+	}
+
+	schema, table := postgres.SplitTableName(args["table_name"])
+	return &DistributeTable{
+		statement:          ddl,
+		tableName:          normalizedTable(mode, unquoteIdentifier(schema)+"."+unquoteIdentifier(table)),
+		tableType:          tableType,
+		distributionColumn: unquoteIdentifier(args["distribution_column"]),
+	}, nil
+}
+
+var createIndexConcurrentlyRegexp = regexp.MustCompile(`(?is)^(CREATE\s+(?:UNIQUE\s+)?INDEX\s+)CONCURRENTLY\s+`)
+
+var floatPrecisionRegexp = regexp.MustCompile(`(?i)\bfloat\s*\(\s*(\d+)\s*\)`)
+
+// Replace FLOAT(p) with REAL for p up to 24, and with DOUBLE PRECISION otherwise, as PostgreSQL does
+func replaceFloatPrecision(ddl string) string {
+	return floatPrecisionRegexp.ReplaceAllStringFunc(ddl, func(float string) string {
+		precision, _ := strconv.Atoi(floatPrecisionRegexp.FindStringSubmatch(float)[1])
+		if precision <= 24 {
+			return "real"
+		}
+		return "double precision"
+	})
+}
+
+var (
+	createIndexRegexp        = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s`)
+	yugabyteColocationRegexp = regexp.MustCompile(`(?is)\)\s*WITH\s*\(\s*colocat(ed|ion)\s*=\s*\w+\s*\)`)
+	yugabyteSplitRegexp      = regexp.MustCompile(`(?is)\)\s*SPLIT\s+(INTO\s+\d+\s+TABLETS|AT\s+VALUES\s*\(.*\))\s*$`)
+	yugabyteHashColumnRegexp = regexp.MustCompile(`(?i)([\w"])\s+HASH\s*([,)])`)
+)
+
+// Strip YugabyteDB's `WITH (colocation = ...)`, `SPLIT INTO` / `SPLIT AT`, and HASH index columns,
+// which sqlparser doesn't support. They can't be changed without recreating a table, so they are not diffed.
+func stripYugabyteStorageClauses(ddl string) string {
+	if createTableRegexp.MatchString(ddl) {
+		ddl = yugabyteSplitRegexp.ReplaceAllString(ddl, ")")
+		ddl = yugabyteColocationRegexp.ReplaceAllString(ddl, ")")
+	} else if createIndexRegexp.MatchString(ddl) {
+		ddl = yugabyteSplitRegexp.ReplaceAllString(ddl, ")")
+		ddl = yugabyteHashColumnRegexp.ReplaceAllString(ddl, "$1$2")
+	}
+	return ddl
+}
+
+var greenplumDistributionRegexp = regexp.MustCompile(`(?is)\)\s*DISTRIBUTED\s+(BY\s*\(([^)]*)\)|RANDOMLY|REPLICATED)\s*$`)
+
+// Strip Greenplum's `DISTRIBUTED BY (...)`, `DISTRIBUTED RANDOMLY`, or `DISTRIBUTED REPLICATED` from `CREATE TABLE`.
+func extractGreenplumDistribution(ddl string) (string, string, []string) {
+	if !createTableRegexp.MatchString(ddl) {
+		return ddl, "", nil
+	}
+	match := greenplumDistributionRegexp.FindStringSubmatchIndex(ddl)
+	if match == nil {
+		return ddl, "", nil
+	}
+	distribution := strings.ToUpper(ddl[match[2]:match[3]])
+	var keys []string
+	if match[4] >= 0 {
+		distribution = "BY"
+		keys = splitKeyColumns(ddl[match[4]:match[5]])
+	}
+	return ddl[:match[0]] + ")", distribution, keys
+}
+
+type redshiftTableAttributes struct {
+	distStyle       string
+	distKey         string
+	sortStyle       string
+	sortKeys        []string
+	columnEncodings map[string]string
+}
+
+var (
+	createTableRegexp             = regexp.MustCompile(`(?is)^CREATE\s+(TEMP(ORARY)?\s+)?TABLE\s`)
+	redshiftTableAttributeRegexp  = regexp.MustCompile(`(?is)\s*(DISTSTYLE\s+(\w+)|DISTKEY\s*\(([^)]*)\)|(?:(COMPOUND|INTERLEAVED)\s+)?SORTKEY\s*(?:\(([^)]*)\)|AUTO)|ENCODE\s+AUTO|BACKUP\s+(?:YES|NO))\s*$`)
+	redshiftColumnAttributeRegexp = regexp.MustCompile(`(?is)\s+(ENCODE\s+(\w+)|DISTKEY|SORTKEY)\b`)
+	redshiftTableConstraintRegexp = regexp.MustCompile(`(?is)^(CONSTRAINT|PRIMARY|UNIQUE|FOREIGN|CHECK|LIKE)\s`)
+)
+
+// Strip Redshift-specific attributes from `CREATE TABLE` so that sqlparser can parse it as PostgreSQL.
+func extractRedshiftTableAttributes(ddl string) (string, *redshiftTableAttributes) {
+	attributes := &redshiftTableAttributes{columnEncodings: map[string]string{}}
+
+	// Table attributes follow the column definitions
+	for {
+		match := redshiftTableAttributeRegexp.FindStringSubmatchIndex(ddl)
+		if match == nil {
+			break
+		}
+		submatch := func(i int) string {
+			if match[2*i] < 0 {
+				return ""
+			}
+			return ddl[match[2*i]:match[2*i+1]]
+		}
+		attribute := strings.ToUpper(submatch(1))
+		switch {
+		case strings.HasPrefix(attribute, "DISTSTYLE"):
+			attributes.distStyle = submatch(2)
+		case strings.HasPrefix(attribute, "DISTKEY"):
+			attributes.distKey = unquoteIdentifier(submatch(3))
+		case strings.Contains(attribute, "SORTKEY"):
+			attributes.sortStyle = submatch(4)
+			if keys := submatch(5); keys != "" {
+				for _, key := range strings.Split(keys, ",") {
+					attributes.sortKeys = append(attributes.sortKeys, unquoteIdentifier(key))
+				}
+			}
+		}
+		ddl = ddl[:match[0]]
+	}
+
+	// Column attributes are inside the outermost parentheses
+	open := strings.Index(ddl, "(")
+	if open < 0 || !strings.HasSuffix(ddl, ")") {
+		return ddl, attributes
+	}
+	definitions := splitTopLevelCommas(ddl[open+1 : len(ddl)-1])
+	for i, definition := range definitions {
+		trimmed := strings.TrimSpace(definition)
+		if trimmed == "" || redshiftTableConstraintRegexp.MatchString(trimmed) {
+			continue
+		}
+		name := strings.Fields(trimmed)[0]
+		column := unquoteIdentifier(name)
+		rest := trimmed[len(name):]
+		for _, match := range redshiftColumnAttributeRegexp.FindAllStringSubmatch(rest, -1) {
+			switch strings.ToUpper(match[1]) {
+			case "DISTKEY":
+				attributes.distKey = column
+			case "SORTKEY":
+				attributes.sortKeys = append(attributes.sortKeys, column)
+			default:
+				attributes.columnEncodings[column] = match[2]
+			}
+		}
+		definitions[i] = " " + name + redshiftColumnAttributeRegexp.ReplaceAllString(rest, "")
+	}
+	return ddl[:open+1] + strings.Join(definitions, ",") + ")", attributes
+}
+
+func (a *redshiftTableAttributes) apply(table *Table) {
+	table.distStyle = strings.ToUpper(a.distStyle)
+	table.distKey = a.distKey
+	if table.distKey != "" && table.distStyle == "" {
+		table.distStyle = "KEY"
+	} else if table.distStyle == "AUTO" {
+		table.distStyle = ""
+	}
+
+	table.sortKeys = a.sortKeys
+	table.sortStyle = strings.ToUpper(a.sortStyle)
+	if len(table.sortKeys) == 0 {
+		table.sortStyle = ""
+	} else if table.sortStyle == "" {
+		table.sortStyle = "COMPOUND"
+	}
+
+	for i, column := range table.columns {
+		if encoding, ok := a.columnEncodings[column.name]; ok {
+			table.columns[i].encoding = strings.ToLower(encoding)
+		}
+	}
+}
+
+type singleStoreTableAttributes struct {
+	tableType string
+	shardKeys *[]string
+	sortKeys  []string
+}
+
+var (
+	singleStoreCreateTableRegexp = regexp.MustCompile(`(?is)^CREATE\s+((?:(?:ROWSTORE|REFERENCE)\s+)*)TABLE\s`)
+	singleStoreClauseRegexp      = regexp.MustCompile(`(?is)^CREATE\s+(ROWSTORE|REFERENCE)\s|\b(SHARD|SORT)\s+KEY\b|\bUSING\s+CLUSTERED\s+COLUMNSTORE\b`)
+	singleStoreKeyRegexp         = regexp.MustCompile("(?is)^(SHARD|SORT)\\s+KEY\\s*(?:`?\\w*`?\\s*)?\\(([^)]*)\\)$")
+	singleStoreColumnstoreRegexp = regexp.MustCompile("(?is)^KEY\\s*(?:`?\\w*`?\\s*)?\\(([^)]*)\\)\\s+USING\\s+CLUSTERED\\s+COLUMNSTORE$")
+)
+
+// Strip SingleStore-specific attributes from `CREATE TABLE` so that sqlparser can parse it as MySQL.
+func extractSingleStoreTableAttributes(ddl string) (string, *singleStoreTableAttributes) {
+	attributes := &singleStoreTableAttributes{}
+	match := singleStoreCreateTableRegexp.FindStringSubmatchIndex(ddl)
+	attributes.tableType = strings.Join(strings.Fields(strings.ToUpper(ddl[match[2]:match[3]])), " ")
+	ddl = "CREATE TABLE " + ddl[match[1]:]
+
+	open := strings.Index(ddl, "(")
+	closing := strings.LastIndex(ddl, ")")
+	if open < 0 || closing < open {
+		return ddl, attributes
+	}
+	definitions := []string{}
+	for _, definition := range splitTopLevelCommas(ddl[open+1 : closing]) {
+		trimmed := strings.TrimSpace(definition)
+		if match := singleStoreKeyRegexp.FindStringSubmatch(trimmed); match != nil {
+			keys := splitKeyColumns(match[2])
+			if strings.EqualFold(match[1], "SHARD") {
+				attributes.shardKeys = &keys
+			} else {
+				attributes.sortKeys = keys
+			}
+		} else if match := singleStoreColumnstoreRegexp.FindStringSubmatch(trimmed); match != nil {
+			attributes.sortKeys = splitKeyColumns(match[1])
+		} else {
+			definitions = append(definitions, definition)
+		}
+	}
+	return ddl[:open+1] + strings.Join(definitions, ",") + ddl[closing:], attributes
+}
+
+func (a *singleStoreTableAttributes) apply(table *Table) {
+	table.tableType = a.tableType
+	table.shardKeys = a.shardKeys
+	if len(a.sortKeys) > 0 {
+		table.sortKeys = a.sortKeys
+	}
+}
+
+func splitKeyColumns(columns string) []string {
+	keys := []string{}
+	for _, column := range strings.Split(columns, ",") {
+		if column = unquoteIdentifier(column); column != "" {
+			keys = append(keys, column)
+		}
+	}
+	return keys
+}
+
+// Split a string by commas which are not enclosed by parentheses or quotes
+func splitTopLevelCommas(str string) []string {
+	var result []string
+	depth, start := 0, 0
+	var quote rune
+	for i, c := range str {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			result = append(result, str[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, str[start:])
+}
+
+// e.g. `CREATE TABLE accounts ( -- @renamed from=users`
+var renamedTableRegexp = regexp.MustCompile(`(?i)^\s*CREATE\s+TABLE\s[^\n]*?--\s*@renamed\s+from=(\S+)`)
+
+// e.g. `full_name text, -- @renamed from=name`, whose comment is dropped by sqlparser
+var renamedColumnRegexp = regexp.MustCompile("(?m)^\\s*([`\"\\[]?[^\\s`\"\\[\\],()]+[`\"\\]]?)\\s[^\\n]*?--\\s*@renamed\\s+from=([`\"\\[]?[^\\s`\"\\[\\],]+[`\"\\]]?)")
+
+// Return a map from new column names to old ones, annotated in CREATE TABLE
+func parseRenamedColumns(ddl string) map[string]string {
+	renamedColumns := map[string]string{}
+	for _, match := range renamedColumnRegexp.FindAllStringSubmatch(ddl, -1) {
+		if strings.EqualFold(match[1], "CREATE") {
+			continue // a renamed table
+		}
+		renamedColumns[strings.Trim(match[1], "`\"[]")] = strings.Trim(match[2], "`\"[]")
+	}
+	return renamedColumns
+}
+
+var backfillRegexp = regexp.MustCompile("(?m)^\\s*([`\"\\[]?[^\\s`\"\\[\\],()]+[`\"\\]]?)\\s[^\\n]*?--\\s*@backfill\\s+([^\\n]*?)\\s*$")
+
+// Return a map from column names to expressions to backfill them, annotated in CREATE TABLE
+func parseBackfills(ddl string) map[string]string {
+	backfills := map[string]string{}
+	for _, match := range backfillRegexp.FindAllStringSubmatch(ddl, -1) {
+		backfills[strings.Trim(match[1], "`\"[]")] = match[2]
+	}
+	return backfills
+}
+
+var backfillDMLRegexp = regexp.MustCompile("(?mi)^\\s*([`\"\\[]?[^\\s`\"\\[\\],()]+[`\"\\]]?)\\s[^\\n]*?--\\s*sqldef:backfill\\s+(UPDATE\\s[^\\n]*?)\\s*;?\\s*$")
+
+// Return a map from column names to UPDATE statements to backfill them, annotated in CREATE TABLE
+func parseBackfillDMLs(ddl string) map[string]string {
+	dmls := map[string]string{}
+	for _, match := range backfillDMLRegexp.FindAllStringSubmatch(ddl, -1) {
+		dmls[strings.Trim(match[1], "`\"[]")] = match[2]
+	}
+	return dmls
+}
+
+func unquoteIdentifier(name string) string {
+	return strings.Trim(strings.TrimSpace(name), "\"`")
+}
+
 // Parse `ddls`, which is expected to `;`-concatenated DDLs
 // and not to include destructive DDL.
 func ParseDDLs(mode GeneratorMode, str string) ([]DDL, error) {
+	return parseDDLs(mode, str, false)
+}
+
+func parseDDLs(mode GeneratorMode, str string, foldIdentifiers bool) ([]DDL, error) {
 	re := regexp.MustCompilePOSIX("^--.*")
-	str = re.ReplaceAllString(str, "")
+	str = re.ReplaceAllStringFunc(str, func(comment string) string {
+		if seedRegexp.MatchString(comment) {
+			return comment // kept for parseDDL
+		}
+		return ""
+	})
 
 	re = regexp.MustCompile("(?s)/\\*.*?\\*/")
 	str = re.ReplaceAllString(str, "")
@@ -480,7 +1064,7 @@ func ParseDDLs(mode GeneratorMode, str string) ([]DDL, error) {
 				break
 			}
 
-			parsed, err = parseDDL(mode, ddl)
+			parsed, err = parseDDL(mode, ddl, foldIdentifiers)
 			if err == nil || i == len(ddls) {
 				break
 			}
@@ -515,7 +1099,7 @@ func normalizeCollate(collate string, table sqlparser.TableSpec) string {
 // Qualify Postgres schema
 func normalizedTableName(mode GeneratorMode, tableName sqlparser.TableName) string {
 	table := tableName.Name.String()
-	if mode == GeneratorModePostgres {
+	if mode == GeneratorModePostgres || mode == GeneratorModeCockroach || mode == GeneratorModeRedshift {
 		if len(tableName.Qualifier.String()) > 0 {
 			table = tableName.Qualifier.String() + "." + table
 		} else {
@@ -526,7 +1110,7 @@ func normalizedTableName(mode GeneratorMode, tableName sqlparser.TableName) stri
 }
 
 func normalizedTable(mode GeneratorMode, tableName string) string {
-	if mode == GeneratorModePostgres {
+	if mode == GeneratorModePostgres || mode == GeneratorModeCockroach || mode == GeneratorModeRedshift {
 		schema, table := postgres.SplitTableName(tableName)
 		return fmt.Sprintf("%s.%s", schema, table)
 	} else {
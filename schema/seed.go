@@ -0,0 +1,349 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/k0kubun/sqldef/sqlparser"
+)
+
+// e.g. `-- @seed` before `INSERT INTO roles (id, name) VALUES (1, 'admin'), (2, 'member')`
+var seedRegexp = regexp.MustCompile(`(?m)^\s*--\s*@seed\s*$`)
+
+// Rows of a table given by INSERT statements annotated by `-- @seed`
+type seed struct {
+	table   string
+	columns []string
+	rows    [][]seedValue
+}
+
+type seedValue struct {
+	sql        string  // written to INSERT and UPDATE
+	value      *string // compared with the current value, and nil for NULL
+	expression bool    // not a literal, e.g. now(), which is written only by INSERT
+}
+
+// Return INSERT, UPDATE, and DELETE statements to make rows of tables in `-- @seed` INSERT statements the desired ones,
+// comparing them with the current rows by their primary keys. Only columns given by INSERT statements are managed.
+// currentRows returns rows of a SELECT statement, and tables existing in currentSQL are skipped if it's nil.
+func GenerateSeedDMLs(mode GeneratorMode, desiredSQL string, currentSQL string, config GeneratorConfig, currentRows func(query string) ([][]*string, error)) ([]string, error) {
+	seeds, err := parseSeeds(mode, desiredSQL)
+	if err != nil {
+		return nil, err
+	}
+	if len(seeds) == 0 {
+		return []string{}, nil
+	}
+	desiredTables, err := parseTables(mode, desiredSQL)
+	if err != nil {
+		return nil, err
+	}
+	currentTables, err := parseTables(mode, currentSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Generator{mode: mode}
+	planned := []*plannedSeed{}
+	dmls := []string{}
+	for _, seed := range seeds {
+		if config.skipDesiredTable(seed.table) {
+			continue
+		}
+		table := findTableByName(desiredTables, seed.table)
+		if table == nil {
+			return nil, fmt.Errorf("table '%s' of -- @seed is not in the desired schema", seed.table)
+		}
+		p := &plannedSeed{seed: seed}
+		for _, column := range primaryKeyColumns(*table) {
+			i := indexOfString(seed.columns, column)
+			if i < 0 {
+				return nil, fmt.Errorf("-- @seed of '%s' needs its primary key '%s'", seed.table, column)
+			}
+			p.keyIndexes = append(p.keyIndexes, i)
+		}
+		if len(p.keyIndexes) == 0 {
+			return nil, fmt.Errorf("-- @seed of '%s' needs a table having a primary key", seed.table)
+		}
+
+		if findTableByName(currentTables, seed.table) != nil {
+			if currentRows == nil {
+				continue // rows are unknown, e.g. for a schema file
+			}
+			columns := []string{}
+			for _, column := range seed.columns {
+				columns = append(columns, g.escapeSQLName(column))
+			}
+			p.rows, err = currentRows(fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), g.escapeTableName(seed.table)))
+			if err != nil {
+				return nil, err
+			}
+		}
+		planned = append(planned, p)
+
+		matched := map[int]bool{}
+		for _, desired := range seed.rows {
+			var current []*string
+			for i, row := range p.rows {
+				if !matched[i] && p.sameKey(desired, p, row) {
+					current = row
+					matched[i] = true
+					break
+				}
+			}
+			if current == nil {
+				values := []string{}
+				for _, value := range desired {
+					values = append(values, value.sql)
+				}
+				dmls = append(dmls, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", g.escapeTableName(seed.table),
+					g.escapeSQLNames(seed.columns), strings.Join(values, ", ")))
+				continue
+			}
+
+			assignments := []string{}
+			for i, value := range desired {
+				if !value.equals(current[i]) {
+					assignments = append(assignments, fmt.Sprintf("%s = %s", g.escapeSQLName(seed.columns[i]), value.sql))
+				}
+			}
+			if len(assignments) > 0 {
+				dmls = append(dmls, fmt.Sprintf("UPDATE %s SET %s WHERE %s", g.escapeTableName(seed.table),
+					strings.Join(assignments, ", "), p.where(g, desired)))
+			}
+		}
+	}
+
+	// Rows in none of -- @seed of the table are deleted, referencing tables first. Every seed of a table selects
+	// the same rows, so the ones selected for the first seed are compared with rows of all seeds by their primary keys.
+	deletes := []string{}
+	deleted := map[string]bool{}
+	for _, first := range planned {
+		if deleted[first.seed.table] {
+			continue
+		}
+		deleted[first.seed.table] = true
+
+		tableDeletes := []string{}
+		for _, current := range first.rows {
+			seeded := false
+			for _, p := range planned {
+				if p.seed.table != first.seed.table {
+					continue
+				}
+				for _, desired := range p.seed.rows {
+					seeded = seeded || p.sameKey(desired, first, current)
+				}
+			}
+			if seeded {
+				continue
+			}
+			key := make([]seedValue, len(first.seed.columns))
+			for _, j := range first.keyIndexes {
+				key[j] = newSeedLiteral(mode, current[j])
+			}
+			tableDeletes = append(tableDeletes, fmt.Sprintf("DELETE FROM %s WHERE %s", g.escapeTableName(first.seed.table), first.where(g, key)))
+		}
+		deletes = append(tableDeletes, deletes...)
+	}
+	return append(deletes, dmls...), nil
+}
+
+// A seed with the positions of its primary key, and the current rows of its columns
+type plannedSeed struct {
+	seed       *seed
+	keyIndexes []int
+	rows       [][]*string
+}
+
+// Return true if a desired row of the seed has the same primary key as a current row selected for the other seed
+func (p *plannedSeed) sameKey(desired []seedValue, other *plannedSeed, current []*string) bool {
+	for k, i := range p.keyIndexes {
+		if !desired[i].equals(current[other.keyIndexes[k]]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *plannedSeed) where(g *Generator, row []seedValue) string {
+	conditions := []string{}
+	for _, i := range p.keyIndexes {
+		conditions = append(conditions, fmt.Sprintf("%s = %s", g.escapeSQLName(p.seed.columns[i]), row[i].sql))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func parseTables(mode GeneratorMode, sql string) ([]*Table, error) {
+	ddls, err := ParseDDLs(mode, sql)
+	if err != nil {
+		return nil, err
+	}
+	return convertDDLsToTables(ddls)
+}
+
+// Parse INSERT statements annotated by `-- @seed`, merging ones for the same table and columns
+func parseSeeds(mode GeneratorMode, sql string) ([]*seed, error) {
+	seeds := []*seed{}
+	for _, loc := range seedRegexp.FindAllStringIndex(sql, -1) {
+		rest := sql[loc[1]:]
+		var insert *sqlparser.Insert
+		var err error
+		for end := 0; ; end++ {
+			next := strings.IndexByte(rest[end:], ';')
+			if next < 0 {
+				end = len(rest)
+			} else {
+				end += next
+			}
+			var stmt sqlparser.Statement
+			stmt, err = sqlparser.ParseStrictDDLWithMode(strings.TrimSpace(rest[:end]), parserMode(mode))
+			if err == nil {
+				var ok bool
+				if insert, ok = stmt.(*sqlparser.Insert); !ok {
+					return nil, fmt.Errorf("-- @seed needs to be followed by INSERT: %s", strings.TrimSpace(rest[:end]))
+				}
+				break
+			}
+			if end == len(rest) {
+				return nil, err
+			}
+		}
+
+		parsed, err := newSeed(mode, insert)
+		if err != nil {
+			return nil, err
+		}
+		merged := false
+		for _, s := range seeds {
+			if s.table == parsed.table && strings.Join(s.columns, ",") == strings.Join(parsed.columns, ",") {
+				s.rows = append(s.rows, parsed.rows...)
+				merged = true
+			}
+		}
+		if !merged {
+			seeds = append(seeds, parsed)
+		}
+	}
+	return seeds, nil
+}
+
+func newSeed(mode GeneratorMode, insert *sqlparser.Insert) (*seed, error) {
+	s := &seed{table: normalizedTableName(mode, insert.Table)}
+	for _, column := range insert.Columns {
+		s.columns = append(s.columns, column.String())
+	}
+	if len(s.columns) == 0 {
+		return nil, fmt.Errorf("INSERT of -- @seed needs columns: %s", sqlparser.String(insert))
+	}
+	values, ok := insert.Rows.(sqlparser.Values)
+	if !ok || insert.OnDup != nil {
+		return nil, fmt.Errorf("INSERT of -- @seed needs to be VALUES: %s", sqlparser.String(insert))
+	}
+	for _, tuple := range values {
+		if len(tuple) != len(s.columns) {
+			return nil, fmt.Errorf("INSERT of -- @seed has %d values for %d columns: %s", len(tuple), len(s.columns), sqlparser.String(tuple))
+		}
+		row := []seedValue{}
+		for _, expr := range tuple {
+			row = append(row, newSeedValue(mode, expr))
+		}
+		s.rows = append(s.rows, row)
+	}
+	return s, nil
+}
+
+func newSeedValue(mode GeneratorMode, expr sqlparser.Expr) seedValue {
+	switch expr := expr.(type) {
+	case *sqlparser.NullVal:
+		return seedValue{sql: "NULL"}
+	case sqlparser.BoolVal:
+		value := map[bool]string{true: "1", false: "0"}[bool(expr)]
+		if mode == GeneratorModePostgres || mode == GeneratorModeCockroach || mode == GeneratorModeRedshift {
+			value = strconv.FormatBool(bool(expr))
+		}
+		return seedValue{sql: value, value: &value}
+	case *sqlparser.SQLVal:
+		value := string(expr.Val)
+		switch expr.Type {
+		case sqlparser.StrVal:
+			return newSeedLiteral(mode, &value)
+		case sqlparser.IntVal, sqlparser.FloatVal:
+			return seedValue{sql: value, value: &value}
+		}
+	}
+	return seedValue{sql: sqlparser.String(expr), expression: true}
+}
+
+// Return a value of a current row written as a string literal, which is cast by the database
+func newSeedLiteral(mode GeneratorMode, value *string) seedValue {
+	if value == nil {
+		return seedValue{sql: "NULL"}
+	}
+	escaped := strings.ReplaceAll(*value, "'", "''")
+	if mode == GeneratorModeMysql {
+		escaped = strings.ReplaceAll(escaped, `\`, `\\`)
+	}
+	return seedValue{sql: "'" + escaped + "'", value: value}
+}
+
+// Return true if the current value is the same, e.g. 1.50 for 1.5, and t for true
+func (v seedValue) equals(current *string) bool {
+	if v.expression {
+		return true
+	}
+	if v.value == nil || current == nil {
+		return v.value == nil && current == nil
+	}
+	if *v.value == *current {
+		return true
+	}
+	if desired, err := strconv.ParseFloat(*v.value, 64); err == nil {
+		if currentFloat, err := strconv.ParseFloat(*current, 64); err == nil {
+			return desired == currentFloat
+		}
+	}
+	desired, err := strconv.ParseBool(*v.value)
+	if err != nil {
+		return false
+	}
+	currentBool, err := strconv.ParseBool(*current)
+	return err == nil && desired == currentBool
+}
+
+func primaryKeyColumns(table Table) []string {
+	columns := []string{}
+	for _, index := range table.indexes {
+		if index.primary {
+			for _, column := range index.columns {
+				columns = append(columns, column.column)
+			}
+			return columns
+		}
+	}
+	for _, column := range table.columns {
+		if column.keyOption == ColumnKeyPrimary {
+			columns = append(columns, column.name)
+		}
+	}
+	return columns
+}
+
+func (g *Generator) escapeSQLNames(names []string) string {
+	escaped := []string{}
+	for _, name := range names {
+		escaped = append(escaped, g.escapeSQLName(name))
+	}
+	return strings.Join(escaped, ", ")
+}
+
+func indexOfString(strs []string, str string) int {
+	for i, s := range strs {
+		if s == str {
+			return i
+		}
+	}
+	return -1
+}
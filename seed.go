@@ -0,0 +1,46 @@
+package sqldef
+
+import (
+	"database/sql"
+
+	"github.com/k0kubun/sqldef/adapter"
+)
+
+// Return a function to read current rows of a table having `-- @seed`, or nil if the database has no rows, e.g. a schema file
+func seedRowsReader(db adapter.Database) func(query string) ([][]*string, error) {
+	if db == nil || db.DB() == nil {
+		return nil
+	}
+	return func(query string) ([][]*string, error) {
+		rows, err := db.DB().Query(query)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		columns, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+
+		result := [][]*string{}
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return nil, err
+			}
+			row := make([]*string, len(columns))
+			for i, value := range values {
+				if value.Valid {
+					str := value.String
+					row[i] = &str
+				}
+			}
+			result = append(result, row)
+		}
+		return result, rows.Err()
+	}
+}
@@ -0,0 +1,324 @@
+package sqldef
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// Options of NewServer, given by `sqldef serve`
+type ServerOptions struct {
+	Token         string        // required as `Authorization: Bearer <token>` to plan and apply
+	ApproverToken string        // required to approve, which must differ from Token so that another requester reviews plans
+	EnableDrop    bool          // apply destructive DDLs of plans, which are skipped otherwise
+	Timeout       time.Duration // of applying a plan, after which the running DDL is cancelled and rolled back. No timeout if 0.
+	Config        schema.GeneratorConfig
+}
+
+const (
+	serverMaxBodySize = 10 << 20       // of a desired schema
+	serverPlanTTL     = 24 * time.Hour // after which plans are removed
+	serverMaxPlans    = 1000           // kept at once, removing the oldest ones
+)
+
+// Statuses of a plan of `sqldef serve`
+const (
+	planPlanned  = "planned"
+	planApproved = "approved"
+	planApplying = "applying"
+	planApplied  = "applied"
+	planFailed   = "failed"
+)
+
+// A plan of `sqldef serve`, which needs to be approved before it's applied
+type serverPlan struct {
+	ID         string       `json:"id"`
+	Status     string       `json:"status"` // planned, approved, applying, applied, or failed
+	Statements []plannedDDL `json:"statements"`
+	Output     string       `json:"output,omitempty"` // applied DDLs, printed like the commands do
+	Error      string       `json:"error,omitempty"`  // why it's failed to apply
+
+	statements  []Statement
+	fingerprint string // of the current schema when it's planned
+	createdAt   time.Time
+}
+
+type server struct {
+	mode    schema.GeneratorMode
+	db      adapter.Database
+	options ServerOptions
+
+	mutex sync.Mutex // of plans, which is not held while a plan is applied so that plans are shown meanwhile
+	plans map[string]*serverPlan
+}
+
+// Return the HTTP API of `sqldef serve` to build a review workflow of schema changes around sqldef.
+// Plans are kept in memory for a day, so they're lost when the server is restarted.
+// The approver's token approves plans, and the other token makes and applies them, so that no single requester does both.
+//
+//	POST /plans               plan DDLs to make the current schema the desired one given as the request body
+//	GET  /plans/{id}          show the plan
+//	POST /plans/{id}/approve  approve the plan by the approver
+//	POST /plans/{id}/apply    apply the approved plan, unless the current schema has changed since it's planned
+func NewServer(generatorMode schema.GeneratorMode, db adapter.Database, options ServerOptions) http.Handler {
+	return &server{mode: generatorMode, db: db, options: options, plans: map[string]*serverPlan{}}
+}
+
+// Return the tokens of ServerOptions in the environment variables. The server is never started without them,
+// since it can change the schema.
+func ServerTokens(tokenEnv string, approverTokenEnv string) (string, string, error) {
+	token := os.Getenv(tokenEnv)
+	if len(token) == 0 {
+		return "", "", fmt.Errorf("environment variable '%s' of --token-env is not set", tokenEnv)
+	}
+	approverToken := os.Getenv(approverTokenEnv)
+	if len(approverToken) == 0 {
+		return "", "", fmt.Errorf("environment variable '%s' of --approver-token-env is not set", approverTokenEnv)
+	} else if approverToken == token {
+		return "", "", fmt.Errorf("the tokens of --token-env and --approver-token-env must be different")
+	}
+	return token, approverToken, nil
+}
+
+// Serve the HTTP API of NewServer on the address of --serve, e.g. for `psqldef --serve`
+func serve(generatorMode schema.GeneratorMode, db adapter.Database, options *Options) {
+	token, approverToken, err := ServerTokens(options.TokenEnv, options.ApproverTokenEnv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config, err := schema.ParseGeneratorConfig(options.TargetTables, options.SkipTables)
+	if err != nil {
+		log.Fatal(err)
+	}
+	serverOptions := ServerOptions{Token: token, ApproverToken: approverToken, EnableDrop: options.EnableDrop, Timeout: options.Timeout, Config: config}
+
+	log.Printf("Listening on %s", options.Serve)
+	log.Fatal(http.ListenAndServe(options.Serve, NewServer(generatorMode, db, serverOptions)))
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	requester, approver := s.isToken(token, s.options.Token), s.isToken(token, s.options.ApproverToken)
+	if (!requester && !approver) || s.options.Token == s.options.ApproverToken {
+		writeServerError(w, http.StatusUnauthorized, "a valid token is required in the Authorization header")
+		return
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "plans" && r.Method == http.MethodPost && requester:
+		s.createPlan(w, r)
+	case len(path) == 2 && path[0] == "plans" && r.Method == http.MethodGet:
+		s.withPlan(w, path[1], func(plan *serverPlan) (int, error) {
+			return http.StatusOK, nil
+		})
+	case len(path) == 3 && path[0] == "plans" && path[2] == "approve" && r.Method == http.MethodPost && approver:
+		s.withPlan(w, path[1], s.approvePlan)
+	case len(path) == 3 && path[0] == "plans" && path[2] == "apply" && r.Method == http.MethodPost && requester:
+		s.applyPlan(w, path[1])
+	case len(path) >= 1 && path[0] == "plans" && r.Method == http.MethodPost:
+		writeServerError(w, http.StatusForbidden, fmt.Sprintf("%s %s is not allowed with the token", r.Method, r.URL.Path))
+	default:
+		writeServerError(w, http.StatusNotFound, fmt.Sprintf("%s %s is not found", r.Method, r.URL.Path))
+	}
+}
+
+func (s *server) isToken(token string, expected string) bool {
+	return len(expected) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+func (s *server) createPlan(w http.ResponseWriter, r *http.Request) {
+	desired, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, serverMaxBodySize))
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "http: request body too large" {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeServerError(w, status, err.Error())
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeOldPlans(serverMaxPlans - 1) // room for the new plan
+	current, err := Export(s.db, s.options.Config.SkipTable)
+	if err != nil {
+		writeServerError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	statements, err := Diff(string(desired), current, s.mode, s.options.Config)
+	if err != nil {
+		writeServerError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		writeServerError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	plan := &serverPlan{
+		ID:          hex.EncodeToString(id),
+		Status:      planPlanned,
+		Statements:  []plannedDDL{},
+		statements:  statements,
+		fingerprint: schemaFingerprint(current),
+		createdAt:   time.Now(),
+	}
+	ddls := []string{}
+	for _, statement := range statements {
+		ddls = append(ddls, statement.SQL)
+	}
+	analyzer := newImpactAnalyzer(s.mode, s.db, ddls)
+	for _, statement := range statements {
+		described := describeDDL(statement.SQL, false)
+		described.Destructive = statement.Destructive
+		described.Skipped = !s.options.EnableDrop && statement.Destructive
+		described.Risk = analyzer.risk(statement.SQL)
+		plan.Statements = append(plan.Statements, described)
+	}
+	s.plans[plan.ID] = plan
+	writeServerJSON(w, http.StatusCreated, plan)
+}
+
+// Remove plans older than serverPlanTTL, and the oldest ones over the number
+func (s *server) removeOldPlans(max int) {
+	var ids []string
+	for id, plan := range s.plans {
+		if plan.Status == planApplying {
+			continue // until its result is recorded
+		}
+		if time.Since(plan.createdAt) > serverPlanTTL {
+			delete(s.plans, id)
+		} else {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return s.plans[ids[i]].createdAt.Before(s.plans[ids[j]].createdAt)
+	})
+	for len(ids) > max {
+		delete(s.plans, ids[0])
+		ids = ids[1:]
+	}
+}
+
+// Run the handler of a plan, and respond with the plan unless the handler fails
+func (s *server) withPlan(w http.ResponseWriter, id string, handler func(plan *serverPlan) (int, error)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeOldPlans(serverMaxPlans)
+	plan, ok := s.plans[id]
+	if !ok {
+		writeServerError(w, http.StatusNotFound, fmt.Sprintf("plan '%s' is not found", id))
+		return
+	}
+	if status, err := handler(plan); err != nil {
+		writeServerError(w, status, err.Error())
+	} else {
+		writeServerJSON(w, status, plan)
+	}
+}
+
+func (s *server) approvePlan(plan *serverPlan) (int, error) {
+	if plan.Status != planPlanned {
+		return http.StatusConflict, fmt.Errorf("plan '%s' is already %s", plan.ID, plan.Status)
+	}
+	plan.Status = planApproved
+	return http.StatusOK, nil
+}
+
+// Apply the approved plan, marking it as applying not to apply it twice while the mutex is released
+func (s *server) applyPlan(w http.ResponseWriter, id string) {
+	s.mutex.Lock()
+	s.removeOldPlans(serverMaxPlans)
+	plan, ok := s.plans[id]
+	if !ok {
+		s.mutex.Unlock()
+		writeServerError(w, http.StatusNotFound, fmt.Sprintf("plan '%s' is not found", id))
+		return
+	}
+	if plan.Status != planApproved {
+		s.mutex.Unlock()
+		writeServerError(w, http.StatusConflict, fmt.Sprintf("plan '%s' is %s, but it needs to be approved to be applied", plan.ID, plan.Status))
+		return
+	}
+	plan.Status = planApplying
+	s.mutex.Unlock()
+
+	status, err := s.runPlan(plan)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err != nil {
+		plan.Status = planApproved // nothing is applied, so it can be applied again
+		writeServerError(w, status, err.Error())
+	} else {
+		writeServerJSON(w, status, plan)
+	}
+}
+
+// Apply the plan under the lock against other applies, unless the current schema has changed since it's planned
+func (s *server) runPlan(plan *serverPlan) (int, error) {
+	// Not cancelled by a disconnected client, not to leave the schema half-applied without a transaction
+	ctx := context.Background()
+	if s.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.options.Timeout)
+		defer cancel()
+	}
+	if locker, ok := s.db.(adapter.ApplyLocker); ok {
+		release, err := locker.LockApply(ctx, func() {})
+		if err != nil {
+			return http.StatusServiceUnavailable, fmt.Errorf("failed to lock the database against concurrent applies: %s", err)
+		}
+		defer release()
+	}
+
+	current, err := Export(s.db, s.options.Config.SkipTable)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if schemaFingerprint(current) != plan.fingerprint {
+		return http.StatusConflict, fmt.Errorf("the current schema has changed since plan '%s' was made. Please submit the schema again", plan.ID)
+	}
+
+	var out bytes.Buffer
+	err = Apply(ctx, s.db, plan.statements, ApplyOptions{EnableDrop: s.options.EnableDrop, Output: &out})
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	plan.Output = out.String()
+	if err != nil {
+		plan.Status = planFailed
+		plan.Error = err.Error()
+	} else {
+		plan.Status = planApplied
+	}
+	return http.StatusOK, nil
+}
+
+func writeServerJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(body)
+}
+
+func writeServerError(w http.ResponseWriter, status int, message string) {
+	writeServerJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,78 @@
+package sqldef
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// A fakeDatabase taking the lock against concurrent applies, which records the statuses of plans while it's locked
+type lockingDatabase struct {
+	*fakeDatabase
+	locked   int
+	statuses []string
+	server   http.Handler
+	id       string
+}
+
+func (d *lockingDatabase) LockApply(ctx context.Context, waiting func()) (func() error, error) {
+	d.locked++
+	d.statuses = append(d.statuses, serverRequest(d.server, "GET", "/plans/"+d.id, "secret", "")["status"].(string))
+	return func() error { return nil }, nil
+}
+
+func serverRequest(server http.Handler, method string, path string, token string, body string) map[string]interface{} {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+	result := map[string]interface{}{"code": float64(res.Code)}
+	json.NewDecoder(res.Body).Decode(&result)
+	return result
+}
+
+func TestServerApply(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1) // each connection opens another in-memory database
+	db := &lockingDatabase{fakeDatabase: &fakeDatabase{tables: map[string]string{}, db: sqlDB}}
+	defer db.Close()
+	db.server = NewServer(schema.GeneratorModeSQLite3, db, ServerOptions{Token: "secret", ApproverToken: "approver"})
+
+	plan := serverRequest(db.server, "POST", "/plans", "secret", "CREATE TABLE users (id integer);")
+	db.id = plan["id"].(string)
+	serverRequest(db.server, "POST", "/plans/"+db.id+"/approve", "approver", "")
+
+	// The plan is not applied when the current schema has changed, and it can be applied after that
+	db.tables["posts"] = "CREATE TABLE posts (id integer);"
+	db.order = []string{"posts"}
+	result := serverRequest(db.server, "POST", "/plans/"+db.id+"/apply", "secret", "")
+	if result["code"] != float64(http.StatusConflict) {
+		t.Errorf("expected 409 for a changed schema, but got %v", result)
+	}
+	db.tables, db.order = map[string]string{}, nil
+	result = serverRequest(db.server, "POST", "/plans/"+db.id+"/apply", "secret", "")
+	if result["code"] != float64(http.StatusOK) || result["status"] != planApplied {
+		t.Errorf("expected the plan to be applied, but got %v", result)
+	}
+
+	// The plan is shown while it's applied under the lock, and it's not applied twice
+	if db.locked != 2 || strings.Join(db.statuses, ",") != "applying,applying" {
+		t.Errorf("expected the lock taken twice while the plan is applying, but got %d times by %v", db.locked, db.statuses)
+	}
+	result = serverRequest(db.server, "POST", "/plans/"+db.id+"/apply", "secret", "")
+	if result["code"] != float64(http.StatusConflict) {
+		t.Errorf("expected 409 for an applied plan, but got %v", result)
+	}
+	if _, err := sqlDB.Exec("SELECT id FROM users"); err != nil {
+		t.Errorf("expected the applied table: %s", err)
+	}
+}
@@ -0,0 +1,54 @@
+package sqldef
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// The current schema saved by --snapshot, which --against-snapshot compares with the desired one without the database
+type schemaSnapshot struct {
+	Dialect string    `json:"dialect"`
+	TakenAt time.Time `json:"taken_at"`
+	Schema  string    `json:"schema"` // in the form of --export
+}
+
+var snapshotDialects = map[schema.GeneratorMode]string{
+	schema.GeneratorModeMysql:     "mysql",
+	schema.GeneratorModePostgres:  "postgres",
+	schema.GeneratorModeSQLite3:   "sqlite3",
+	schema.GeneratorModeMssql:     "mssql",
+	schema.GeneratorModeCockroach: "cockroach",
+	schema.GeneratorModeRedshift:  "redshift",
+}
+
+func writeSnapshot(path string, generatorMode schema.GeneratorMode, currentDDLs string) error {
+	buf, err := json.MarshalIndent(schemaSnapshot{
+		Dialect: snapshotDialects[generatorMode],
+		TakenAt: time.Now().UTC().Truncate(time.Second),
+		Schema:  currentDDLs,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// Read the schema saved by --snapshot, which must be taken from a database of the same dialect
+func ReadSnapshot(path string, generatorMode schema.GeneratorMode) (string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var snapshot schemaSnapshot
+	if err := json.Unmarshal(buf, &snapshot); err != nil || len(snapshot.Dialect) == 0 {
+		return "", fmt.Errorf("'%s' is not a snapshot written by --snapshot", path)
+	}
+	if snapshot.Dialect != snapshotDialects[generatorMode] {
+		return "", fmt.Errorf("'%s' is a snapshot of %s, not %s", path, snapshot.Dialect, snapshotDialects[generatorMode])
+	}
+	return snapshot.Schema, nil
+}
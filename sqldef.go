@@ -1,33 +1,213 @@
 package sqldef
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/k0kubun/sqldef/adapter"
 	"github.com/k0kubun/sqldef/schema"
+	"github.com/k0kubun/sqldef/schema/batch"
 )
 
 type Options struct {
-	DesiredFile string
-	CurrentFile string
-	DryRun      bool
-	Export      bool
-	SkipDrop    bool
-	BeforeApply string
+	DesiredFiles     []string
+	CurrentFile      string
+	DesiredDB        adapter.Database // given by --desired-db, whose schema is used instead of DesiredFiles
+	DryRun           bool
+	Check            bool
+	Watch            bool
+	Interval         time.Duration // of --watch
+	Webhook          string        // a URL to post changes of the drift found by --watch
+	Serve            string        // an address to serve the HTTP API of NewServer on, instead of applying the desired schema
+	TokenEnv         string        // an environment variable of the token of --serve to plan and apply
+	ApproverTokenEnv string        // an environment variable of the token of --serve to approve
+	Interactive      bool          // review each DDL before applying them
+	Lint             bool
+	Normalize        bool
+	Config           string // the config file given by --config
+	Export           bool
+	ExportDir        string
+	Snapshot         string // a file to save the current schema to, for --against-snapshot
+	AgainstSnapshot  string // a file saved by --snapshot, used as the current schema instead of the database
+	GenerateGo       string // the package name given by --generate-go
+	ExportFormat     string // mermaid or dot to print an ER diagram, or json to print the inventory of the schema
+	ExpandEnv        bool
+	Template         string
+	Env              string   // the environment to keep -- sqldef:if env=name blocks for
+	Variables        []string // name=value of psql variables, which are also set by \set in the schema. Only psqldef, cockroachdef, and redshiftdef
+	Output           string   // "text", "json", "markdown", "migration", or "liquibase"
+	OutputFile       string   // a file to write the output to instead of stdout
+	Quiet            bool     // print nothing, and exit with 2 when there are differences like --check
+	NoColor          bool
+	Compact          bool   // print generated DDLs as they are, without formatting CREATE TABLE
+	QuoteIdentifiers string // "always", "auto", or "never"
+	FoldIdentifiers  bool   // Only psqldef, mysqldef, cockroachdef, and redshiftdef
+	Schema           string // Only psqldef: a schema to apply the desired schema to, qualifying its names
+	Plan             string
+	Rollback         string
+	ApplyPlan        string
+	SkipDrop         bool
+	EnableDrop       bool
+	BeforeApply      string
+	AfterApply       string
+	PreApplyHook     string // a shell command run before applying DDLs, which aborts it by failing
+	PostApplyHook    string // a shell command run after applying DDLs
+	Progress         bool   // print progress of each DDL and a timing summary to stderr
+	Summary          bool   // print numbers of changes and durations after DDLs
+	NoTransaction    bool   // Only psqldef and mysqldef
+	PgBouncer        bool   // Only psqldef, which commits each DDL
+	NoApplyLock      bool   // Only psqldef and mysqldef
+	ApplyConcurrency int    // Only psqldef, mysqldef, mssqldef, cockroachdef, and redshiftdef
+	SafeTypeChange   bool   // Only psqldef and mysqldef
+	SafeNotNull      bool   // Only psqldef, cockroachdef, and mysqldef
+	Descriptions     bool   // Only psqldef and mysqldef
+	Impact           bool   // Only psqldef and mysqldef
+	MaxRisk          string // "safe", "blocking", "destructive", or empty
+	Policy           string
+	Only             []string // objects or numbers of DDLs to apply, deferring the others
+	Retry            int
+	RetryWait        time.Duration
+	Timeout          time.Duration
+	TargetTables     []string
+	SkipTables       []string
+	Manifest         string
+	LockFile         string // a file to record fingerprints of the applied schema, to warn out-of-band changes
+	Resume           string // a file to record DDLs not applied yet, to continue a failed apply from the first of them
+	History          bool   // record applied DDLs to the sqldef_history table
+	AuditLog         string // a file to append a JSON line to for each run
+	Target           string // the database recorded by --audit-log, e.g. user@host:port/dbname
+	Version          string // of the command, recorded by --history
+
+	// Given by --output=migration
+	MigrationDir    string
+	MigrationFormat string // "golang-migrate" or "flyway"
 }
 
 // Main function shared by `mysqldef` and `psqldef`
 func Run(generatorMode schema.GeneratorMode, db adapter.Database, options *Options) {
-	currentDDLs, err := adapter.DumpDDLs(db)
+	if options.Quiet {
+		options.Check = true
+	}
+	if len(options.Serve) > 0 {
+		serve(generatorMode, db, options)
+		return
+	}
+	if len(options.OutputFile) > 0 || options.Quiet {
+		restore, err := redirectStdout(options.OutputFile, options.Quiet)
+		if err != nil {
+			log.Fatalf("Failed to open '%s': %s", options.OutputFile, err)
+		}
+		defer restore()
+	}
+
+	timer := exitOnTimeout(options.Timeout)
+	deadline := time.Now().Add(options.Timeout)
+
+	config, err := schema.ParseGeneratorConfig(options.TargetTables, append(append([]string{}, options.SkipTables...), historyTable))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var managedTables []string
+	if len(options.Manifest) > 0 {
+		managedTables, err = readManifest(options.Manifest)
+		if err != nil {
+			log.Fatalf("Failed to read '%s': %s", options.Manifest, err)
+		}
+		config = config.WithManagedTables(managedTables)
+	}
+	if options.SafeTypeChange {
+		config = config.WithSafeTypeChange()
+	}
+	if options.SafeNotNull {
+		config = config.WithSafeNotNull()
+	}
+	if len(options.Schema) > 0 {
+		config = config.WithSchema(options.Schema)
+	}
+	if len(options.QuoteIdentifiers) > 0 {
+		config = config.WithQuoteIdentifiers(options.QuoteIdentifiers)
+	}
+	if options.FoldIdentifiers {
+		config, err = foldIdentifiers(generatorMode, db, config)
+		if err != nil {
+			log.Fatalf("Failed to read lower_case_table_names: %s", err)
+		}
+	}
+
+	if options.Lint {
+		lintSchema(generatorMode, options)
+		return
+	}
+	if options.Normalize {
+		normalizeSchema(generatorMode, config, options)
+		return
+	}
+	if len(options.GenerateGo) > 0 && !options.Export {
+		generateGo(generatorMode, readDesiredDDLs(generatorMode, readFiles(generatorMode, options.DesiredFiles), options), config, options)
+		return
+	}
+	if len(options.ExportFormat) > 0 && !options.Export {
+		printExportFormat(generatorMode, readDesiredDDLs(generatorMode, readFiles(generatorMode, options.DesiredFiles), options), config, options)
+		return
+	}
+
+	skipTable := config.SkipTable
+	if len(options.CurrentFile) > 0 || len(options.AgainstSnapshot) > 0 {
+		skipTable = nil // FileDatabase's table name is a file name. Parsed DDLs are filtered instead.
+	}
+	if len(options.ExportDir) > 0 {
+		if err := exportDir(db, options.ExportDir, skipTable); err != nil {
+			log.Fatalf("Failed to export to '%s': %s", options.ExportDir, err)
+		}
+		return
+	}
+
+	if options.Watch {
+		watchDrift(generatorMode, db, skipTable, config, options)
+		return
+	}
+
+	// Lock before dumping the current schema, so that DDLs are generated against changes of a concurrent run
+	dryRun := options.DryRun || options.Check || len(options.CurrentFile) > 0 || len(options.AgainstSnapshot) > 0 || len(options.Plan) > 0
+	migrating := options.Output == "migration" || options.Output == "liquibase" // DDLs are written for another tool to apply them
+	applying := !dryRun && !options.Export && len(options.Snapshot) == 0 && !migrating
+	if locker, ok := db.(adapter.ApplyLocker); ok && applying && !options.NoApplyLock {
+		release, err := lockApply(locker)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer release()
+	}
+
+	currentDDLs, err := adapter.DumpDDLs(db, skipTable)
 	if err != nil {
 		log.Fatal(fmt.Sprintf("Error on DumpDDLs: %s", err))
 	}
 
+	if len(options.Snapshot) > 0 {
+		if err := writeSnapshot(options.Snapshot, generatorMode, currentDDLs); err != nil {
+			log.Fatalf("Failed to write '%s': %s", options.Snapshot, err)
+		}
+		return
+	}
+
 	if options.Export {
+		if len(options.GenerateGo) > 0 {
+			generateGo(generatorMode, currentDDLs, config, options)
+			return
+		}
+		if len(options.ExportFormat) > 0 {
+			printExportFormat(generatorMode, currentDDLs, config, options)
+			return
+		}
 		if currentDDLs == "" {
 			fmt.Printf("-- No table exists --\n")
 		} else {
@@ -36,52 +216,507 @@ func Run(generatorMode schema.GeneratorMode, db adapter.Database, options *Optio
 		return
 	}
 
-	sql, err := ReadFile(options.DesiredFile)
+	var audit *auditLog
+	if len(options.AuditLog) > 0 {
+		target := options.Target
+		if len(options.CurrentFile) > 0 {
+			target = options.CurrentFile
+		}
+		audit = startAuditLog(options.AuditLog, target)
+		defer audit.finish()
+	}
+
+	if len(options.LockFile) > 0 {
+		if err := checkLockFile(options.LockFile, currentDDLs); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Destructive changes are skipped by default to protect databases from a truncated schema file
+	skipDrop := options.SkipDrop || !options.EnableDrop
+
+	var resumedDDLs []string
+	if len(options.Resume) > 0 {
+		resumedDDLs, err = readResumeFile(options.Resume)
+		if err != nil {
+			log.Fatalf("Failed to read '%s': %s", options.Resume, err)
+		}
+	}
+
+	var ddls []string
+	var desiredDDLs string
+	if resumedDDLs != nil {
+		if len(options.Rollback) > 0 || migrating {
+			log.Fatalf("--rollback and --output=%s can't be used to resume an apply, since they need the desired schema.", options.Output)
+		}
+		fmt.Fprintf(os.Stderr, "-- Resuming %d DDLs not applied yet in '%s' --\n", len(resumedDDLs), options.Resume)
+		ddls = resumedDDLs
+		skipDrop = false // skipped DDLs are not recorded
+	} else if len(options.ApplyPlan) > 0 {
+		if len(options.Rollback) > 0 || migrating {
+			log.Fatalf("--rollback and --output=%s can't be used with --apply, since they need the desired schema.", options.Output)
+		}
+		ddls, err = readPlan(options.ApplyPlan, currentDDLs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		skipDrop = false // skipped DDLs are already excluded from the plan
+	} else {
+		ddls, desiredDDLs = generateDDLs(generatorMode, db, currentDDLs, config, options)
+	}
+	if len(options.Only) > 0 {
+		ddls, err = selectOnlyDDLs(ddls, options.Only)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if options.SafeTypeChange && !options.NoTransaction && !(dryRun || migrating) {
+		for _, ddl := range ddls {
+			// Batches of a backfill in a transaction hold locks of all rows until the end, and writes to them can't be synced
+			if strings.HasSuffix(ddl, batch.Suffix) {
+				log.Fatal("--safe-type-change is applied only with --no-transaction, while the table is not written")
+			}
+		}
+	}
+	audit.plan(ddls, skipDrop, dryRun || migrating)
+
+	analyzer := newImpactAnalyzer(generatorMode, db, ddls)
+	if generatorMode == schema.GeneratorModePostgres || generatorMode == schema.GeneratorModeMysql {
+		if err := checkServerVersion(generatorMode, analyzer.serverVersion(), ddls, skipDrop, options.NoTransaction); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(options.MaxRisk) > 0 {
+		if err := checkMaxRisk(analyzer, ddls, skipDrop, options.MaxRisk); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(options.Policy) > 0 {
+		if err := checkPolicy(options.Policy, ddls, skipDrop); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(options.Plan) > 0 {
+		if err := writePlan(options.Plan, ddls, skipDrop, currentDDLs); err != nil {
+			log.Fatalf("Failed to write '%s': %s", options.Plan, err)
+		}
+	}
+	if len(options.Rollback) > 0 {
+		if err := writeRollback(options.Rollback, generatorMode, ddls, skipDrop, currentDDLs, desiredDDLs, config); err != nil {
+			log.Fatalf("Failed to write '%s': %s", options.Rollback, err)
+		}
+	}
+
+	if options.Output == "migration" {
+		audit.finish()
+		showMigration(generatorMode, ddls, skipDrop, currentDDLs, desiredDDLs, config, options)
+		return
+	}
+	if options.Output == "liquibase" {
+		audit.finish()
+		showLiquibase(generatorMode, ddls, skipDrop, currentDDLs, desiredDDLs, config, options)
+		return
+	}
+
+	if dryRun && options.Output == "json" {
+		var summary *planSummary
+		if options.Summary {
+			s := newPlanSummary(ddls, skipDrop, analyzer)
+			summary = &s
+		}
+		showJSONDDLs(ddls, skipDrop, analyzer, options.Impact, summary)
+		audit.finish() // before exiting by --check
+		exitOnDrift(ddls, options)
+		return
+	}
+	if dryRun && options.Output == "markdown" {
+		showMarkdownDDLs(ddls, skipDrop, analyzer, options.Impact)
+		audit.finish()
+		exitOnDrift(ddls, options)
+		return
+	}
+	if len(ddls) == 0 {
+		if adapter.JSONLog() && !dryRun {
+			logSummary(ddls, skipDrop, time.Now())
+		} else {
+			fmt.Println("-- Nothing is modified --")
+		}
+		if len(options.LockFile) > 0 && !dryRun {
+			if err := writeLockFile(options.LockFile, desiredDDLs, currentDDLs); err != nil {
+				log.Fatalf("Failed to write '%s': %s", options.LockFile, err)
+			}
+		}
+		if resumedDDLs != nil && !dryRun {
+			if err := os.Remove(options.Resume); err != nil {
+				log.Fatalf("Failed to remove '%s': %s", options.Resume, err)
+			}
+		}
+		return
+	}
+
+	if dryRun {
+		showDDLs(ddls, skipDrop, options.BeforeApply, options.AfterApply, newDDLFormatter(options.NoColor, analyzer, options.Impact))
+		if options.Summary {
+			fmt.Println(newPlanSummary(ddls, skipDrop, analyzer))
+		}
+		audit.finish()
+		exitOnDrift(ddls, options)
+		return
+	}
+
+	if options.Interactive {
+		in, out, closeTerminal, err := openReviewTerminal(options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		approved, quit, err := reviewDDLs(ddls, skipDrop, analyzer, in, out)
+		closeTerminal()
+		if err != nil {
+			log.Fatal(err)
+		} else if quit {
+			log.Fatal("Aborted by --interactive without applying any DDL.")
+		}
+		ddls = approved
+		audit.plan(ddls, skipDrop, false)
+		if !hasAppliedDDLs(ddls, skipDrop) {
+			fmt.Println("-- No DDL is approved --")
+			return
+		}
+	}
+
+	hooked := hasAppliedDDLs(ddls, skipDrop)
+	if hooked && len(options.PreApplyHook) > 0 {
+		if err := runApplyHook("pre", options.PreApplyHook, ddls, skipDrop); err != nil {
+			log.Fatalf("Aborted before applying DDLs, since %s", err)
+		}
+	}
+
+	ctx, cancel := applyContext(timer, deadline)
+	defer cancel()
+	var progressOut io.Writer
+	if options.Progress {
+		progressOut = os.Stderr
+	}
+	transactional := transactionalApply(generatorMode, options)
+	var resume *resumeFile
+	if len(options.Resume) > 0 {
+		resume, err = newResumeFile(options.Resume, ddls, skipDrop)
+		if err != nil {
+			log.Fatalf("Failed to write '%s': %s", options.Resume, err)
+		}
+	}
+	var history []appliedDDL
+	onApplied := func(ddl string, duration time.Duration) {
+		if resume != nil && !transactional {
+			resume.applied(ddl)
+		}
+		history = append(history, appliedDDL{statement: ddl, duration: duration})
+	}
+	var summary planSummary
+	if options.Summary {
+		summary = newPlanSummary(ddls, skipDrop, analyzer) // estimated before the apply changes sizes of tables
+	}
+	start := time.Now()
+	err = adapter.RunDDLs(ctx, db, ddls, skipDrop, options.BeforeApply, options.AfterApply, options.NoTransaction,
+		options.ApplyConcurrency, adapter.Retry{Count: options.Retry, Wait: options.RetryWait}, os.Stdout, progressOut, onApplied)
+	if options.History && (err == nil || !transactional) { // DDLs failed in a transaction are rolled back
+		if err := recordHistory(generatorMode, db, history, ddls, options.Version); err != nil {
+			fmt.Fprintf(os.Stderr, "-- WARNING: Failed to record applied DDLs to %s: %s --\n", historyTable, err)
+		}
+	}
 	if err != nil {
-		log.Fatalf("Failed to read '%s': %s", options.DesiredFile, err)
+		if resume != nil {
+			log.Fatalf("%s\nDDLs not applied yet are recorded in '%s'. Run it again with --resume to continue from the first of them.", err, options.Resume)
+		}
+		log.Fatal(err)
+	}
+	if resume != nil {
+		if err := resume.remove(); err != nil {
+			log.Fatalf("Failed to remove '%s': %s", options.Resume, err)
+		}
+	}
+	audit.succeeded()
+	if adapter.JSONLog() {
+		logSummary(ddls, skipDrop, start)
+	} else if options.Summary {
+		fmt.Println(summary.took(time.Since(start)))
 	}
-	desiredDDLs := sql
 
-	ddls, err := schema.GenerateIdempotentDDLs(generatorMode, desiredDDLs, currentDDLs)
+	if len(options.Manifest) > 0 {
+		err = appendManifest(options.Manifest, managedTables, schema.CreatedTableNames(generatorMode, ddls))
+		if err != nil {
+			log.Fatalf("Failed to update '%s': %s", options.Manifest, err)
+		}
+	}
+	if len(options.LockFile) > 0 {
+		appliedDDLs, err := adapter.DumpDDLs(db, skipTable)
+		if err != nil {
+			log.Fatalf("Error on DumpDDLs after applying DDLs: %s", err)
+		}
+		if err := writeLockFile(options.LockFile, desiredDDLs, appliedDDLs); err != nil {
+			log.Fatalf("Failed to write '%s': %s", options.LockFile, err)
+		}
+	}
+	if hooked && len(options.PostApplyHook) > 0 {
+		if err := runApplyHook("post", options.PostApplyHook, ddls, skipDrop); err != nil {
+			log.Fatalf("DDLs were applied, but %s", err)
+		}
+	}
+}
+
+// Wait for another process applying DDLs to the database, which is given up by --lock-timeout or --timeout
+func lockApply(locker adapter.ApplyLocker) (func() error, error) {
+	release, err := locker.LockApply(context.Background(), func() {
+		fmt.Fprintln(os.Stderr, "-- Waiting for another sqldef applying DDLs to the database --")
+	})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("Failed to lock the database against concurrent applies: %s", err)
 	}
-	if len(ddls) == 0 {
+	return release, nil
+}
+
+// Compare identifiers as the database resolves them: PostgreSQL lowers unquoted ones, and MySQL compares table names
+// case-insensitively unless lower_case_table_names is 0. It's unknown for --current-file, where table names are kept.
+func foldIdentifiers(generatorMode schema.GeneratorMode, db adapter.Database, config schema.GeneratorConfig) (schema.GeneratorConfig, error) {
+	switch generatorMode {
+	case schema.GeneratorModePostgres, schema.GeneratorModeCockroach, schema.GeneratorModeRedshift:
+		return config.WithFoldedIdentifiers(), nil
+	case schema.GeneratorModeMysql:
+		if folder, ok := db.(adapter.TableNameFolder); ok {
+			lowerCaseTableNames, err := folder.LowerCaseTableNames()
+			if err != nil {
+				return config, err
+			}
+			if lowerCaseTableNames != 0 {
+				return config.WithLowerCaseTableNames(), nil
+			}
+		}
+	}
+	return config, nil
+}
+
+// Write a pair of up and down migration files to --migration-dir instead of applying DDLs
+func showMigration(generatorMode schema.GeneratorMode, ddls []string, skipDrop bool, currentDDLs string, desiredDDLs string, config schema.GeneratorConfig, options *Options) {
+	if len(options.MigrationDir) == 0 {
+		log.Fatal("--output=migration needs --migration-dir")
+	}
+	applied := 0
+	for _, ddl := range ddls {
+		if !skipDrop || !adapter.IsDropDDLIn(ddls, ddl) {
+			applied++
+		}
+	}
+	if applied == 0 {
 		fmt.Println("-- Nothing is modified --")
 		return
 	}
 
-	if options.DryRun || len(options.CurrentFile) > 0 {
-		showDDLs(ddls, options.SkipDrop, options.BeforeApply)
-		return
+	paths, err := writeMigration(options.MigrationDir, options.MigrationFormat, generatorMode, ddls, skipDrop, currentDDLs, desiredDDLs, config)
+	if err != nil {
+		log.Fatalf("Failed to write a migration to '%s': %s", options.MigrationDir, err)
+	}
+	fmt.Println("-- Migration --")
+	for _, path := range paths {
+		fmt.Println(path)
 	}
+	exitOnDrift(ddls, options)
+}
 
-	err = adapter.RunDDLs(db, ddls, options.SkipDrop, options.BeforeApply)
+// Print a "summary" event of --log-format=json after applying DDLs
+func logSummary(ddls []string, skipDrop bool, start time.Time) {
+	skipped := 0
+	for _, ddl := range ddls {
+		if skipDrop && adapter.IsDropDDLIn(ddls, ddl) {
+			skipped++
+		}
+	}
+	adapter.LogEvent("summary", map[string]interface{}{
+		"applied":     len(ddls) - skipped,
+		"skipped":     skipped,
+		"duration_ms": adapter.DurationMs(time.Since(start)),
+		"plan":        newPlanSummary(ddls, skipDrop, nil),
+	})
+}
+
+// With --check, exit with 2 when the schema has drifted, reserving 1 for errors
+func exitOnDrift(ddls []string, options *Options) {
+	if options.Check && len(ddls) > 0 {
+		os.Exit(2)
+	}
+}
+
+// Print the desired schema in the form of --export, to compare it with an exported one as text
+func normalizeSchema(generatorMode schema.GeneratorMode, config schema.GeneratorConfig, options *Options) {
+	ddls, err := schema.NormalizeDDLs(generatorMode, readDesiredDDLs(generatorMode, readFiles(generatorMode, options.DesiredFiles), options), config)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if len(ddls) == 0 {
+		fmt.Printf("-- No table exists --\n")
+	} else {
+		fmt.Printf("%s;\n", strings.Join(ddls, ";\n\n"))
+	}
 }
 
-// TODO: Warn if both the second --file and database options are specified
-func ParseFiles(files []string) (string, string) {
+// Print Go structs of tables in the desired schema, or the current one with --export
+func generateGo(generatorMode schema.GeneratorMode, sql string, config schema.GeneratorConfig, options *Options) {
+	source, err := schema.GenerateGoStructs(generatorMode, sql, config, options.GenerateGo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(source)
+}
+
+// Print an ER diagram or a JSON inventory of the desired schema, or the current one with --export
+func printExportFormat(generatorMode schema.GeneratorMode, sql string, config schema.GeneratorConfig, options *Options) {
+	var out string
+	var err error
+	if options.ExportFormat == "json" {
+		out, err = schema.GenerateInventory(generatorMode, sql, config)
+	} else {
+		out, err = schema.GenerateERD(generatorMode, sql, config, options.ExportFormat)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(out)
+}
+
+// Generate DDLs from the current schema and desired files or --desired-db, and return them with the desired schema
+func generateDDLs(generatorMode schema.GeneratorMode, db adapter.Database, currentDDLs string, config schema.GeneratorConfig, options *Options) ([]string, string) {
+	var desiredDDLs string
+	if options.DesiredDB != nil {
+		var err error
+		desiredDDLs, err = adapter.DumpDDLs(options.DesiredDB, config.SkipTable)
+		if err != nil {
+			log.Fatalf("Error on DumpDDLs of the desired database: %s", err)
+		}
+	} else {
+		desiredDDLs = readDesiredDDLs(generatorMode, readFiles(generatorMode, options.DesiredFiles), options)
+	}
+
+	ddls, err := schema.GenerateIdempotentDDLs(generatorMode, desiredDDLs, currentDDLs, config)
+	if err != nil {
+		fmt.Fprintln(log.Writer(), err) // stderr, or an "error" event with --log-format=json
+		os.Exit(1)
+	}
+	if !options.Compact {
+		ddls = formatDDLs(generatorMode, ddls, desiredDDLs)
+	}
+	if options.DesiredDB == nil {
+		dmls, err := schema.GenerateSeedDMLs(generatorMode, desiredDDLs, currentDDLs, config, seedRowsReader(db))
+		if err != nil {
+			fmt.Fprintln(log.Writer(), err)
+			os.Exit(1)
+		}
+		ddls = append(ddls, dmls...)
+	}
+	return ddls, desiredDDLs
+}
+
+// Read desired files, converting YAML schemas to the schema SQL of the dialect
+func readFiles(generatorMode schema.GeneratorMode, files []string) []string {
+	var sqls []string
+	for _, file := range files {
+		sql, err := ReadFile(file)
+		if err != nil {
+			log.Fatalf("Failed to read '%s': %s", file, err)
+		}
+		if isYAMLSchema(file) {
+			sql, err = convertYAMLSchema(generatorMode, sql)
+			if err != nil {
+				log.Fatalf("Failed to convert '%s': %s", file, err)
+			}
+		}
+		if generatorMode == schema.GeneratorModePostgres && isPgDump(sql) {
+			sql = convertPgDump(sql)
+		}
+		sqls = append(sqls, sql)
+	}
+	return sqls
+}
+
+// Join desired files, processed by -- sqldef:if blocks, psql variables, --template, --expand-env, and --descriptions
+func readDesiredDDLs(generatorMode schema.GeneratorMode, sqls []string, options *Options) string {
+	sections := make([]string, len(sqls))
+	for i, sql := range sqls {
+		section, err := applyConditionals(sql, options.Env)
+		if err != nil {
+			log.Fatalf("Failed to read -- sqldef:if blocks: %s", err)
+		}
+		sections[i] = section
+	}
+	desiredDDLs := joinFiles(sections)
+	var err error
+	if generatorMode == schema.GeneratorModePostgres || generatorMode == schema.GeneratorModeCockroach || generatorMode == schema.GeneratorModeRedshift {
+		variables, err := parseVariables(options.Variables)
+		if err != nil {
+			log.Fatal(err)
+		}
+		desiredDDLs, err = substituteVariables(desiredDDLs, variables)
+		if err != nil {
+			log.Fatalf("Failed to substitute psql variables: %s", err)
+		}
+	}
+	if len(options.Template) > 0 {
+		desiredDDLs, err = executeTemplate(desiredDDLs, options.Template)
+		if err != nil {
+			log.Fatalf("Failed to execute --template: %s", err)
+		}
+	}
+	if options.ExpandEnv {
+		desiredDDLs, err = expandEnv(desiredDDLs)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if options.Descriptions {
+		desiredDDLs, err = schema.ConvertDescriptions(generatorMode, desiredDDLs)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	return desiredDDLs
+}
+
+// Return desired files and a current file. All files are desired ones when a database is given.
+// Otherwise, the first one of multiple files is compared with the rest of them.
+func ParseFiles(files []string, database bool) ([]string, string) {
 	if len(files) == 0 {
 		panic("ParseFiles got empty files") // assume default:"-"
 	}
 
-	desiredFile := files[0]
-	currentFile := ""
-	if len(files) == 2 {
-		desiredFile = files[1]
-		currentFile = files[0]
-	} else if len(files) > 2 {
-		fmt.Printf("Expected only one or two --file options, but got: %v\n", files)
-		os.Exit(1)
+	if database || len(files) == 1 {
+		return files, ""
 	}
-	return desiredFile, currentFile
+	return files[1:], files[0]
 }
 
+// Read a file, "-" for stdin, or all *.sql files in a directory or matching a glob like schema/**/*.sql, expanding include directives
 func ReadFile(filepath string) (string, error) {
+	if filepath != "-" {
+		files, err := expandFiles(filepath)
+		if err != nil {
+			return "", err
+		}
+		if len(files) != 1 || files[0] != filepath {
+			var sqls []string
+			for _, file := range files {
+				buf, err := ioutil.ReadFile(file)
+				if err != nil {
+					return "", err
+				}
+				sql, err := expandIncludes(string(buf), file)
+				if err != nil {
+					return "", err
+				}
+				sqls = append(sqls, sql)
+			}
+			return joinFiles(sqls), nil
+		}
+	}
+
 	var err error
 	var buf []byte
 
@@ -99,19 +734,121 @@ func ReadFile(filepath string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return string(buf), nil
+	return expandIncludes(string(buf), filepath)
+}
+
+// Only ${VAR} is expanded, not to break $1 or $$ in PostgreSQL functions
+var envVarRegexp = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Expand ${VAR} in the schema with environment variables, e.g. role names differing between environments
+func expandEnv(sql string) (string, error) {
+	var err error
+	expanded := envVarRegexp.ReplaceAllStringFunc(sql, func(match string) string {
+		name := envVarRegexp.FindStringSubmatch(match)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable '%s' is not set for --expand-env", name)
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// Concatenate files in order, without letting a statement continue to the next file
+func joinFiles(sqls []string) string {
+	return strings.Join(sqls, "\n;\n")
 }
 
-func showDDLs(ddls []string, skipDrop bool, beforeApply string) {
+// Expand a directory or a glob into files in lexical order. `**/` matches zero or more directories.
+func expandFiles(pattern string) ([]string, error) {
+	if stat, err := os.Stat(pattern); err == nil {
+		if !stat.IsDir() {
+			return []string{pattern}, nil
+		}
+		return walkFiles(pattern, func(path string) bool { return filepath.Ext(path) == ".sql" })
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil // let ReadFile return the error
+	}
+
+	// Walk the directory before the first wildcard, and match paths with the whole pattern
+	root := filepath.Dir(pattern[:strings.IndexAny(pattern, "*?[")] + "x")
+	re, err := globToRegexp(filepath.ToSlash(filepath.Clean(pattern)))
+	if err != nil {
+		return nil, err
+	}
+	files, err := walkFiles(root, func(path string) bool { return re.MatchString(filepath.ToSlash(path)) })
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file matches '%s'", pattern)
+	}
+	return files, nil
+}
+
+func walkFiles(root string, match func(path string) bool) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && match(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				re.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				re.WriteString(".*")
+				i++
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '[' in '%s'", glob)
+			}
+			re.WriteString(glob[i : i+end+1])
+			i += end
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+func showDDLs(ddls []string, skipDrop bool, beforeApply string, afterApply string, formatter *ddlFormatter) {
 	fmt.Println("-- dry run --")
 	if len(beforeApply) > 0 {
 		fmt.Println(beforeApply)
 	}
+	skipped := 0
 	for _, ddl := range ddls {
-		if skipDrop && strings.Contains(ddl, "DROP") {
-			fmt.Printf("-- Skipped: %s;\n", ddl)
+		if skipDrop && adapter.IsDropDDLIn(ddls, ddl) {
+			formatter.println(ddl, fmt.Sprintf("-- Skipped: %s;", ddl))
+			skipped++
 			continue
 		}
-		fmt.Printf("%s;\n", ddl)
+		formatter.println(ddl, fmt.Sprintf("%s;", ddl))
+	}
+	if len(afterApply) > 0 {
+		fmt.Println(afterApply)
 	}
+	adapter.PrintSkippedDrops(os.Stdout, skipped)
 }
@@ -65,7 +65,18 @@ func ParseStrictDDL(sql string) (Statement, error) {
 }
 
 func ParseStrictDDLWithMode(sql string, mode ParserMode) (Statement, error) {
+	return parseStrictDDL(NewStringTokenizer(sql, mode), sql)
+}
+
+// ParseStrictDDLFoldingIdentifiers is the same as ParseStrictDDLWithMode except it
+// lowers unquoted identifiers, which is how PostgreSQL resolves them.
+func ParseStrictDDLFoldingIdentifiers(sql string, mode ParserMode) (Statement, error) {
 	tokenizer := NewStringTokenizer(sql, mode)
+	tokenizer.FoldIdentifiers = true
+	return parseStrictDDL(tokenizer, sql)
+}
+
+func parseStrictDDL(tokenizer *Tokenizer, sql string) (Statement, error) {
 	if yyParse(tokenizer) != 0 {
 		return nil, fmt.Errorf(
 			"found syntax error when parsing DDL \"%s\": %v", sql, tokenizer.LastError,
@@ -726,6 +737,35 @@ func (node *DDL) Format(buf *TrackedBuffer) {
 		} else {
 			buf.Myprintf("%s table %v", node.Action, node.Table)
 		}
+	case CreateIndexStr:
+		buf.Myprintf("create ")
+		if node.IndexSpec.Unique {
+			buf.Myprintf("unique ")
+		}
+		buf.Myprintf("index %v on %v", node.IndexSpec.Name, node.Table)
+		if node.IndexSpec.Type.String() != "" {
+			buf.Myprintf(" using %v", node.IndexSpec.Type)
+		}
+		formatIndexColumns(buf, node.IndexCols, node.IndexExpr)
+		buf.Myprintf("%v", node.IndexSpec.Where)
+	case AddIndexStr:
+		buf.Myprintf("alter table %v add ", node.Table)
+		if node.IndexSpec.Constraint {
+			buf.Myprintf("constraint %v unique", node.IndexSpec.Name)
+		} else {
+			if node.IndexSpec.Unique {
+				buf.Myprintf("unique ")
+			}
+			buf.Myprintf("index %v", node.IndexSpec.Name)
+		}
+		formatIndexColumns(buf, node.IndexCols, nil)
+	case AddPrimaryKeyStr:
+		buf.Myprintf("alter table only %v add constraint %v primary key", node.Table, node.IndexSpec.Name)
+		formatIndexColumns(buf, node.IndexCols, nil)
+	case AddForeignKeyStr:
+		buf.Myprintf("alter table only %v add %v", node.Table, node.ForeignKey)
+	case CreateTypeStr:
+		buf.Myprintf("create type %v as %v", node.Type.Name, &node.Type.Type)
 	case CreateVindexStr:
 		buf.Myprintf("%s %v %v", node.Action, node.VindexSpec.Name, node.VindexSpec)
 	case CreateViewStr:
@@ -761,6 +801,31 @@ func (node *DDL) walkSubtree(visit Visit) error {
 	)
 }
 
+// Print index columns with their lengths, directions, and operator classes, or an expression
+func formatIndexColumns(buf *TrackedBuffer, columns []IndexColumn, expr Expr) {
+	if expr != nil {
+		buf.Myprintf(" (%v)", expr)
+		return
+	}
+	buf.Myprintf(" (")
+	for i, col := range columns {
+		if i != 0 {
+			buf.Myprintf(", ")
+		}
+		buf.Myprintf("%v", col.Column)
+		if col.Length != nil {
+			buf.Myprintf("(%v)", col.Length)
+		}
+		if col.OperatorClass != "" {
+			buf.Myprintf(" %s", col.OperatorClass)
+		}
+		if col.Direction != "" && col.Direction != AscScr {
+			buf.Myprintf(" %s", col.Direction)
+		}
+	}
+	buf.Myprintf(")")
+}
+
 // Partition strings
 const (
 	ReorganizeStr = "reorganize partition"
@@ -853,6 +918,12 @@ func (ts *TableSpec) Format(buf *TrackedBuffer) {
 	for _, idx := range ts.Indexes {
 		buf.Myprintf(",\n\t%v", idx)
 	}
+	for _, fk := range ts.ForeignKeys {
+		buf.Myprintf(",\n\t%v", fk)
+	}
+	for _, check := range ts.Checks {
+		buf.Myprintf(",\n\t%v", check)
+	}
 
 	buf.Myprintf("\n)%s", strings.Replace(ts.Options, ", ", ",\n  ", -1))
 }
@@ -1007,6 +1078,21 @@ type CheckDefinition struct {
 	NoInherit         BoolVal
 }
 
+// Format formats the node.
+func (check *CheckDefinition) Format(buf *TrackedBuffer) {
+	if check.ConstraintName.String() != "" {
+		buf.Myprintf("constraint %v ", check.ConstraintName)
+	}
+	buf.Myprintf("check (%v)", check.Where.Expr)
+	if check.NoInherit {
+		buf.Myprintf(" no inherit")
+	}
+}
+
+func (check *CheckDefinition) walkSubtree(visit Visit) error {
+	return nil
+}
+
 // Format returns a canonical string representation of the type and all relevant options
 func (ct *ColumnType) Format(buf *TrackedBuffer) {
 	buf.Myprintf("%s", ct.Type)
@@ -1427,6 +1513,38 @@ type ForeignKeyDefinition struct {
 	NotForReplication bool
 }
 
+// Format formats the node.
+func (fk *ForeignKeyDefinition) Format(buf *TrackedBuffer) {
+	if fk.ConstraintName.String() != "" {
+		buf.Myprintf("constraint %v ", fk.ConstraintName)
+	}
+	buf.Myprintf("foreign key %v(", fk.IndexName)
+	for i, col := range fk.IndexColumns {
+		if i != 0 {
+			buf.Myprintf(", ")
+		}
+		buf.Myprintf("%v", col)
+	}
+	buf.Myprintf(") references %v (", fk.ReferenceName)
+	for i, col := range fk.ReferenceColumns {
+		if i != 0 {
+			buf.Myprintf(", ")
+		}
+		buf.Myprintf("%v", col)
+	}
+	buf.Myprintf(")")
+	if fk.OnDelete.String() != "" {
+		buf.Myprintf(" on delete %s", fk.OnDelete.String())
+	}
+	if fk.OnUpdate.String() != "" {
+		buf.Myprintf(" on update %s", fk.OnUpdate.String())
+	}
+}
+
+func (fk *ForeignKeyDefinition) walkSubtree(visit Visit) error {
+	return nil
+}
+
 type Policy struct {
 	Name       ColIdent
 	Permissive Permissive
@@ -1345,7 +1345,7 @@ func TestCaseSensitivity(t *testing.T) {
 		output: "create table A (\n\tB int\n)",
 	}, {
 		input:  "create index b on A (c)",
-		output: "create index table A",
+		output: "create index b on A (c)",
 	}, {
 		input:  "alter table A foo",
 		output: "alter table A",
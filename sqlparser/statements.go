@@ -0,0 +1,126 @@
+package sqlparser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A statement parsed by ParseStatements, with its location in the SQL
+type PositionedStatement struct {
+	Statement Statement
+	SQL       string // the source text of the statement without the trailing ';'
+	Offset    int    // byte offset of the statement in the SQL
+	Line      int    // 1-based
+	Column    int    // 1-based, in bytes
+}
+
+// Returned by ParseStatements for a statement which can't be parsed
+type ParseError struct {
+	Line   int
+	Column int
+	SQL    string // the source text of the statement
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	lineCommentRegexp  = regexp.MustCompile(`(?m)^[ \t]*--.*$`)
+	blockCommentRegexp = regexp.MustCompile(`(?s)/\*.*?\*/`)
+)
+
+// ParseStatements parses `;`-separated statements of the dialect, e.g. a schema file, and returns them with their
+// locations, so that linters and code generators can report them. The AST can be printed back with String.
+// Since `;` may appear in a statement, e.g. in a string or a trigger, statements are extended to the next `;`
+// until they're parsed.
+func ParseStatements(sql string, mode ParserMode) ([]PositionedStatement, error) {
+	// Blank comments out, keeping offsets and newlines, so that `;` in them doesn't split statements
+	blank := func(comment string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '\n' {
+				return r
+			}
+			return ' '
+		}, comment)
+	}
+	source := lineCommentRegexp.ReplaceAllStringFunc(sql, blank)
+	source = blockCommentRegexp.ReplaceAllStringFunc(source, blank)
+
+	statements := []PositionedStatement{}
+	start := 0
+	for start < len(source) {
+		end := start
+		firstEnd := -1 // reported on a parse error instead of the rest of the SQL
+		var stmt Statement
+		var err error
+		for {
+			next := strings.IndexByte(source[end:], ';')
+			if next < 0 {
+				end = len(source)
+			} else {
+				end += next
+			}
+			if firstEnd < 0 {
+				firstEnd = end
+			}
+
+			text := strings.TrimSpace(source[start:end])
+			if text == "" {
+				break
+			}
+			stmt, err = ParseStrictDDLWithMode(text, mode)
+			if err == nil || end == len(source) {
+				break
+			}
+			end++ // retry with the next `;`
+		}
+
+		offset := start + len(source[start:end]) - len(strings.TrimLeft(source[start:end], " \t\r\n"))
+		if text := strings.TrimSpace(source[offset:end]); text != "" {
+			if err != nil {
+				return statements, newParseError(sql, offset, strings.TrimSpace(source[offset:firstEnd]), mode)
+			}
+			line, column := location(sql, offset)
+			statements = append(statements, PositionedStatement{
+				Statement: stmt, SQL: strings.TrimSpace(sql[offset:end]), Offset: offset, Line: line, Column: column,
+			})
+		}
+		start = end + 1
+	}
+	return statements, nil
+}
+
+// e.g. "syntax error at position 38 near 'bar'", where the position is the one after reading a lookahead character
+var errorPositionRegexp = regexp.MustCompile(`at position (\d+)(?: near '(.*)')?$`)
+
+// Return a ParseError locating the token where the statement at the offset failed to be parsed
+func newParseError(sql string, offset int, text string, mode ParserMode) *ParseError {
+	tokenizer := NewStringTokenizer(text, mode)
+	yyParse(tokenizer)
+	errorOffset := offset
+	if m := errorPositionRegexp.FindStringSubmatch(tokenizer.LastError.Error()); m != nil {
+		position, _ := strconv.Atoi(m[1])
+		tokenLength := len(m[2])
+		if tokenLength == 0 { // a punctuation
+			tokenLength = 1
+		}
+		if position = position - 1 - tokenLength; position > 0 && position < len(text) {
+			errorOffset += position
+		}
+	}
+	line, column := location(sql, errorOffset)
+	return &ParseError{Line: line, Column: column, SQL: strings.TrimSpace(sql[offset : offset+len(text)]), Err: tokenizer.LastError}
+}
+
+// Return the 1-based line and column of the byte offset
+func location(sql string, offset int) (int, int) {
+	return strings.Count(sql[:offset], "\n") + 1, offset - strings.LastIndex(sql[:offset], "\n")
+}
@@ -0,0 +1,62 @@
+package sqlparser
+
+import (
+	"testing"
+)
+
+func TestParseStatements(t *testing.T) {
+	sql := "-- users; table\n" +
+		"CREATE TABLE users (\n" +
+		"  id int PRIMARY KEY,\n" +
+		"  name varchar(20) DEFAULT ';'\n" +
+		");\n" +
+		"\n" +
+		"  CREATE INDEX idx ON users (name);\n"
+	statements, err := ParseStatements(sql, ParserModeMysql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 2 {
+		t.Fatalf("expected 2 statements, but got %d", len(statements))
+	}
+
+	expected := []PositionedStatement{
+		{SQL: "CREATE TABLE users (\n  id int PRIMARY KEY,\n  name varchar(20) DEFAULT ';'\n)", Offset: 16, Line: 2, Column: 1},
+		{SQL: "CREATE INDEX idx ON users (name)", Offset: 96, Line: 7, Column: 3},
+	}
+	for i, statement := range statements {
+		if statement.SQL != expected[i].SQL || statement.Offset != expected[i].Offset ||
+			statement.Line != expected[i].Line || statement.Column != expected[i].Column {
+			t.Errorf("statement %d:\ngot  %q at %d (%d:%d)\nwant %q at %d (%d:%d)", i, statement.SQL, statement.Offset, statement.Line,
+				statement.Column, expected[i].SQL, expected[i].Offset, expected[i].Line, expected[i].Column)
+		}
+	}
+	if _, ok := statements[0].Statement.(*DDL); !ok {
+		t.Errorf("expected *DDL, but got %T", statements[0].Statement)
+	}
+
+	// The printed AST can be parsed again
+	for _, statement := range statements {
+		if _, err := ParseStrictDDLWithMode(String(statement.Statement), ParserModeMysql); err != nil {
+			t.Errorf("failed to parse the printed statement: %s", err)
+		}
+	}
+}
+
+func TestParseStatementsError(t *testing.T) {
+	sql := "CREATE TABLE users (id int);\nCREATE TABLE posts (id int,, x int);\n"
+	statements, err := ParseStatements(sql, ParserModeMysql)
+	if len(statements) != 1 {
+		t.Errorf("expected a statement before the error, but got %d", len(statements))
+	}
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError, but got %v", err)
+	}
+	if parseErr.Line != 2 || parseErr.Column != 28 || parseErr.SQL != "CREATE TABLE posts (id int,, x int)" {
+		t.Errorf("unexpected error location: %d:%d %q", parseErr.Line, parseErr.Column, parseErr.SQL)
+	}
+	if expected := "line 2, column 28: syntax error at position 29"; parseErr.Error() != expected {
+		t.Errorf("expected %q, but got %q", expected, parseErr.Error())
+	}
+}
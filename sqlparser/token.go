@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/k0kubun/sqldef/sqlparser/dependency/bytes2"
 	"github.com/k0kubun/sqldef/sqlparser/dependency/sqltypes"
@@ -56,6 +57,9 @@ type Tokenizer struct {
 	specialComment *Tokenizer
 	mode           ParserMode
 
+	// Lower unquoted identifiers as PostgreSQL does
+	FoldIdentifiers bool
+
 	buf     []byte
 	bufPos  int
 	bufSize int
@@ -486,6 +490,12 @@ func init() {
 	}
 }
 
+// IsKeyword returns true if the string is a keyword, which needs quoting to be used as an identifier
+func IsKeyword(str string) bool {
+	_, ok := keywords[strings.ToLower(str)]
+	return ok
+}
+
 // KeywordString returns the string corresponding to the given keyword
 func KeywordString(id int) string {
 	str, ok := keywordStrings[id]
@@ -729,7 +739,7 @@ func (tkn *Tokenizer) scanIdentifier(firstByte byte, isDbSystemVariable bool) (i
 		return keywordID, lowered
 	}
 	// dual must always be case-insensitive
-	if loweredStr == "dual" {
+	if loweredStr == "dual" || tkn.FoldIdentifiers {
 		return ID, lowered
 	}
 	return ID, buffer.Bytes()
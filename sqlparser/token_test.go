@@ -65,6 +65,28 @@ func TestLiteralID(t *testing.T) {
 	}
 }
 
+func TestFoldIdentifiers(t *testing.T) {
+	testcases := []struct {
+		in  string
+		out string
+	}{{
+		in:  "Users",
+		out: "users",
+	}, {
+		in:  `"Users"`,
+		out: "Users",
+	}}
+
+	for _, tcase := range testcases {
+		tkn := NewStringTokenizer(tcase.in, ParserModePostgres)
+		tkn.FoldIdentifiers = true
+		id, out := tkn.Scan()
+		if id != ID || string(out) != tcase.out {
+			t.Errorf("Scan(%s): %d, %s, want %d, %s", tcase.in, id, out, ID, tcase.out)
+		}
+	}
+}
+
 func tokenName(id int) string {
 	if id == STRING {
 		return "STRING"
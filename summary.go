@@ -0,0 +1,144 @@
+package sqldef
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/k0kubun/sqldef/adapter"
+)
+
+// Numbers of changes in a plan, printed by --summary and given as "plan" of the "summary" event of --log-format=json
+type planSummary struct {
+	Statements       int      `json:"statements"` // DDLs to run, excluding skipped ones
+	TablesCreated    int      `json:"tables_created"`
+	TablesAltered    int      `json:"tables_altered"`
+	TablesDropped    int      `json:"tables_dropped"`
+	ColumnsAdded     int      `json:"columns_added"`
+	ColumnsAltered   int      `json:"columns_altered"`
+	ColumnsDropped   int      `json:"columns_dropped"`
+	IndexesCreated   int      `json:"indexes_created"`
+	IndexesDropped   int      `json:"indexes_dropped"`
+	Destructive      int      `json:"destructive"`
+	Skipped          int      `json:"skipped"`                     // destructive DDLs skipped without --enable-drop
+	EstimatedSeconds *int64   `json:"estimated_seconds,omitempty"` // a sum of durations estimated by the impact analysis
+	DurationMs       *float64 `json:"duration_ms,omitempty"`       // how long the apply took
+}
+
+// Count changes of DDLs. With an analyzer, durations of DDLs are estimated from sizes of tables, which queries the database.
+func newPlanSummary(ddls []string, skipDrop bool, analyzer *impactAnalyzer) planSummary {
+	var summary planSummary
+	alteredTables := map[string]bool{}
+	for _, ddl := range ddls {
+		destructive := adapter.IsDropDDLIn(ddls, ddl)
+		if skipDrop && destructive {
+			summary.Skipped++
+			continue
+		}
+		summary.Statements++
+		if destructive {
+			summary.Destructive++
+		}
+
+		operations := ddlOperations(ddl)
+		switch operations[0] {
+		case "CREATE TABLE":
+			summary.TablesCreated++
+		case "DROP TABLE":
+			summary.TablesDropped++
+		case "ALTER TABLE":
+			alteredTables[strings.ToLower(describeDDL(ddl, false).Object)] = true
+		case "CREATE INDEX":
+			summary.IndexesCreated++
+		case "DROP INDEX":
+			summary.IndexesDropped++
+		}
+		for _, operation := range operations[1:] {
+			switch operation {
+			case "ADD COLUMN":
+				summary.ColumnsAdded++
+			case "ALTER COLUMN", "CHANGE COLUMN", "MODIFY COLUMN":
+				summary.ColumnsAltered++
+			case "DROP COLUMN":
+				summary.ColumnsDropped++
+			case "ADD INDEX", "ADD KEY", "ADD UNIQUE":
+				summary.IndexesCreated++
+			case "DROP INDEX", "DROP KEY":
+				summary.IndexesDropped++
+			}
+		}
+
+		if analyzer != nil {
+			if impact := analyzer.impact(ddl); impact != nil && impact.Duration != nil {
+				if summary.EstimatedSeconds == nil {
+					summary.EstimatedSeconds = new(int64)
+				}
+				*summary.EstimatedSeconds += *impact.Duration
+			}
+		}
+	}
+	summary.TablesAltered = len(alteredTables)
+	return summary
+}
+
+// Set how long the apply took
+func (s planSummary) took(duration time.Duration) planSummary {
+	durationMs := adapter.DurationMs(duration)
+	s.DurationMs = &durationMs
+	return s
+}
+
+// e.g. -- Summary: 1 table created, 2 columns added, 1 destructive DDL; estimated ~5m --
+func (s planSummary) String() string {
+	var changes []string
+	for _, change := range []struct {
+		count int
+		noun  string
+		verb  string
+	}{
+		{s.TablesCreated, "table", "created"},
+		{s.TablesAltered, "table", "altered"},
+		{s.TablesDropped, "table", "dropped"},
+		{s.ColumnsAdded, "column", "added"},
+		{s.ColumnsAltered, "column", "altered"},
+		{s.ColumnsDropped, "column", "dropped"},
+		{s.IndexesCreated, "index", "created"},
+		{s.IndexesDropped, "index", "dropped"},
+	} {
+		if change.count > 0 {
+			changes = append(changes, fmt.Sprintf("%s %s", countNoun(change.count, change.noun), change.verb))
+		}
+	}
+	if s.Destructive > 0 {
+		changes = append(changes, countNoun(s.Destructive, "destructive DDL"))
+	}
+	if s.Skipped > 0 {
+		changes = append(changes, countNoun(s.Skipped, "destructive DDL")+" skipped")
+	}
+	if len(changes) == 0 {
+		changes = append(changes, countNoun(s.Statements, "DDL"))
+	}
+
+	summary := "-- Summary: " + strings.Join(changes, ", ")
+	var durations []string
+	if s.EstimatedSeconds != nil {
+		durations = append(durations, "estimated ~"+formatDuration(*s.EstimatedSeconds))
+	}
+	if s.DurationMs != nil {
+		durations = append(durations, "took "+time.Duration(*s.DurationMs*float64(time.Millisecond)).Round(time.Millisecond).String())
+	}
+	if len(durations) > 0 {
+		summary += "; " + strings.Join(durations, ", ")
+	}
+	return summary + " --"
+}
+
+// e.g. 1 table and 2 indexes
+func countNoun(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	} else if noun == "index" {
+		return fmt.Sprintf("%d indexes", count)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
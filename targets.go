@@ -0,0 +1,268 @@
+package sqldef
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v2"
+)
+
+// A database of --targets, whose keys are long option names overriding the command line, e.g.
+//
+//   - name: shard-01
+//     host: shard-01.db.example.com
+//     database: app
+//   - name: shard-02
+//     host: shard-02.db.example.com
+//     database: app
+//
+// `database` is given as db_name, and `name` is used in the output, which defaults to the host and the database.
+// `password` is given by the environment of the command, not to show it in arguments of processes.
+type target struct {
+	name       string
+	database   string
+	args       []string
+	env        []string
+	outputFile bool // given by the target itself
+}
+
+// Environment variable giving `password` of a target by --password-env
+const targetPasswordEnv = "SQLDEF_TARGET_PASSWORD"
+
+// Characters of a target name which can't be a part of a file name, e.g. `/` of the default name
+var unsafeFileNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func readTargets(parser *flags.Parser, targetsFile string) ([]target, error) {
+	buf, err := ioutil.ReadFile(targetsFile)
+	if err != nil {
+		return nil, err
+	}
+	var items []yaml.MapSlice
+	if err := yaml.Unmarshal(buf, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", targetsFile, err)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no target is listed in %s", targetsFile)
+	}
+
+	var targets []target
+	names := map[string]bool{}
+	for i, item := range items {
+		var t target
+		host := ""
+		for _, option := range item {
+			key := fmt.Sprint(option.Key)
+			var values []string
+			switch value := option.Value.(type) {
+			case []interface{}:
+				for _, v := range value {
+					values = append(values, fmt.Sprint(v))
+				}
+			case yaml.MapSlice:
+				return nil, fmt.Errorf("unexpected mapping for '%s' of target #%d in %s", key, i+1, targetsFile)
+			case nil:
+				continue
+			default:
+				values = []string{fmt.Sprint(value)}
+			}
+
+			switch key {
+			case "name":
+				t.name = values[0]
+				continue
+			case "database":
+				t.database = values[0]
+				continue
+			case "targets", "targets-concurrency":
+				return nil, fmt.Errorf("'%s' can't be given to target #%d in %s", key, i+1, targetsFile)
+			case "host":
+				host = values[0]
+			case "password":
+				if parser.FindOptionByLongName("password-env") != nil {
+					// --password-file= is given not to be used together with --password-env of the command line
+					t.args = append(t.args, "--password-env="+targetPasswordEnv, "--password-file=")
+					t.env = append(t.env, targetPasswordEnv+"="+values[0])
+					continue
+				}
+			case "output-file":
+				t.outputFile = true
+			}
+			flag := parser.FindOptionByLongName(key)
+			if flag == nil {
+				return nil, fmt.Errorf("unknown option '%s' of target #%d in %s", key, i+1, targetsFile)
+			}
+			for _, value := range values {
+				if _, ok := flag.Value().(bool); !ok {
+					t.args = append(t.args, "--"+key+"="+value)
+				} else if value == "true" {
+					t.args = append(t.args, "--"+key)
+				}
+			}
+		}
+
+		if t.name == "" {
+			t.name = strings.Trim(host+"/"+t.database, "/")
+			if t.name == "" {
+				t.name = fmt.Sprintf("#%d", i+1)
+			}
+		}
+		if names[t.name] {
+			return nil, fmt.Errorf("target '%s' is listed twice in %s", t.name, targetsFile)
+		}
+		names[t.name] = true
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// Run the command for each database listed in --targets, e.g. shards sharing the same schema, up to the concurrency at once.
+// Outputs of targets are printed in the order of the file as each of them finishes, followed by a report of all targets.
+// It returns the exit status: 1 if any target fails, 2 if any target has differences by --check, or 0.
+func ApplyTargets(parser *flags.Parser, targetsFile string, concurrency int, files []string, databases []string) int {
+	targets, err := readTargets(parser, targetsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read '%s': %s\n", targetsFile, err)
+		return 1
+	}
+	outputFile := ""
+	if option := parser.FindOptionByLongName("output-file"); option != nil {
+		outputFile, _ = option.Value().(string)
+	}
+	for _, t := range targets {
+		if len(t.database) > 0 && len(databases) > 0 {
+			fmt.Fprintf(os.Stderr, "Both db_name %v and the database of target '%s' are given\n", databases, t.name)
+			return 1
+		}
+	}
+	return runTargets(targets, concurrency, files, outputFile)
+}
+
+// Run the command for each schema matching --each-schema with --schema, e.g. schemas of tenants, like targets of --targets
+func ApplyEachSchema(schemas []string, concurrency int, files []string, outputFile string) int {
+	var targets []target
+	for _, schema := range schemas {
+		// --each-schema= is given not to fan out again by --each-schema in the config file
+		targets = append(targets, target{name: schema, args: []string{"--each-schema=", "--schema=" + schema}})
+	}
+	if len(targets) == 0 {
+		fmt.Println("-- No schema matches --each-schema --")
+		return 0
+	}
+	return runTargets(targets, concurrency, files, outputFile)
+}
+
+// --output-file of the command line is suffixed by the name of each target, e.g. plan.shard-01.sql, not to be overwritten by others
+func runTargets(targets []target, concurrency int, files []string, outputFile string) int {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// The schema given by stdin is shared by all targets
+	var stdin []byte
+	var err error
+	for _, file := range files {
+		if file == "-" && stdin == nil {
+			if stdin, err = ioutil.ReadAll(os.Stdin); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read stdin: %s\n", err)
+				return 1
+			}
+		}
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find the executable: %s\n", err)
+		return 1
+	}
+
+	type result struct {
+		output []byte
+		status int
+	}
+	results := make([]chan result, len(targets))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		results[i] = make(chan result, 1)
+		wg.Add(1)
+		go func(t target, done chan<- result) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// --targets= is given not to fan out again by --targets in the config file
+			args := append(append(append([]string{}, os.Args[1:]...), "--targets="), t.args...)
+			if len(outputFile) > 0 && !t.outputFile {
+				args = append(args, "--output-file="+targetOutputFile(outputFile, t.name))
+			}
+			if len(t.database) > 0 {
+				args = append(args, t.database)
+			}
+			var output bytes.Buffer
+			cmd := exec.Command(executable, args...)
+			cmd.Stdin = bytes.NewReader(stdin)
+			cmd.Stdout = &output
+			cmd.Stderr = &output
+			cmd.Env = append(append(os.Environ(), "SQLDEF_TARGET="+t.name), t.env...)
+			status := 0
+			if err := cmd.Run(); err != nil {
+				var exitErr *exec.ExitError
+				if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+					status = exitErr.ExitCode()
+				} else {
+					fmt.Fprintf(&output, "%s\n", err)
+					status = 1
+				}
+			}
+			done <- result{output: output.Bytes(), status: status}
+		}(t, results[i])
+	}
+
+	var succeeded, different, failed []string
+	for i, t := range targets {
+		r := <-results[i]
+		fmt.Printf("-- Target %s --\n", t.name)
+		os.Stdout.Write(r.output)
+		if len(r.output) > 0 && r.output[len(r.output)-1] != '\n' {
+			fmt.Println()
+		}
+		switch r.status {
+		case 0:
+			succeeded = append(succeeded, t.name)
+		case 2:
+			different = append(different, t.name)
+		default:
+			failed = append(failed, t.name)
+		}
+	}
+	wg.Wait()
+
+	report := []string{fmt.Sprintf("%d succeeded", len(succeeded))}
+	if len(different) > 0 {
+		report = append(report, fmt.Sprintf("%d with differences (%s)", len(different), strings.Join(different, ", ")))
+	}
+	if len(failed) > 0 {
+		report = append(report, fmt.Sprintf("%d failed (%s)", len(failed), strings.Join(failed, ", ")))
+	}
+	fmt.Printf("-- Targets: %s --\n", strings.Join(report, ", "))
+
+	if len(failed) > 0 {
+		return 1
+	} else if len(different) > 0 {
+		return 2
+	}
+	return 0
+}
+
+func targetOutputFile(outputFile string, name string) string {
+	ext := filepath.Ext(outputFile)
+	return strings.TrimSuffix(outputFile, ext) + "." + unsafeFileNameRegexp.ReplaceAllString(name, "_") + ext
+}
@@ -0,0 +1,62 @@
+package sqldef
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+var templateFuncs = template.FuncMap{
+	// {{range $i := seq 16}}CREATE TABLE events_{{$i}} (...);{{end}}
+	"seq": func(n int) []int {
+		s := make([]int, n)
+		for i := range s {
+			s[i] = i
+		}
+		return s
+	},
+}
+
+// Preprocess the schema SQL with text/template, using values in a YAML or JSON file
+func executeTemplate(sql string, valuesFile string) (string, error) {
+	buf, err := ioutil.ReadFile(valuesFile)
+	if err != nil {
+		return "", err
+	}
+	var values interface{}
+	if err := yaml.Unmarshal(buf, &values); err != nil {
+		return "", fmt.Errorf("failed to parse '%s': %s", valuesFile, err)
+	}
+
+	tmpl, err := template.New("schema").Funcs(templateFuncs).Option("missingkey=error").Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, normalizeYAMLValue(values)); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// yaml.v2 decodes mappings into map[interface{}]interface{}, whose keys can't be sorted by text/template
+func normalizeYAMLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, val := range v {
+			m[fmt.Sprint(key)] = normalizeYAMLValue(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeYAMLValue(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
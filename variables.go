@@ -0,0 +1,136 @@
+package sqldef
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// psql's meta-commands to set and unset variables, e.g. `\set fillfactor 70` and `\unset fillfactor`
+var setVariableRegexp = regexp.MustCompile(`^[ \t]*\\(set|unset)(?:[ \t]+(\w+))?(?:[ \t]+(.*?))?[ \t]*$`)
+
+var variableNameRegexp = regexp.MustCompile(`^\w+`)
+
+// Parse name=value of --variable, like psql's --variable
+func parseVariables(variables []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, variable := range variables {
+		nameAndValue := strings.SplitN(variable, "=", 2)
+		if len(nameAndValue) != 2 || nameAndValue[0] == "" || variableNameRegexp.FindString(nameAndValue[0]) != nameAndValue[0] {
+			return nil, fmt.Errorf("--variable=%s should be name=value", variable)
+		}
+		values[nameAndValue[0]] = nameAndValue[1]
+	}
+	return values, nil
+}
+
+// Substitute psql variables set by `\set` lines and --variable, i.e. `:name` as is, `:'name'` as a literal, and `:"name"` as an identifier.
+// As psql does, variables are not substituted in quotes, comments, and casts like `::text`, and undefined ones are left as they are.
+func substituteVariables(sql string, variables map[string]string) (string, error) {
+	values := map[string]string{}
+	for name, value := range variables {
+		values[name] = value
+	}
+	var result strings.Builder
+	quote := "" // a closing quote of the current string, identifier, or dollar-quoted body, or "*/" of a comment
+	lineComment := false
+	lineNumber := 1
+	for i := 0; i < len(sql); {
+		// Meta-commands take the whole line
+		if (i == 0 || sql[i-1] == '\n') && quote == "" {
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			if m := setVariableRegexp.FindStringSubmatch(sql[i : i+end]); m != nil {
+				if m[2] == "" {
+					return "", fmt.Errorf("line %d: \\%s needs a variable name", lineNumber, m[1])
+				}
+				if m[1] == "set" {
+					values[m[2]] = unquoteVariable(m[3])
+				} else {
+					delete(values, m[2])
+				}
+				i += end // blanked to keep line numbers of errors
+				continue
+			}
+		}
+
+		c := sql[i]
+		switch {
+		case c == '\n':
+			lineNumber++
+			lineComment = false
+		case lineComment:
+		case quote != "":
+			if strings.HasPrefix(sql[i:], quote) {
+				result.WriteString(quote)
+				i += len(quote)
+				quote = ""
+				continue
+			}
+		case strings.HasPrefix(sql[i:], "--"):
+			lineComment = true
+		case strings.HasPrefix(sql[i:], "/*"):
+			quote = "*/"
+			result.WriteString("/*")
+			i += 2
+			continue
+		case c == '\'' || c == '"':
+			quote = string(c)
+		case c == '$':
+			if tag := dollarQuoteRegexp.FindString(sql[i:]); tag != "" {
+				quote = tag
+				result.WriteString(tag)
+				i += len(tag)
+				continue
+			}
+		case c == ':' && strings.HasPrefix(sql[i:], "::"):
+			result.WriteString("::")
+			i += 2
+			continue
+		case c == ':':
+			if value, length, ok := lookupVariable(sql[i+1:], values); ok {
+				result.WriteString(value)
+				i += 1 + length
+				continue
+			}
+		}
+		result.WriteByte(c)
+		i++
+	}
+	return result.String(), nil
+}
+
+// e.g. $$ and $body$ opening a dollar-quoted string of PostgreSQL
+var dollarQuoteRegexp = regexp.MustCompile(`^\$(?:[A-Za-z_]\w*)?\$`)
+
+// Return the value of a variable reference following `:`, and the length of the reference
+func lookupVariable(reference string, values map[string]string) (string, int, bool) {
+	if len(reference) > 0 && (reference[0] == '\'' || reference[0] == '"') {
+		name := variableNameRegexp.FindString(reference[1:])
+		if name == "" || len(reference) < len(name)+2 || reference[len(name)+1] != reference[0] {
+			return "", 0, false
+		}
+		value, ok := values[name]
+		if !ok {
+			return "", 0, false
+		}
+		quote := string(reference[0])
+		return quote + strings.ReplaceAll(value, quote, quote+quote) + quote, len(name) + 2, true
+	}
+	name := variableNameRegexp.FindString(reference)
+	value, ok := values[name]
+	if name == "" || !ok {
+		return "", 0, false
+	}
+	return value, len(name), true
+}
+
+// A value of `\set` may be quoted as psql does, doubling quotes in it
+func unquoteVariable(value string) string {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	return value
+}
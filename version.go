@@ -0,0 +1,104 @@
+package sqldef
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// Syntax in DDLs which needs a server of the version or later
+type versionRequirement struct {
+	mode          schema.GeneratorMode
+	regexp        *regexp.Regexp
+	feature       string
+	version       [3]int
+	inTransaction bool // needed only when DDLs are run in a transaction
+}
+
+var versionRequirements = []versionRequirement{
+	// PostgreSQL, whose versions are compared by major and minor ones
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)^\s*CREATE\s+POLICY\s`), "CREATE POLICY", [3]int{9, 5}, false},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)^\s*CREATE\s+(UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?IF\s+NOT\s+EXISTS\s`), "CREATE INDEX IF NOT EXISTS", [3]int{9, 5}, false},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)\sADD\s+COLUMN\s+IF\s+NOT\s+EXISTS\s`), "ADD COLUMN IF NOT EXISTS", [3]int{9, 6}, false},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)\sAS\s+IDENTITY\b|\sDROP\s+IDENTITY\b`), "identity columns", [3]int{10}, false},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)^\s*CREATE\s+TABLE\s.*\sPARTITION\s+(BY|OF)\s`), "declarative partitioning", [3]int{10}, false},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)^\s*CREATE\s+(UNIQUE\s+)?INDEX\s.*\)\s*INCLUDE\s*\(`), "covering indexes by INCLUDE", [3]int{11}, false},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)^\s*CREATE\s+(OR\s+REPLACE\s+)?PROCEDURE\s`), "CREATE PROCEDURE", [3]int{11}, false},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)\sGENERATED\s+ALWAYS\s+AS\s*\(.*\)\s*STORED\b`), "generated columns", [3]int{12}, false},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)^\s*ALTER\s+TYPE\s+\S+\s+ADD\s+VALUE\s`), "ALTER TYPE ... ADD VALUE in a transaction", [3]int{12}, true},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)^\s*CREATE\s+OR\s+REPLACE\s+(CONSTRAINT\s+)?TRIGGER\s`), "CREATE OR REPLACE TRIGGER", [3]int{14}, false},
+	{schema.GeneratorModePostgres, regexp.MustCompile(`(?is)\sNULLS\s+NOT\s+DISTINCT\b`), "NULLS NOT DISTINCT", [3]int{15}, false},
+
+	// MySQL, not MariaDB
+	{schema.GeneratorModeMysql, regexp.MustCompile(`(?is)\sRENAME\s+(INDEX|KEY)\s`), "RENAME INDEX", [3]int{5, 7, 0}, false},
+	{schema.GeneratorModeMysql, regexp.MustCompile(`(?is)\sRENAME\s+COLUMN\s`), "RENAME COLUMN", [3]int{8, 0, 0}, false},
+	{schema.GeneratorModeMysql, regexp.MustCompile("(?is)\\sDEFAULT\\s*\\("), "expressions as DEFAULT", [3]int{8, 0, 13}, false},
+	{schema.GeneratorModeMysql, regexp.MustCompile("(?is)^\\s*CREATE\\s+.*INDEX\\s+\\S+\\s+ON\\s+\\S+\\s*\\(\\s*\\(|\\s(INDEX|KEY)\\s+\\S+\\s*\\(\\s*\\("), "functional key parts of indexes", [3]int{8, 0, 13}, false},
+	{schema.GeneratorModeMysql, regexp.MustCompile(`(?is)\sCHECK\s*\(|\sDROP\s+CHECK\s`), "CHECK constraints, which are ignored by older servers", [3]int{8, 0, 16}, false},
+	{schema.GeneratorModeMysql, regexp.MustCompile(`(?is)\sDROP\s+CONSTRAINT\s`), "DROP CONSTRAINT", [3]int{8, 0, 19}, false},
+}
+
+var (
+	postgresServerVersionRegexp = regexp.MustCompile(`^PostgreSQL (\d+)(?:\.(\d+))?`)
+	mysqlServerVersionRegexp    = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+)
+
+// Fail with the first DDL to run whose syntax needs a newer server than the connected one.
+// Nothing is checked when the version is unknown, e.g. with --against-snapshot, or for MariaDB and other forks.
+func checkServerVersion(generatorMode schema.GeneratorMode, version string, ddls []string, skipDrop bool, noTransaction bool) error {
+	var m []string
+	var serverName string
+	switch {
+	case generatorMode == schema.GeneratorModePostgres && !strings.Contains(version, "-YB-") && !strings.Contains(version, "Greenplum"):
+		m = postgresServerVersionRegexp.FindStringSubmatch(version)
+		serverName = strings.TrimSpace(strings.SplitN(version, " on ", 2)[0]) // e.g. PostgreSQL 9.6.24
+	case generatorMode == schema.GeneratorModeMysql && !strings.Contains(version, "MariaDB"):
+		m = mysqlServerVersionRegexp.FindStringSubmatch(version)
+		serverName = "MySQL " + version
+	}
+	if m == nil {
+		return nil
+	}
+	server := [3]int{}
+	for i, part := range m[1:] {
+		server[i], _ = strconv.Atoi(part) // 0 for a missing minor version
+	}
+
+	for _, ddl := range ddls {
+		if skipDrop && adapter.IsDropDDLIn(ddls, ddl) {
+			continue
+		}
+		for _, requirement := range versionRequirements {
+			if requirement.mode != generatorMode || (requirement.inTransaction && noTransaction) ||
+				!olderVersion(server, requirement.version) || !requirement.regexp.MatchString(ddl) {
+				continue
+			}
+			return fmt.Errorf("%s needs %s or later, but the server is %s:\n%s;", requirement.feature,
+				formatServerVersion(generatorMode, requirement.version), serverName, ddl)
+		}
+	}
+	return nil
+}
+
+func olderVersion(version [3]int, than [3]int) bool {
+	for i := range version {
+		if version[i] != than[i] {
+			return version[i] < than[i]
+		}
+	}
+	return false
+}
+
+func formatServerVersion(generatorMode schema.GeneratorMode, version [3]int) string {
+	if generatorMode == schema.GeneratorModePostgres {
+		if version[0] >= 10 {
+			return fmt.Sprintf("PostgreSQL %d", version[0])
+		}
+		return fmt.Sprintf("PostgreSQL %d.%d", version[0], version[1])
+	}
+	return fmt.Sprintf("MySQL %d.%d.%d", version[0], version[1], version[2])
+}
@@ -0,0 +1,143 @@
+package sqldef
+
+import (
+	"testing"
+
+	"github.com/k0kubun/sqldef/schema"
+)
+
+func TestCheckServerVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          schema.GeneratorMode
+		version       string
+		ddls          []string
+		skipDrop      bool
+		noTransaction bool
+		expected      string // an empty string for no error
+	}{
+		{
+			name:     "MySQL below the minimum",
+			mode:     schema.GeneratorModeMysql,
+			version:  "5.7.44-log",
+			ddls:     []string{"ALTER TABLE `users` RENAME COLUMN `name` TO `login`"},
+			expected: "RENAME COLUMN needs MySQL 8.0.0 or later, but the server is MySQL 5.7.44-log:\nALTER TABLE `users` RENAME COLUMN `name` TO `login`;",
+		},
+		{
+			name:    "MySQL of the minimum",
+			mode:    schema.GeneratorModeMysql,
+			version: "8.0.0",
+			ddls:    []string{"ALTER TABLE `users` RENAME COLUMN `name` TO `login`"},
+		},
+		{
+			name:     "MySQL below the minimum patch version",
+			mode:     schema.GeneratorModeMysql,
+			version:  "8.0.12",
+			ddls:     []string{"ALTER TABLE `users` ADD COLUMN `id` binary(16) DEFAULT (uuid_to_bin(uuid()))"},
+			expected: "expressions as DEFAULT needs MySQL 8.0.13 or later, but the server is MySQL 8.0.12:\nALTER TABLE `users` ADD COLUMN `id` binary(16) DEFAULT (uuid_to_bin(uuid()));",
+		},
+		{
+			name:    "MariaDB not checked",
+			mode:    schema.GeneratorModeMysql,
+			version: "5.5.5-10.4.32-MariaDB",
+			ddls:    []string{"ALTER TABLE `users` RENAME COLUMN `name` TO `login`"},
+		},
+		{
+			name:     "PostgreSQL below the minimum",
+			mode:     schema.GeneratorModePostgres,
+			version:  "PostgreSQL 9.4.26 on x86_64-pc-linux-gnu, compiled by gcc",
+			ddls:     []string{"CREATE POLICY p ON users USING (true)"},
+			expected: "CREATE POLICY needs PostgreSQL 9.5 or later, but the server is PostgreSQL 9.4.26:\nCREATE POLICY p ON users USING (true);",
+		},
+		{
+			name:     "PostgreSQL below the minimum major version",
+			mode:     schema.GeneratorModePostgres,
+			version:  "PostgreSQL 11.22 on x86_64-pc-linux-gnu",
+			ddls:     []string{"CREATE TABLE users (id integer, name text)", "ALTER TABLE users ADD COLUMN c integer GENERATED ALWAYS AS (id * 2) STORED"},
+			expected: "generated columns needs PostgreSQL 12 or later, but the server is PostgreSQL 11.22:\nALTER TABLE users ADD COLUMN c integer GENERATED ALWAYS AS (id * 2) STORED;",
+		},
+		{
+			name:    "PostgreSQL of a later minor version",
+			mode:    schema.GeneratorModePostgres,
+			version: "PostgreSQL 12.3",
+			ddls:    []string{"ALTER TABLE users ADD COLUMN c integer GENERATED ALWAYS AS (id * 2) STORED"},
+		},
+		{
+			name:     "PostgreSQL of a version without the minor version",
+			mode:     schema.GeneratorModePostgres,
+			version:  "PostgreSQL 9 (a fork)",
+			ddls:     []string{"ALTER TABLE users ADD COLUMN IF NOT EXISTS c integer"},
+			expected: "ADD COLUMN IF NOT EXISTS needs PostgreSQL 9.6 or later, but the server is PostgreSQL 9 (a fork):\nALTER TABLE users ADD COLUMN IF NOT EXISTS c integer;",
+		},
+		{
+			name:     "PostgreSQL in a transaction",
+			mode:     schema.GeneratorModePostgres,
+			version:  "PostgreSQL 11.22",
+			ddls:     []string{"ALTER TYPE mood ADD VALUE 'happy'"},
+			expected: "ALTER TYPE ... ADD VALUE in a transaction needs PostgreSQL 12 or later, but the server is PostgreSQL 11.22:\nALTER TYPE mood ADD VALUE 'happy';",
+		},
+		{
+			name:          "PostgreSQL without a transaction",
+			mode:          schema.GeneratorModePostgres,
+			version:       "PostgreSQL 11.22",
+			ddls:          []string{"ALTER TYPE mood ADD VALUE 'happy'"},
+			noTransaction: true,
+		},
+		{
+			name:    "YugabyteDB not checked",
+			mode:    schema.GeneratorModePostgres,
+			version: "PostgreSQL 11.2-YB-2.20.0.0-b0 on x86_64-pc-linux-gnu",
+			ddls:    []string{"CREATE TABLE t (c integer GENERATED ALWAYS AS (1) STORED)"},
+		},
+		{
+			name:     "skipped DROP not checked",
+			mode:     schema.GeneratorModeMysql,
+			version:  "8.0.18",
+			ddls:     []string{"ALTER TABLE `users` DROP CONSTRAINT `c`"},
+			skipDrop: true,
+		},
+		{
+			name:     "applied DROP checked",
+			mode:     schema.GeneratorModeMysql,
+			version:  "8.0.18",
+			ddls:     []string{"ALTER TABLE `users` DROP CONSTRAINT `c`"},
+			expected: "DROP CONSTRAINT needs MySQL 8.0.19 or later, but the server is MySQL 8.0.18:\nALTER TABLE `users` DROP CONSTRAINT `c`;",
+		},
+		{
+			name:    "unparsable MySQL version",
+			mode:    schema.GeneratorModeMysql,
+			version: "unknown",
+			ddls:    []string{"ALTER TABLE `users` RENAME COLUMN `name` TO `login`"},
+		},
+		{
+			name:    "unparsable PostgreSQL version",
+			mode:    schema.GeneratorModePostgres,
+			version: "CockroachDB CCL v23.1.11",
+			ddls:    []string{"CREATE POLICY p ON users USING (true)"},
+		},
+		{
+			name:    "unknown version",
+			mode:    schema.GeneratorModePostgres,
+			version: "",
+			ddls:    []string{"CREATE POLICY p ON users USING (true)"},
+		},
+		{
+			name:    "other databases not checked",
+			mode:    schema.GeneratorModeMssql,
+			version: "Microsoft SQL Server 2012",
+			ddls:    []string{"ALTER TABLE [users] RENAME COLUMN [name] TO [login]"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkServerVersion(test.mode, test.version, test.ddls, test.skipDrop, test.noTransaction)
+			actual := ""
+			if err != nil {
+				actual = err.Error()
+			}
+			if actual != test.expected {
+				t.Errorf("expected %q but got %q", test.expected, actual)
+			}
+		})
+	}
+}
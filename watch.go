@@ -0,0 +1,101 @@
+package sqldef
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/k0kubun/sqldef/adapter"
+	"github.com/k0kubun/sqldef/schema"
+)
+
+// A payload posted to --webhook when the drift of the schema changes. `text` is shown by Slack's incoming webhooks.
+type driftPayload struct {
+	Text       string       `json:"text"`
+	Drifted    bool         `json:"drifted"`    // false when the database matches the desired schema again
+	Statements []plannedDDL `json:"statements"` // DDLs to make the database match the desired schema
+}
+
+// With --watch, compare the current schema with the desired one every --interval, and report changes of the drift
+// to stdout and --webhook. Errors of a check are logged without stopping, e.g. while the database is restarted.
+func watchDrift(generatorMode schema.GeneratorMode, db adapter.Database, skipTable func(string) bool, config schema.GeneratorConfig, options *Options) {
+	for _, file := range options.DesiredFiles {
+		if file == "-" {
+			log.Fatal("--watch needs --file, since stdin can't be read again")
+		}
+	}
+	if options.Interval <= 0 {
+		log.Fatal("--interval needs to be positive")
+	}
+
+	var reported []string // DDLs of the last report, not to repeat the same alert every interval
+	for first := true; ; first = false {
+		if !first {
+			time.Sleep(options.Interval)
+		}
+		currentDDLs, err := adapter.DumpDDLs(db, skipTable)
+		if err != nil {
+			log.Printf("Error on DumpDDLs: %s", err)
+			continue
+		}
+		ddls, _ := generateDDLs(generatorMode, db, currentDDLs, config, options)
+		if strings.Join(ddls, ";\n") == strings.Join(reported, ";\n") {
+			continue
+		}
+
+		payload := newDriftPayload(generatorMode, db, ddls, options)
+		fmt.Printf("-- %s: %s --\n", time.Now().Format(time.RFC3339), strings.SplitN(payload.Text, "\n", 2)[0])
+		for _, ddl := range ddls {
+			fmt.Printf("%s;\n", ddl)
+		}
+		if len(options.Webhook) > 0 {
+			if err := postWebhook(options.Webhook, payload); err != nil {
+				log.Printf("Failed to post to --webhook: %s", err)
+				continue // retried at the next check
+			}
+		}
+		reported = ddls
+	}
+}
+
+func newDriftPayload(generatorMode schema.GeneratorMode, db adapter.Database, ddls []string, options *Options) driftPayload {
+	files := strings.Join(options.DesiredFiles, ", ")
+	payload := driftPayload{Drifted: len(ddls) > 0, Statements: []plannedDDL{}}
+	if !payload.Drifted {
+		payload.Text = fmt.Sprintf("The database matches %s again", files)
+		return payload
+	}
+
+	var statements []string
+	analyzer := newImpactAnalyzer(generatorMode, db, ddls)
+	for _, ddl := range ddls {
+		described := describeDDL(ddl, false)
+		described.Destructive = adapter.IsDropDDLIn(ddls, ddl)
+		described.Risk = analyzer.risk(ddl)
+		payload.Statements = append(payload.Statements, described)
+		statements = append(statements, ddl+";")
+	}
+	payload.Text = fmt.Sprintf("The database has drifted from %s, needing %d DDLs\n```\n%s\n```", files, len(ddls), strings.Join(statements, "\n"))
+	return payload
+}
+
+func postWebhook(url string, payload driftPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s responded %s", url, res.Status)
+	}
+	return nil
+}
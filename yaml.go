@@ -0,0 +1,300 @@
+package sqldef
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/k0kubun/sqldef/schema"
+	"gopkg.in/yaml.v2"
+)
+
+// A dialect-independent schema, which is given as a desired file of `.yml` or `.yaml`
+type yamlSchema struct {
+	Tables []yamlTable `yaml:"tables"`
+	Views  []yamlView  `yaml:"views"`
+}
+
+type yamlTable struct {
+	Name        string           `yaml:"name"` // can be qualified by a schema, e.g. public.users
+	Columns     []yamlColumn     `yaml:"columns"`
+	PrimaryKey  []string         `yaml:"primary_key"`
+	Indexes     []yamlIndex      `yaml:"indexes"`
+	ForeignKeys []yamlForeignKey `yaml:"foreign_keys"`
+	Checks      []yamlCheck      `yaml:"checks"`
+}
+
+type yamlColumn struct {
+	Name          string      `yaml:"name"`
+	Type          string      `yaml:"type"` // a portable type in yamlColumnTypes, or a data type of the dialect
+	Size          int         `yaml:"size"`
+	Precision     int         `yaml:"precision"`
+	Scale         int         `yaml:"scale"`
+	Nullable      bool        `yaml:"nullable"`
+	Default       interface{} `yaml:"default"`            // a literal, which is quoted if it's a string
+	DefaultSQL    string      `yaml:"default_expression"` // e.g. CURRENT_TIMESTAMP
+	PrimaryKey    bool        `yaml:"primary_key"`
+	AutoIncrement bool        `yaml:"auto_increment"`
+	Unique        bool        `yaml:"unique"`
+}
+
+type yamlIndex struct {
+	Name    string   `yaml:"name"`
+	Columns []string `yaml:"columns"`
+	Unique  bool     `yaml:"unique"`
+}
+
+type yamlForeignKey struct {
+	Name       string   `yaml:"name"`
+	Columns    []string `yaml:"columns"`
+	References struct {
+		Table   string   `yaml:"table"`
+		Columns []string `yaml:"columns"`
+	} `yaml:"references"`
+	OnDelete string `yaml:"on_delete"` // e.g. cascade, set null
+	OnUpdate string `yaml:"on_update"`
+}
+
+type yamlCheck struct {
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
+}
+
+type yamlView struct {
+	Name       string `yaml:"name"`
+	Definition string `yaml:"definition"` // a SELECT statement
+}
+
+// Data types of portable types, where %d is replaced with size, or precision and scale
+var yamlColumnTypes = map[schema.GeneratorMode]map[string]string{
+	schema.GeneratorModePostgres: {
+		"string": "varchar(%d)", "text": "text", "integer": "integer", "bigint": "bigint", "smallint": "smallint",
+		"boolean": "boolean", "float": "double precision", "decimal": "decimal(%d, %d)", "date": "date",
+		"datetime": "timestamp", "json": "jsonb", "binary": "bytea", "uuid": "uuid",
+	},
+	schema.GeneratorModeMysql: {
+		"string": "varchar(%d)", "text": "text", "integer": "int", "bigint": "bigint", "smallint": "smallint",
+		"boolean": "tinyint(1)", "float": "double", "decimal": "decimal(%d, %d)", "date": "date",
+		"datetime": "datetime", "json": "json", "binary": "longblob", "uuid": "char(36)",
+	},
+	schema.GeneratorModeSQLite3: {
+		"string": "varchar(%d)", "text": "text", "integer": "integer", "bigint": "bigint", "smallint": "smallint",
+		"boolean": "boolean", "float": "real", "decimal": "decimal(%d, %d)", "date": "date",
+		"datetime": "datetime", "json": "text", "binary": "blob", "uuid": "text",
+	},
+	schema.GeneratorModeMssql: {
+		"string": "nvarchar(%d)", "text": "nvarchar(max)", "integer": "int", "bigint": "bigint", "smallint": "smallint",
+		"boolean": "bit", "float": "float", "decimal": "decimal(%d, %d)", "date": "date",
+		"datetime": "datetime2", "json": "nvarchar(max)", "binary": "varbinary(max)", "uuid": "uniqueidentifier",
+	},
+}
+
+// Return true if a desired file is a YAML schema, which is converted to the schema SQL of the dialect
+func isYAMLSchema(file string) bool {
+	ext := filepath.Ext(file)
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// Convert a YAML schema to the schema SQL of the dialect, so that a schema can be shared by MySQL and PostgreSQL
+func convertYAMLSchema(generatorMode schema.GeneratorMode, src string) (string, error) {
+	if generatorMode == schema.GeneratorModeCockroach || generatorMode == schema.GeneratorModeRedshift {
+		generatorMode = schema.GeneratorModePostgres
+	}
+	var s yamlSchema
+	if err := yaml.UnmarshalStrict([]byte(src), &s); err != nil {
+		return "", fmt.Errorf("failed to parse the YAML schema: %s", err)
+	}
+
+	ddls := []string{}
+	for _, table := range s.Tables {
+		tableDDLs, err := createYAMLTable(generatorMode, table)
+		if err != nil {
+			return "", err
+		}
+		ddls = append(ddls, tableDDLs...)
+	}
+	for _, view := range s.Views {
+		if view.Name == "" || view.Definition == "" {
+			return "", fmt.Errorf("a view needs name and definition")
+		}
+		ddls = append(ddls, fmt.Sprintf("CREATE VIEW %s AS %s", quoteYAMLTable(generatorMode, view.Name),
+			strings.TrimSuffix(strings.TrimSpace(view.Definition), ";")))
+	}
+
+	if len(ddls) == 0 {
+		return "", nil
+	}
+	return strings.Join(ddls, ";\n") + ";\n", nil
+}
+
+func createYAMLTable(mode schema.GeneratorMode, table yamlTable) ([]string, error) {
+	if table.Name == "" {
+		return nil, fmt.Errorf("a table needs name")
+	}
+	name := table.Name[strings.LastIndex(table.Name, ".")+1:]
+	primaryKey := table.PrimaryKey
+	for _, column := range table.Columns {
+		if column.PrimaryKey {
+			if len(table.PrimaryKey) > 0 {
+				return nil, fmt.Errorf("primary_key of both the table '%s' and its column '%s' is given", table.Name, column.Name)
+			}
+			primaryKey = append(primaryKey, column.Name)
+		}
+	}
+
+	definitions := []string{}
+	indexes := []string{}
+	inlinePrimaryKey := false
+	for _, column := range table.Columns {
+		if column.Name == "" || column.Type == "" {
+			return nil, fmt.Errorf("a column of '%s' needs name and type", table.Name)
+		}
+		sqlType := yamlColumnType(mode, column)
+		if column.AutoIncrement && mode == schema.GeneratorModePostgres {
+			sqlType = map[string]string{"smallint": "smallserial", "bigint": "bigserial"}[sqlType]
+			if sqlType == "" {
+				sqlType = "serial"
+			}
+		} else if column.AutoIncrement && mode == schema.GeneratorModeSQLite3 {
+			sqlType = "integer" // AUTOINCREMENT is allowed only for INTEGER PRIMARY KEY
+		}
+		definition := quoteConvertedName(mode, column.Name) + " " + sqlType
+		if !column.Nullable {
+			definition += " NOT NULL"
+		}
+		if column.AutoIncrement {
+			switch mode {
+			case schema.GeneratorModeMysql:
+				definition += " AUTO_INCREMENT"
+			case schema.GeneratorModeMssql:
+				definition += " IDENTITY(1,1)"
+			case schema.GeneratorModeSQLite3:
+				if len(primaryKey) != 1 || primaryKey[0] != column.Name {
+					return nil, fmt.Errorf("auto_increment of '%s.%s' needs to be the primary key in SQLite", table.Name, column.Name)
+				}
+				definition += " PRIMARY KEY AUTOINCREMENT"
+				inlinePrimaryKey = true
+			}
+		}
+		if column.DefaultSQL != "" {
+			definition += " DEFAULT " + column.DefaultSQL
+		} else if column.Default != nil {
+			definition += " DEFAULT " + yamlDefaultValue(mode, column.Default)
+		}
+		definitions = append(definitions, definition)
+
+		if column.Unique {
+			indexes = append(indexes, createYAMLIndex(mode, table.Name, yamlIndex{Columns: []string{column.Name}, Unique: true}))
+		}
+	}
+
+	if len(primaryKey) > 0 && !inlinePrimaryKey {
+		definition := fmt.Sprintf("PRIMARY KEY (%s)", quoteYAMLColumns(mode, primaryKey))
+		if mode == schema.GeneratorModePostgres || mode == schema.GeneratorModeMssql {
+			definition = fmt.Sprintf("CONSTRAINT %s %s", quoteConvertedName(mode, name+"_pkey"), definition)
+		}
+		definitions = append(definitions, definition)
+	}
+	for _, fk := range table.ForeignKeys {
+		if len(fk.Columns) == 0 || fk.References.Table == "" || len(fk.References.Columns) != len(fk.Columns) {
+			return nil, fmt.Errorf("references of a foreign key of '%s' don't match its columns", table.Name)
+		}
+		fkName := fk.Name
+		if fkName == "" {
+			fkName = fmt.Sprintf("%s_%s_fkey", name, strings.Join(fk.Columns, "_"))
+		}
+		definition := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)", quoteConvertedName(mode, fkName),
+			quoteYAMLColumns(mode, fk.Columns), quoteYAMLTable(mode, fk.References.Table), quoteYAMLColumns(mode, fk.References.Columns))
+		if fk.OnDelete != "" {
+			definition += " ON DELETE " + strings.ToUpper(fk.OnDelete)
+		}
+		if fk.OnUpdate != "" {
+			definition += " ON UPDATE " + strings.ToUpper(fk.OnUpdate)
+		}
+		definitions = append(definitions, definition)
+	}
+	for _, check := range table.Checks {
+		definition := fmt.Sprintf("CHECK (%s)", check.Expression)
+		if check.Name != "" {
+			definition = fmt.Sprintf("CONSTRAINT %s %s", quoteConvertedName(mode, check.Name), definition)
+		}
+		definitions = append(definitions, definition)
+	}
+	for _, index := range table.Indexes {
+		if len(index.Columns) == 0 {
+			return nil, fmt.Errorf("an index of '%s' needs columns", table.Name)
+		}
+		indexes = append(indexes, createYAMLIndex(mode, table.Name, index))
+	}
+
+	ddls := []string{fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", quoteYAMLTable(mode, table.Name), strings.Join(definitions, ",\n  "))}
+	return append(ddls, indexes...), nil
+}
+
+// Return a data type of a portable type, or the given type of the dialect
+func yamlColumnType(mode schema.GeneratorMode, column yamlColumn) string {
+	sqlType, ok := yamlColumnTypes[mode][strings.ToLower(column.Type)]
+	if !ok {
+		return column.Type
+	}
+	switch strings.ToLower(column.Type) {
+	case "string":
+		size := column.Size
+		if size == 0 {
+			size = 255
+		}
+		return fmt.Sprintf(sqlType, size)
+	case "decimal":
+		precision := column.Precision
+		if precision == 0 {
+			precision = 10
+		}
+		return fmt.Sprintf(sqlType, precision, column.Scale)
+	default:
+		return sqlType
+	}
+}
+
+func yamlDefaultValue(mode schema.GeneratorMode, value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case bool:
+		if mode == schema.GeneratorModePostgres {
+			return fmt.Sprint(v)
+		}
+		return map[bool]string{true: "1", false: "0"}[v]
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Return an index, named as `{table}_{columns}_key` for a unique one and `{table}_{columns}_idx` otherwise by default
+func createYAMLIndex(mode schema.GeneratorMode, table string, index yamlIndex) string {
+	kind, suffix := "", "idx"
+	if index.Unique {
+		kind, suffix = "UNIQUE ", "key"
+	}
+	name := index.Name
+	if name == "" {
+		name = fmt.Sprintf("%s_%s_%s", table[strings.LastIndex(table, ".")+1:], strings.Join(index.Columns, "_"), suffix)
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", kind, quoteConvertedName(mode, name), quoteYAMLTable(mode, table),
+		quoteYAMLColumns(mode, index.Columns))
+}
+
+func quoteYAMLTable(mode schema.GeneratorMode, name string) string {
+	names := []string{}
+	for _, part := range strings.Split(name, ".") {
+		names = append(names, quoteConvertedName(mode, part))
+	}
+	return strings.Join(names, ".")
+}
+
+func quoteYAMLColumns(mode schema.GeneratorMode, columns []string) string {
+	quoted := []string{}
+	for _, column := range columns {
+		quoted = append(quoted, quoteConvertedName(mode, column))
+	}
+	return strings.Join(quoted, ", ")
+}